@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/certmagic"
+	"go.uber.org/zap"
+)
+
+const (
+	// acmeCertLifetime is the lifetime of Let's Encrypt and ZeroSSL
+	// certificates, to turn acme.renewBefore into certmagic's ratio.
+	acmeCertLifetime = 90 * 24 * time.Hour
+
+	acmeDefaultRenewBefore = 30 * 24 * time.Hour
+	acmeMinRenewBefore     = 24 * time.Hour
+	acmeMaxRenewBefore     = 60 * 24 * time.Hour
+)
+
+// acmeRenewalWindowRatio turns acme.renewBefore into the share of the
+// certificate's lifetime certmagic renews in. 0 is the default of 30 days,
+// which leaves weeks of retries if the CA is down when renewal starts.
+func acmeRenewalWindowRatio(renewBefore time.Duration) (float64, error) {
+	if renewBefore == 0 {
+		renewBefore = acmeDefaultRenewBefore
+	}
+	if renewBefore < acmeMinRenewBefore || renewBefore > acmeMaxRenewBefore {
+		return 0, fmt.Errorf("must be between %s and %s", acmeMinRenewBefore, acmeMaxRenewBefore)
+	}
+	return float64(renewBefore) / float64(acmeCertLifetime), nil
+}
+
+// acmeRenewAt is when certmagic starts renewing leaf, the same way it
+// computes it: the window is a share of the certificate's own lifetime.
+func acmeRenewAt(leaf *x509.Certificate, ratio float64) time.Time {
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	return leaf.NotAfter.Add(-time.Duration(float64(lifetime) * ratio))
+}
+
+// acmeManage gets the certificates for domains ready before the server
+// starts. If renewing fails but every domain still has a valid certificate
+// in storage, the server starts with those, and renewal is retried in the
+// background: a CA outage shouldn't take the server down weeks before the
+// certificate expires.
+func acmeManage(cmCfg *certmagic.Config, cmCache *certmagic.Cache, domains []string, ratio float64) error {
+	err := cmCfg.ManageSync(context.Background(), domains)
+	if err != nil {
+		// ManageSync stops at the first domain that fails, this loads the
+		// rest and keeps retrying the renewals
+		if asyncErr := cmCfg.ManageAsync(context.Background(), domains); asyncErr != nil {
+			return err
+		}
+	}
+	leaves := make(map[string]*x509.Certificate, len(domains))
+	for _, domain := range domains {
+		for _, cert := range cmCache.AllMatchingCertificates(domain) {
+			if cert.Leaf != nil && !cert.Expired() {
+				leaves[domain] = cert.Leaf
+				break
+			}
+		}
+		if leaves[domain] == nil {
+			if err == nil {
+				err = errors.New("no certificate for " + domain)
+			}
+			return err
+		}
+	}
+	for _, domain := range domains {
+		leaf := leaves[domain]
+		if err != nil {
+			logger.Warn("cannot renew ACME certificate, serving the current one until the CA is reachable again",
+				zap.String("domain", domain), zap.Time("expires", leaf.NotAfter), zap.Error(err))
+		}
+		logger.Info("ACME certificate", zap.String("domain", domain),
+			zap.Time("expires", leaf.NotAfter), zap.Time("renewAt", acmeRenewAt(leaf, ratio)))
+	}
+	return nil
+}
+
+// acmeOnEvent logs renewals done while the server runs, and failed ones
+// where the server keeps serving the current certificate.
+func acmeOnEvent(ctx context.Context, event string, data map[string]any) error {
+	if renewal, _ := data["renewal"].(bool); !renewal {
+		return nil
+	}
+	domain, _ := data["identifier"].(string)
+	remaining, _ := data["remaining"].(time.Duration)
+	switch event {
+	case "cert_failed":
+		err, _ := data["error"].(error)
+		logger.Warn("ACME renewal failed, still serving the current certificate, will retry",
+			zap.String("domain", domain), zap.Duration("remaining", remaining), zap.Error(err))
+	case "cert_obtained":
+		logger.Info("ACME certificate renewed", zap.String("domain", domain))
+	}
+	return nil
+}
+
+// acmeRenewalResult reports when the stored certificate of an ACME domain
+// gets renewed. Past the renewal time it should have been replaced
+// already, which means renewing keeps failing.
+func acmeRenewalResult(leaf *x509.Certificate, ratio float64, now time.Time) checkResult {
+	r := checkResult{Name: "ACME Renewal"}
+	name := leaf.Subject.CommonName
+	if len(leaf.DNSNames) > 0 {
+		name = leaf.DNSNames[0]
+	}
+	renewAt := acmeRenewAt(leaf, ratio)
+	days := func(d time.Duration) int { return int(d.Hours() / 24) }
+	switch {
+	case now.After(leaf.NotAfter):
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("%s: certificate expired on %s. Check the server log for ACME errors.",
+			name, leaf.NotAfter.Format(time.DateOnly))
+	case now.After(renewAt):
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("%s: renewal due %d day(s) ago and not done yet, expires in %d day(s) on %s. "+
+			"Check the server log for ACME errors, e.g. the CA or the challenge port not reachable.",
+			name, days(now.Sub(renewAt)), days(leaf.NotAfter.Sub(now)), leaf.NotAfter.Format(time.DateOnly))
+	default:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("%s: renews in %d day(s) on %s, expires on %s",
+			name, days(renewAt.Sub(now)), renewAt.Format(time.DateOnly), leaf.NotAfter.Format(time.DateOnly))
+	}
+	return r
+}
+
+// acmeChallenge is a challenge type the acme section lets certmagic use.
+// For HTTP-01 and TLS-ALPN-01, Port is the TCP port certmagic listens on
+// locally, while the CA always connects to PublicPort, 80 or 443.
+type acmeChallenge struct {
+	Name       string
+	Port       int
+	PublicPort int
+}
+
+// acmeChallenges returns the challenges of an acme section. The legacy
+// section, without type, allows both HTTP-01 and TLS-ALPN-01 unless
+// disabled, and certmagic uses whichever works.
+func acmeChallenges(typ string, disableHTTP, disableTLSALPN bool, httpPort, tlsPort int) []acmeChallenge {
+	port := func(alt, def int) int {
+		if alt != 0 {
+			return alt
+		}
+		return def
+	}
+	httpChallenge := acmeChallenge{Name: "HTTP-01", Port: port(httpPort, 80), PublicPort: 80}
+	tlsChallenge := acmeChallenge{Name: "TLS-ALPN-01", Port: port(tlsPort, 443), PublicPort: 443}
+	switch strings.ToLower(typ) {
+	case "http":
+		return []acmeChallenge{httpChallenge}
+	case "tls":
+		return []acmeChallenge{tlsChallenge}
+	case "dns":
+		return []acmeChallenge{{Name: "DNS-01"}}
+	case "":
+		var challenges []acmeChallenge
+		if !disableHTTP {
+			challenges = append(challenges, httpChallenge)
+		}
+		if !disableTLSALPN {
+			challenges = append(challenges, tlsChallenge)
+		}
+		return challenges
+	default:
+		return nil
+	}
+}
+
+// acmeDNSCredentials are the acme.dns.config keys each DNS provider needs.
+var acmeDNSCredentials = map[string][]string{
+	"cloudflare": {"cloudflare_api_token"},
+	"duckdns":    {"duckdns_api_token"},
+	"gandi":      {"gandi_api_token"},
+	"godaddy":    {"godaddy_api_token"},
+	"namedotcom": {"namedotcom_token", "namedotcom_user"},
+	"vultr":      {"vultr_api_token"},
+}
+
+// acmeDNSResult checks that DNS-01 has a provider with its credentials.
+// They aren't tried, that would change the domain's records.
+func acmeDNSResult(provider string, config map[string]string) checkResult {
+	r := checkResult{Name: "ACME Challenge", Status: checkFail}
+	keys, ok := acmeDNSCredentials[strings.ToLower(provider)]
+	switch {
+	case provider == "":
+		r.Message = "DNS-01 (acme.type: dns), but acme.dns.name is empty. Set it to your DNS provider, e.g. cloudflare."
+		return r
+	case !ok:
+		names := make([]string, 0, len(acmeDNSCredentials))
+		for name := range acmeDNSCredentials {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		r.Message = fmt.Sprintf("DNS-01 through %s, which isn't supported. Use one of %s.", provider, strings.Join(names, ", "))
+		return r
+	}
+	var missing []string
+	for _, k := range keys {
+		if config[k] == "" {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) > 0 {
+		r.Message = fmt.Sprintf("DNS-01 through %s, but acme.dns.config has no %s. Create an API token at the provider.",
+			provider, strings.Join(missing, " or "))
+		return r
+	}
+	r.Status = checkOK
+	r.Message = fmt.Sprintf("DNS-01 through %s, credentials set (not tried, they're only used when a certificate is due). "+
+		"Works without any open port.", provider)
+	return r
+}
+
+// acmePortResult reports whether certmagic can listen on the port of an
+// HTTP-01 or TLS-ALPN-01 challenge, bindErr being the error of trying.
+func acmePortResult(c acmeChallenge, bindErr error) checkResult {
+	r := checkResult{Name: "ACME Challenge", Status: checkFail}
+	switch {
+	case bindErr == nil:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("%s on TCP port %d, which is free. The CA connects to it from the internet, "+
+			"so the firewall and the provider must let TCP %d in.", c.Name, c.Port, c.PublicPort)
+		if c.Port != c.PublicPort {
+			r.Message = fmt.Sprintf("%s on TCP port %d, which is free. The CA connects to port %d, "+
+				"so something in front must forward TCP %d to %d.", c.Name, c.Port, c.PublicPort, c.PublicPort, c.Port)
+		}
+	case strings.Contains(bindErr.Error(), "address already in use") ||
+		strings.Contains(bindErr.Error(), "Only one usage of each socket address"):
+		r.Message = fmt.Sprintf("%s needs TCP port %d, which another program (a web server?) is using. "+
+			"Stop it, or use acme.type: dns.", c.Name, c.Port)
+	case strings.Contains(bindErr.Error(), "permission denied"):
+		r.Message = fmt.Sprintf("%s needs TCP port %d, which only root or CAP_NET_BIND_SERVICE can listen on.", c.Name, c.Port)
+	default:
+		r.Message = fmt.Sprintf("%s needs TCP port %d: %v", c.Name, c.Port, bindErr)
+	}
+	return r
+}
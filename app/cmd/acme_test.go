@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACMERenewalWindowRatio(t *testing.T) {
+	ratio, err := acmeRenewalWindowRatio(0)
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.0/3, ratio, 1e-9)
+
+	ratio, err = acmeRenewalWindowRatio(45 * 24 * time.Hour)
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5, ratio, 1e-9)
+
+	_, err = acmeRenewalWindowRatio(time.Hour)
+	assert.Error(t, err)
+	_, err = acmeRenewalWindowRatio(89 * 24 * time.Hour)
+	assert.Error(t, err)
+}
+
+func TestACMERenewalResult(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{
+		DNSNames:  []string{"example.com"},
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(acmeCertLifetime),
+	}
+	ratio, _ := acmeRenewalWindowRatio(0)
+	assert.Equal(t, notBefore.Add(60*24*time.Hour), acmeRenewAt(leaf, ratio))
+
+	r := acmeRenewalResult(leaf, ratio, notBefore.Add(50*24*time.Hour))
+	assert.Equal(t, checkOK, r.Status)
+	assert.Contains(t, r.Message, "example.com: renews in 10 day(s) on 2026-03-02")
+
+	r = acmeRenewalResult(leaf, ratio, notBefore.Add(75*24*time.Hour))
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "renewal due 15 day(s) ago")
+
+	r = acmeRenewalResult(leaf, ratio, notBefore.Add(91*24*time.Hour))
+	assert.Equal(t, checkFail, r.Status)
+}
+
+func TestACMEChallenges(t *testing.T) {
+	assert.Equal(t, []acmeChallenge{{Name: "HTTP-01", Port: 8080, PublicPort: 80}}, acmeChallenges("HTTP", false, false, 8080, 0))
+	assert.Equal(t, []acmeChallenge{{Name: "TLS-ALPN-01", Port: 443, PublicPort: 443}}, acmeChallenges("tls", true, true, 0, 0))
+	assert.Equal(t, []acmeChallenge{{Name: "DNS-01"}}, acmeChallenges("dns", false, false, 0, 0))
+	assert.Len(t, acmeChallenges("", false, false, 0, 0), 2)
+	assert.Equal(t, []acmeChallenge{{Name: "TLS-ALPN-01", Port: 8443, PublicPort: 443}}, acmeChallenges("", true, false, 0, 8443))
+	assert.Empty(t, acmeChallenges("", true, true, 0, 0))
+	assert.Empty(t, acmeChallenges("email", false, false, 0, 0))
+
+	assert.Equal(t, checkOK, acmeDNSResult("Cloudflare", map[string]string{"cloudflare_api_token": "x"}).Status)
+	assert.Equal(t, checkFail, acmeDNSResult("", nil).Status)
+	assert.Equal(t, checkFail, acmeDNSResult("route53", nil).Status)
+	r := acmeDNSResult("namedotcom", map[string]string{"namedotcom_token": "x"})
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "namedotcom_user")
+
+	c := acmeChallenge{Name: "HTTP-01", Port: 8080, PublicPort: 80}
+	r = acmePortResult(c, nil)
+	assert.Equal(t, checkOK, r.Status)
+	assert.Contains(t, r.Message, "forward TCP 80 to 8080")
+	r = acmePortResult(c, errors.New("listen tcp :8080: bind: address already in use"))
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "another program")
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditTrafficStatsHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := newAuditLogger(path)
+	assert.NoError(t, err)
+	auditLog = l
+	defer func() { auditLog = nil }()
+
+	var kicked []string
+	handler := auditTrafficStatsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/kick" {
+			// The body must still be readable after the audit handler
+			_ = json.NewDecoder(r.Body).Decode(&kicked)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/kick", strings.NewReader(`["alice","bob"]`)),
+		httptest.NewRequest(http.MethodGet, "/traffic", nil),
+		httptest.NewRequest(http.MethodGet, "/traffic?clear=1", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	assert.Equal(t, []string{"alice", "bob"}, kicked)
+
+	bs, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	assert.Len(t, lines, 2)
+	var entries []auditEntry
+	for _, line := range lines {
+		var e auditEntry
+		assert.NoError(t, json.Unmarshal([]byte(line), &e))
+		entries = append(entries, e)
+	}
+	assert.Equal(t, "kick", entries[0].Action)
+	assert.Equal(t, "alice,bob", entries[0].Target)
+	assert.True(t, entries[0].OK)
+	assert.Equal(t, "clear-traffic-stats", entries[1].Action)
+	assert.False(t, entries[1].OK)
+}
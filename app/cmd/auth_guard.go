@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	// authFailureLogInterval is the least time between two "auth failed"
+	// lines for the same IP. Failures in between are counted in the next.
+	authFailureLogInterval = 10 * time.Second
+
+	// authGuardMaxTracked bounds the number of IPs remembered. Expired
+	// entries are dropped when it's reached.
+	authGuardMaxTracked = 10000
+
+	defaultAuthFailureWindow      = 10 * time.Minute
+	defaultAuthFailureBanDuration = time.Hour
+)
+
+type serverConfigAuthFailureBan struct {
+	MaxFailures int           `mapstructure:"maxFailures"`
+	Window      time.Duration `mapstructure:"window"`
+	Duration    time.Duration `mapstructure:"duration"`
+}
+
+// authGuard logs failed auth attempts per source IP, rate limited, and
+// optionally bans an IP for a while after too many failures. A banned IP
+// is rejected without checking its credentials.
+type authGuard struct {
+	Authenticator server.Authenticator
+	MaxFailures   int // 0 disables bans
+	Window        time.Duration
+	BanDuration   time.Duration
+
+	mutex sync.Mutex
+	ips   map[string]*authFailures
+	now   func() time.Time
+}
+
+type authFailures struct {
+	Count       int // within the window starting at First
+	First       time.Time
+	LastLog     time.Time
+	Unlogged    int
+	BannedUntil time.Time
+}
+
+type authBan struct {
+	IP    string    `json:"ip"`
+	Until time.Time `json:"until"`
+}
+
+func newAuthGuard(auth server.Authenticator, ban serverConfigAuthFailureBan) *authGuard {
+	g := &authGuard{
+		Authenticator: auth,
+		MaxFailures:   ban.MaxFailures,
+		Window:        ban.Window,
+		BanDuration:   ban.Duration,
+		ips:           make(map[string]*authFailures),
+		now:           time.Now,
+	}
+	if g.Window == 0 {
+		g.Window = defaultAuthFailureWindow
+	}
+	if g.BanDuration == 0 {
+		g.BanDuration = defaultAuthFailureBanDuration
+	}
+	return g
+}
+
+func (g *authGuard) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	ip := addrIP(addr)
+	now := g.now()
+
+	g.mutex.Lock()
+	if f := g.ips[ip]; f != nil && now.Before(f.BannedUntil) {
+		g.mutex.Unlock()
+		return false, ""
+	}
+	g.mutex.Unlock()
+
+	ok, id = g.Authenticator.Authenticate(addr, auth, tx)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if ok {
+		delete(g.ips, ip)
+		return ok, id
+	}
+	f := g.ips[ip]
+	if f == nil {
+		if len(g.ips) >= authGuardMaxTracked {
+			g.sweep(now)
+			if len(g.ips) >= authGuardMaxTracked {
+				// Under a wide attack, keep the IPs already tracked
+				return ok, id
+			}
+		}
+		f = &authFailures{}
+		g.ips[ip] = f
+	}
+	if now.Sub(f.First) > g.Window {
+		f.Count, f.First = 0, now
+	}
+	f.Count++
+	if g.MaxFailures > 0 && f.Count >= g.MaxFailures {
+		f.BannedUntil = now.Add(g.BanDuration)
+		logger.Warn("banned IP after repeated auth failures", zap.String("ip", ip),
+			zap.Int("failures", f.Count), zap.Duration("window", g.Window), zap.Time("until", f.BannedUntil))
+		f.Count, f.Unlogged, f.LastLog = 0, 0, now
+		return ok, id
+	}
+	if now.Sub(f.LastLog) < authFailureLogInterval {
+		f.Unlogged++
+		return ok, id
+	}
+	logger.Warn("auth failed", zap.String("addr", addr.String()), zap.String("ip", ip),
+		zap.Int("failures", f.Count), zap.Int("unlogged", f.Unlogged), zap.Duration("window", g.Window))
+	f.LastLog, f.Unlogged = now, 0
+	return ok, id
+}
+
+// sweep drops the IPs that are neither banned nor within the window.
+func (g *authGuard) sweep(now time.Time) {
+	for ip, f := range g.ips {
+		if !now.Before(f.BannedUntil) && now.Sub(f.First) > g.Window {
+			delete(g.ips, ip)
+		}
+	}
+}
+
+// Bans returns the IPs banned right now, the latest ban first.
+func (g *authGuard) Bans() []authBan {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := g.now()
+	bans := make([]authBan, 0)
+	for ip, f := range g.ips {
+		if now.Before(f.BannedUntil) {
+			bans = append(bans, authBan{IP: ip, Until: f.BannedUntil})
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		if !bans[i].Until.Equal(bans[j].Until) {
+			return bans[i].Until.After(bans[j].Until)
+		}
+		return bans[i].IP < bans[j].IP
+	})
+	return bans
+}
+
+// authBansHandler adds GET /bans, the currently banned IPs, to the traffic
+// stats API. It checks the secret the same way as the rest of the API.
+func authBansHandler(next http.Handler, secret string, guard *authGuard) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/bans" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if secret != "" && r.Header.Get("Authorization") != secret {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(guard.Bans())
+	})
+}
+
+func addrIP(addr net.Addr) string {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP.String()
+	case *net.TCPAddr:
+		return a.IP.String()
+	}
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// fillAuthGuard must be called after fillAuthenticator, as it wraps it,
+// and before fillTrafficLogger, which serves the bans.
+func (c *serverConfig) fillAuthGuard(hyConfig *server.Config) error {
+	ban := c.Limits.AuthFailureBan
+	if ban.MaxFailures < 0 {
+		return configError{Field: "limits.authFailureBan.maxFailures", Err: errors.New("must not be negative")}
+	}
+	if ban.Window < 0 {
+		return configError{Field: "limits.authFailureBan.window", Err: errors.New("must not be negative")}
+	}
+	if ban.Duration < 0 {
+		return configError{Field: "limits.authFailureBan.duration", Err: errors.New("must not be negative")}
+	}
+	c.authGuard = newAuthGuard(hyConfig.Authenticator, ban)
+	hyConfig.Authenticator = c.authGuard
+	return nil
+}
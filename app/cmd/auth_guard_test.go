@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type passwordAuthenticator string
+
+func (a passwordAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (bool, string) {
+	return auth == string(a), "user"
+}
+
+func TestAuthGuard(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	g := newAuthGuard(passwordAuthenticator("weak_ahh_password"), serverConfigAuthFailureBan{MaxFailures: 3})
+	g.now = func() time.Time { return now }
+	attacker := &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 40000}
+	user := &net.UDPAddr{IP: net.ParseIP("198.51.100.7"), Port: 40001}
+
+	// Failures spread out over more than the window never add up to a ban
+	for i := 0; i < 5; i++ {
+		ok, _ := g.Authenticate(attacker, "guess", 0)
+		assert.False(t, ok)
+		now = now.Add(defaultAuthFailureWindow * 2 / 3)
+	}
+	assert.Empty(t, g.Bans())
+
+	for i := 0; i < 3; i++ {
+		g.Authenticate(attacker, "guess", 0)
+	}
+	assert.Equal(t, []authBan{{IP: "203.0.113.5", Until: now.Add(defaultAuthFailureBanDuration)}}, g.Bans())
+
+	// Banned: even the right password is rejected, other IPs aren't affected
+	ok, _ := g.Authenticate(attacker, "weak_ahh_password", 0)
+	assert.False(t, ok)
+	ok, id := g.Authenticate(user, "weak_ahh_password", 0)
+	assert.True(t, ok)
+	assert.Equal(t, "user", id)
+
+	handler := authBansHandler(http.NotFoundHandler(), "secret", g)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/bans", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	req := httptest.NewRequest(http.MethodGet, "/bans", nil)
+	req.Header.Set("Authorization", "secret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var bans []authBan
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &bans))
+	assert.Len(t, bans, 1)
+
+	now = now.Add(defaultAuthFailureBanDuration)
+	assert.Empty(t, g.Bans())
+	ok, _ = g.Authenticate(attacker, "weak_ahh_password", 0)
+	assert.True(t, ok)
+}
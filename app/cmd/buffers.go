@@ -3,6 +3,10 @@ package cmd
 import (
 	"fmt"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"go.uber.org/zap"
 )
@@ -17,6 +21,13 @@ const (
 	libyalinkDesiredWriteBuffer = 8 * 1024 * 1024
 )
 
+// tunedUDPConns are the sockets tuned so far, kept so that they can be
+// re-tuned after the operator raises the sysctl limits.
+var (
+	tunedUDPConnsMutex sync.Mutex
+	tunedUDPConns      []*net.UDPConn
+)
+
 // tuneUDPBuffer attempts to set the UDP socket read/write buffers to the
 // desired sizes for optimal performance on unstable Libyan connections.
 // It logs the requested vs. granted sizes transparently so operators can
@@ -25,7 +36,31 @@ func tuneUDPBuffer(conn *net.UDPConn, log *zap.Logger) {
 	if conn == nil || log == nil {
 		return
 	}
+	tunedUDPConnsMutex.Lock()
+	tunedUDPConns = append(tunedUDPConns, conn)
+	tunedUDPConnsMutex.Unlock()
+	applyUDPBuffer(conn, log)
+}
+
+// retuneUDPBuffersOnSignal re-applies the buffer sizes to all tuned
+// sockets whenever the process receives SIGHUP, so that new sysctl limits
+// take effect without a restart. SIGHUP is never delivered on Windows.
+func retuneUDPBuffersOnSignal(log *zap.Logger) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	for range sigChan {
+		tunedUDPConnsMutex.Lock()
+		conns := append([]*net.UDPConn(nil), tunedUDPConns...)
+		tunedUDPConnsMutex.Unlock()
+		log.Info("[LibyaLink] SIGHUP received, re-tuning UDP socket buffers", zap.Int("sockets", len(conns)))
+		auditLog.Record("retune-udp-buffers", fmt.Sprintf("%d sockets", len(conns)), "signal SIGHUP", true)
+		for _, conn := range conns {
+			applyUDPBuffer(conn, log)
+		}
+	}
+}
 
+func applyUDPBuffer(conn *net.UDPConn, log *zap.Logger) {
 	log.Info("[LibyaLink] Tuning UDP socket buffers for Libyan network conditions...")
 
 	// --- Read Buffer ---
@@ -78,6 +113,17 @@ func tuneUDPBuffer(conn *net.UDPConn, log *zap.Logger) {
 	}
 }
 
+// primaryUDPBuffers returns the granted buffer sizes of the first socket
+// tuned, the main listener's, or 0 if there's none.
+func primaryUDPBuffers() (rcv, snd int) {
+	tunedUDPConnsMutex.Lock()
+	defer tunedUDPConnsMutex.Unlock()
+	if len(tunedUDPConns) == 0 {
+		return 0, 0
+	}
+	return getUDPReadBufferSize(tunedUDPConns[0]), getUDPWriteBufferSize(tunedUDPConns[0])
+}
+
 // getUDPReadBufferSize attempts to read the actual buffer size via SyscallConn.
 // Falls back to 0 if the syscall approach isn't available.
 func getUDPReadBufferSize(conn *net.UDPConn) int {
@@ -0,0 +1,59 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+// +build linux darwin freebsd openbsd netbsd
+
+package cmd
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRetuneUDPBuffersOnSignal(t *testing.T) {
+	oldConns := tunedUDPConns
+	defer func() {
+		tunedUDPConnsMutex.Lock()
+		tunedUDPConns = oldConns
+		tunedUDPConnsMutex.Unlock()
+	}()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	defer conn.Close()
+	tuneUDPBuffer(conn, zap.NewNop())
+	tuned := getUDPReadBufferSize(conn)
+	// Something else shrank the buffer after it was tuned
+	assert.NoError(t, conn.SetReadBuffer(4096))
+	shrunk := getUDPReadBufferSize(conn)
+	assert.Less(t, shrunk, tuned)
+
+	// Caught here as well, a SIGHUP sent before the re-tuner listens
+	// must not kill the test
+	caught := make(chan os.Signal, 16)
+	signal.Notify(caught, syscall.SIGHUP)
+	defer signal.Stop(caught)
+
+	core, logs := observer.New(zap.InfoLevel)
+	go retuneUDPBuffersOnSignal(zap.New(core))
+	deadline := time.Now().Add(5 * time.Second)
+	for logs.FilterMessage("[LibyaLink] SIGHUP received, re-tuning UDP socket buffers").Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("SIGHUP didn't re-tune the sockets")
+		}
+		assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+		time.Sleep(20 * time.Millisecond)
+	}
+	for getUDPReadBufferSize(conn) == shrunk && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, tuned, getUDPReadBufferSize(conn))
+	entry := logs.FilterMessage("[LibyaLink] SIGHUP received, re-tuning UDP socket buffers").All()[0]
+	assert.Equal(t, int64(len(oldConns)+1), entry.ContextMap()["sockets"])
+}
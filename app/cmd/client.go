@@ -11,6 +11,7 @@ import (
 	"net/netip"
 	"os"
 	"os/signal"
+	"regexp"
 	"runtime"
 	"slices"
 	"strconv"
@@ -262,8 +263,19 @@ func (c *clientConfig) fillConnFactory(hyConfig *client.Config) error {
 	return nil
 }
 
+// authEnvPlaceholderRegexp matches an auth of the form "${NAME}", which is
+// read from the environment variable NAME (see gen-client --secret-ref).
+var authEnvPlaceholderRegexp = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
 func (c *clientConfig) fillAuth(hyConfig *client.Config) error {
 	hyConfig.Auth = c.Auth
+	if m := authEnvPlaceholderRegexp.FindStringSubmatch(c.Auth); m != nil {
+		auth, ok := os.LookupEnv(m[1])
+		if !ok {
+			return configError{Field: "auth", Err: fmt.Errorf("environment variable %s is not set", m[1])}
+		}
+		hyConfig.Auth = auth
+	}
 	return nil
 }
 
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+// clientDoctorUDPProbe is a public DNS server queried over UDP, to tell a
+// network that blocks all UDP from a server that doesn't answer.
+const clientDoctorUDPProbe = "1.1.1.1:53"
+
+var (
+	clientDoctorURL     string
+	clientDoctorTimeout time.Duration
+)
+
+var clientDoctorCmd = &cobra.Command{
+	Use:   "client-doctor",
+	Short: "Find out why the client doesn't connect",
+	Long: `Run the client config through every step of connecting and report where it
+fails: the server's DNS name, UDP on this network, the QUIC handshake, the
+TLS certificate, the password, and a request through the proxy. Send the
+report along when asking for help.
+
+Examples:
+  libyalink client-doctor -c client.yaml
+  libyalink client-doctor -c client.yaml --url https://example.com`,
+	Run: runClientDoctor,
+}
+
+func init() {
+	initClientDoctorFlags()
+	rootCmd.AddCommand(clientDoctorCmd)
+}
+
+func initClientDoctorFlags() {
+	clientDoctorCmd.Flags().StringVar(&clientDoctorURL, "url", "https://www.gstatic.com/generate_204", "URL to fetch through the proxy")
+	clientDoctorCmd.Flags().DurationVar(&clientDoctorTimeout, "timeout", 10*time.Second, "give up on each step after this long (4s-120s)")
+}
+
+func runClientDoctor(cmd *cobra.Command, args []string) {
+	if clientDoctorTimeout < 4*time.Second || clientDoctorTimeout > 120*time.Second {
+		fmt.Fprintln(os.Stderr, "Error: --timeout must be between 4s and 120s.")
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Println("╔══════════════════════════════════════════════════════╗")
+	fmt.Println("║       LibyaLink Client Doctor — Connection Check    ║")
+	fmt.Println("║       Powered by Hysteria 2                         ║")
+	fmt.Println("╚══════════════════════════════════════════════════════╝")
+	fmt.Println()
+
+	results := clientDoctorChecks()
+
+	fmt.Println("─── Diagnostic Results ───")
+	fmt.Println()
+	var failed *checkResult
+	warnCount := 0
+	for i, r := range results {
+		fmt.Printf("  %s  [%s] %s\n", r.Status, r.Name, r.Message)
+		if r.Status == checkFail && failed == nil {
+			failed = &results[i]
+		}
+		if r.Status == checkWarn {
+			warnCount++
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("──────────────────────────")
+	switch {
+	case failed != nil:
+		fmt.Printf("  %s Failed at [%s]. Fix it and run again, the steps after it were not checked.\n", checkFail, failed.Name)
+	case warnCount > 0:
+		fmt.Printf("  %s Connected with %d warning(s)\n", checkWarn, warnCount)
+	default:
+		fmt.Println("  ✅ Connected — All checks passed!")
+	}
+	fmt.Println()
+}
+
+// clientDoctorChecks runs the steps of connecting in order, and stops at
+// the first one that fails, as the next ones depend on it.
+func clientDoctorChecks() []checkResult {
+	if err := viper.ReadInConfig(); err != nil {
+		return []checkResult{{Name: "Config File", Status: checkFail, Message: fmt.Sprintf("Cannot read config file: %v", err)}}
+	}
+	var config clientConfig
+	if err := viper.Unmarshal(&config); err != nil {
+		return []checkResult{{Name: "Config File", Status: checkFail, Message: fmt.Sprintf("Cannot parse config file: %v", err)}}
+	}
+	config.parseURI()
+	if config.Server == "" {
+		return []checkResult{{Name: "Config File", Status: checkFail, Message: "No server address ('server' is empty)"}}
+	}
+	results := []checkResult{{Name: "Config File", Status: checkOK, Message: fmt.Sprintf("Config loaded from: %s", viper.ConfigFileUsed())}}
+
+	host, _, _ := parseServerAddrString(config.Server)
+	dns := checkServerDNS(host)
+	results = append(results, dns)
+	if dns.Status == checkFail {
+		return results
+	}
+
+	config.QUIC.MaxIdleTimeout = clientDoctorTimeout
+	hyConfig, err := config.Config()
+	if err != nil {
+		return append(results, checkResult{Name: "Client Config", Status: checkFail, Message: err.Error()})
+	}
+
+	udp := checkUDPReachable()
+	results = append(results, udp)
+
+	c, info, err := client.NewClient(hyConfig)
+	results = append(results, clientDoctorHandshake(config, err, udp.Status == checkOK)...)
+	if err != nil {
+		return results
+	}
+	defer c.Close()
+	if !info.UDPEnabled {
+		results = append(results, checkResult{Name: "UDP Relay", Status: checkInfo,
+			Message: "The server doesn't relay UDP, so apps that need it (calls, games, QUIC) fall back to TCP or fail"})
+	}
+	return append(results, checkProxyFetch(c, clientDoctorURL))
+}
+
+func checkServerDNS(host string) checkResult {
+	if net.ParseIP(host) != nil {
+		return checkResult{Name: "DNS", Status: checkOK, Message: fmt.Sprintf("Server %s is an IP address, no lookup needed", host)}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clientDoctorTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return checkResult{Name: "DNS", Status: checkFail, Message: fmt.Sprintf(
+			"Cannot resolve %s: %v. Check the server name for typos, or try another DNS server, e.g. 1.1.1.1 or 8.8.8.8.", host, err)}
+	}
+	return checkResult{Name: "DNS", Status: checkOK, Message: fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", "))}
+}
+
+// checkUDPReachable sends a DNS query over UDP to clientDoctorUDPProbe.
+// Only a warning if it fails, as some networks only block that server.
+func checkUDPReachable() checkResult {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", clientDoctorUDPProbe)
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), clientDoctorTimeout)
+	defer cancel()
+	start := time.Now()
+	if _, err := r.LookupHost(ctx, "www.gstatic.com"); err != nil {
+		return checkResult{Name: "UDP", Status: checkWarn, Message: fmt.Sprintf(
+			"No answer over UDP from %s: %v. This network may block UDP, which Hysteria needs.", clientDoctorUDPProbe, err)}
+	}
+	return checkResult{Name: "UDP", Status: checkOK, Message: fmt.Sprintf(
+		"UDP works on this network (%s answered in %s)", clientDoctorUDPProbe, time.Since(start).Round(time.Millisecond))}
+}
+
+// clientDoctorHandshake reports the handshake, TLS and auth steps from the
+// result of connecting, up to the one that failed. udpOK is whether
+// checkUDPReachable passed, to tell why the server doesn't answer.
+func clientDoctorHandshake(config clientConfig, err error, udpOK bool) []checkResult {
+	handshakeOK := checkResult{Name: "Handshake", Status: checkOK, Message: fmt.Sprintf("%s answered", config.Server)}
+	var tlsOK checkResult
+	switch {
+	case config.TLS.Insecure:
+		tlsOK = checkResult{Name: "TLS", Status: checkWarn, Message: "Certificate not verified (tls.insecure). " +
+			"Anyone in the path could pretend to be the server, set tls.pinSHA256 to the server's certificate hash to prevent it."}
+	case config.TLS.PinSHA256 != "":
+		tlsOK = checkResult{Name: "TLS", Status: checkOK, Message: "Certificate matches tls.pinSHA256"}
+	default:
+		sni := config.TLS.SNI
+		if sni == "" {
+			sni, _, _ = parseServerAddrString(config.Server)
+		}
+		tlsOK = checkResult{Name: "TLS", Status: checkOK, Message: fmt.Sprintf("Certificate verified for %s", sni)}
+	}
+	if err == nil {
+		return []checkResult{handshakeOK, tlsOK, {Name: "Auth", Status: checkOK, Message: "Server accepted the password"}}
+	}
+
+	var fullErr hyErrors.ServerFullError
+	if errors.As(err, &fullErr) {
+		msg := "Server accepted the password but is full (limits.maxConnections), try again later"
+		if fullErr.Fallback != "" {
+			msg += " or use the fallback server " + fullErr.Fallback
+		}
+		return []checkResult{handshakeOK, tlsOK, {Name: "Auth", Status: checkFail, Message: msg}}
+	}
+	var quotaErr hyErrors.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		msg := "Server accepted the password but this user's data quota is used up"
+		if !quotaErr.Reset.IsZero() {
+			msg += fmt.Sprintf(", it resets on %s", quotaErr.Reset.Local().Format(time.DateTime))
+		}
+		return []checkResult{handshakeOK, tlsOK, {Name: "Auth", Status: checkFail, Message: msg}}
+	}
+	var authErr hyErrors.AuthError
+	if errors.As(err, &authErr) {
+		return []checkResult{handshakeOK, tlsOK, {Name: "Auth", Status: checkFail, Message: fmt.Sprintf(
+			"Server rejected the password (HTTP status %d). Check 'auth', it's user:password for servers with several users.", authErr.StatusCode)}}
+	}
+
+	var netErr net.Error
+	msg := err.Error()
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout(), strings.Contains(msg, "timeout"):
+		hint := "This network passes UDP, so check the port, that the server is running and its firewall allows UDP"
+		if !udpOK {
+			hint = "UDP looks blocked on this network (see above), try another one, e.g. mobile data instead of Wi-Fi"
+		}
+		if config.Obfs.Type != "" {
+			hint += ", and the obfs password: a server with obfs ignores packets without the right one"
+		}
+		return []checkResult{{Name: "Handshake", Status: checkFail, Message: fmt.Sprintf("No answer from %s. %s.", config.Server, hint)}}
+	case strings.Contains(msg, "certificate") || strings.Contains(msg, "x509"):
+		hint := "Set tls.sni to the name on the certificate, or tls.insecure: true for a self-signed one."
+		if config.TLS.PinSHA256 != "" {
+			hint = "The server's certificate changed, update tls.pinSHA256."
+		}
+		return []checkResult{handshakeOK, {Name: "TLS", Status: checkFail, Message: fmt.Sprintf("%v. %s", err, hint)}}
+	default:
+		return []checkResult{{Name: "Handshake", Status: checkFail, Message: fmt.Sprintf("Connection failed: %v", err)}}
+	}
+}
+
+// checkProxyFetch fetches url through the proxy, the way an app would.
+func checkProxyFetch(c client.Client, url string) checkResult {
+	hc := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return c.TCP(addr)
+			},
+		},
+		Timeout: clientDoctorTimeout,
+	}
+	start := time.Now()
+	resp, err := hc.Get(url)
+	if err != nil {
+		return checkResult{Name: "Proxy Fetch", Status: checkFail, Message: fmt.Sprintf(
+			"Connected, but fetching %s failed: %v. The server may not reach the internet, check its outbounds, ACL and DNS.", url, err)}
+	}
+	resp.Body.Close()
+	return checkResult{Name: "Proxy Fetch", Status: checkOK, Message: fmt.Sprintf(
+		"GET %s returned %q in %s", url, resp.Status, time.Since(start).Round(time.Millisecond))}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+func TestClientDoctorHandshake(t *testing.T) {
+	config := clientConfig{Server: "example.com:443"}
+	names := func(results []checkResult) (names, statuses []string) {
+		for _, r := range results {
+			names = append(names, r.Name)
+			statuses = append(statuses, r.Status)
+		}
+		return
+	}
+
+	n, s := names(clientDoctorHandshake(config, nil, true))
+	assert.Equal(t, []string{"Handshake", "TLS", "Auth"}, n)
+	assert.Equal(t, []string{checkOK, checkOK, checkOK}, s)
+
+	// Accepted but not verified
+	insecure := config
+	insecure.TLS.Insecure = true
+	_, s = names(clientDoctorHandshake(insecure, nil, true))
+	assert.Equal(t, []string{checkOK, checkWarn, checkOK}, s)
+
+	results := clientDoctorHandshake(config, hyErrors.AuthError{StatusCode: 404}, true)
+	assert.Equal(t, checkFail, results[2].Status)
+	assert.Contains(t, results[2].Message, "404")
+
+	results = clientDoctorHandshake(config, hyErrors.ServerFullError{Fallback: "backup.example.com:443"}, true)
+	assert.Equal(t, checkFail, results[2].Status)
+	assert.Contains(t, results[2].Message, "backup.example.com:443")
+
+	results = clientDoctorHandshake(config, hyErrors.QuotaExceededError{}, true)
+	assert.Equal(t, checkFail, results[2].Status)
+	assert.Contains(t, results[2].Message, "quota")
+
+	// Fails at the TLS step, auth not reached
+	results = clientDoctorHandshake(config, hyErrors.ConnectError{Err: errors.New("CRYPTO_ERROR 0x12a (local): tls: failed to verify certificate: x509: certificate signed by unknown authority")}, true)
+	n, s = names(results)
+	assert.Equal(t, []string{"Handshake", "TLS"}, n)
+	assert.Equal(t, []string{checkOK, checkFail}, s)
+	assert.Contains(t, results[1].Message, "tls.insecure")
+
+	// No answer, blamed on the network or the server depending on UDP
+	timeout := hyErrors.ConnectError{Err: errors.New("timeout: no recent network activity")}
+	results = clientDoctorHandshake(config, timeout, true)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, checkFail, results[0].Status)
+		assert.Contains(t, results[0].Message, "firewall")
+	}
+	withObfs := config
+	withObfs.Obfs.Type = "salamander"
+	results = clientDoctorHandshake(withObfs, timeout, false)
+	assert.Contains(t, results[0].Message, "UDP looks blocked")
+	assert.Contains(t, results[0].Message, "obfs password")
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/spf13/viper"
+
+	"github.com/apernet/hysteria/core/v2/client"
 )
 
 // TestClientConfig tests the parsing of the client config
@@ -204,3 +206,22 @@ func stringRef(s string) *string {
 func uint32Ref(i uint32) *uint32 {
 	return &i
 }
+
+// TestClientConfigAuthEnv tests reading the auth from an environment
+// variable placeholder, as written by gen-client --secret-ref
+func TestClientConfigAuthEnv(t *testing.T) {
+	t.Setenv("LIBYALINK_TEST_AUTH", "weak_ahh_password")
+
+	var hyConfig client.Config
+	c := clientConfig{Auth: "${LIBYALINK_TEST_AUTH}"}
+	assert.NoError(t, c.fillAuth(&hyConfig))
+	assert.Equal(t, "weak_ahh_password", hyConfig.Auth)
+
+	c = clientConfig{Auth: "${LIBYALINK_TEST_NOPE}"}
+	assert.Error(t, c.fillAuth(&hyConfig))
+
+	// Not a placeholder, used as is
+	c = clientConfig{Auth: "pa${ss}"}
+	assert.NoError(t, c.fillAuth(&hyConfig))
+	assert.Equal(t, "pa${ss}", hyConfig.Auth)
+}
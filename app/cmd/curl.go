@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apernet/hysteria/core/v2/client"
+)
+
+var (
+	curlServer   string
+	curlAuth     string
+	curlObfs     string
+	curlSNI      string
+	curlInsecure bool
+	curlTimeout  time.Duration
+)
+
+var curlCmd = &cobra.Command{
+	Use:   "curl URL",
+	Short: "Fetch a URL through a server",
+	Long: `Connect to a server, fetch a URL through it and print the status and how long
+each step took. Unlike test-auth, which stops after the password, this checks
+that the server actually reaches the internet, e.g. after setting up a new one.
+
+The exit code is 0 if the URL was fetched (whatever the HTTP status), 1 if
+fetching it failed, and 2 if the connection to the server failed.
+
+Examples:
+  libyalink curl --server 1.2.3.4:443 --auth "mypassword" --insecure https://example.com
+  libyalink curl --server example.com:443 --auth "alice:her_password" --obfs "obfs_password" www.google.com`,
+	Run: runCurl,
+}
+
+func init() {
+	initCurlFlags()
+	rootCmd.AddCommand(curlCmd)
+}
+
+func initCurlFlags() {
+	curlCmd.Flags().StringVar(&curlServer, "server", "", "server address, host:port (required)")
+	curlCmd.Flags().StringVar(&curlAuth, "auth", "", "password, user:password for userpass auth (required)")
+	curlCmd.Flags().StringVar(&curlObfs, "obfs", "", "obfuscation password (salamander)")
+	curlCmd.Flags().StringVar(&curlSNI, "sni", "", "TLS SNI (default: the server host)")
+	curlCmd.Flags().BoolVar(&curlInsecure, "insecure", false, "skip TLS certificate verification of the server, e.g. for a self-signed certificate")
+	curlCmd.Flags().DurationVar(&curlTimeout, "timeout", 20*time.Second, "give up on the connection and on the fetch after this long each (4s-120s)")
+
+	curlCmd.MarkFlagRequired("server")
+	curlCmd.MarkFlagRequired("auth")
+}
+
+// curlTimings is how long each step of fetching a URL took.
+type curlTimings struct {
+	Handshake time.Duration // To the server, with auth
+	Connect   time.Duration // To the URL's host, through the server
+	TLS       time.Duration // 0 for http://
+	FirstByte time.Duration // From sending the request
+	Total     time.Duration // Of the fetch, without Handshake
+	Bytes     int64
+}
+
+func runCurl(cmd *cobra.Command, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Error: give one URL to fetch, e.g. libyalink curl --server 1.2.3.4:443 --auth pass https://example.com")
+		os.Exit(1)
+	}
+	u, err := parseCurlURL(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid URL '%s': %v\n", args[0], err)
+		os.Exit(1)
+	}
+	if curlTimeout < 4*time.Second || curlTimeout > 120*time.Second {
+		fmt.Fprintln(os.Stderr, "Error: --timeout must be between 4s and 120s.")
+		os.Exit(1)
+	}
+
+	config := clientConfig{
+		Server: curlServer,
+		Auth:   curlAuth,
+		TLS: clientConfigTLS{
+			SNI:      curlSNI,
+			Insecure: curlInsecure,
+		},
+		QUIC: clientConfigQUIC{
+			MaxIdleTimeout: curlTimeout,
+		},
+	}
+	if curlObfs != "" {
+		config.Obfs.Type = "salamander"
+		config.Obfs.Salamander.Password = curlObfs
+	}
+	hyConfig, err := config.Config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	c, _, err := client.NewClient(hyConfig)
+	if err != nil {
+		message, _ := testAuthVerdict(err)
+		fmt.Printf("%s %s\n", checkFail, message)
+		os.Exit(2)
+	}
+	defer c.Close()
+	handshake := time.Since(start)
+
+	resp, t, err := curlFetch(c, u)
+	t.Handshake = handshake
+	if err != nil {
+		fmt.Printf("%s Connected to %s in %s, but fetching %s failed: %v\n",
+			checkFail, curlServer, handshake.Round(time.Millisecond), u, err)
+		fmt.Println("   The server may not reach the internet, check its outbounds, ACL and DNS.")
+		os.Exit(1)
+	}
+	fmt.Printf("%s %s %s from %s\n", checkOK, resp.Proto, resp.Status, u)
+	printCurlTimings(t)
+}
+
+// parseCurlURL takes a URL like curl does, where "example.com" means
+// http://example.com. Only http and https are supported.
+func parseCurlURL(s string) (*url.URL, error) {
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme '%s', use http or https", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("no host")
+	}
+	return u, nil
+}
+
+// curlFetch does a GET of u through c and reads the whole body.
+func curlFetch(c client.Client, u *url.URL) (*http.Response, curlTimings, error) {
+	var t curlTimings
+	var tlsStart, sent time.Time
+	trace := &httptrace.ClientTrace{
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.TLS = time.Since(tlsStart)
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { sent = time.Now() },
+		GotFirstResponseByte: func() { t.FirstByte = time.Since(sent) },
+	}
+	hc := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				start := time.Now()
+				conn, err := c.TCP(addr)
+				t.Connect = time.Since(start)
+				return conn, err
+			},
+		},
+		Timeout: curlTimeout,
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, t, err
+	}
+	req.Header.Set("User-Agent", "libyalink-curl/"+appVersion)
+	start := time.Now()
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, t, err
+	}
+	defer resp.Body.Close()
+	t.Bytes, err = io.Copy(io.Discard, resp.Body)
+	t.Total = time.Since(start)
+	return resp, t, err
+}
+
+func printCurlTimings(t curlTimings) {
+	ms := func(d time.Duration) string { return d.Round(time.Millisecond).String() }
+	fmt.Printf("   Server handshake: %s\n", ms(t.Handshake))
+	fmt.Printf("   Connect:          %s (through the server)\n", ms(t.Connect))
+	if t.TLS > 0 {
+		fmt.Printf("   TLS:              %s\n", ms(t.TLS))
+	}
+	fmt.Printf("   First byte:       %s\n", ms(t.FirstByte))
+	fmt.Printf("   Total:            %s, %s at %s\n", ms(t.Total), formatBytes(int(t.Bytes)),
+		formatSpeed(uint32(min(t.Bytes, math.MaxUint32)), t.Total, true))
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCurlURL(t *testing.T) {
+	u, err := parseCurlURL("example.com/path")
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/path", u.String())
+
+	u, err = parseCurlURL("https://example.com:8443")
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", u.Hostname())
+
+	for _, s := range []string{"ftp://example.com", "https://", "http://[::1"} {
+		_, err := parseCurlURL(s)
+		assert.Error(t, err, s)
+	}
+}
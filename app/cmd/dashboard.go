@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	dashboardMaxUsers   = 15
+	dashboardMaxStreams = 10
+)
+
+var (
+	dashboardAPI      string
+	dashboardSecret   string
+	dashboardInterval time.Duration
+)
+
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Live view of a running server in the terminal",
+	Long: `Show the online users with their speed and traffic, the latest connections,
+the banned IPs, the UDP buffers and the doctor results of a running server,
+refreshed every few seconds. The numbers come from the traffic stats API,
+which must be enabled (trafficStats.listen). The address and secret are read
+from the server config unless --api and --secret are given.
+
+Type a command and press Enter (a refresh hides what you typed so far, but
+it's still there):
+  k <user>...  kick users (they can reconnect, change their password to keep them out)
+  d            run the doctor checks again
+  q            quit
+
+Examples:
+  libyalink dashboard -c /etc/hysteria/config.yaml
+  libyalink dashboard --api 127.0.0.1:9999 --secret "stats_secret" --interval 5s`,
+	Run: runDashboard,
+}
+
+func init() {
+	initDashboardFlags()
+	rootCmd.AddCommand(dashboardCmd)
+}
+
+func initDashboardFlags() {
+	dashboardCmd.Flags().StringVar(&dashboardAPI, "api", "", "traffic stats API address (default: trafficStats.listen of the config)")
+	dashboardCmd.Flags().StringVar(&dashboardSecret, "secret", "", "traffic stats API secret (default: trafficStats.secret of the config)")
+	dashboardCmd.Flags().DurationVar(&dashboardInterval, "interval", 2*time.Second, "refresh interval")
+}
+
+type dashboardSnapshot struct {
+	Time    time.Time
+	Online  map[string]int
+	Traffic map[string]statsAPITraffic
+	Streams []statsAPIStream
+	Bans    []authBan
+	Buffers []checkResult
+	Err     error
+}
+
+type dashboardUser struct {
+	ID     string
+	Conns  int
+	Tx, Rx uint64
+	// Per second since the previous snapshot
+	TxRate, RxRate uint64
+}
+
+func runDashboard(cmd *cobra.Command, args []string) {
+	if dashboardInterval < 500*time.Millisecond {
+		fmt.Fprintln(os.Stderr, "Error: --interval must be at least 500ms.")
+		os.Exit(1)
+	}
+	configErr := viper.ReadInConfig()
+	if dashboardAPI == "" {
+		dashboardAPI = viper.GetString("trafficStats.listen")
+	}
+	if !cmd.Flags().Changed("secret") {
+		dashboardSecret = viper.GetString("trafficStats.secret")
+	}
+	if dashboardAPI == "" {
+		if configErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot read the config (%v), use -c or --api.\n", configErr)
+		} else {
+			fmt.Fprintln(os.Stderr, "Error: the traffic stats API is off, set trafficStats.listen in the config.")
+		}
+		os.Exit(1)
+	}
+	client, err := newStatsAPIClient(dashboardAPI, dashboardSecret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid API address '%s': %v\n", dashboardAPI, err)
+		os.Exit(1)
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	doctorCh := make(chan []checkResult, 1)
+	startDoctor := func() string {
+		if configErr != nil {
+			return "The doctor checks need the server config, use -c."
+		}
+		go func() { doctorCh <- dashboardDoctorChecks() }()
+		return "Running the doctor checks..."
+	}
+	status := startDoctor()
+
+	ticker := time.NewTicker(dashboardInterval)
+	defer ticker.Stop()
+	var prev dashboardSnapshot
+	var doctor []checkResult
+	for {
+		cur := fetchDashboardSnapshot(client)
+		var buf strings.Builder
+		buf.WriteString("\033[H\033[2J") // Home and clear the screen
+		renderDashboard(&buf, prev, cur, doctor, status)
+		fmt.Print(buf.String())
+		if cur.Err == nil {
+			prev = cur
+		}
+
+		select {
+		case <-ticker.C:
+		case results := <-doctorCh:
+			doctor, status = results, ""
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil // Stdin closed, keep refreshing
+				continue
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 0:
+			case fields[0] == "q":
+				return
+			case fields[0] == "d":
+				status = startDoctor()
+			case fields[0] == "k" && len(fields) > 1:
+				if err := client.Kick(fields[1:]...); err != nil {
+					status = fmt.Sprintf("%s Kick failed: %v", checkFail, err)
+				} else {
+					status = fmt.Sprintf("%s Kicked %s", checkOK, strings.Join(fields[1:], ", "))
+				}
+			default:
+				status = fmt.Sprintf("Unknown command %q. Use k <user>, d or q.", line)
+			}
+		case <-sigs:
+			return
+		}
+	}
+}
+
+func fetchDashboardSnapshot(client *statsAPIClient) dashboardSnapshot {
+	s := dashboardSnapshot{Time: time.Now(), Buffers: checkUDPBuffers()}
+	if s.Online, s.Err = client.Online(); s.Err != nil {
+		return s
+	}
+	if s.Traffic, s.Err = client.Traffic(); s.Err != nil {
+		return s
+	}
+	if s.Streams, s.Err = client.Streams(); s.Err != nil {
+		return s
+	}
+	// Older servers don't serve /bans
+	s.Bans, _ = client.Bans()
+	return s
+}
+
+// dashboardDoctorChecks runs the doctor checks without those that fail
+// because the server is running, e.g. its port being in use.
+func dashboardDoctorChecks() []checkResult {
+	var results []checkResult
+	for _, r := range doctorChecks() {
+		if strings.Contains(r.Message, "already in use") {
+			continue
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// dashboardUsers merges the online users and their traffic, fastest first.
+// Rates are computed against prev, if it's a valid snapshot.
+func dashboardUsers(prev, cur dashboardSnapshot) []dashboardUser {
+	ids := make(map[string]bool)
+	for id, n := range cur.Online {
+		if n > 0 {
+			ids[id] = true
+		}
+	}
+	for id := range cur.Traffic {
+		ids[id] = true
+	}
+	elapsed := cur.Time.Sub(prev.Time).Seconds()
+	users := make([]dashboardUser, 0, len(ids))
+	for id := range ids {
+		u := dashboardUser{ID: id, Conns: cur.Online[id], Tx: cur.Traffic[id].Tx, Rx: cur.Traffic[id].Rx}
+		if p, ok := prev.Traffic[id]; ok && elapsed > 0 && u.Tx >= p.Tx && u.Rx >= p.Rx {
+			u.TxRate = uint64(float64(u.Tx-p.Tx) / elapsed)
+			u.RxRate = uint64(float64(u.Rx-p.Rx) / elapsed)
+		}
+		users = append(users, u)
+	}
+	slices.SortFunc(users, func(a, b dashboardUser) int {
+		if ra, rb := a.TxRate+a.RxRate, b.TxRate+b.RxRate; ra != rb {
+			if ra > rb {
+				return -1
+			}
+			return 1
+		}
+		if a.Conns != b.Conns {
+			return b.Conns - a.Conns
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return users
+}
+
+func renderDashboard(w io.Writer, prev, cur dashboardSnapshot, doctor []checkResult, status string) {
+	fmt.Fprintf(w, "LibyaLink Dashboard — %s — API %s — refresh %s\n\n",
+		cur.Time.Format("2006-01-02 15:04:05"), dashboardAPI, dashboardInterval)
+	if cur.Err != nil {
+		fmt.Fprintf(w, "%s Cannot reach the traffic stats API: %v\n\n", checkFail, cur.Err)
+	} else {
+		users := dashboardUsers(prev, cur)
+		online, conns := 0, 0
+		var txRate, rxRate uint64
+		for _, u := range users {
+			if u.Conns > 0 {
+				online++
+				conns += u.Conns
+			}
+			txRate += u.TxRate
+			rxRate += u.RxRate
+		}
+		fmt.Fprintf(w, "Online: %d user(s), %d connection(s), %d stream(s). Speed: %s down / %s up\n\n",
+			online, conns, len(cur.Streams), formatDashboardRate(txRate), formatDashboardRate(rxRate))
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "USER\tCONNS\tDOWN\tUP\tDOWN TOTAL\tUP TOTAL")
+		for i, u := range users {
+			if i == dashboardMaxUsers {
+				fmt.Fprintf(tw, "... %d more\t\t\t\t\t\n", len(users)-i)
+				break
+			}
+			// The server's tx is what the user downloads
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", u.ID, u.Conns, formatDashboardRate(u.TxRate),
+				formatDashboardRate(u.RxRate), formatDashboardBytes(u.Tx), formatDashboardBytes(u.Rx))
+		}
+		tw.Flush()
+		fmt.Fprintln(w)
+
+		streams := slices.Clone(cur.Streams)
+		slices.SortFunc(streams, func(a, b statsAPIStream) int { return b.InitialAt.Compare(a.InitialAt) })
+		fmt.Fprintln(w, "Latest connections:")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for i, s := range streams {
+			if i == dashboardMaxStreams {
+				break
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s ago\n", s.Auth, s.ReqAddr, strings.ToUpper(s.State),
+				cur.Time.Sub(s.InitialAt).Round(time.Second))
+		}
+		tw.Flush()
+		if len(streams) == 0 {
+			fmt.Fprintln(w, "  none")
+		}
+		fmt.Fprintln(w)
+
+		if len(cur.Bans) > 0 {
+			ips := make([]string, 0, len(cur.Bans))
+			for _, b := range cur.Bans {
+				ips = append(ips, b.IP)
+			}
+			fmt.Fprintf(w, "Banned IPs (%d): %s\n\n", len(ips), strings.Join(ips, ", "))
+		}
+	}
+
+	fmt.Fprintln(w, "UDP buffers:")
+	for _, r := range cur.Buffers {
+		fmt.Fprintf(w, "  %s  [%s] %s\n", r.Status, r.Name, r.Message)
+	}
+	fmt.Fprintln(w)
+
+	if doctor != nil {
+		var problems []checkResult
+		for _, r := range doctor {
+			if r.Status == checkFail || r.Status == checkWarn {
+				problems = append(problems, r)
+			}
+		}
+		fmt.Fprintf(w, "Doctor: %d check(s), %d problem(s)\n", len(doctor), len(problems))
+		for _, r := range problems {
+			fmt.Fprintf(w, "  %s  [%s] %s\n", r.Status, r.Name, r.Message)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if status != "" {
+		fmt.Fprintln(w, status)
+	}
+	fmt.Fprint(w, "k <user> kick · d doctor · q quit > ")
+}
+
+func formatDashboardRate(bytesPerSec uint64) string {
+	return formatSpeed(uint32(min(bytesPerSec, math.MaxUint32)), time.Second, false)
+}
+
+func formatDashboardBytes(b uint64) string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(b)/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(b)/(1<<20))
+	default:
+		return fmt.Sprintf("%dKB", b>>10)
+	}
+}
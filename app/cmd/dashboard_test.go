@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/extras/v2/trafficlogger"
+)
+
+func TestDashboardUsers(t *testing.T) {
+	now := time.Now()
+	prev := dashboardSnapshot{
+		Time:    now.Add(-2 * time.Second),
+		Traffic: map[string]statsAPITraffic{"alice": {Tx: 1000, Rx: 100}, "bob": {Tx: 5000, Rx: 500}},
+	}
+	cur := dashboardSnapshot{
+		Time:    now,
+		Online:  map[string]int{"alice": 2, "carol": 1},
+		Traffic: map[string]statsAPITraffic{"alice": {Tx: 9000, Rx: 300}, "bob": {Tx: 5000, Rx: 500}},
+	}
+	assert.Equal(t, []dashboardUser{
+		{ID: "alice", Conns: 2, Tx: 9000, Rx: 300, TxRate: 4000, RxRate: 100},
+		{ID: "carol", Conns: 1},
+		{ID: "bob", Tx: 5000, Rx: 500},
+	}, dashboardUsers(prev, cur))
+
+	// Stats cleared in between, no negative rates
+	cur.Traffic["alice"] = statsAPITraffic{Tx: 10, Rx: 10}
+	assert.Zero(t, dashboardUsers(prev, cur)[0].TxRate)
+}
+
+func TestFetchDashboardSnapshot(t *testing.T) {
+	tss := trafficlogger.NewTrafficStatsServer("stats_secret")
+	tss.LogOnlineState("alice", true)
+	tss.LogTraffic("alice", 2048, 1024)
+	srv := httptest.NewServer(tss)
+	defer srv.Close()
+
+	client, err := newStatsAPIClient(srv.Listener.Addr().String(), "stats_secret")
+	assert.NoError(t, err)
+	s := fetchDashboardSnapshot(client)
+	assert.NoError(t, s.Err)
+	assert.Equal(t, map[string]int{"alice": 1}, s.Online)
+	assert.Equal(t, map[string]statsAPITraffic{"alice": {Tx: 2048, Rx: 1024}}, s.Traffic)
+	assert.Empty(t, s.Bans)
+
+	var buf strings.Builder
+	renderDashboard(&buf, dashboardSnapshot{}, s, nil, "")
+	assert.Contains(t, buf.String(), "Online: 1 user(s), 1 connection(s)")
+	assert.Contains(t, buf.String(), "alice")
+
+	assert.NoError(t, client.Kick("alice"))
+	client.Secret = "wrong"
+	assert.Error(t, fetchDashboardSnapshot(client).Err)
+}
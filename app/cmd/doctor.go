@@ -1,21 +1,22 @@
 package cmd
 
 import (
-	"crypto/tls"
+	"encoding/json"
 	"fmt"
-	"net"
+	"io"
 	"os"
-	"runtime"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 const (
 	checkOK   = "✅"
 	checkFail = "❌"
 	checkWarn = "⚠️"
+	checkInfo = "ℹ️" // Not counted as a problem
 )
 
 var doctorCmd = &cobra.Command{
@@ -23,14 +24,59 @@ var doctorCmd = &cobra.Command{
 	Short: "Diagnose server configuration and environment",
 	Long: `Run a comprehensive diagnostic check on the server configuration and system environment.
 Validates YAML syntax, TLS/ACME config, file permissions, port availability,
-and system tuning parameters. Designed for operators to quickly identify issues.`,
+and system tuning parameters. Designed for operators to quickly identify issues.
+
+Use --show-config to print the effective configuration, with secrets redacted
+and the source of each value (flag, env, file or default).
+
+Every run ends with a 0-100 health score. Use --history-file to append the
+score to a file after each run, and --history to show how it changed.
+
+The exit code is 1 if any check failed, and otherwise 0, or 2 with --strict
+if there were warnings. Use it to gate CI or systemd's ExecStartPre=.
+
+Use --output json for scripts: a "checks" array of objects with "name",
+"status" ("ok", "warn", "fail" or "info") and "message", and a "summary"
+object with the count of each status and the health score.
+
+Use --fix to fix what doctor can before checking: for now the UDP buffer
+limits (net.core.rmem_max and wmem_max) on Linux, which it sets and saves in
+/etc/sysctl.d/99-libyalink.conf. It needs root, and says what it changed.
+
+Use --self-test to also connect to the server like a client, with the obfs
+and password in the config, and send a datagram through it: to the running
+server if the listen port is taken, otherwise to a throwaway one started from
+the config.`,
 	Run: runDoctor,
 }
 
+var (
+	doctorShowConfig   bool
+	doctorClientConfig string
+	doctorHistoryFile  string
+	doctorHistory      bool
+	doctorOutput       string
+	doctorStrict       bool
+	doctorFix          bool
+	doctorSelfTest     bool
+)
+
 func init() {
+	initDoctorFlags()
 	rootCmd.AddCommand(doctorCmd)
 }
 
+func initDoctorFlags() {
+	doctorCmd.Flags().BoolVar(&doctorShowConfig, "show-config", false, "print the effective configuration and where each value comes from, then exit")
+	doctorCmd.Flags().StringVar(&doctorClientConfig, "client-config", "", "client config to check against the server's auth and obfs (default: client.yaml/yml/json next to the server config)")
+	doctorCmd.Flags().StringVar(&doctorHistoryFile, "history-file", "", "append the health score with a timestamp to this file")
+	doctorCmd.Flags().BoolVar(&doctorHistory, "history", false, "show the health score trend recorded in --history-file, then exit")
+	doctorCmd.Flags().StringVar(&doctorOutput, "output", "text", "output format: 'text' or 'json'")
+	doctorCmd.Flags().BoolVar(&doctorStrict, "strict", false, "exit with 2 if there are warnings but no failures")
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "fix what can be fixed before checking: the UDP buffer limits on Linux, as root")
+	doctorCmd.Flags().BoolVar(&doctorSelfTest, "self-test", false, "after the checks, connect to the server like a client and send a datagram through it")
+}
+
 type checkResult struct {
 	Name    string
 	Status  string // checkOK, checkFail, checkWarn
@@ -38,6 +84,46 @@ type checkResult struct {
 }
 
 func runDoctor(cmd *cobra.Command, args []string) {
+	// Before --fix changes anything
+	if doctorOutput != "text" && doctorOutput != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown --output '%s'. Use 'text' or 'json'.\n", doctorOutput)
+		os.Exit(1)
+	}
+	if doctorShowConfig {
+		showEffectiveConfig()
+		return
+	}
+	if doctorHistory {
+		showHealthHistory()
+		return
+	}
+	if doctorFix {
+		// Keep stdout JSON only
+		w := io.Writer(os.Stdout)
+		if doctorOutput == "json" {
+			w = os.Stderr
+		}
+		fmt.Fprintln(w, "─── Fixes ───")
+		fmt.Fprintln(w)
+		if tried, failed := runDoctorFixes(w, fixableChecks()); tried == 0 {
+			fmt.Fprintln(w, "  Nothing to fix.")
+		} else if failed == 0 {
+			fmt.Fprintln(w, "  Send SIGHUP to a running server to apply the new limits to its sockets: kill -HUP $(pidof libyalink)")
+		}
+		fmt.Fprintln(w)
+	}
+	if doctorOutput == "json" {
+		report := newDoctorReport(doctorChecks())
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		recordHealthHistory(report.Summary.Score, report.Summary.Fail, report.Summary.Warn)
+		os.Exit(doctorExitCode(report.Summary.Fail, report.Summary.Warn, doctorStrict))
+	}
+
 	fmt.Println()
 	fmt.Println("╔══════════════════════════════════════════════════════╗")
 	fmt.Println("║          LibyaLink Doctor — System Diagnostic       ║")
@@ -45,25 +131,7 @@ func runDoctor(cmd *cobra.Command, args []string) {
 	fmt.Println("╚══════════════════════════════════════════════════════╝")
 	fmt.Println()
 
-	results := make([]checkResult, 0, 10)
-
-	// 1. Check config file readability
-	results = append(results, checkConfigReadable()...)
-
-	// 2. Check TLS / ACME conflict
-	results = append(results, checkTLSACMEConflict()...)
-
-	// 3. Check TLS cert/key file permissions
-	results = append(results, checkTLSFiles()...)
-
-	// 4. Check listen port availability
-	results = append(results, checkPortAvailability()...)
-
-	// 5. Check UDP buffer sizes (Linux)
-	results = append(results, checkUDPBuffers()...)
-
-	// 6. Check auth configuration
-	results = append(results, checkAuthConfig()...)
+	results := doctorChecks()
 
 	// Print results
 	fmt.Println("─── Diagnostic Results ───")
@@ -91,347 +159,275 @@ func runDoctor(cmd *cobra.Command, args []string) {
 	} else {
 		fmt.Printf("  %s %d error(s), %d warning(s) found. Fix the issues above.\n", checkFail, failCount, warnCount)
 	}
+	score := healthScore(failCount, warnCount)
+	fmt.Printf("  Health score: %d/100\n", score)
 	fmt.Println()
-}
 
-func checkConfigReadable() []checkResult {
-	err := viper.ReadInConfig()
-	if err != nil {
-		return []checkResult{{
-			Name:    "Config File",
-			Status:  checkFail,
-			Message: fmt.Sprintf("Cannot read config file: %v", err),
-		}}
-	}
-	return []checkResult{{
-		Name:    "Config File",
-		Status:  checkOK,
-		Message: fmt.Sprintf("Config loaded from: %s", viper.ConfigFileUsed()),
-	}}
+	recordHealthHistory(score, failCount, warnCount)
+	os.Exit(doctorExitCode(failCount, warnCount, doctorStrict))
 }
 
-func checkTLSACMEConflict() []checkResult {
-	// If config is not readable, skip
-	if viper.ConfigFileUsed() == "" {
-		return nil
+// doctorExitCode is 1 if a check failed, 2 if strict and a check warned,
+// and 0 otherwise.
+func doctorExitCode(failCount, warnCount int, strict bool) int {
+	switch {
+	case failCount > 0:
+		return 1
+	case strict && warnCount > 0:
+		return 2
+	default:
+		return 0
 	}
+}
 
-	hasTLS := viper.IsSet("tls")
-	hasACME := viper.IsSet("acme")
-
-	if hasTLS && hasACME {
-		return []checkResult{{
-			Name:    "TLS/ACME",
-			Status:  checkFail,
-			Message: "Both 'tls' and 'acme' are set. You must use one or the other, not both.",
-		}}
+// recordHealthHistory appends the result of this run to --history-file, if given.
+func recordHealthHistory(score, failCount, warnCount int) {
+	if doctorHistoryFile == "" {
+		return
 	}
-	if !hasTLS && !hasACME {
-		return []checkResult{{
-			Name:    "TLS/ACME",
-			Status:  checkFail,
-			Message: "Neither 'tls' nor 'acme' is configured. One is required for the server to start.",
-		}}
+	if err := appendHealthHistory(doctorHistoryFile, time.Now(), score, failCount, warnCount); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot write history file: %v\n", err)
+		os.Exit(1)
 	}
-	if hasTLS {
-		return []checkResult{{
-			Name:    "TLS/ACME",
-			Status:  checkOK,
-			Message: "TLS mode: using local certificate files.",
-		}}
-	}
-	return []checkResult{{
-		Name:    "TLS/ACME",
-		Status:  checkOK,
-		Message: "ACME mode: using automatic certificate provisioning.",
-	}}
 }
 
-func checkTLSFiles() []checkResult {
-	if !viper.IsSet("tls") {
-		return nil // ACME mode, no files to check
-	}
+// doctorReport is the output of --output json.
+type doctorReport struct {
+	Checks  []doctorReportCheck `json:"checks"`
+	Summary doctorReportSummary `json:"summary"`
+}
 
-	certPath := viper.GetString("tls.cert")
-	keyPath := viper.GetString("tls.key")
+type doctorReportCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // "ok", "warn", "fail" or "info"
+	Message string `json:"message"`
+}
 
-	var results []checkResult
+type doctorReportSummary struct {
+	OK    int `json:"ok"`
+	Warn  int `json:"warn"`
+	Fail  int `json:"fail"`
+	Info  int `json:"info"`
+	Score int `json:"score"`
+}
 
-	// Check cert file
-	if certPath == "" {
-		results = append(results, checkResult{
-			Name:    "TLS Cert",
-			Status:  checkFail,
-			Message: "tls.cert path is empty.",
-		})
-	} else {
-		if r := checkFileReadable("TLS Cert", certPath); r.Status != checkOK {
-			results = append(results, r)
-		} else {
-			results = append(results, r)
-		}
-	}
+// checkStatusNames are the plain names of the statuses, for scripts.
+var checkStatusNames = map[string]string{
+	checkOK:   "ok",
+	checkWarn: "warn",
+	checkFail: "fail",
+	checkInfo: "info",
+}
 
-	// Check key file
-	if keyPath == "" {
-		results = append(results, checkResult{
-			Name:    "TLS Key",
-			Status:  checkFail,
-			Message: "tls.key path is empty.",
-		})
-	} else {
-		if r := checkFileReadable("TLS Key", keyPath); r.Status != checkOK {
-			results = append(results, r)
-		} else {
-			results = append(results, r)
+func newDoctorReport(results []checkResult) doctorReport {
+	report := doctorReport{Checks: make([]doctorReportCheck, 0, len(results))}
+	for _, r := range results {
+		status := checkStatusNames[r.Status]
+		switch r.Status {
+		case checkOK:
+			report.Summary.OK++
+		case checkWarn:
+			report.Summary.Warn++
+		case checkFail:
+			report.Summary.Fail++
+		case checkInfo:
+			report.Summary.Info++
 		}
+		report.Checks = append(report.Checks, doctorReportCheck{Name: r.Name, Status: status, Message: r.Message})
 	}
+	report.Summary.Score = healthScore(report.Summary.Fail, report.Summary.Warn)
+	return report
+}
 
-	// If both are readable, try to parse the pair
-	if certPath != "" && keyPath != "" {
-		_, err := tls.LoadX509KeyPair(certPath, keyPath)
-		if err != nil {
-			results = append(results, checkResult{
-				Name:    "TLS Pair",
-				Status:  checkFail,
-				Message: fmt.Sprintf("Certificate/Key pair is invalid: %v", err),
-			})
-		} else {
-			results = append(results, checkResult{
-				Name:    "TLS Pair",
-				Status:  checkOK,
-				Message: "Certificate and key pair loaded successfully.",
-			})
-		}
-	}
+// doctorChecks runs every check, in the order they are reported.
+func doctorChecks() []checkResult {
+	results := make([]checkResult, 0, 10)
 
-	return results
-}
+	// 1. Check config file readability
+	results = append(results, checkConfigReadable()...)
 
-func checkFileReadable(name, path string) checkResult {
-	info, err := os.Stat(path)
-	if os.IsNotExist(err) {
-		return checkResult{
-			Name:    name,
-			Status:  checkFail,
-			Message: fmt.Sprintf("File not found: %s", path),
-		}
-	}
-	if os.IsPermission(err) {
-		return checkResult{
-			Name:    name,
-			Status:  checkFail,
-			Message: fmt.Sprintf("Permission denied on %s", path),
-		}
-	}
-	if err != nil {
-		return checkResult{
-			Name:    name,
-			Status:  checkFail,
-			Message: fmt.Sprintf("Error accessing %s: %v", path, err),
-		}
-	}
+	// 2. Check TLS / ACME conflict
+	results = append(results, checkTLSACMEConflict()...)
 
-	// Try actually reading
-	f, err := os.Open(path)
-	if err != nil {
-		return checkResult{
-			Name:    name,
-			Status:  checkFail,
-			Message: fmt.Sprintf("Cannot open %s: %v", path, err),
-		}
-	}
-	f.Close()
-
-	// Check file isn't empty
-	if info.Size() == 0 {
-		return checkResult{
-			Name:    name,
-			Status:  checkFail,
-			Message: fmt.Sprintf("File is empty: %s", path),
-		}
-	}
+	// 3. Check TLS cert/key file permissions
+	results = append(results, checkTLSFiles()...)
 
-	return checkResult{
-		Name:    name,
-		Status:  checkOK,
-		Message: fmt.Sprintf("Readable (%d bytes): %s", info.Size(), path),
-	}
-}
+	// 4. Check listen port availability
+	results = append(results, checkPortAvailability()...)
 
-func checkPortAvailability() []checkResult {
-	listenAddr := viper.GetString("listen")
-	if listenAddr == "" {
-		listenAddr = defaultListenAddr
-	}
+	// 5. Check UDP buffer sizes (Linux)
+	results = append(results, checkUDPBuffers()...)
 
-	var results []checkResult
+	// 6. Check auth configuration
+	results = append(results, checkAuthConfig()...)
 
-	// Check UDP port (primary for QUIC)
-	udpAddr, err := net.ResolveUDPAddr("udp", listenAddr)
-	if err != nil {
-		results = append(results, checkResult{
-			Name:    "UDP Port",
-			Status:  checkFail,
-			Message: fmt.Sprintf("Invalid listen address '%s': %v", listenAddr, err),
-		})
-		return results
-	}
+	// 7. Check for duplicate or shadowed config keys
+	results = append(results, checkDuplicateKeys()...)
 
-	conn, err := net.ListenUDP("udp", udpAddr)
-	if err != nil {
-		errStr := err.Error()
-		if strings.Contains(errStr, "address already in use") ||
-			strings.Contains(errStr, "Only one usage of each socket address") {
-			results = append(results, checkResult{
-				Name:    "UDP Port",
-				Status:  checkFail,
-				Message: fmt.Sprintf("Port %s is already in use! Another process (Apache/Nginx/Hysteria?) is binding it.", listenAddr),
-			})
-		} else if strings.Contains(errStr, "permission denied") ||
-			strings.Contains(errStr, "bind: permission denied") {
-			results = append(results, checkResult{
-				Name:    "UDP Port",
-				Status:  checkFail,
-				Message: fmt.Sprintf("Permission denied binding to %s. Use a port > 1024 or run with elevated privileges.", listenAddr),
-			})
-		} else {
-			results = append(results, checkResult{
-				Name:    "UDP Port",
-				Status:  checkFail,
-				Message: fmt.Sprintf("Cannot bind UDP %s: %v", listenAddr, err),
-			})
-		}
-	} else {
-		conn.Close()
-		results = append(results, checkResult{
-			Name:    "UDP Port",
-			Status:  checkOK,
-			Message: fmt.Sprintf("UDP %s is available.", listenAddr),
-		})
+	// 8. Check masquerade configuration
+	results = append(results, checkMasquerade()...)
+
+	// 9. Check that passwords survive being embedded in share URIs
+	results = append(results, checkShareablePasswords()...)
+
+	// 10. Check QUIC tuning parameters
+	results = append(results, checkQUICConfig()...)
+
+	// 11. Check that a companion client config still matches the server
+	results = append(results, checkCompanionClient()...)
+
+	// 12. Check ACME storage permissions and lock files
+	results = append(results, checkACMEStorage()...)
+
+	// 13. Check additional listeners
+	results = append(results, checkListeners()...)
+
+	// 14. Check strict security mode
+	results = append(results, checkStrictMode()...)
+
+	// 15. Check thread/PID limits (Linux)
+	results = append(results, checkThreadLimits()...)
+
+	// 16. Check that masquerade isn't undermined by a self-signed cert
+	results = append(results, checkMasqueradeCert()...)
+
+	// 17. Check the per-user idle timeout
+	results = append(results, checkIdleTimeout()...)
+
+	// 18. Check for hypervisors with known UDP performance issues (Linux)
+	results = append(results, checkHypervisor()...)
+
+	// 19. Check the time zone of the log timestamps
+	results = append(results, checkLogTimeZone()...)
+
+	// 20. Check auth failure bans, and list the current ones
+	results = append(results, checkAuthFailureBans()...)
+
+	// 21. Check that the temp, working and ACME directories are writable
+	results = append(results, checkWritableDirs()...)
+
+	// 22. Check that the server's address is reachable from the internet
+	results = append(results, checkPublicAddress()...)
+
+	// 23. Check that the ACME challenge can be answered
+	results = append(results, checkACMEChallenge()...)
+
+	// 24. Check the clock against NTP, for the features that depend on it
+	results = append(results, checkClock()...)
+
+	// 25. Check that the data quotas parse
+	results = append(results, checkQuota()...)
+
+	// 26. Check how obfs and masquerade work together
+	results = append(results, checkCamouflage()...)
+
+	// 27. Check that the ACME domains resolve to this server
+	results = append(results, checkDNSResolution()...)
+
+	// 28. Check the open file limit (Unix)
+	results = append(results, checkFileDescriptorLimit()...)
+
+	// 29. Check that the server can listen on IPv6 too
+	results = append(results, checkIPv6()...)
+
+	// 30. Check that TCP uses BBR and fq (Linux)
+	results = append(results, checkTCPCongestion()...)
+
+	// 31. Check that the conntrack table isn't nearly full (Linux)
+	results = append(results, checkConntrack()...)
+
+	// 32. Check for SELinux/AppArmor denials (Linux), last as it looks at
+	// the permission errors found by the checks above
+	results = append(results, checkMACDenials(results)...)
+
+	// 33. With --self-test, connect to the server like a client
+	if doctorSelfTest {
+		results = append(results, checkSelfTest()...)
 	}
 
 	return results
 }
 
-func checkUDPBuffers() []checkResult {
-	if runtime.GOOS != "linux" {
-		return []checkResult{{
-			Name:    "UDP Buffers",
-			Status:  checkWarn,
-			Message: fmt.Sprintf("Buffer check only runs on Linux (current OS: %s). See docs/libya_tuning.md", runtime.GOOS),
-		}}
-	}
+const (
+	healthFailPenalty = 20
+	healthWarnPenalty = 5
+)
 
-	var results []checkResult
+// healthScore condenses the check results into a single 0-100 number.
+// A failure costs as much as four warnings.
+func healthScore(failCount, warnCount int) int {
+	return max(0, 100-failCount*healthFailPenalty-warnCount*healthWarnPenalty)
+}
 
-	// Check rmem_max
-	rmem, err := os.ReadFile("/proc/sys/net/core/rmem_max")
-	if err == nil {
-		val := strings.TrimSpace(string(rmem))
-		results = append(results, checkBufferValue("UDP rmem_max", val, 8388608))
-	}
+type healthHistoryEntry struct {
+	Time      time.Time
+	Score     int
+	FailCount int
+	WarnCount int
+}
 
-	// Check wmem_max
-	wmem, err := os.ReadFile("/proc/sys/net/core/wmem_max")
-	if err == nil {
-		val := strings.TrimSpace(string(wmem))
-		results = append(results, checkBufferValue("UDP wmem_max", val, 8388608))
+// appendHealthHistory records one doctor run as a line of
+// "<RFC 3339 time> <score> <failures> <warnings>".
+func appendHealthHistory(path string, t time.Time, score, failCount, warnCount int) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
 	}
-
-	if len(results) == 0 {
-		results = append(results, checkResult{
-			Name:    "UDP Buffers",
-			Status:  checkWarn,
-			Message: "Could not read sysctl buffer values. Run 'sysctl net.core.rmem_max' manually.",
-		})
+	_, err = fmt.Fprintf(f, "%s %d %d %d\n", t.Format(time.RFC3339), score, failCount, warnCount)
+	if cerr := f.Close(); err == nil {
+		err = cerr
 	}
-
-	return results
+	return err
 }
 
-func checkBufferValue(name, valStr string, recommended int) checkResult {
-	var val int
-	fmt.Sscanf(valStr, "%d", &val)
-	if val >= recommended {
-		return checkResult{
-			Name:    name,
-			Status:  checkOK,
-			Message: fmt.Sprintf("%d bytes (>= %d recommended). Good!", val, recommended),
+// parseHealthHistory parses a history file, skipping lines it doesn't
+// understand so that a hand-edited file still shows a trend.
+func parseHealthHistory(data string) []healthHistoryEntry {
+	var entries []healthHistoryEntry
+	for _, line := range strings.Split(data, "\n") {
+		var ts string
+		var e healthHistoryEntry
+		if _, err := fmt.Sscanf(line, "%s %d %d %d", &ts, &e.Score, &e.FailCount, &e.WarnCount); err != nil {
+			continue
 		}
+		t, err := time.Parse(time.RFC3339, ts)
+		if err != nil {
+			continue
+		}
+		e.Time = t
+		entries = append(entries, e)
 	}
-	return checkResult{
-		Name:    name,
-		Status:  checkWarn,
-		Message: fmt.Sprintf("%d bytes (< %d recommended). Run the tuning script for full speed. See docs/libya_tuning.md", val, recommended),
-	}
+	return entries
 }
 
-func checkAuthConfig() []checkResult {
-	authType := viper.GetString("auth.type")
-	if authType == "" {
-		return []checkResult{{
-			Name:    "Auth",
-			Status:  checkFail,
-			Message: "No auth.type configured. Server requires authentication.",
-		}}
+func showHealthHistory() {
+	if doctorHistoryFile == "" {
+		fmt.Fprintln(os.Stderr, "Error: --history requires --history-file")
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(doctorHistoryFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot read history file: %v\n", err)
+		os.Exit(1)
+	}
+	entries := parseHealthHistory(string(data))
+	if len(entries) == 0 {
+		fmt.Println("No health scores recorded yet.")
+		return
 	}
 
-	switch strings.ToLower(authType) {
-	case "password":
-		pw := viper.GetString("auth.password")
-		if pw == "" {
-			return []checkResult{{
-				Name:    "Auth",
-				Status:  checkFail,
-				Message: "auth.type is 'password' but auth.password is empty.",
-			}}
-		}
-		if len(pw) < 8 {
-			return []checkResult{{
-				Name:    "Auth",
-				Status:  checkWarn,
-				Message: "auth.password is very short (< 8 chars). Consider using a stronger password.",
-			}}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSCORE\tCHANGE\tERRORS\tWARNINGS\t")
+	for i, e := range entries {
+		change := ""
+		if i > 0 {
+			change = fmt.Sprintf("%+d", e.Score-entries[i-1].Score)
 		}
-		return []checkResult{{
-			Name:    "Auth",
-			Status:  checkOK,
-			Message: "Password authentication configured.",
-		}}
-	case "userpass":
-		up := viper.GetStringMapString("auth.userpass")
-		if len(up) == 0 {
-			return []checkResult{{
-				Name:    "Auth",
-				Status:  checkFail,
-				Message: "auth.type is 'userpass' but no user:password entries found.",
-			}}
-		}
-		return []checkResult{{
-			Name:    "Auth",
-			Status:  checkOK,
-			Message: fmt.Sprintf("User/pass authentication configured (%d users).", len(up)),
-		}}
-	case "http", "https":
-		url := viper.GetString("auth.http.url")
-		if url == "" {
-			return []checkResult{{
-				Name:    "Auth",
-				Status:  checkFail,
-				Message: "auth.type is 'http' but auth.http.url is empty.",
-			}}
-		}
-		return []checkResult{{
-			Name:    "Auth",
-			Status:  checkOK,
-			Message: fmt.Sprintf("HTTP authentication configured: %s", url),
-		}}
-	default:
-		return []checkResult{{
-			Name:    "Auth",
-			Status:  checkOK,
-			Message: fmt.Sprintf("Authentication type: %s", authType),
-		}}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%d\t%s\n", e.Time.Local().Format("2006-01-02 15:04"),
+			e.Score, change, e.FailCount, e.WarnCount, strings.Repeat("█", e.Score/10))
 	}
+	_ = w.Flush()
+
+	first, last := entries[0], entries[len(entries)-1]
+	fmt.Printf("\nScore went from %d to %d over %d run(s).\n", first.Score, last.Score, len(entries))
 }
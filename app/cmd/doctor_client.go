@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	"github.com/apernet/hysteria/extras/v2/auth"
+)
+
+// companionClientConfigNames are the client config files doctor looks
+// for next to the server config when --client-config is not given.
+var companionClientConfigNames = []string{"client.yaml", "client.yml", "client.json"}
+
+func checkCompanionClient() []checkResult {
+	path := doctorClientConfig
+	if path == "" {
+		serverPath := viper.ConfigFileUsed()
+		if serverPath == "" {
+			return nil
+		}
+		for _, name := range companionClientConfigNames {
+			p := filepath.Join(filepath.Dir(serverPath), name)
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+		if path == "" {
+			return nil // No companion client config, nothing to compare
+		}
+	}
+
+	cv := viper.New()
+	cv.SetConfigFile(path)
+	if err := cv.ReadInConfig(); err != nil {
+		return []checkResult{{
+			Name:    "Client Config",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot read client config %s: %v", path, err),
+		}}
+	}
+
+	// Never print the values themselves, only whether they match
+	results := []checkResult{checkCompanionAuth(path, cv.GetString("auth"))}
+	serverObfs := strings.ToLower(viper.GetString("obfs.type"))
+	clientObfs := strings.ToLower(cv.GetString("obfs.type"))
+	switch {
+	case serverObfs == "" && clientObfs == "":
+		// No obfs on either side
+	case serverObfs != clientObfs:
+		results = append(results, checkResult{
+			Name:    "Client Obfs",
+			Status:  checkFail,
+			Message: fmt.Sprintf("obfs.type differs between the server and %s.", path),
+		})
+	case viper.GetString("obfs.salamander.password") != cv.GetString("obfs.salamander.password"):
+		results = append(results, checkResult{
+			Name:    "Client Obfs",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Obfs password differs between the server and %s.", path),
+		})
+	default:
+		results = append(results, checkResult{
+			Name:    "Client Obfs",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Obfs password matches %s.", path),
+		})
+	}
+	if r, ok := bandwidthPairingResult(path, viper.GetString("bandwidth.up"), viper.GetString("bandwidth.down"),
+		cv.GetString("bandwidth.up"), cv.GetString("bandwidth.down")); ok {
+		results = append(results, r)
+	}
+	return results
+}
+
+// bandwidthDirectionsNote explains the server's bandwidth, which is from
+// the server's side: its up is what clients download.
+func bandwidthDirectionsNote(up, down string) checkResult {
+	return checkResult{
+		Name:   "Bandwidth Directions",
+		Status: checkInfo,
+		Message: fmt.Sprintf("bandwidth.up (%s) caps what the server sends, each client's download, and bandwidth.down (%s) "+
+			"what it receives, their upload. A client's bandwidth.down pairs with the server's up, its up with the server's down.",
+			bandwidthOrNone(up), bandwidthOrNone(down)),
+	}
+}
+
+func bandwidthOrNone(s string) string {
+	if s == "" {
+		return "not set"
+	}
+	return s
+}
+
+// bandwidthPairingResult checks the bandwidth of the client config at path
+// against the server's. The server sends at the lower of its up and the
+// client's down, and tells the client to upload at most its down, so
+// values that only fit the server the other way around were swapped. ok is
+// false if the client sets no bandwidth, and uses BBR, or a value doesn't
+// parse, which the server and client report themselves.
+func bandwidthPairingResult(path, serverUp, serverDown, clientUp, clientDown string) (checkResult, bool) {
+	var su, sd, cu, cd uint64
+	for _, v := range []struct {
+		s string
+		n *uint64
+	}{{serverUp, &su}, {serverDown, &sd}, {clientUp, &cu}, {clientDown, &cd}} {
+		if v.s == "" {
+			continue
+		}
+		n, err := utils.ConvBandwidth(v.s)
+		if err != nil {
+			return checkResult{}, false
+		}
+		*v.n = n
+	}
+	if cu == 0 && cd == 0 {
+		return checkResult{}, false
+	}
+	fits := func(up, down uint64) bool {
+		return (sd == 0 || up <= sd) && (su == 0 || down <= su)
+	}
+	r := checkResult{Name: "Client Bandwidth", Status: checkOK}
+	switch {
+	case cu != 0 && cd != 0 && cu != cd && !fits(cu, cd) && fits(cd, cu):
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("bandwidth in %s looks swapped: up %s, down %s, but the server receives at most %s (its down) "+
+			"and sends at most %s (its up). The client's up is its upload, and its down its download.",
+			path, bandwidthOrNone(clientUp), bandwidthOrNone(clientDown), bandwidthOrNone(serverDown), bandwidthOrNone(serverUp))
+	case !fits(cu, cd):
+		var over []string
+		if su != 0 && cd > su {
+			over = append(over, fmt.Sprintf("down %s is above the server's up %s", clientDown, serverUp))
+		}
+		if sd != 0 && cu > sd {
+			over = append(over, fmt.Sprintf("up %s is above the server's down %s", clientUp, serverDown))
+		}
+		r.Status = checkInfo
+		r.Message = fmt.Sprintf("bandwidth in %s: %s, so the server's limit applies. Lower the client's to match.",
+			path, strings.Join(over, ", "))
+	default:
+		r.Message = fmt.Sprintf("bandwidth in %s (up %s, down %s) fits the server's.", path, bandwidthOrNone(clientUp), bandwidthOrNone(clientDown))
+	}
+	return r, true
+}
+
+func checkCompanionAuth(path, clientAuth string) checkResult {
+	if secret := viper.GetString("auth.token.secret"); secret != "" && auth.IsToken(clientAuth) {
+		_, expiry, window, err := auth.ParseToken([]byte(secret), clientAuth)
+		if err != nil {
+			return checkResult{
+				Name:    "Client Auth",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Token in %s was not signed with auth.token.secret.", path),
+			}
+		}
+		if !time.Now().Before(expiry) {
+			return checkResult{
+				Name:    "Client Auth",
+				Status:  checkWarn,
+				Message: fmt.Sprintf("Token in %s expired on %s.", path, expiry.UTC().Format(time.RFC3339)),
+			}
+		}
+		msg := fmt.Sprintf("Token in %s is valid until %s.", path, expiry.UTC().Format(time.RFC3339))
+		if window != nil {
+			msg = fmt.Sprintf("Token in %s is valid until %s, daily %s.", path, expiry.UTC().Format(time.RFC3339), window)
+		}
+		return checkResult{
+			Name:    "Client Auth",
+			Status:  checkOK,
+			Message: msg,
+		}
+	}
+
+	var match bool
+	switch strings.ToLower(viper.GetString("auth.type")) {
+	case "password":
+		pw, err := doctorAuthPassword()
+		match = err == nil && clientAuth == pw
+	case "userpass":
+		user, pass, ok := strings.Cut(clientAuth, ":")
+		if ok {
+			for u, p := range viper.GetStringMapString("auth.userpass") {
+				// Viper lowercases map keys, the authenticator does the same
+				if strings.EqualFold(u, user) && p == pass {
+					match = true
+					break
+				}
+			}
+		}
+	case "token":
+		// Only tokens are accepted, and this one did not verify above
+	default:
+		return checkResult{
+			Name:    "Client Auth",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Auth in %s is checked by the external %s backend, not verified here.", path, viper.GetString("auth.type")),
+		}
+	}
+	if !match {
+		return checkResult{
+			Name:    "Client Auth",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Auth in %s differs from the server's credentials.", path),
+		}
+	}
+	return checkResult{
+		Name:    "Client Auth",
+		Status:  checkOK,
+		Message: fmt.Sprintf("Auth in %s matches the server.", path),
+	}
+}
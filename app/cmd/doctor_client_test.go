@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCompanionClient(t *testing.T) {
+	defer viper.Reset()
+
+	dir := t.TempDir()
+	serverPath := filepath.Join(dir, "server.yaml")
+	clientPath := filepath.Join(dir, "client.yaml")
+	writeConfigs := func(server, client string) {
+		t.Helper()
+		assert.NoError(t, os.WriteFile(serverPath, []byte(server), 0o600))
+		assert.NoError(t, os.WriteFile(clientPath, []byte(client), 0o600))
+		viper.Reset()
+		viper.SetConfigFile(serverPath)
+		assert.NoError(t, viper.ReadInConfig())
+	}
+	statuses := func(results []checkResult) map[string]string {
+		m := make(map[string]string)
+		for _, r := range results {
+			m[r.Name] = r.Status
+			// Only whether they match, never the values
+			assert.NotContains(t, r.Message, "weak_ahh_password")
+			assert.NotContains(t, r.Message, "obfs_secret")
+		}
+		return m
+	}
+
+	const server = "auth:\n  type: password\n  password: weak_ahh_password\n" +
+		"obfs:\n  type: salamander\n  salamander:\n    password: obfs_secret\n"
+	writeConfigs(server, "auth: weak_ahh_password\nobfs:\n  type: salamander\n  salamander:\n    password: obfs_secret\n")
+	assert.Equal(t, map[string]string{"Client Auth": checkOK, "Client Obfs": checkOK}, statuses(checkCompanionClient()))
+
+	// Only the server's side was rotated
+	writeConfigs(server, "auth: weak_ahh_password_old\nobfs:\n  type: salamander\n  salamander:\n    password: obfs_secret_old\n")
+	assert.Equal(t, map[string]string{"Client Auth": checkFail, "Client Obfs": checkFail}, statuses(checkCompanionClient()))
+
+	writeConfigs(server, "auth: weak_ahh_password\n")
+	results := checkCompanionClient()
+	assert.Equal(t, map[string]string{"Client Auth": checkOK, "Client Obfs": checkFail}, statuses(results))
+	assert.Contains(t, results[1].Message, "obfs.type differs")
+
+	// Viper lowercases the user names of the server
+	writeConfigs("auth:\n  type: userpass\n  userpass:\n    Ali: weak_ahh_password\n", "auth: Ali:weak_ahh_password\n")
+	assert.Equal(t, map[string]string{"Client Auth": checkOK}, statuses(checkCompanionClient()))
+	writeConfigs("auth:\n  type: userpass\n  userpass:\n    ali: weak_ahh_password\n", "auth: zoe:weak_ahh_password\n")
+	assert.Equal(t, map[string]string{"Client Auth": checkFail}, statuses(checkCompanionClient()))
+
+	// Nothing to compare without a client config
+	assert.NoError(t, os.Remove(clientPath))
+	assert.Empty(t, checkCompanionClient())
+	assert.NoError(t, os.WriteFile(clientPath, []byte("auth: [unclosed\n"), 0o600))
+	assert.Equal(t, map[string]string{"Client Config": checkFail}, statuses(checkCompanionClient()))
+}
+
+func TestBandwidthPairingResult(t *testing.T) {
+	_, ok := bandwidthPairingResult("client.yaml", "100 mbps", "20 mbps", "", "")
+	assert.False(t, ok)
+	_, ok = bandwidthPairingResult("client.yaml", "100 mbps", "20 mbps", "lots", "")
+	assert.False(t, ok)
+
+	r, ok := bandwidthPairingResult("client.yaml", "100 mbps", "20 mbps", "10 mbps", "50 mbps")
+	assert.True(t, ok)
+	assert.Equal(t, checkOK, r.Status)
+	// Copied from the server, or swapped
+	r, _ = bandwidthPairingResult("client.yaml", "100 mbps", "20 mbps", "100 mbps", "20 mbps")
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "swapped")
+	r, _ = bandwidthPairingResult("client.yaml", "100 mbps", "20 mbps", "50 mbps", "10 mbps")
+	assert.Equal(t, checkWarn, r.Status)
+	// Too much, but the right way around
+	r, _ = bandwidthPairingResult("client.yaml", "100 mbps", "20 mbps", "10 mbps", "200 mbps")
+	assert.Equal(t, checkInfo, r.Status)
+	assert.Contains(t, r.Message, "down 200 mbps is above the server's up 100 mbps")
+	// Only one direction set on each side
+	r, _ = bandwidthPairingResult("client.yaml", "100 mbps", "", "", "80 mbps")
+	assert.Equal(t, checkOK, r.Status)
+	r, _ = bandwidthPairingResult("client.yaml", "", "20 mbps", "", "80 mbps")
+	assert.Equal(t, checkOK, r.Status)
+}
@@ -0,0 +1,699 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+func checkConfigReadable() []checkResult {
+	err := viper.ReadInConfig()
+	if err != nil {
+		return []checkResult{{
+			Name:    "Config File",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot read config file: %v", err),
+		}}
+	}
+	return []checkResult{{
+		Name:    "Config File",
+		Status:  checkOK,
+		Message: fmt.Sprintf("Config loaded from: %s", viper.ConfigFileUsed()),
+	}}
+}
+
+func checkAuthConfig() []checkResult {
+	authType := viper.GetString("auth.type")
+	if authType == "" {
+		return []checkResult{{
+			Name:    "Auth",
+			Status:  checkFail,
+			Message: "No auth.type configured. Server requires authentication.",
+		}}
+	}
+
+	switch strings.ToLower(authType) {
+	case "password":
+		if viper.GetString("auth.password") == "" && viper.GetString("auth.passwordFile") == "" && viper.GetString("auth.passwordEnv") == "" {
+			return []checkResult{{
+				Name:    "Auth",
+				Status:  checkFail,
+				Message: "auth.type is 'password' but auth.password is empty.",
+			}}
+		}
+		pw, err := doctorAuthPassword()
+		if err != nil {
+			return []checkResult{{
+				Name:    "Auth",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Cannot resolve the auth password: %v", err),
+			}}
+		}
+		if len(pw) < 8 {
+			return []checkResult{{
+				Name:    "Auth",
+				Status:  checkWarn,
+				Message: "auth.password is very short (< 8 chars). Consider using a stronger password.",
+			}}
+		}
+		source := ""
+		switch {
+		case viper.GetString("auth.passwordFile") != "":
+			source = " (read from auth.passwordFile)"
+		case viper.GetString("auth.passwordEnv") != "":
+			source = " (read from auth.passwordEnv)"
+		case utils.IsEncryptedSecret(viper.GetString("auth.password")):
+			source = " (decrypted)"
+		}
+		return []checkResult{{
+			Name:    "Auth",
+			Status:  checkOK,
+			Message: "Password authentication configured" + source + ".",
+		}}
+	case "userpass":
+		up := viper.GetStringMapString("auth.userpass")
+		if len(up) == 0 {
+			return []checkResult{{
+				Name:    "Auth",
+				Status:  checkFail,
+				Message: "auth.type is 'userpass' but no user:password entries found.",
+			}}
+		}
+		results := []checkResult{{
+			Name:    "Auth",
+			Status:  checkOK,
+			Message: fmt.Sprintf("User/pass authentication configured (%d users).", len(up)),
+		}}
+		for _, users := range duplicateUserPasswords(up) {
+			results = append(results, checkResult{
+				Name:   "Auth",
+				Status: checkWarn,
+				Message: fmt.Sprintf("Users %s share a password: each can log in as the others, so their stats and kicks are unreliable. "+
+					"Give each user their own password. With security.strict the server refuses to start.", strings.Join(users, ", ")),
+			})
+		}
+		return results
+	case "http", "https":
+		url := viper.GetString("auth.http.url")
+		if url == "" {
+			return []checkResult{{
+				Name:    "Auth",
+				Status:  checkFail,
+				Message: "auth.type is 'http' but auth.http.url is empty.",
+			}}
+		}
+		return []checkResult{{
+			Name:    "Auth",
+			Status:  checkOK,
+			Message: fmt.Sprintf("HTTP authentication configured: %s", url),
+		}}
+	default:
+		return []checkResult{{
+			Name:    "Auth",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Authentication type: %s", authType),
+		}}
+	}
+}
+
+func checkDuplicateKeys() []checkResult {
+	cfgPath := viper.ConfigFileUsed()
+	if cfgPath == "" {
+		return nil
+	}
+	switch strings.ToLower(filepath.Ext(cfgPath)) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		return nil // Already reported by checkConfigReadable
+	}
+
+	// Parse into a raw node tree instead of using viper,
+	// which silently keeps only the last value of a duplicate key
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return []checkResult{{
+			Name:    "Duplicate Keys",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot parse config file: %v", err),
+		}}
+	}
+
+	dups := findDuplicateYAMLKeys(&root, "")
+	if len(dups) == 0 {
+		return []checkResult{{
+			Name:    "Duplicate Keys",
+			Status:  checkOK,
+			Message: "No duplicate or shadowed keys found.",
+		}}
+	}
+	results := make([]checkResult, 0, len(dups))
+	for _, d := range dups {
+		lines := make([]string, len(d.Lines))
+		for i, l := range d.Lines {
+			lines[i] = fmt.Sprintf("%d", l)
+		}
+		results = append(results, checkResult{
+			Name:   "Duplicate Keys",
+			Status: checkWarn,
+			Message: fmt.Sprintf("'%s' is defined on lines %s. Only the last one (line %d) takes effect.",
+				d.Path, strings.Join(lines, ", "), d.Lines[len(d.Lines)-1]),
+		})
+	}
+	return results
+}
+
+type yamlDuplicateKey struct {
+	Path  string
+	Lines []int
+}
+
+// findDuplicateYAMLKeys walks the node tree and reports keys defined more than
+// once in the same mapping. Keys are compared case-insensitively, as viper
+// treats "Listen" and "listen" as the same key.
+func findDuplicateYAMLKeys(n *yaml.Node, prefix string) []yamlDuplicateKey {
+	var dups []yamlDuplicateKey
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			dups = append(dups, findDuplicateYAMLKeys(c, prefix)...)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			dups = append(dups, findDuplicateYAMLKeys(c, fmt.Sprintf("%s[%d]", prefix, i))...)
+		}
+	case yaml.MappingNode:
+		var order []string
+		lines := make(map[string][]int)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			key := strings.ToLower(k.Value)
+			if _, ok := lines[key]; !ok {
+				order = append(order, key)
+			}
+			lines[key] = append(lines[key], k.Line)
+			path := k.Value
+			if prefix != "" {
+				path = prefix + "." + k.Value
+			}
+			dups = append(dups, findDuplicateYAMLKeys(v, path)...)
+		}
+		for _, key := range order {
+			if len(lines[key]) > 1 {
+				path := key
+				if prefix != "" {
+					path = prefix + "." + key
+				}
+				dups = append(dups, yamlDuplicateKey{Path: path, Lines: lines[key]})
+			}
+		}
+	}
+	return dups
+}
+
+func checkAuthFailureBans() []checkResult {
+	maxFailures := viper.GetInt("limits.authFailureBan.maxFailures")
+	if maxFailures <= 0 {
+		return []checkResult{{
+			Name:   "Auth Bans",
+			Status: checkInfo,
+			Message: "Failed logins are logged but never banned. Set limits.authFailureBan.maxFailures " +
+				"to block IPs that keep guessing passwords.",
+		}}
+	}
+	listen := viper.GetString("trafficStats.listen")
+	if listen == "" {
+		return []checkResult{{
+			Name:    "Auth Bans",
+			Status:  checkOK,
+			Message: fmt.Sprintf("IPs are banned after %d failed logins. Set trafficStats.listen to see the current bans.", maxFailures),
+		}}
+	}
+	bans, err := fetchAuthBans(listen, viper.GetString("trafficStats.secret"))
+	if err != nil {
+		return []checkResult{{
+			Name:    "Auth Bans",
+			Status:  checkOK,
+			Message: fmt.Sprintf("IPs are banned after %d failed logins (current bans unavailable: %v).", maxFailures, err),
+		}}
+	}
+	if len(bans) == 0 {
+		return []checkResult{{
+			Name:    "Auth Bans",
+			Status:  checkOK,
+			Message: fmt.Sprintf("IPs are banned after %d failed logins, none banned right now.", maxFailures),
+		}}
+	}
+	ips := make([]string, 0, len(bans))
+	for _, b := range bans {
+		ips = append(ips, b.IP)
+	}
+	return []checkResult{{
+		Name:   "Auth Bans",
+		Status: checkInfo,
+		Message: fmt.Sprintf("%d IP(s) banned right now after %d failed logins: %s.",
+			len(bans), maxFailures, strings.Join(ips, ", ")),
+	}}
+}
+
+// fetchAuthBans asks the running server for its current bans through the
+// traffic stats API.
+func fetchAuthBans(listen, secret string) ([]authBan, error) {
+	c, err := newStatsAPIClient(listen, secret)
+	if err != nil {
+		return nil, err
+	}
+	return c.Bans()
+}
+
+func checkLogTimeZone() []checkResult {
+	zone, offset := time.Now().Zone()
+	switch {
+	case viper.GetBool("log.utc") || logUTC.Load():
+		return []checkResult{{
+			Name:    "Log Time Zone",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Logs are in UTC (system time zone: %s).", zone),
+		}}
+	case offset == 0:
+		return []checkResult{{
+			Name:    "Log Time Zone",
+			Status:  checkOK,
+			Message: fmt.Sprintf("System time zone is %s, logs are in UTC.", zone),
+		}}
+	default:
+		return []checkResult{{
+			Name:   "Log Time Zone",
+			Status: checkInfo,
+			Message: fmt.Sprintf("Logs are in the local time zone %s (UTC%s). With several servers, "+
+				"set log.utc: true on all of them so that their logs line up.", zone, time.Now().Format("-07:00")),
+		}}
+	}
+}
+
+// doctorAuthPassword resolves auth.password like the server does,
+// following auth.passwordFile, auth.passwordEnv and encrypted values.
+func doctorAuthPassword() (string, error) {
+	return resolveSecret("auth.password", viper.GetString("auth.password"),
+		viper.GetString("auth.passwordFile"), viper.GetString("auth.passwordEnv"))
+}
+
+func checkShareablePasswords() []checkResult {
+	var results []checkResult
+	switch strings.ToLower(viper.GetString("auth.type")) {
+	case "password":
+		pw, err := doctorAuthPassword()
+		if err != nil {
+			// Reported by checkAuthConfig
+			break
+		}
+		if reason := uriUnsafePasswordReason(pw); reason != "" {
+			results = append(results, checkResult{
+				Name:    "Share URI",
+				Status:  checkWarn,
+				Message: fmt.Sprintf("auth.password %s. It works on the server but may fail to import from a hysteria2:// URI.", reason),
+			})
+		}
+	case "userpass":
+		up := viper.GetStringMapString("auth.userpass")
+		users := make([]string, 0, len(up))
+		for user := range up {
+			users = append(users, user)
+		}
+		slices.Sort(users)
+		for _, user := range users {
+			if strings.Contains(user, ":") {
+				results = append(results, checkResult{
+					Name:    "Share URI",
+					Status:  checkWarn,
+					Message: fmt.Sprintf("Username '%s' contains ':', which is ambiguous in 'user:pass' share URIs.", user),
+				})
+			}
+			if reason := uriUnsafePasswordReason(up[user]); reason != "" {
+				results = append(results, checkResult{
+					Name:    "Share URI",
+					Status:  checkWarn,
+					Message: fmt.Sprintf("Password of user '%s' %s. It may fail to import from a hysteria2:// URI.", user, reason),
+				})
+			}
+		}
+	default:
+		return nil
+	}
+	if len(results) == 0 {
+		return []checkResult{{
+			Name:    "Share URI",
+			Status:  checkOK,
+			Message: "All passwords can be safely embedded in hysteria2:// URIs.",
+		}}
+	}
+	return results
+}
+
+// uriUnsafePasswordReason returns why a password is likely to be mangled by
+// client apps when shared in a URI, even after percent-encoding, or "" if it's fine.
+func uriUnsafePasswordReason(pw string) string {
+	if !utf8.ValidString(pw) {
+		return "is not valid UTF-8"
+	}
+	if strings.TrimSpace(pw) != pw {
+		return "has leading or trailing whitespace, which many clients trim"
+	}
+	for _, c := range pw {
+		if unicode.IsControl(c) {
+			return "contains control characters"
+		}
+	}
+	return ""
+}
+
+type effectiveConfigEntry struct {
+	Key    string
+	Value  string
+	Source string // "flag", "env", "file" or "default"
+}
+
+// effectiveConfigDefaults are the values used for common keys when they
+// are not set in the config file. Keep in sync with core/server/config.go.
+var effectiveConfigDefaults = []effectiveConfigEntry{
+	{Key: "listen", Value: defaultListenAddr},
+	{Key: "udpIdleTimeout", Value: "60s"},
+	{Key: "quic.initStreamReceiveWindow", Value: "8388608"},
+	{Key: "quic.maxStreamReceiveWindow", Value: "8388608"},
+	{Key: "quic.initConnReceiveWindow", Value: "20971520"},
+	{Key: "quic.maxConnReceiveWindow", Value: "20971520"},
+	{Key: "quic.maxIdleTimeout", Value: "30s"},
+	{Key: "quic.maxIncomingStreams", Value: "1024"},
+	{Key: "quic.initCongestionWindow", Value: "32"},
+	{Key: "masquerade.type", Value: "404"},
+}
+
+func showEffectiveConfig() {
+	var entries []effectiveConfigEntry
+
+	// Global options, which come from flags or environment variables
+	entries = append(entries,
+		flagConfigEntry("log-level", appLogLevelEnv, logLevel),
+		flagConfigEntry("log-format", appLogFormatEnv, logFormat),
+		flagConfigEntry("disable-update-check", appDisableUpdateCheckEnv, fmt.Sprint(disableUpdateCheck)),
+	)
+
+	if err := viper.ReadInConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot read config file: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(viper.ConfigFileUsed())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot read config file: %v\n", err)
+		os.Exit(1)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot parse config file: %v\n", err)
+		os.Exit(1)
+	}
+	fileEntries := flattenYAMLConfig(&root, "", nil)
+	entries = append(entries, fileEntries...)
+
+	set := make(map[string]bool, len(fileEntries))
+	for _, e := range fileEntries {
+		set[strings.ToLower(e.Key)] = true
+	}
+	for _, d := range effectiveConfigDefaults {
+		if !set[strings.ToLower(d.Key)] {
+			entries = append(entries, effectiveConfigEntry{Key: d.Key, Value: d.Value, Source: "default"})
+		}
+	}
+	if viper.IsSet("acme") && !set["acme.dir"] {
+		source := "default"
+		if os.Getenv(appACMEDirEnv) != "" {
+			source = "env " + appACMEDirEnv
+		}
+		entries = append(entries, effectiveConfigEntry{Key: "acme.dir", Value: envOrDefaultString(appACMEDirEnv, "acme"), Source: source})
+	}
+	if viper.IsSet("acme") && !set["acme.renewbefore"] {
+		entries = append(entries, effectiveConfigEntry{Key: "acme.renewBefore", Value: acmeDefaultRenewBefore.String(), Source: "default"})
+	}
+
+	fmt.Printf("# Effective configuration (%s), secrets redacted\n", viper.ConfigFileUsed())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t# %s\n", e.Key, e.Value, e.Source)
+	}
+	_ = w.Flush()
+}
+
+// flagConfigEntry reports a global flag's value and whether it was set
+// on the command line, through its environment variable, or defaulted.
+func flagConfigEntry(flag, env, value string) effectiveConfigEntry {
+	source := "default"
+	if f := rootCmd.PersistentFlags().Lookup(flag); f != nil && f.Changed {
+		source = "flag --" + flag
+	} else if os.Getenv(env) != "" {
+		source = "env " + env
+	}
+	return effectiveConfigEntry{Key: flag, Value: value, Source: source}
+}
+
+// flattenYAMLConfig turns a config node tree into dotted keys with their
+// scalar values. When a key is repeated only the last one is kept, like
+// viper does. Secrets are redacted.
+func flattenYAMLConfig(n *yaml.Node, prefix string, entries []effectiveConfigEntry) []effectiveConfigEntry {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			entries = flattenYAMLConfig(c, prefix, entries)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			entries = flattenYAMLConfig(c, fmt.Sprintf("%s[%d]", prefix, i), entries)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			path := k.Value
+			if prefix != "" {
+				path = prefix + "." + k.Value
+			}
+			entries = slices.DeleteFunc(entries, func(e effectiveConfigEntry) bool {
+				lk, lp := strings.ToLower(e.Key), strings.ToLower(path)
+				return lk == lp || strings.HasPrefix(lk, lp+".") || strings.HasPrefix(lk, lp+"[")
+			})
+			entries = flattenYAMLConfig(v, path, entries)
+		}
+	case yaml.ScalarNode:
+		value := n.Value
+		if isSecretConfigKey(prefix) && value != "" {
+			value = "<redacted>"
+		}
+		entries = append(entries, effectiveConfigEntry{Key: prefix, Value: value, Source: "file"})
+	case yaml.AliasNode:
+		entries = flattenYAMLConfig(n.Alias, prefix, entries)
+	}
+	return entries
+}
+
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	if strings.HasPrefix(key, "auth.userpass.") || strings.HasPrefix(key, "acme.dns.config.") {
+		return true
+	}
+	last := key[strings.LastIndex(key, ".")+1:]
+	for _, s := range []string{"password", "secret", "token"} {
+		if strings.Contains(last, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkQuota() []checkResult {
+	var quota serverConfigQuota
+	if err := viper.UnmarshalKey("limits.quota", &quota); err != nil {
+		return []checkResult{{
+			Name:    "Data Quotas",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot parse limits.quota: %v", err),
+		}}
+	}
+	if !quota.Enabled() {
+		return nil
+	}
+	return quotaResults(quota, viper.GetString("auth.type"), viper.GetStringMapString("auth.userpass"))
+}
+
+// quotaResults checks that the quotas parse, that the quota file can be read,
+// and that the users with a quota can log in by that name.
+func quotaResults(quota serverConfigQuota, authType string, userpass map[string]string) []checkResult {
+	t, err := newQuotaTracker(quota)
+	if err != nil {
+		return []checkResult{{
+			Name:    "Data Quotas",
+			Status:  checkFail,
+			Message: fmt.Sprintf("%v. The server will refuse to start.", err),
+		}}
+	}
+	var results []checkResult
+	if strings.EqualFold(authType, "userpass") {
+		var unknown []string
+		for user := range t.Users {
+			if _, ok := userpass[user]; !ok {
+				unknown = append(unknown, user)
+			}
+		}
+		if len(unknown) > 0 {
+			slices.Sort(unknown)
+			results = append(results, checkResult{
+				Name:    "Data Quotas",
+				Status:  checkWarn,
+				Message: fmt.Sprintf("limits.quota.users has users that aren't in auth.userpass: %s. Their quota is never used.", strings.Join(unknown, ", ")),
+			})
+		}
+	}
+	def := "unlimited"
+	if t.Default > 0 {
+		def = formatQuotaBytes(t.Default)
+	}
+	results = append(results, checkResult{
+		Name:   "Data Quotas",
+		Status: checkOK,
+		Message: fmt.Sprintf("%d user quota(s), %s for everyone else, reset on day %d of each month, the cycle started %s.",
+			len(t.Users), def, t.ResetDay, t.period.Format(time.DateOnly)),
+	})
+	return results
+}
+
+func checkListeners() []checkResult {
+	var listeners []serverConfigListener
+	if err := viper.UnmarshalKey("listeners", &listeners); err != nil {
+		return []checkResult{{
+			Name:    "Listeners",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot parse listeners: %v", err),
+		}}
+	}
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	mainListen := viper.GetString("listen")
+	if mainListen == "" {
+		mainListen = defaultListenAddr
+	}
+	ports := map[int]string{extractPortFromAddr(mainListen): "listen"}
+
+	var results []checkResult
+	for i, l := range listeners {
+		name := l.Name
+		if name == "" {
+			name = fmt.Sprintf("listeners[%d]", i)
+		}
+		fail := func(msg string) {
+			results = append(results, checkResult{
+				Name:    "Listener " + name,
+				Status:  checkFail,
+				Message: msg,
+			})
+		}
+		if l.Listen == "" {
+			fail("listen address is empty.")
+			continue
+		}
+		bw, err := parseServerBandwidth(l.Bandwidth, fmt.Sprintf("listeners[%d].bandwidth", i))
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		port := extractPortFromAddr(l.Listen)
+		if other, ok := ports[port]; ok {
+			fail(fmt.Sprintf("port %d is already used by %s.", port, other))
+			continue
+		}
+		ports[port] = name
+		uAddr, err := net.ResolveUDPAddr("udp", l.Listen)
+		if err != nil {
+			fail(fmt.Sprintf("invalid listen address '%s': %v", l.Listen, err))
+			continue
+		}
+		conn, err := net.ListenUDP("udp", uAddr)
+		if err != nil {
+			fail(fmt.Sprintf("cannot bind UDP %s: %v", l.Listen, err))
+			continue
+		}
+		conn.Close()
+
+		limits := "unlimited"
+		switch {
+		case l.Bandwidth.Up == "" && l.Bandwidth.Down == "":
+			limits = "same as the main listener"
+		case bw.MaxTx != 0 || bw.MaxRx != 0:
+			limits = fmt.Sprintf("up %s, down %s", orUnlimited(l.Bandwidth.Up), orUnlimited(l.Bandwidth.Down))
+		}
+		results = append(results, checkResult{
+			Name:    "Listener " + name,
+			Status:  checkOK,
+			Message: fmt.Sprintf("UDP %s is available, bandwidth: %s.", l.Listen, limits),
+		})
+	}
+	return results
+}
+
+func checkStrictMode() []checkResult {
+	if viper.ConfigFileUsed() == "" {
+		return nil
+	}
+	var config serverConfig
+	if err := viper.Unmarshal(&config); err != nil {
+		return []checkResult{{
+			Name:    "Strict Mode",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot parse config: %v", err),
+		}}
+	}
+	if !config.Security.Strict {
+		return []checkResult{{
+			Name:    "Strict Mode",
+			Status:  checkOK,
+			Message: "Strict mode is off. Set security.strict: true to refuse insecure settings and unauthenticated clients.",
+		}}
+	}
+	violations := config.strictViolations()
+	if len(violations) == 0 {
+		return []checkResult{{
+			Name:    "Strict Mode",
+			Status:  checkOK,
+			Message: "Strict mode is active, no insecure settings found.",
+		}}
+	}
+	results := make([]checkResult, 0, len(violations))
+	for _, v := range violations {
+		results = append(results, checkResult{
+			Name:    "Strict Mode",
+			Status:  checkFail,
+			Message: fmt.Sprintf("%s: %v. The server will refuse to start.", v.Field, v.Err),
+		})
+	}
+	return results
+}
+
+func orUnlimited(bw string) string {
+	if bw == "" {
+		return "unlimited"
+	}
+	return bw
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestFindDuplicateYAMLKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []yamlDuplicateKey
+	}{
+		{
+			name: "no duplicates",
+			yaml: "listen: :443\nauth:\n  type: password\n  password: hello\n",
+			want: nil,
+		},
+		{
+			name: "top level",
+			yaml: "listen: :443\nauth:\n  type: password\nlisten: :8443\n",
+			want: []yamlDuplicateKey{{Path: "listen", Lines: []int{1, 4}}},
+		},
+		{
+			name: "nested and case-insensitive",
+			yaml: "auth:\n  type: password\n  Type: userpass\n",
+			want: []yamlDuplicateKey{{Path: "auth.type", Lines: []int{2, 3}}},
+		},
+		{
+			name: "inside sequence",
+			yaml: "outbounds:\n  - name: a\n    type: direct\n    name: b\n",
+			want: []yamlDuplicateKey{{Path: "outbounds[0].name", Lines: []int{2, 4}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var root yaml.Node
+			assert.NoError(t, yaml.Unmarshal([]byte(tt.yaml), &root))
+			assert.Equal(t, tt.want, findDuplicateYAMLKeys(&root, ""))
+		})
+	}
+}
+
+func TestFlattenYAMLConfig(t *testing.T) {
+	data := `listen: :8443
+auth:
+  type: userpass
+  userpass:
+    alice: secret1
+obfs:
+  salamander:
+    password: hunter2
+acme:
+  domains:
+    - a.example.com
+    - b.example.com
+listen: :9443
+`
+	var root yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(data), &root))
+	assert.Equal(t, []effectiveConfigEntry{
+		{Key: "auth.type", Value: "userpass", Source: "file"},
+		{Key: "auth.userpass.alice", Value: "<redacted>", Source: "file"},
+		{Key: "obfs.salamander.password", Value: "<redacted>", Source: "file"},
+		{Key: "acme.domains[0]", Value: "a.example.com", Source: "file"},
+		{Key: "acme.domains[1]", Value: "b.example.com", Source: "file"},
+		{Key: "listen", Value: ":9443", Source: "file"},
+	}, flattenYAMLConfig(&root, "", nil))
+}
+
+func TestURIUnsafePasswordReason(t *testing.T) {
+	tests := []struct {
+		pw   string
+		want string
+	}{
+		{"weak_ahh_password", ""},
+		{"p@ss:w/rd?#&=%", ""}, // Percent-encoded in the URI
+		{"пароль密码", ""},
+		{" weak_ahh_password", "whitespace"},
+		{"weak_ahh_password\n", "whitespace"},
+		{"weak\tahh", "control characters"},
+		{"weak\x00ahh", "control characters"},
+		{"weak\xffahh", "UTF-8"},
+	}
+	for _, tt := range tests {
+		got := uriUnsafePasswordReason(tt.pw)
+		if tt.want == "" {
+			assert.Empty(t, got, "%q", tt.pw)
+		} else {
+			assert.Contains(t, got, tt.want, "%q", tt.pw)
+		}
+	}
+}
+
+func TestCheckShareablePasswords(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("auth.type", "password")
+	viper.Set("auth.password", "p@ss:w/rd?#")
+	r := checkShareablePasswords()
+	assert.Len(t, r, 1)
+	assert.Equal(t, checkOK, r[0].Status)
+
+	viper.Set("auth.password", "weak_ahh_password ")
+	r = checkShareablePasswords()
+	assert.Len(t, r, 1)
+	assert.Equal(t, checkWarn, r[0].Status)
+	assert.Contains(t, r[0].Message, "auth.password has leading or trailing whitespace")
+
+	viper.Reset()
+	viper.Set("auth.type", "userpass")
+	viper.Set("auth.userpass", map[string]string{"ali": "pw1", "zoe": "pw\x01", "a:b": "pw3"})
+	r = checkShareablePasswords()
+	assert.Len(t, r, 2)
+	assert.Contains(t, r[0].Message, "Username 'a:b'")
+	assert.Contains(t, r[1].Message, "Password of user 'zoe' contains control characters")
+
+	viper.Reset()
+	viper.Set("auth.type", "http")
+	assert.Empty(t, checkShareablePasswords())
+}
+
+func TestQuotaResults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quota.json")
+	userpass := map[string]string{"alice": "alice_password", "bob": "bob_password"}
+
+	results := quotaResults(serverConfigQuota{Default: "50GB", Users: map[string]string{"alice": "100GB"}, File: file}, "userpass", userpass)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, checkOK, results[0].Status)
+		assert.Contains(t, results[0].Message, "50.0GB for everyone else")
+	}
+
+	results = quotaResults(serverConfigQuota{Users: map[string]string{"carol": "10GB"}, File: file}, "userpass", userpass)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, checkWarn, results[0].Status)
+		assert.Contains(t, results[0].Message, "carol")
+	}
+
+	results = quotaResults(serverConfigQuota{Default: "50 gigs", File: file}, "userpass", userpass)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, checkFail, results[0].Status)
+		assert.Contains(t, results[0].Message, "limits.quota.default")
+	}
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// doctorSysctlConf is where --fix saves the sysctls it sets, the same
+	// file as the tuning script in docs/libya_tuning.md
+	doctorSysctlConf = "/etc/sysctl.d/99-libyalink.conf"
+
+	// fixedUDPBuffer is what --fix sets the buffer limits to, the tuning
+	// script's value, twice what the server asks for
+	fixedUDPBuffer = 16 * 1024 * 1024
+)
+
+// doctorFixes are the checks --fix knows how to fix when they warn or fail,
+// by name. The others are left alone.
+var doctorFixes = map[string]func() (string, error){
+	"UDP rmem_max": func() (string, error) {
+		return fixSysctl("/proc/sys", doctorSysctlConf, "net.core.rmem_max", fixedUDPBuffer)
+	},
+	"UDP wmem_max": func() (string, error) {
+		return fixSysctl("/proc/sys", doctorSysctlConf, "net.core.wmem_max", fixedUDPBuffer)
+	},
+}
+
+// fixableChecks runs the checks that have a fix in doctorFixes.
+func fixableChecks() []checkResult {
+	return checkUDPBuffers()
+}
+
+// runDoctorFixes fixes what it can of results and says what it changed.
+// It returns the number of fixes tried and of those that failed.
+func runDoctorFixes(w io.Writer, results []checkResult) (tried, failed int) {
+	for _, r := range results {
+		fix, ok := doctorFixes[r.Name]
+		if !ok || (r.Status != checkWarn && r.Status != checkFail) {
+			continue
+		}
+		tried++
+		msg, err := fix()
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "  %s  [%s] %v\n", checkFail, r.Name, err)
+			continue
+		}
+		fmt.Fprintf(w, "  🔧  [%s] %s\n", r.Name, msg)
+	}
+	return tried, failed
+}
+
+// fixSysctl sets key to value under procRoot, normally /proc/sys, and saves
+// it in conf so that it survives a reboot.
+func fixSysctl(procRoot, conf, key string, value int) (string, error) {
+	path := filepath.Join(procRoot, strings.ReplaceAll(key, ".", "/"))
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", key, err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(value)), 0o644); err != nil {
+		return "", fixError(key, err)
+	}
+	data, err := os.ReadFile(conf)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", fixError(conf, err)
+	}
+	if err := os.WriteFile(conf, []byte(setSysctlLine(string(data), key, value)), 0o644); err != nil {
+		return "", fmt.Errorf("%s set to %d until the next reboot, but %w", key, value, fixError(conf, err))
+	}
+	return fmt.Sprintf("%s set to %d (was %s), and saved in %s.", key, value, strings.TrimSpace(string(old)), conf), nil
+}
+
+// fixError explains why what could not be written.
+func fixError(what string, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("cannot write %s: permission denied, re-run with sudo", what)
+	case errors.Is(err, syscall.EROFS):
+		return fmt.Errorf("cannot write %s: read-only, e.g. in a container; set it on the host instead", what)
+	default:
+		return fmt.Errorf("cannot write %s: %w", what, err)
+	}
+}
+
+// setSysctlLine sets key to value in the sysctl.conf contents conf,
+// replacing the line that sets it, or adding one.
+func setSysctlLine(conf, key string, value int) string {
+	line := fmt.Sprintf("%s = %d", key, value)
+	lines := strings.Split(strings.TrimSuffix(conf, "\n"), "\n")
+	if conf == "" {
+		lines = nil
+	}
+	found := false
+	for i, l := range lines {
+		k, _, ok := strings.Cut(l, "=")
+		if ok && strings.TrimSpace(k) == key {
+			if found {
+				// A duplicate, the last one would win
+				lines[i] = "# " + l
+				continue
+			}
+			lines[i] = line
+			found = true
+		}
+	}
+	if !found {
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetSysctlLine(t *testing.T) {
+	assert.Equal(t, "net.core.rmem_max = 16777216\n", setSysctlLine("", "net.core.rmem_max", 16777216))
+	assert.Equal(t, "# Tuning\nnet.core.wmem_max = 1\nnet.core.rmem_max = 16777216\n",
+		setSysctlLine("# Tuning\nnet.core.wmem_max = 1\n", "net.core.rmem_max", 16777216))
+	assert.Equal(t, "net.core.rmem_max = 16777216\nnet.core.wmem_max = 1\n# net.core.rmem_max=4096\n",
+		setSysctlLine("net.core.rmem_max=212992\nnet.core.wmem_max = 1\nnet.core.rmem_max=4096", "net.core.rmem_max", 16777216))
+}
+
+func TestFixSysctl(t *testing.T) {
+	dir := t.TempDir()
+	procRoot := filepath.Join(dir, "proc")
+	assert.NoError(t, os.MkdirAll(filepath.Join(procRoot, "net", "core"), 0o755))
+	procFile := filepath.Join(procRoot, "net", "core", "rmem_max")
+	assert.NoError(t, os.WriteFile(procFile, []byte("212992\n"), 0o644))
+	conf := filepath.Join(dir, "99-libyalink.conf")
+
+	msg, err := fixSysctl(procRoot, conf, "net.core.rmem_max", 16777216)
+	assert.NoError(t, err)
+	assert.Contains(t, msg, "set to 16777216 (was 212992)")
+	bs, _ := os.ReadFile(procFile)
+	assert.Equal(t, "16777216", string(bs))
+	bs, _ = os.ReadFile(conf)
+	assert.Equal(t, "net.core.rmem_max = 16777216\n", string(bs))
+
+	_, err = fixSysctl(procRoot, conf, "net.core.wmem_max", 16777216)
+	assert.ErrorContains(t, err, "cannot read net.core.wmem_max")
+
+	assert.ErrorContains(t, fixError("net.core.rmem_max", os.ErrPermission), "re-run with sudo")
+}
+
+func TestRunDoctorFixes(t *testing.T) {
+	oldFixes := doctorFixes
+	defer func() { doctorFixes = oldFixes }()
+	var fixed []string
+	doctorFixes = map[string]func() (string, error){
+		"UDP rmem_max": func() (string, error) {
+			fixed = append(fixed, "rmem")
+			return "net.core.rmem_max set", nil
+		},
+		"UDP wmem_max": func() (string, error) {
+			fixed = append(fixed, "wmem")
+			return "", errors.New("cannot write net.core.wmem_max: permission denied, re-run with sudo")
+		},
+	}
+
+	var buf bytes.Buffer
+	tried, failed := runDoctorFixes(&buf, []checkResult{
+		{Name: "UDP rmem_max", Status: checkWarn},
+		{Name: "UDP wmem_max", Status: checkWarn},
+		{Name: "Thread Limits", Status: checkWarn},
+	})
+	assert.Equal(t, 2, tried)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, []string{"rmem", "wmem"}, fixed)
+	assert.Contains(t, buf.String(), "[UDP rmem_max] net.core.rmem_max set")
+	assert.Contains(t, buf.String(), "re-run with sudo")
+	assert.NotContains(t, buf.String(), "Thread Limits")
+
+	// Checks that pass are left alone
+	fixed = nil
+	tried, _ = runDoctorFixes(&buf, []checkResult{{Name: "UDP rmem_max", Status: checkOK}})
+	assert.Equal(t, 0, tried)
+	assert.Empty(t, fixed)
+}
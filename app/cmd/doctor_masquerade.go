@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func checkMasquerade() []checkResult {
+	masqType := strings.ToLower(viper.GetString("masquerade.type"))
+	switch masqType {
+	case "", "404":
+		return []checkResult{{
+			Name:    "Masquerade",
+			Status:  checkOK,
+			Message: "Default 404 masquerade. Consider 'string' or 'proxy' to look like a real website.",
+		}}
+	case "file":
+		dir := viper.GetString("masquerade.file.dir")
+		if dir == "" {
+			return []checkResult{{
+				Name:    "Masquerade",
+				Status:  checkFail,
+				Message: "masquerade.type is 'file' but masquerade.file.dir is empty.",
+			}}
+		}
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return []checkResult{{
+				Name:    "Masquerade",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Cannot access masquerade directory %s: %v", dir, err),
+			}}
+		}
+		if !fi.IsDir() {
+			return []checkResult{{
+				Name:    "Masquerade",
+				Status:  checkFail,
+				Message: fmt.Sprintf("masquerade.file.dir is not a directory: %s", dir),
+			}}
+		}
+		return []checkResult{{
+			Name:    "Masquerade",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Serving files from: %s", dir),
+		}}
+	case "string":
+		content := viper.GetString("masquerade.string.content")
+		file := viper.GetString("masquerade.string.file")
+		if content != "" && file != "" {
+			return []checkResult{{
+				Name:    "Masquerade",
+				Status:  checkFail,
+				Message: "Both masquerade.string.content and masquerade.string.file are set. Use one or the other.",
+			}}
+		}
+		if file != "" {
+			return []checkResult{checkFileReadable("Masquerade", file)}
+		}
+		if content == "" {
+			return []checkResult{{
+				Name:    "Masquerade",
+				Status:  checkFail,
+				Message: "masquerade.type is 'string' but neither content nor file is set.",
+			}}
+		}
+		return []checkResult{{
+			Name:    "Masquerade",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Serving inline content (%d bytes).", len(content)),
+		}}
+	case "proxy":
+		proxyURL := viper.GetString("masquerade.proxy.url")
+		if proxyURL == "" {
+			return []checkResult{{
+				Name:    "Masquerade",
+				Status:  checkFail,
+				Message: "masquerade.type is 'proxy' but masquerade.proxy.url is empty.",
+			}}
+		}
+		return []checkResult{checkMasqueradeProxyURL(proxyURL)}
+	default:
+		return []checkResult{{
+			Name:    "Masquerade",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Unsupported masquerade type: %s", masqType),
+		}}
+	}
+}
+
+// checkMasqueradeProxyURL reports the scheme of the proxy masquerade's
+// backend. The front always speaks TLS, so a plain HTTP backend gives
+// itself away, e.g. by redirecting to https:// or sending http:// links.
+func checkMasqueradeProxyURL(proxyURL string) checkResult {
+	u, err := url.Parse(proxyURL)
+	if err != nil || u.Host == "" {
+		return checkResult{
+			Name:    "Masquerade",
+			Status:  checkFail,
+			Message: fmt.Sprintf("masquerade.proxy.url is not a valid URL: %s", proxyURL),
+		}
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		return checkResult{
+			Name:    "Masquerade",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Proxying to: %s (https backend)", proxyURL),
+		}
+	case "http":
+		return checkResult{
+			Name:   "Masquerade",
+			Status: checkWarn,
+			Message: fmt.Sprintf("Proxying to: %s (plain http backend). The server presents TLS, but the site "+
+				"behind it may redirect to https or link to http:// pages, which a probe can notice. "+
+				"Use the site's https:// URL.", proxyURL),
+		}
+	default:
+		return checkResult{
+			Name:    "Masquerade",
+			Status:  checkFail,
+			Message: fmt.Sprintf("masquerade.proxy.url has an unsupported scheme %q, use https.", u.Scheme),
+		}
+	}
+}
+
+func checkMasqueradeCert() []checkResult {
+	if !viper.IsSet("masquerade") || !viper.IsSet("tls") {
+		return nil // ACME certs are always CA-issued
+	}
+	certPath := viper.GetString("tls.cert")
+	if certPath == "" {
+		return nil // Reported by checkTLSFiles
+	}
+	cert, err := loadLeafCertificate(certPath)
+	if err != nil {
+		return nil // Reported by checkTLSFiles
+	}
+	issuer := cert.Issuer.String()
+	if !isSelfSignedCertificate(cert) {
+		return []checkResult{{
+			Name:    "Masquerade Cert",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Certificate issued by %s.", issuer),
+		}}
+	}
+	return []checkResult{{
+		Name:   "Masquerade Cert",
+		Status: checkWarn,
+		Message: fmt.Sprintf("Certificate is self-signed (issuer %s). A browser or censor probing the masquerade "+
+			"site gets a certificate warning, which gives the server away. Use a real certificate (e.g. acme) for convincing camouflage.", issuer),
+	}}
+}
+
+func checkCamouflage() []checkResult {
+	obfs := strings.ToLower(viper.GetString("obfs.type")) == "salamander"
+	return []checkResult{camouflageResult(obfs,
+		strings.ToLower(viper.GetString("masquerade.type")),
+		viper.GetString("masquerade.listenHTTPS") != "")}
+}
+
+// camouflageResult lists the active camouflage features and reports the
+// combinations that work against each other. obfs is whether Salamander is
+// on, masqType the configured masquerade type ("" for the default 404), tcp
+// whether the masquerade also listens on TCP.
+func camouflageResult(obfs bool, masqType string, tcp bool) checkResult {
+	r := checkResult{Name: "Camouflage"}
+	var active []string
+	if obfs {
+		active = append(active, "Salamander obfs")
+	} else if masqType == "" {
+		active = append(active, "default 404 masquerade on HTTP/3")
+	} else {
+		active = append(active, fmt.Sprintf("%s masquerade on HTTP/3", masqType))
+	}
+	if tcp {
+		active = append(active, "masquerade on TCP (listenHTTP/listenHTTPS)")
+	}
+	r.Message = "Active: " + strings.Join(active, ", ") + "."
+	switch {
+	case obfs && tcp:
+		r.Status = checkWarn
+		r.Message += " The TCP site advertises HTTP/3 on the listen port (Alt-Svc), but with obfs the port only answers " +
+			"clients with the obfs password, so a browser or probe following it gets nothing back, which looks neither " +
+			"like a website nor like plain UDP. Use one or the other: remove obfs so the port serves the site over " +
+			"HTTP/3 too, or remove masquerade.listenHTTP/listenHTTPS."
+	case obfs && masqType != "":
+		r.Status = checkWarn
+		r.Message += fmt.Sprintf(" masquerade.type is %q, but with obfs only clients with the obfs password get through "+
+			"to QUIC, so nobody ever sees the masquerade site. Remove the masquerade section, or remove obfs for the "+
+			"port to look like a website.", masqType)
+	case obfs:
+		r.Status = checkOK
+		r.Message += " The port looks like random UDP and doesn't answer probes without the obfs password."
+	case masqType == "":
+		r.Status = checkInfo
+		r.Message += " The port answers like an HTTP/3 server, see Masquerade."
+	case !tcp:
+		r.Status = checkOK
+		r.Message += " The port answers HTTP/3 like a website. Real websites also serve TCP, " +
+			"set masquerade.listenHTTPS to do the same."
+	default:
+		r.Status = checkOK
+		r.Message += " The server answers HTTP/3 and HTTPS like a website."
+	}
+	return r
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckMasqueradeProxyURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://news.ycombinator.com/", checkOK},
+		{"HTTPS://example.com", checkOK},
+		{"http://example.com", checkWarn},
+		{"ftp://example.com", checkFail},
+		{"example.com", checkFail},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, checkMasqueradeProxyURL(tt.url).Status, tt.url)
+	}
+}
+
+func TestCamouflageResult(t *testing.T) {
+	tests := []struct {
+		obfs     bool
+		masqType string
+		tcp      bool
+		status   string
+		want     string
+	}{
+		{false, "", false, checkInfo, "Active: default 404 masquerade on HTTP/3."},
+		{false, "proxy", false, checkOK, "set masquerade.listenHTTPS"},
+		{false, "proxy", true, checkOK, "Active: proxy masquerade on HTTP/3, masquerade on TCP"},
+		{true, "", false, checkOK, "Active: Salamander obfs. The port looks like random UDP"},
+		{true, "string", false, checkWarn, "nobody ever sees the masquerade site"},
+		{true, "proxy", true, checkWarn, "Active: Salamander obfs, masquerade on TCP"},
+	}
+	for _, tt := range tests {
+		r := camouflageResult(tt.obfs, tt.masqType, tt.tcp)
+		assert.Equal(t, "Camouflage", r.Name)
+		assert.Equal(t, tt.status, r.Status, tt.want)
+		assert.Contains(t, r.Message, tt.want)
+	}
+}
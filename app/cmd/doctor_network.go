@@ -0,0 +1,605 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func checkPortAvailability() []checkResult {
+	listenAddr := viper.GetString("listen")
+	if listenAddr == "" {
+		listenAddr = defaultListenAddr
+	}
+
+	var results []checkResult
+
+	// Check UDP port (primary for QUIC)
+	udpAddr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		results = append(results, checkResult{
+			Name:    "UDP Port",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Invalid listen address '%s': %v", listenAddr, err),
+		})
+		return results
+	}
+
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		errStr := err.Error()
+		if strings.Contains(errStr, "address already in use") ||
+			strings.Contains(errStr, "Only one usage of each socket address") {
+			results = append(results, checkResult{
+				Name:    "UDP Port",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Port %s is already in use! Another process (Apache/Nginx/Hysteria?) is binding it.", listenAddr),
+			})
+		} else if strings.Contains(errStr, "permission denied") ||
+			strings.Contains(errStr, "bind: permission denied") {
+			results = append(results, checkResult{
+				Name:    "UDP Port",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Permission denied binding to %s. Use a port > 1024 or run with elevated privileges.", listenAddr),
+			})
+		} else {
+			results = append(results, checkResult{
+				Name:    "UDP Port",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Cannot bind UDP %s: %v", listenAddr, err),
+			})
+		}
+	} else {
+		conn.Close()
+		results = append(results, checkResult{
+			Name:    "UDP Port",
+			Status:  checkOK,
+			Message: fmt.Sprintf("UDP %s is available.", listenAddr),
+		})
+	}
+
+	// Hysteria itself is UDP only, the TCP ports belong to the masquerade
+	// HTTP/HTTPS servers that make the server look like a normal website
+	for _, key := range []string{"masquerade.listenHTTP", "masquerade.listenHTTPS"} {
+		addr := viper.GetString(key)
+		if addr == "" {
+			continue
+		}
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			results = append(results, checkResult{
+				Name:    "TCP Port",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Cannot bind TCP %s (%s): %v", addr, key, err),
+			})
+			continue
+		}
+		ln.Close()
+		results = append(results, checkResult{
+			Name:    "TCP Port",
+			Status:  checkOK,
+			Message: fmt.Sprintf("TCP %s (%s) is available.", addr, key),
+		})
+	}
+
+	return results
+}
+
+// checkIPv6 tries to listen on the UDP port over IPv6 on its own, as the
+// port check above is satisfied by IPv4 alone.
+func checkIPv6() []checkResult {
+	listenAddr := viper.GetString("listen")
+	if listenAddr == "" {
+		listenAddr = defaultListenAddr
+	}
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return nil // Reported by checkPortAvailability
+	}
+	ip := net.ParseIP(host)
+	if host != "" && ip == nil {
+		return nil // A host name, whichever family it resolves to
+	}
+	if ip != nil && ip.To4() != nil {
+		return []checkResult{ipv6Result(host, port, nil, false, nil)}
+	}
+	if host == "" {
+		host = "::"
+	}
+	conn, bindErr := net.ListenPacket("udp6", net.JoinHostPort(host, port))
+	if bindErr == nil {
+		conn.Close()
+	}
+	disabled := false
+	if bs, err := os.ReadFile("/proc/sys/net/ipv6/conf/all/disable_ipv6"); err == nil {
+		disabled = strings.TrimSpace(string(bs)) == "1"
+	}
+	var global []net.IP
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.To4() == nil && n.IP.IsGlobalUnicast() && !n.IP.IsPrivate() {
+				global = append(global, n.IP)
+			}
+		}
+	}
+	return []checkResult{ipv6Result(host, port, bindErr, disabled, global)}
+}
+
+// ipv6Result rates listening on host over IPv6, with bindErr the result of
+// trying, disabled whether IPv6 is turned off on the machine and global its
+// public IPv6 addresses. An IPv4 host is only noted.
+func ipv6Result(host, port string, bindErr error, disabled bool, global []net.IP) checkResult {
+	r := checkResult{Name: "IPv6"}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		r.Status = checkInfo
+		r.Message = fmt.Sprintf("Listening on IPv4 only (%s). Clients that connect over IPv6, e.g. when the domain has an AAAA record, "+
+			"can't reach the server; listen on :%s for both.", host, port)
+		return r
+	}
+	switch {
+	case disabled || errors.Is(bindErr, syscall.EAFNOSUPPORT):
+		r.Status = checkWarn
+		r.Message = "IPv6 is disabled on this machine, only IPv4 clients can connect. Remove any AAAA record of the " +
+			"server's domain, or turn IPv6 on (sysctl net.ipv6.conf.all.disable_ipv6=0) if the host provides it."
+	case errors.Is(bindErr, syscall.EADDRINUSE):
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("UDP [%s]:%s is already in use over IPv6 by another process.", host, port)
+	case bindErr != nil:
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("Cannot listen on UDP [%s]:%s over IPv6: %v", host, port, bindErr)
+	case len(global) == 0:
+		r.Status = checkInfo
+		r.Message = fmt.Sprintf("UDP [%s]:%s works over IPv6, but this machine has no public IPv6 address, so clients connect over IPv4.", host, port)
+	default:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("UDP [%s]:%s is available over IPv6, public address %s. Make sure the firewall allows the port "+
+			"over IPv6 too (ip6tables, or IPV6=yes for ufw).", host, port, global[0])
+	}
+	return r
+}
+
+// cgnatNet is the shared address space of RFC 6598, which ISPs use
+// behind carrier-grade NAT.
+var cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0).To4(), Mask: net.CIDRMask(10, 32)}
+
+// checkPublicAddress warns when the address the server listens on, or the
+// machine's primary address if it listens on all of them, is a private or
+// CGNAT one that clients on the internet can't reach.
+func checkPublicAddress() []checkResult {
+	listenAddr := viper.GetString("listen")
+	if listenAddr == "" {
+		listenAddr = defaultListenAddr
+	}
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		// Reported by checkPortAvailability
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() {
+		ip, err = primaryIP()
+		if err != nil {
+			return []checkResult{{Name: "Public Address", Status: checkInfo,
+				Message: fmt.Sprintf("Cannot find the primary address, no default route? (%v)", err)}}
+		}
+	}
+	if ip.IsLoopback() {
+		// Not meant to be public, e.g. behind a local relay
+		return nil
+	}
+
+	// A domain that resolves to a public address means a known mapping,
+	// e.g. a router forwarding the port
+	var mapped []net.IP
+	for _, domain := range viper.GetStringSlice("acme.domains") {
+		addrs, err := net.LookupIP(domain)
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if addressClass(a) == "" {
+				mapped = append(mapped, a)
+			}
+		}
+	}
+	return []checkResult{publicAddressResult(ip, port, mapped)}
+}
+
+// primaryIP returns the address of the default route. Nothing is sent,
+// connecting a UDP socket only picks the route.
+func primaryIP() (net.IP, error) {
+	conn, err := net.Dial("udp", "1.1.1.1:53")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// addressClass returns what kind of non-public address ip is, or "" for a
+// public one.
+func addressClass(ip net.IP) string {
+	switch {
+	case cgnatNet.Contains(ip):
+		return "CGNAT"
+	case ip.IsPrivate():
+		return "private"
+	case ip.IsLinkLocalUnicast():
+		return "link-local"
+	case ip.IsLoopback():
+		return "loopback"
+	default:
+		return ""
+	}
+}
+
+func publicAddressResult(ip net.IP, port string, mapped []net.IP) checkResult {
+	r := checkResult{Name: "Public Address"}
+	class := addressClass(ip)
+	switch {
+	case class == "":
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("Server address %s is public", ip)
+	case len(mapped) > 0:
+		r.Status = checkInfo
+		r.Message = fmt.Sprintf("Server address %s is %s, but the ACME domain resolves to %s. "+
+			"Make sure UDP port %s there is forwarded to this machine.", ip, class, mapped[0], port)
+	case class == "CGNAT":
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("Server address %s is in the carrier-grade NAT range 100.64.0.0/10: "+
+			"the ISP shares its public address with other customers, and clients on the internet can't reach this machine. "+
+			"Use a VPS or a line with a public IP.", ip)
+	default:
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("Server address %s is %s: clients on the internet can only reach it if the router "+
+			"forwards UDP port %s to it, and only if the router itself has a public IP.", ip, class, port)
+	}
+	return r
+}
+
+func checkQUICConfig() []checkResult {
+	var results []checkResult
+
+	if viper.IsSet("quic.initCongestionWindow") {
+		cwnd := viper.GetInt("quic.initCongestionWindow")
+		switch {
+		case cwnd < 4 || cwnd > 1000:
+			results = append(results, checkResult{
+				Name:    "QUIC Init CWND",
+				Status:  checkFail,
+				Message: fmt.Sprintf("quic.initCongestionWindow is %d packets, must be between 4 and 1000.", cwnd),
+			})
+		case cwnd > 256:
+			results = append(results, checkResult{
+				Name:    "QUIC Init CWND",
+				Status:  checkWarn,
+				Message: fmt.Sprintf("quic.initCongestionWindow is %d packets. Values this large can cause bursts of loss on slow 4G links.", cwnd),
+			})
+		default:
+			results = append(results, checkResult{
+				Name:    "QUIC Init CWND",
+				Status:  checkOK,
+				Message: fmt.Sprintf("Initial congestion window: %d packets (BBR only).", cwnd),
+			})
+		}
+	}
+
+	if viper.IsSet("quic.cc") {
+		results = append(results, quicCCResult(viper.GetString("quic.cc"), viper.GetString("bandwidth.up"), viper.GetBool("ignoreClientBandwidth")))
+	}
+
+	if viper.IsSet("bandwidth.up") || viper.IsSet("bandwidth.down") {
+		results = append(results, bandwidthDirectionsNote(viper.GetString("bandwidth.up"), viper.GetString("bandwidth.down")))
+	}
+
+	if viper.IsSet("quic.maxDatagramSize") {
+		results = append(results, maxDatagramSizeResult(viper.GetInt("quic.maxDatagramSize")))
+	}
+
+	if viper.IsSet("quic.keepAlivePeriod") {
+		period := viper.GetDuration("quic.keepAlivePeriod")
+		switch {
+		case period < 2*time.Second || period > 60*time.Second:
+			results = append(results, checkResult{
+				Name:    "QUIC Keep-Alive",
+				Status:  checkFail,
+				Message: fmt.Sprintf("quic.keepAlivePeriod is %s, must be between 2s and 60s.", period),
+			})
+		case period > 25*time.Second:
+			results = append(results, checkResult{
+				Name:    "QUIC Keep-Alive",
+				Status:  checkWarn,
+				Message: fmt.Sprintf("quic.keepAlivePeriod is %s. Carrier-grade NAT often drops idle UDP mappings after ~30s, consider 15s.", period),
+			})
+		default:
+			results = append(results, checkResult{
+				Name:    "QUIC Keep-Alive",
+				Status:  checkOK,
+				Message: fmt.Sprintf("Server keep-alive every %s.", period),
+			})
+		}
+	}
+
+	return results
+}
+
+// quicCCResult checks quic.cc against the bandwidth it sends at.
+func quicCCResult(cc, up string, ignoreClientBandwidth bool) checkResult {
+	r := checkResult{Name: "QUIC CC", Status: checkOK}
+	switch strings.ToLower(cc) {
+	case "", "auto":
+		r.Message = "Brutal at the speed each client gives, BBR for the clients that don't (quic.cc: auto)."
+		if ignoreClientBandwidth {
+			r.Message = "BBR for every client, as ignoreClientBandwidth is set (quic.cc: auto)."
+		}
+	case "bbr":
+		r.Message = "BBR for every client (quic.cc: bbr)."
+		if up != "" {
+			r.Status = checkInfo
+			r.Message = "quic.cc is bbr, so bandwidth.up doesn't apply: BBR finds the speed by itself."
+		}
+	case "brutal":
+		switch {
+		case up == "":
+			r.Status = checkWarn
+			r.Message = "quic.cc is brutal but bandwidth.up isn't set, so clients get Brutal at whatever speed they ask for, " +
+				"even above the server's line. Set bandwidth.up to the server's upload speed."
+		case ignoreClientBandwidth:
+			r.Message = fmt.Sprintf("Brutal at bandwidth.up (%s) for every client (quic.cc: brutal).", up)
+		default:
+			r.Message = fmt.Sprintf("Brutal at the speed each client gives, up to bandwidth.up (%s), which the others get (quic.cc: brutal).", up)
+		}
+	default:
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("quic.cc %q isn't supported, use auto, bbr or brutal.", cc)
+	}
+	return r
+}
+
+// mobilePathMTU is the path MTU many mobile networks have, lower than
+// Ethernet's 1500 because of their tunnels.
+const mobilePathMTU = 1400
+
+// maxDatagramSizeResult checks quic.maxDatagramSize, which fixes the size
+// of the server's packets. On the wire they get 28 bytes of IPv4 and UDP
+// headers, or 48 with IPv6.
+func maxDatagramSizeResult(size int) checkResult {
+	r := checkResult{Name: "QUIC Datagram Size"}
+	switch {
+	case size == 0:
+		r.Status = checkOK
+		r.Message = "Path MTU discovery from 1280 bytes (quic.maxDatagramSize: 0)."
+	case size < 1200 || size > 1452:
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("quic.maxDatagramSize is %d bytes, must be between 1200 and 1452.", size)
+	case size+48 > mobilePathMTU:
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("quic.maxDatagramSize is %d bytes, %d on the wire with IPv6, more than the %d-byte path MTU of many mobile networks. "+
+			"Path MTU discovery is off with it, so those clients would stall. Use 1350 or less, or leave it unset.",
+			size, size+48, mobilePathMTU)
+	default:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("Packets of at most %d bytes, path MTU discovery off.", size)
+	}
+	return r
+}
+
+func checkIdleTimeout() []checkResult {
+	if !viper.IsSet("limits.idleTimeout") {
+		return nil
+	}
+	value := viper.GetString("limits.idleTimeout")
+	timeout, err := time.ParseDuration(value)
+	switch {
+	case err != nil:
+		return []checkResult{{
+			Name:    "Idle Timeout",
+			Status:  checkFail,
+			Message: fmt.Sprintf("limits.idleTimeout %q is not a valid duration (e.g. 30m).", value),
+		}}
+	case timeout == 0:
+		return []checkResult{{
+			Name:    "Idle Timeout",
+			Status:  checkOK,
+			Message: "Idle clients are never disconnected.",
+		}}
+	case timeout < 30*time.Second:
+		return []checkResult{{
+			Name:    "Idle Timeout",
+			Status:  checkFail,
+			Message: fmt.Sprintf("limits.idleTimeout is %s, must be 0 (disabled) or at least 30s.", timeout),
+		}}
+	case timeout < 5*time.Minute:
+		return []checkResult{{
+			Name:   "Idle Timeout",
+			Status: checkWarn,
+			Message: fmt.Sprintf("limits.idleTimeout is %s. Users reading a page or with the app in the background "+
+				"will be disconnected often and have to reconnect, consider 10m or more.", timeout),
+		}}
+	default:
+		return []checkResult{{
+			Name:    "Idle Timeout",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Clients without traffic are disconnected after %s.", timeout),
+		}}
+	}
+}
+
+// doctorSTUNServers are asked in turn for the server's public address.
+var doctorSTUNServers = []string{"stun.l.google.com:19302", "stun.cloudflare.com:3478"}
+
+// checkDNSResolution checks that each ACME domain resolves to this server,
+// for both the HTTP and TLS-ALPN challenges and the clients.
+func checkDNSResolution() []checkResult {
+	if !viper.IsSet("acme") || viper.IsSet("tls") {
+		return nil
+	}
+	domains := viper.GetStringSlice("acme.domains")
+	if len(domains) == 0 {
+		return nil // The server config check reports it
+	}
+	var own []net.IP
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok {
+				own = append(own, n.IP)
+			}
+		}
+	}
+	var public net.IP
+	var publicErr error
+	for _, server := range doctorSTUNServers {
+		if public, publicErr = stunPublicIP(server, 3*time.Second); publicErr == nil {
+			own = append(own, public)
+			break
+		}
+	}
+	results := make([]checkResult, 0, len(domains))
+	for _, domain := range domains {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		addrs, err := net.DefaultResolver.LookupIP(ctx, "ip", domain)
+		cancel()
+		results = append(results, dnsResolutionResult(domain, addrs, err, own, public, publicErr))
+	}
+	return results
+}
+
+// dnsResolutionResult compares the addresses domain resolves to with own,
+// this machine's addresses and, if it could be found, its public one.
+func dnsResolutionResult(domain string, addrs []net.IP, lookupErr error, own []net.IP, public net.IP, publicErr error) checkResult {
+	r := checkResult{Name: "DNS Resolution"}
+	if lookupErr == nil && len(addrs) == 0 {
+		lookupErr = errors.New("no A or AAAA records")
+	}
+	if lookupErr != nil {
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("%s doesn't resolve (%v), so no certificate can be issued and clients can't find the server.", domain, lookupErr)
+		if public != nil {
+			r.Message += fmt.Sprintf(" Add an A record for it pointing to %s, this server's public address.", public)
+		}
+		return r
+	}
+	var other []string
+	ipv6 := false
+	for _, a := range addrs {
+		if !slices.ContainsFunc(own, a.Equal) {
+			other = append(other, a.String())
+			ipv6 = ipv6 || a.To4() == nil
+		}
+	}
+	if len(other) == 0 {
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("%s resolves to this server.", domain)
+		return r
+	}
+	if public != nil {
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("%s resolves to %s, which isn't this server (public address %s). "+
+			"Certificate issuance fails unless the address forwards to this machine; fix the DNS record.",
+			domain, strings.Join(other, ", "), public)
+	} else {
+		r.Status = checkInfo
+		r.Message = fmt.Sprintf("%s resolves to %s, which isn't an address of this machine, "+
+			"and the public address couldn't be found to compare (%v). Make sure it's this server's.",
+			domain, strings.Join(other, ", "), publicErr)
+	}
+	if ipv6 {
+		r.Message += " The CA tries the AAAA record first when there is one."
+	}
+	return r
+}
+
+// stunPublicIP asks a STUN server for the address it sees this machine's
+// UDP packets come from, the address clients connect to.
+func stunPublicIP(server string, timeout time.Duration) (net.IP, error) {
+	conn, err := net.DialTimeout("udp4", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	var txID [12]byte
+	if _, err := rand.Read(txID[:]); err != nil {
+		return nil, err
+	}
+	req := make([]byte, 20)
+	binary.BigEndian.PutUint16(req, stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	copy(req[8:], txID[:])
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, 512)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return nil, err
+	}
+	return stunMappedAddress(resp[:n], txID)
+}
+
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMagicCookie     = 0x2112A442
+	stunMappedAddr      = 0x0001
+	stunXORMappedAddr   = 0x0020
+)
+
+// stunMappedAddress returns the address in a STUN binding response to the
+// request txID.
+func stunMappedAddress(resp []byte, txID [12]byte) (net.IP, error) {
+	if len(resp) < 20 || binary.BigEndian.Uint16(resp) != stunBindingResponse ||
+		binary.BigEndian.Uint32(resp[4:]) != stunMagicCookie || !bytes.Equal(resp[8:20], txID[:]) {
+		return nil, errors.New("not a STUN binding response")
+	}
+	attrs := resp[20:]
+	if l := int(binary.BigEndian.Uint16(resp[2:])); l < len(attrs) {
+		attrs = attrs[:l]
+	}
+	var mapped net.IP
+	for len(attrs) >= 4 {
+		typ, l := binary.BigEndian.Uint16(attrs), int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+l {
+			break
+		}
+		v := attrs[4 : 4+l]
+		// Attributes are padded to 4 bytes
+		attrs = attrs[min(len(attrs), 4+(l+3)&^3):]
+		if len(v) < 8 || (typ != stunXORMappedAddr && typ != stunMappedAddr) {
+			continue
+		}
+		var ip net.IP
+		switch {
+		case v[1] == 0x01 && len(v) == 8:
+			ip = slices.Clone(v[4:8])
+		case v[1] == 0x02 && len(v) == 20:
+			ip = slices.Clone(v[4:20])
+		default:
+			continue
+		}
+		if typ == stunXORMappedAddr {
+			// XORed with the magic cookie, followed by the transaction ID
+			key := append(slices.Clone(resp[4:8]), txID[:]...)
+			for i := range ip {
+				ip[i] ^= key[i]
+			}
+			return ip, nil
+		}
+		mapped = ip
+	}
+	if mapped == nil {
+		return nil, errors.New("no mapped address in the STUN response")
+	}
+	return mapped, nil
+}
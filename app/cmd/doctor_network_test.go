@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQUICCCResult(t *testing.T) {
+	assert.Equal(t, checkOK, quicCCResult("auto", "", false).Status)
+	assert.Equal(t, checkOK, quicCCResult("BBR", "", false).Status)
+	assert.Equal(t, checkInfo, quicCCResult("bbr", "100 mbps", false).Status)
+	assert.Equal(t, checkOK, quicCCResult("brutal", "100 mbps", false).Status)
+	assert.Contains(t, quicCCResult("brutal", "100 mbps", true).Message, "every client")
+	r := quicCCResult("brutal", "", false)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "bandwidth.up")
+	assert.Equal(t, checkFail, quicCCResult("cubic", "", false).Status)
+}
+
+func TestMaxDatagramSizeResult(t *testing.T) {
+	assert.Equal(t, checkOK, maxDatagramSizeResult(0).Status)
+	assert.Equal(t, checkOK, maxDatagramSizeResult(1200).Status)
+	assert.Equal(t, checkOK, maxDatagramSizeResult(1352).Status)
+	assert.Equal(t, checkWarn, maxDatagramSizeResult(1353).Status)
+	assert.Equal(t, checkWarn, maxDatagramSizeResult(1452).Status)
+	assert.Equal(t, checkFail, maxDatagramSizeResult(1199).Status)
+	assert.Equal(t, checkFail, maxDatagramSizeResult(1500).Status)
+}
+
+func TestIPv6Result(t *testing.T) {
+	r := ipv6Result("0.0.0.0", "443", nil, false, nil)
+	assert.Equal(t, "IPv6", r.Name)
+	assert.Equal(t, checkInfo, r.Status)
+	assert.Contains(t, r.Message, "IPv4 only (0.0.0.0)")
+
+	global := []net.IP{net.ParseIP("2001:db8::10")}
+	r = ipv6Result("::", "443", nil, false, global)
+	assert.Equal(t, checkOK, r.Status)
+	assert.Contains(t, r.Message, "2001:db8::10")
+
+	r = ipv6Result("::", "443", nil, false, nil)
+	assert.Equal(t, checkInfo, r.Status)
+	assert.Contains(t, r.Message, "no public IPv6 address")
+
+	r = ipv6Result("::", "443", &net.OpError{Op: "listen", Err: syscall.EAFNOSUPPORT}, false, nil)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "disabled")
+	r = ipv6Result("::", "443", nil, true, global)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "disabled")
+
+	r = ipv6Result("::", "443", &net.OpError{Op: "listen", Err: syscall.EADDRINUSE}, false, global)
+	assert.Equal(t, checkFail, r.Status)
+
+	r = ipv6Result("2001:db8::10", "443", &net.OpError{Op: "listen", Err: syscall.EADDRNOTAVAIL}, false, global)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "[2001:db8::10]:443")
+	assert.Contains(t, r.Message, "Cannot listen")
+}
+
+func TestDNSResolutionResult(t *testing.T) {
+	public := net.ParseIP("198.51.100.7")
+	own := []net.IP{net.ParseIP("10.0.0.5"), public}
+
+	r := dnsResolutionResult("vpn.example.com", []net.IP{public}, nil, own, public, nil)
+	assert.Equal(t, "DNS Resolution", r.Name)
+	assert.Equal(t, checkOK, r.Status)
+
+	r = dnsResolutionResult("vpn.example.com", nil, errors.New("no such host"), own, public, nil)
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "no such host")
+	assert.Contains(t, r.Message, "pointing to 198.51.100.7")
+
+	r = dnsResolutionResult("vpn.example.com", []net.IP{net.ParseIP("203.0.113.9")}, nil, own, public, nil)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "resolves to 203.0.113.9")
+	assert.NotContains(t, r.Message, "AAAA")
+
+	// A stale AAAA record next to a correct A record
+	r = dnsResolutionResult("vpn.example.com", []net.IP{public, net.ParseIP("2001:db8::1")}, nil, own, public, nil)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "resolves to 2001:db8::1")
+	assert.Contains(t, r.Message, "AAAA record first")
+
+	// Behind NAT with no public address to compare with
+	r = dnsResolutionResult("vpn.example.com", []net.IP{net.ParseIP("203.0.113.9")}, nil, own[:1], nil, errors.New("i/o timeout"))
+	assert.Equal(t, checkInfo, r.Status)
+	assert.Contains(t, r.Message, "i/o timeout")
+}
+
+func TestSTUNMappedAddress(t *testing.T) {
+	var txID [12]byte
+	copy(txID[:], "libyalink-tx")
+	resp := make([]byte, 20)
+	binary.BigEndian.PutUint16(resp, stunBindingResponse)
+	binary.BigEndian.PutUint32(resp[4:], stunMagicCookie)
+	copy(resp[8:], txID[:])
+	// An unknown attribute with padding, then XOR-MAPPED-ADDRESS 198.51.100.7:443
+	resp = append(resp, 0x80, 0x22, 0, 3, 'a', 'b', 'c', 0)
+	resp = append(resp, 0, 0x20, 0, 8, 0, 1, 443>>8^0x21, 443&0xff^0x12)
+	for i, b := range net.ParseIP("198.51.100.7").To4() {
+		resp = append(resp, b^resp[4+i])
+	}
+	binary.BigEndian.PutUint16(resp[2:], uint16(len(resp)-20))
+
+	ip, err := stunMappedAddress(resp, txID)
+	assert.NoError(t, err)
+	assert.Equal(t, "198.51.100.7", ip.String())
+
+	_, err = stunMappedAddress(resp, [12]byte{})
+	assert.Error(t, err)
+	_, err = stunMappedAddress(resp[:20], txID)
+	assert.Error(t, err)
+}
+
+func TestPublicAddressResult(t *testing.T) {
+	assert.Equal(t, "CGNAT", addressClass(net.ParseIP("100.72.1.2")))
+	assert.Equal(t, "", addressClass(net.ParseIP("100.128.0.1")))
+	assert.Equal(t, "private", addressClass(net.ParseIP("192.168.1.10")))
+	assert.Equal(t, "private", addressClass(net.ParseIP("fd00::1")))
+	assert.Equal(t, "", addressClass(net.ParseIP("203.0.113.5")))
+
+	r := publicAddressResult(net.ParseIP("203.0.113.5"), "443", nil)
+	assert.Equal(t, checkOK, r.Status)
+
+	r = publicAddressResult(net.ParseIP("100.72.1.2"), "443", nil)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "100.72.1.2")
+	assert.Contains(t, r.Message, "carrier-grade NAT")
+
+	r = publicAddressResult(net.ParseIP("192.168.1.10"), "8443", nil)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "forwards UDP port 8443")
+
+	r = publicAddressResult(net.ParseIP("192.168.1.10"), "443", []net.IP{net.ParseIP("203.0.113.5")})
+	assert.Equal(t, checkInfo, r.Status)
+	assert.Contains(t, r.Message, "203.0.113.5")
+}
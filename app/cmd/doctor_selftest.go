@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+// checkSelfTest connects to the server like a client, with the obfs and
+// password in the config, and sends a datagram through it. If the listen
+// port is taken it tests the running server, otherwise a throwaway one
+// started from the config.
+func checkSelfTest() []checkResult {
+	var config serverConfig
+	if err := viper.Unmarshal(&config); err != nil {
+		return []checkResult{{Name: "Self-Test", Status: checkFail, Message: fmt.Sprintf("Cannot parse the config: %v", err)}}
+	}
+	auth, haveAuth, err := doctorSelfTestAuth(&config)
+	if err != nil {
+		return []checkResult{{Name: "Self-Test", Status: checkFail, Message: fmt.Sprintf("Cannot resolve the auth password: %v", err)}}
+	}
+
+	// The server's startup logs would garble the report
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	listenAddr := config.Listen
+	if listenAddr == "" {
+		listenAddr = defaultListenAddr
+	}
+	hyConfig := &server.Config{}
+	err = config.fillConn(hyConfig)
+	running := err != nil && (strings.Contains(err.Error(), "address already in use") ||
+		strings.Contains(err.Error(), "Only one usage of each socket address"))
+	var target string
+	switch {
+	case running:
+		if !haveAuth {
+			return []checkResult{{Name: "Self-Test", Status: checkInfo, Message: fmt.Sprintf(
+				"Port %s is in use, probably by the running server, but auth.type %s has no password in the config to log in with. "+
+					"Stop the server to test a throwaway one instead.", listenAddr, config.Auth.Type)}}
+		}
+		uAddr, err := net.ResolveUDPAddr("udp", listenAddr)
+		if err != nil {
+			return []checkResult{{Name: "Self-Test", Status: checkFail, Message: fmt.Sprintf("Invalid listen address '%s': %v", listenAddr, err)}}
+		}
+		if target, err = selfTestServerAddr(uAddr); err != nil {
+			return []checkResult{{Name: "Self-Test", Status: checkFail, Message: err.Error()}}
+		}
+	case err != nil:
+		return []checkResult{{Name: "Self-Test", Status: checkFail, Message: fmt.Sprintf("Cannot start a test server: %v", err)}}
+	default:
+		s, secret, err := config.startDoctorTestServer(hyConfig, auth, haveAuth)
+		if err != nil {
+			return []checkResult{{Name: "Self-Test", Status: checkFail, Message: fmt.Sprintf("Cannot start a test server: %v", err)}}
+		}
+		defer s.Close()
+		auth = secret
+		if target, err = selfTestServerAddr(hyConfig.Conn.LocalAddr()); err != nil {
+			return []checkResult{{Name: "Self-Test", Status: checkFail, Message: err.Error()}}
+		}
+	}
+
+	start := time.Now()
+	hc, info, err := config.selfTestConnect(target, auth)
+	handshake := selfTestHandshakeResult(target, running, strings.ToLower(config.Obfs.Type) == "salamander", time.Since(start), err)
+	if err != nil {
+		return []checkResult{handshake}
+	}
+	defer hc.Close()
+	if config.DisableUDP || !info.UDPEnabled {
+		return []checkResult{handshake, {Name: "Self-Test UDP", Status: checkInfo, Message: "Skipped, UDP is disabled (disableUDP)"}}
+	}
+	start = time.Now()
+	err = selfTestUDPEcho(hc)
+	return []checkResult{handshake, selfTestEchoResult(time.Since(start), err)}
+}
+
+// doctorSelfTestAuth is what a client of the server logs in with:
+// auth.password, or the first user of auth.userpass. ok is false for the
+// other auth types, which have no password in the config.
+func doctorSelfTestAuth(c *serverConfig) (auth string, ok bool, err error) {
+	switch strings.ToLower(c.Auth.Type) {
+	case "password":
+		pw, err := resolveSecret("auth.password", c.Auth.Password, c.Auth.PasswordFile, c.Auth.PasswordEnv)
+		if err != nil {
+			return "", false, err
+		}
+		return pw, pw != "", nil
+	case "userpass":
+		users := make([]string, 0, len(c.Auth.UserPass))
+		for user := range c.Auth.UserPass {
+			users = append(users, user)
+		}
+		if len(users) == 0 {
+			return "", false, nil
+		}
+		sort.Strings(users)
+		return users[0] + ":" + c.Auth.UserPass[users[0]], true, nil
+	default:
+		return "", false, nil
+	}
+}
+
+// startDoctorTestServer starts a server from the config on hyConfig.Conn,
+// with direct outbounds. Without a password in the config (haveAuth false)
+// it also lets in a random secret, which it returns in place of auth.
+func (c *serverConfig) startDoctorTestServer(hyConfig *server.Config, auth string, haveAuth bool) (server.Server, string, error) {
+	fillers := []func(*server.Config) error{
+		c.fillQUICConfig,
+		c.fillDisableUDP,
+		c.fillAuthenticator,
+	}
+	if c.ACME != nil {
+		// Getting a certificate here would count against the CA's rate
+		// limits, and the self-test doesn't verify it anyway
+		cert, err := selfSignedCertificate(c.ACME.Domains)
+		if err != nil {
+			_ = hyConfig.Conn.Close()
+			return nil, "", err
+		}
+		hyConfig.TLSConfig.Certificates = []tls.Certificate{cert}
+	} else {
+		fillers = append([]func(*server.Config) error{c.fillTLSConfig}, fillers...)
+	}
+	for _, f := range fillers {
+		if err := f(hyConfig); err != nil {
+			_ = hyConfig.Conn.Close()
+			return nil, "", err
+		}
+	}
+	// The self-test has no client certificate for tls.clientCA
+	hyConfig.TLSConfig.ClientCAs = nil
+	if !haveAuth {
+		a, err := newSelfTestAuthenticator(hyConfig.Authenticator)
+		if err != nil {
+			_ = hyConfig.Conn.Close()
+			return nil, "", err
+		}
+		hyConfig.Authenticator = a
+		auth = a.Secret
+	}
+	s, err := server.NewServer(hyConfig)
+	if err != nil {
+		_ = hyConfig.Conn.Close()
+		return nil, "", err
+	}
+	go func() { _ = s.Serve() }()
+	return s, auth, nil
+}
+
+// selfSignedCertificate makes a short-lived certificate for names.
+func selfSignedCertificate(names []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// selfTestHandshakeResult explains how connecting to the server at target
+// went. running is whether it's the running server rather than a test one.
+func selfTestHandshakeResult(target string, running, obfs bool, elapsed time.Duration, err error) checkResult {
+	which := "a test server started from the config"
+	if running {
+		which = "the running server"
+	}
+	r := checkResult{Name: "Self-Test", Status: checkFail}
+	var authErr hyErrors.AuthError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("Logged in to %s at %s in %s", which, target, elapsed.Round(time.Millisecond))
+	case errors.As(err, &authErr) && running:
+		r.Message = fmt.Sprintf("The running server at %s refused the password in the config (HTTP status %d). "+
+			"It may still run with an older config, restart it to apply this one.", target, authErr.StatusCode)
+	case errors.As(err, &authErr):
+		r.Message = fmt.Sprintf("A test server started from the config refused its password (HTTP status %d), check 'auth'.", authErr.StatusCode)
+	case errors.As(err, &netErr) && netErr.Timeout(), strings.Contains(err.Error(), "timeout"):
+		r.Message = fmt.Sprintf("No answer from %s at %s.", which, target)
+		switch {
+		case running && obfs:
+			r.Message += " It ignores packets without its obfs password, it may still run with an older config, restart it to apply this one."
+		case running:
+			r.Message += " Check that it's LibyaLink listening on the port, and that it doesn't use obfs if the config doesn't."
+		default:
+			r.Message += " Check that the firewall allows UDP on the loopback interface."
+		}
+	default:
+		r.Message = fmt.Sprintf("Handshake with %s at %s failed: %v", which, target, err)
+	}
+	return r
+}
+
+// selfTestEchoResult explains how sending a datagram through the server
+// went.
+func selfTestEchoResult(elapsed time.Duration, err error) checkResult {
+	if err != nil {
+		return checkResult{Name: "Self-Test UDP", Status: checkFail, Message: fmt.Sprintf("Logged in, but the UDP echo failed: %v", err)}
+	}
+	return checkResult{Name: "Self-Test UDP", Status: checkOK, Message: fmt.Sprintf(
+		"Datagram echoed through the server in %s", elapsed.Round(time.Millisecond))}
+}
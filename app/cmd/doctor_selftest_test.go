@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+func TestDoctorSelfTestAuth(t *testing.T) {
+	c := &serverConfig{Auth: serverConfigAuth{Type: "password", Password: "weak_ahh_password"}}
+	auth, ok, err := doctorSelfTestAuth(c)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "weak_ahh_password", auth)
+
+	c = &serverConfig{Auth: serverConfigAuth{Type: "userpass", UserPass: map[string]string{"zoe": "pw2", "ali": "pw1"}}}
+	auth, ok, err = doctorSelfTestAuth(c)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "ali:pw1", auth)
+
+	c = &serverConfig{Auth: serverConfigAuth{Type: "http"}}
+	_, ok, err = doctorSelfTestAuth(c)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	c = &serverConfig{Auth: serverConfigAuth{Type: "password", PasswordFile: "/nonexistent/password"}}
+	_, _, err = doctorSelfTestAuth(c)
+	assert.Error(t, err)
+}
+
+func TestSelfTestHandshakeResult(t *testing.T) {
+	r := selfTestHandshakeResult("127.0.0.1:443", true, false, 12345*time.Microsecond, nil)
+	assert.Equal(t, checkOK, r.Status)
+	assert.Equal(t, "Logged in to the running server at 127.0.0.1:443 in 12ms", r.Message)
+
+	r = selfTestHandshakeResult("127.0.0.1:443", true, false, 0, hyErrors.AuthError{StatusCode: 404})
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "restart it")
+
+	r = selfTestHandshakeResult("127.0.0.1:443", false, false, 0, hyErrors.AuthError{StatusCode: 404})
+	assert.Contains(t, r.Message, "test server")
+
+	timeout := hyErrors.ConnectError{Err: errors.New("timeout: no recent network activity")}
+	r = selfTestHandshakeResult("127.0.0.1:443", true, true, 0, timeout)
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "obfs password")
+	r = selfTestHandshakeResult("127.0.0.1:443", false, true, 0, timeout)
+	assert.Contains(t, r.Message, "loopback")
+
+	r = selfTestHandshakeResult("127.0.0.1:443", false, false, 0, os.ErrPermission)
+	assert.Contains(t, r.Message, "failed: permission denied")
+}
+
+func TestSelfTestEchoResult(t *testing.T) {
+	r := selfTestEchoResult(3*time.Millisecond, nil)
+	assert.Equal(t, checkOK, r.Status)
+	assert.Equal(t, "Datagram echoed through the server in 3ms", r.Message)
+	r = selfTestEchoResult(0, errors.New("no UDP echo"))
+	assert.Equal(t, checkFail, r.Status)
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cert, err := selfSignedCertificate([]string{"vpn.example.com"})
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+	assert.NoError(t, leaf.VerifyHostname("vpn.example.com"))
+}
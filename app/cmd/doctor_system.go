@@ -0,0 +1,816 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func checkUDPBuffers() []checkResult {
+	if runtime.GOOS != "linux" {
+		return []checkResult{{
+			Name:    "UDP Buffers",
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Buffer check only runs on Linux (current OS: %s). See docs/libya_tuning.md", runtime.GOOS),
+		}}
+	}
+
+	var results []checkResult
+
+	// Check rmem_max
+	rmem, err := os.ReadFile("/proc/sys/net/core/rmem_max")
+	if err == nil {
+		val := strings.TrimSpace(string(rmem))
+		results = append(results, checkBufferValue("UDP rmem_max", val, 8388608))
+	}
+
+	// Check wmem_max
+	wmem, err := os.ReadFile("/proc/sys/net/core/wmem_max")
+	if err == nil {
+		val := strings.TrimSpace(string(wmem))
+		results = append(results, checkBufferValue("UDP wmem_max", val, 8388608))
+	}
+
+	if len(results) == 0 {
+		results = append(results, checkResult{
+			Name:    "UDP Buffers",
+			Status:  checkWarn,
+			Message: "Could not read sysctl buffer values. Run 'sysctl net.core.rmem_max' manually.",
+		})
+	}
+
+	return results
+}
+
+func checkBufferValue(name, valStr string, recommended int) checkResult {
+	var val int
+	fmt.Sscanf(valStr, "%d", &val)
+	if val >= recommended {
+		return checkResult{
+			Name:    name,
+			Status:  checkOK,
+			Message: fmt.Sprintf("%d bytes (>= %d recommended). Good!", val, recommended),
+		}
+	}
+	return checkResult{
+		Name:    name,
+		Status:  checkWarn,
+		Message: fmt.Sprintf("%d bytes (< %d recommended). Run the tuning script for full speed. See docs/libya_tuning.md", val, recommended),
+	}
+}
+
+// checkTCPCongestion checks that the kernel's TCP uses BBR, paced by fq.
+// QUIC has its own congestion control (quic.cc), but the masquerade site
+// and any other TCP traffic of the server go through the kernel's.
+func checkTCPCongestion() []checkResult {
+	if runtime.GOOS != "linux" {
+		return []checkResult{{
+			Name:    "TCP Congestion",
+			Status:  checkInfo,
+			Message: fmt.Sprintf("TCP congestion control check only runs on Linux (current OS: %s).", runtime.GOOS),
+		}}
+	}
+	cc, err := os.ReadFile("/proc/sys/net/ipv4/tcp_congestion_control")
+	if err != nil {
+		return []checkResult{{
+			Name:    "TCP Congestion",
+			Status:  checkInfo,
+			Message: "Could not read net.ipv4.tcp_congestion_control. Run 'sysctl net.ipv4.tcp_congestion_control' manually.",
+		}}
+	}
+	// Both are missing in some containers, and are only informative
+	qdisc, _ := os.ReadFile("/proc/sys/net/core/default_qdisc")
+	available, _ := os.ReadFile("/proc/sys/net/ipv4/tcp_available_congestion_control")
+	return []checkResult{tcpCongestionResult(strings.TrimSpace(string(cc)), strings.TrimSpace(string(qdisc)),
+		strings.Fields(string(available)))}
+}
+
+func tcpCongestionResult(cc, qdisc string, available []string) checkResult {
+	r := checkResult{Name: "TCP Congestion", Status: checkWarn}
+	const persist = " Add both to /etc/sysctl.d/99-libyalink.conf to keep them after a reboot, see docs/libya_tuning.md."
+	switch {
+	case cc == "bbr" && qdisc == "fq":
+		r.Status = checkOK
+		r.Message = "BBR with fq. Good for the masquerade site and other TCP traffic."
+	case cc == "bbr":
+		if qdisc == "" {
+			qdisc = "unknown"
+		}
+		r.Message = fmt.Sprintf("BBR, but the default qdisc is %s, and BBR paces its packets best with fq. "+
+			"Run 'sysctl -w net.core.default_qdisc=fq' and reboot, it only applies to interfaces brought up afterwards. "+
+			"Add it to /etc/sysctl.d/99-libyalink.conf to keep it, see docs/libya_tuning.md.", qdisc)
+	default:
+		enable := "sysctl -w net.core.default_qdisc=fq && sysctl -w net.ipv4.tcp_congestion_control=bbr"
+		if len(available) > 0 && !slices.Contains(available, "bbr") {
+			enable = "modprobe tcp_bbr && " + enable
+		}
+		r.Message = fmt.Sprintf("%s, not BBR. QUIC connections use quic.cc and don't mind, but the masquerade site and other TCP traffic "+
+			"lose much of their speed to packet loss on long international links without BBR. Run '%s'.%s", cc, enable, persist)
+	}
+	return r
+}
+
+// conntrackWarnPercent is the share of the conntrack table in use above
+// which checkConntrack warns.
+const conntrackWarnPercent = 80
+
+// checkConntrack checks how full the netfilter connection tracking table
+// is. Once it's full the kernel drops packets of new flows, which clients
+// see as random disconnects. Nothing to check without the conntrack module.
+func checkConntrack() []checkResult {
+	count, err := os.ReadFile("/proc/sys/net/netfilter/nf_conntrack_count")
+	if err != nil {
+		return nil
+	}
+	limit, err := os.ReadFile("/proc/sys/net/netfilter/nf_conntrack_max")
+	if err != nil {
+		return nil
+	}
+	c, err1 := strconv.Atoi(strings.TrimSpace(string(count)))
+	m, err2 := strconv.Atoi(strings.TrimSpace(string(limit)))
+	if err1 != nil || err2 != nil || m <= 0 {
+		return nil
+	}
+	return []checkResult{conntrackResult(c, m)}
+}
+
+func conntrackResult(count, limit int) checkResult {
+	percent := count * 100 / limit
+	if percent > conntrackWarnPercent {
+		return checkResult{
+			Name:   "Conntrack",
+			Status: checkWarn,
+			Message: fmt.Sprintf("%d of %d entries in use (%d%%). When the table is full, new UDP flows are dropped "+
+				"and clients disconnect at random. Raise it with 'sysctl -w net.netfilter.nf_conntrack_max=%d', "+
+				"and add it to /etc/sysctl.d/99-libyalink.conf to keep it, see docs/libya_tuning.md.", count, limit, percent, limit*2),
+		}
+	}
+	return checkResult{
+		Name:    "Conntrack",
+		Status:  checkOK,
+		Message: fmt.Sprintf("%d of %d entries in use (%d%%)", count, limit, percent),
+	}
+}
+
+const (
+	// Go multiplexes goroutines onto few threads, but every blocking
+	// syscall (DNS lookups, file reads) under load pins one. Below these
+	// limits thread creation fails under load and the runtime aborts.
+	recommendedThreadLimit = 1024
+	minimumThreadLimit     = 256
+)
+
+// threadLimit is one limit on the number of threads the server can
+// create, -1 means unlimited.
+type threadLimit struct {
+	Name  string
+	Value int
+}
+
+func checkThreadLimits() []checkResult {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+
+	var limits []threadLimit
+	for _, f := range []struct{ name, path string }{
+		{"kernel.threads-max", "/proc/sys/kernel/threads-max"},
+		{"kernel.pid_max", "/proc/sys/kernel/pid_max"},
+	} {
+		if bs, err := os.ReadFile(f.path); err == nil {
+			if v, ok := parseLimitValue(string(bs)); ok {
+				limits = append(limits, threadLimit{f.name, v})
+			}
+		}
+	}
+	if bs, err := os.ReadFile("/proc/self/limits"); err == nil {
+		if v, ok := parseProcLimit(string(bs), "Max processes"); ok {
+			limits = append(limits, threadLimit{"ulimit -u", v})
+		}
+	}
+	if bs, err := os.ReadFile("/proc/self/cgroup"); err == nil {
+		for _, path := range cgroupPidsMaxPaths(string(bs)) {
+			if bs, err := os.ReadFile(path); err == nil {
+				if v, ok := parseLimitValue(string(bs)); ok {
+					limits = append(limits, threadLimit{"cgroup pids.max", v})
+				}
+				break
+			}
+		}
+	}
+	if len(limits) == 0 {
+		return []checkResult{{
+			Name:    "Thread Limits",
+			Status:  checkWarn,
+			Message: "Could not read any thread/PID limits.",
+		}}
+	}
+
+	effective := threadLimit{Value: -1}
+	parts := make([]string, 0, len(limits))
+	for _, l := range limits {
+		v := "unlimited"
+		if l.Value >= 0 {
+			v = fmt.Sprint(l.Value)
+		}
+		parts = append(parts, l.Name+"="+v)
+		if l.Value >= 0 && (effective.Value < 0 || l.Value < effective.Value) {
+			effective = l
+		}
+	}
+	summary := strings.Join(parts, ", ")
+	switch {
+	case effective.Value >= 0 && effective.Value < minimumThreadLimit:
+		return []checkResult{{
+			Name:   "Thread Limits",
+			Status: checkFail,
+			Message: fmt.Sprintf("%s. %s limits the server to %d threads (< %d), it will crash under load. Raise it (e.g. TasksMax= in the systemd unit, or the container's pids limit).",
+				summary, effective.Name, effective.Value, minimumThreadLimit),
+		}}
+	case effective.Value >= 0 && effective.Value < recommendedThreadLimit:
+		return []checkResult{{
+			Name:   "Thread Limits",
+			Status: checkWarn,
+			Message: fmt.Sprintf("%s. %s limits the server to %d threads (< %d recommended), many concurrent connections may fail.",
+				summary, effective.Name, effective.Value, recommendedThreadLimit),
+		}}
+	}
+	return []checkResult{{
+		Name:    "Thread Limits",
+		Status:  checkOK,
+		Message: summary + ".",
+	}}
+}
+
+// parseLimitValue parses a limit file such as pids.max, where "max" means
+// unlimited.
+func parseLimitValue(s string) (int, bool) {
+	s = strings.TrimSpace(s)
+	if s == "max" || s == "unlimited" {
+		return -1, true
+	}
+	v, err := strconv.Atoi(s)
+	return v, err == nil
+}
+
+// parseProcLimit returns the soft limit of the named resource from
+// /proc/self/limits.
+func parseProcLimit(data, name string) (int, bool) {
+	for _, line := range strings.Split(data, "\n") {
+		rest, ok := strings.CutPrefix(line, name)
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return 0, false
+		}
+		return parseLimitValue(fields[0])
+	}
+	return 0, false
+}
+
+// cgroupPidsMaxPaths returns where the pids.max of this process's cgroup
+// can be, from the contents of /proc/self/cgroup. Both cgroup v2 and the
+// v1 pids controller are covered.
+func cgroupPidsMaxPaths(data string) []string {
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			paths = append(paths, filepath.Join("/sys/fs/cgroup", parts[2], "pids.max"))
+		case slices.Contains(strings.Split(parts[1], ","), "pids"):
+			paths = append(paths, filepath.Join("/sys/fs/cgroup/pids", parts[2], "pids.max"))
+		}
+	}
+	return paths
+}
+
+// recommendedFileLimit is the open file limit below which a busy server
+// can run out: every client holds a socket, and every TCP request one more.
+const recommendedFileLimit = 65535
+
+func checkFileDescriptorLimit() []checkResult {
+	if runtime.GOOS == "windows" {
+		return []checkResult{{
+			Name:    "Open Files",
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Open file limit check only runs on Unix (current OS: %s).", runtime.GOOS),
+		}}
+	}
+	// Go raises the soft limit to the hard one at startup, so this is
+	// also what the server gets when started the same way
+	soft, _, err := fileDescriptorLimit()
+	if err != nil {
+		return []checkResult{{
+			Name:    "Open Files",
+			Status:  checkWarn,
+			Message: fmt.Sprintf("Cannot read the open file limit: %v", err),
+		}}
+	}
+	return []checkResult{fileDescriptorLimitResult(soft)}
+}
+
+func fileDescriptorLimitResult(limit uint64) checkResult {
+	if limit >= recommendedFileLimit {
+		v := fmt.Sprint(limit)
+		if limit >= 1<<62 {
+			v = "unlimited"
+		}
+		return checkResult{Name: "Open Files", Status: checkOK, Message: fmt.Sprintf("Open file limit is %s.", v)}
+	}
+	return checkResult{
+		Name:   "Open Files",
+		Status: checkWarn,
+		Message: fmt.Sprintf("Open file limit is %d (< %d recommended): every connection and TCP request takes a file descriptor, "+
+			"so a busy server starts dropping connections. Raise it with LimitNOFILE=%d in the systemd unit, or ulimit -n %d "+
+			"before starting the server; doctor sees the limit of the shell it runs in.",
+			limit, recommendedFileLimit, recommendedFileLimit, recommendedFileLimit),
+	}
+}
+
+func checkHypervisor() []checkResult {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	readTrimmed := func(path string) string {
+		bs, _ := os.ReadFile(path)
+		return strings.TrimSpace(string(bs))
+	}
+	cpuinfo, _ := os.ReadFile("/proc/cpuinfo")
+	name := detectHypervisor(
+		readTrimmed("/sys/class/dmi/id/sys_vendor"),
+		readTrimmed("/sys/class/dmi/id/product_name"),
+		readTrimmed("/sys/hypervisor/type"),
+		cpuinfoHasFlag(string(cpuinfo), "hypervisor"))
+	if name == "" {
+		return []checkResult{{
+			Name:    "Hypervisor",
+			Status:  checkOK,
+			Message: "No hypervisor detected (bare metal).",
+		}}
+	}
+	notes := hypervisorNotes(name, readNICs("/sys/class/net"))
+	if len(notes) == 0 {
+		return []checkResult{{
+			Name:    "Hypervisor",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Running on %s, no known UDP performance issues.", name),
+		}}
+	}
+	return []checkResult{{
+		Name:   "Hypervisor",
+		Status: checkInfo,
+		Message: fmt.Sprintf("Running on %s, which can cap UDP throughput whatever the tuning. %s",
+			name, strings.Join(notes, " ")),
+	}}
+}
+
+// detectHypervisor names the virtualization platform from the DMI system
+// vendor and product, /sys/hypervisor/type and the cpuid hypervisor flag.
+// It returns "" on bare metal.
+func detectHypervisor(sysVendor, productName, xenType string, cpuFlag bool) string {
+	v := strings.ToLower(sysVendor + " " + productName)
+	switch {
+	case strings.Contains(v, "vmware"):
+		return "VMware"
+	case strings.Contains(v, "innotek"), strings.Contains(v, "virtualbox"):
+		return "VirtualBox"
+	case strings.Contains(v, "microsoft") && strings.Contains(v, "virtual"):
+		return "Hyper-V"
+	case strings.Contains(v, "xen"), xenType == "xen":
+		return "Xen"
+	case strings.Contains(v, "amazon ec2"):
+		return "AWS Nitro"
+	case strings.Contains(v, "google"):
+		return "Google Compute Engine"
+	case strings.Contains(v, "parallels"):
+		return "Parallels"
+	case strings.Contains(v, "qemu"), strings.Contains(v, "kvm"), strings.Contains(v, "openstack"),
+		strings.Contains(v, "digitalocean"), strings.Contains(v, "hetzner"), strings.Contains(v, "vultr"):
+		return "KVM"
+	case cpuFlag:
+		return "an unknown hypervisor"
+	}
+	return ""
+}
+
+// cpuinfoHasFlag reports whether the first CPU in /proc/cpuinfo has flag.
+func cpuinfoHasFlag(cpuinfo, flag string) bool {
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(key) == "flags" {
+			return slices.Contains(strings.Fields(value), flag)
+		}
+	}
+	return false
+}
+
+type nicInfo struct {
+	Name    string
+	Driver  string
+	RXQueue int
+}
+
+// readNICs lists the physical (or paravirtual) network interfaces with their
+// driver and number of receive queues.
+func readNICs(sysClassNet string) []nicInfo {
+	entries, err := os.ReadDir(sysClassNet)
+	if err != nil {
+		return nil
+	}
+	var nics []nicInfo
+	for _, e := range entries {
+		driver, err := os.Readlink(filepath.Join(sysClassNet, e.Name(), "device", "driver"))
+		if err != nil {
+			continue // Virtual interface (lo, bridges, tunnels)
+		}
+		queues, _ := filepath.Glob(filepath.Join(sysClassNet, e.Name(), "queues", "rx-*"))
+		nics = append(nics, nicInfo{Name: e.Name(), Driver: filepath.Base(driver), RXQueue: len(queues)})
+	}
+	return nics
+}
+
+// hypervisorNotes returns the workarounds for the known UDP performance
+// issues of a platform, given its network interfaces.
+func hypervisorNotes(name string, nics []nicInfo) []string {
+	var notes []string
+	switch name {
+	case "KVM":
+		for _, nic := range nics {
+			if nic.Driver == "virtio_net" && nic.RXQueue == 1 {
+				notes = append(notes, fmt.Sprintf("%s is a single-queue virtio-net, so one CPU handles all UDP. "+
+					"Enable multiqueue on the host (queues=N) and run \"ethtool -L %s combined N\".", nic.Name, nic.Name))
+			}
+		}
+	case "VMware":
+		for _, nic := range nics {
+			if nic.Driver == "e1000" || nic.Driver == "e1000e" {
+				notes = append(notes, fmt.Sprintf("%s uses the emulated %s adapter, switch it to vmxnet3.", nic.Name, nic.Driver))
+			}
+		}
+	case "VirtualBox":
+		notes = append(notes, "VirtualBox NAT networking is slow for UDP, use a bridged adapter with the virtio-net type.")
+	case "Hyper-V":
+		notes = append(notes, "The synthetic network adapter is slow for UDP. On Azure enable Accelerated Networking, "+
+			"elsewhere give hv_netvsc more channels with \"ethtool -L <interface> combined N\".")
+	case "Xen":
+		notes = append(notes, "Xen netfront is slow for UDP with a single queue, raise xen_netfront.max_queues "+
+			"or move to an HVM/PVH guest (on AWS, an ENA instance type).")
+	}
+	return notes
+}
+
+// macLogFiles are the logs that SELinux and AppArmor denials end up in,
+// depending on whether auditd is running.
+var macLogFiles = []string{"/var/log/audit/audit.log", "/var/log/kern.log", "/var/log/syslog", "/var/log/messages"}
+
+// macLogTail is how much of the end of each log is scanned for denials.
+const macLogTail = 4 << 20
+
+func checkMACDenials(results []checkResult) []checkResult {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+	var active []string
+	if enforce, err := os.ReadFile("/sys/fs/selinux/enforce"); err == nil {
+		if strings.TrimSpace(string(enforce)) == "1" {
+			active = append(active, "SELinux (enforcing)")
+		} else {
+			active = append(active, "SELinux (permissive, denials are only logged)")
+		}
+	}
+	if enabled, err := os.ReadFile("/sys/module/apparmor/parameters/enabled"); err == nil &&
+		strings.TrimSpace(string(enabled)) == "Y" {
+		active = append(active, "AppArmor")
+	}
+	if len(active) == 0 {
+		return []checkResult{{
+			Name:    "SELinux/AppArmor",
+			Status:  checkOK,
+			Message: "Neither SELinux nor AppArmor is active.",
+		}}
+	}
+
+	names := []string{"libyalink", "hysteria"}
+	if exe, err := os.Executable(); err == nil {
+		names = append(names, filepath.Base(exe))
+	}
+	var denials, scanned []string
+	for _, path := range macLogFiles {
+		data, err := readFileTail(path, macLogTail)
+		if err != nil {
+			continue
+		}
+		scanned = append(scanned, path)
+		denials = append(denials, findMACDenials(string(data), names)...)
+	}
+
+	var permissionErrors []string
+	for _, r := range results {
+		if r.Status != checkOK && strings.Contains(strings.ToLower(r.Message), "permission denied") {
+			permissionErrors = append(permissionErrors, r.Name)
+		}
+	}
+
+	on := strings.Join(active, " and ")
+	hint := "Check with \"ausearch -m avc -ts recent\" (SELinux) or \"dmesg | grep DENIED\" (AppArmor)."
+	switch {
+	case len(denials) > 0:
+		return []checkResult{{
+			Name:   "SELinux/AppArmor",
+			Status: checkWarn,
+			Message: fmt.Sprintf("%s is active and denied the server %d time(s) recently, so \"permission denied\" "+
+				"errors may not be about file modes. Latest: %s Fix the policy (e.g. semanage port/fcontext, "+
+				"or the AppArmor profile) rather than the file permissions.",
+				on, len(denials), denials[len(denials)-1]),
+		}}
+	case len(permissionErrors) > 0:
+		return []checkResult{{
+			Name:   "SELinux/AppArmor",
+			Status: checkWarn,
+			Message: fmt.Sprintf("%s is active, and %s failed with \"permission denied\". It may be the "+
+				"policy rather than file modes. %s", on, strings.Join(permissionErrors, ", "), hint),
+		}}
+	case len(scanned) == 0:
+		return []checkResult{{
+			Name:   "SELinux/AppArmor",
+			Status: checkInfo,
+			Message: fmt.Sprintf("%s is active, but no audit or kernel log is readable (run as root to scan it). "+
+				"Doctor doesn't run confined like the service does, so a check passing here can still be "+
+				"denied to the server. %s", on, hint),
+		}}
+	default:
+		return []checkResult{{
+			Name:   "SELinux/AppArmor",
+			Status: checkOK,
+			Message: fmt.Sprintf("%s is active, no recent denials for the server in %s.",
+				on, strings.Join(scanned, ", ")),
+		}}
+	}
+}
+
+// findMACDenials returns the SELinux AVC and AppArmor denial lines in log
+// that are about a process with one of the names, oldest first.
+func findMACDenials(log string, names []string) []string {
+	var denials []string
+	for _, line := range strings.Split(log, "\n") {
+		if !strings.Contains(line, "avc:  denied") && !strings.Contains(line, `apparmor="DENIED"`) {
+			continue
+		}
+		for _, name := range names {
+			if strings.Contains(line, `comm="`+name+`"`) ||
+				(strings.Contains(line, `exe="`) && strings.Contains(line, "/"+name+`"`)) {
+				denials = append(denials, strings.TrimSpace(line))
+				break
+			}
+		}
+	}
+	return denials
+}
+
+// readFileTail reads up to the last n bytes of a file.
+func readFileTail(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > n {
+		if _, err := f.Seek(info.Size()-n, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return io.ReadAll(f)
+}
+
+const (
+	doctorNTPServer = "pool.ntp.org:123"
+	// ntpEpochOffset is the seconds from 1900, the NTP epoch, to 1970
+	ntpEpochOffset = 2208988800
+	// clockSkewWarn already shifts the minutes of token schedule windows
+	clockSkewWarn = time.Minute
+	// clockSkewFail is the clock skew self-signed certificates tolerate,
+	// they're valid from an hour before they're made
+	clockSkewFail = time.Hour
+)
+
+func checkClock() []checkResult {
+	var uses []string
+	if strings.ToLower(viper.GetString("auth.type")) == "token" {
+		uses = append(uses, "signed tokens expire and keep their daily windows by the server's clock")
+	}
+	if viper.IsSet("tls") || viper.IsSet("acme") {
+		uses = append(uses, "clients reject a certificate that isn't valid yet by their clock, e.g. one made on a server whose clock is ahead")
+	}
+	obfs := strings.ToLower(viper.GetString("obfs.type")) == "salamander"
+	offset, err := ntpOffset(doctorNTPServer, 3*time.Second)
+	if err != nil {
+		return []checkResult{{
+			Name:    "Clock",
+			Status:  checkInfo,
+			Message: fmt.Sprintf("Cannot ask %s for the time, UDP port 123 blocked? (%v)", doctorNTPServer, err),
+		}}
+	}
+	return []checkResult{clockSkewResult(offset, uses, obfs)}
+}
+
+// clockSkewResult rates offset, how far this machine's clock is behind
+// (positive) or ahead of NTP. uses are the ways the config depends on the
+// clock, obfs whether Salamander is on.
+func clockSkewResult(offset time.Duration, uses []string, obfs bool) checkResult {
+	skew := offset.Abs()
+	dir := "behind"
+	if offset < 0 {
+		dir = "ahead"
+	}
+	r := checkResult{Name: "Clock"}
+	switch {
+	case skew < clockSkewWarn:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("Clock is within %s of NTP.", skew.Round(time.Millisecond))
+		return r
+	case skew < clockSkewFail:
+		r.Status = checkWarn
+	default:
+		r.Status = checkFail
+	}
+	r.Message = fmt.Sprintf("Clock is %s %s NTP, turn on time sync (timedatectl set-ntp true).", skew.Round(time.Second), dir)
+	if len(uses) > 0 {
+		r.Message += " It matters here: " + strings.Join(uses, "; ") + "."
+	}
+	if obfs {
+		r.Message += " Salamander obfs doesn't use the time and Hysteria has no replay window, " +
+			"so obfs rejections come from the password (see Client Obfs), not from the clock."
+	}
+	return r
+}
+
+// ntpOffset asks an NTP server for the time with a single SNTP request and
+// returns how far the local clock is behind it.
+func ntpOffset(server string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	req := make([]byte, 48)
+	req[0] = 0x1B // No leap warning, version 3, client mode
+	t1 := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	t4 := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	return ntpResponseOffset(resp[:n], t1, t4)
+}
+
+// ntpResponseOffset computes the clock offset from an SNTP response, sent
+// at t1 and received at t4 by the local clock.
+func ntpResponseOffset(resp []byte, t1, t4 time.Time) (time.Duration, error) {
+	if len(resp) < 48 {
+		return 0, errors.New("short NTP response")
+	}
+	if resp[0]&0x07 != 4 {
+		return 0, errors.New("not an NTP server response")
+	}
+	if resp[1] == 0 {
+		return 0, errors.New("NTP server refused the request")
+	}
+	ntpTime := func(b []byte) time.Time {
+		secs := int64(binary.BigEndian.Uint32(b)) - ntpEpochOffset
+		frac := int64(binary.BigEndian.Uint32(b[4:]))
+		return time.Unix(secs, frac*int64(time.Second)>>32)
+	}
+	t2, t3 := ntpTime(resp[32:]), ntpTime(resp[40:])
+	return (t2.Sub(t1) + t3.Sub(t4)) / 2, nil
+}
+
+// doctorDir is a directory the server or its commands write files to.
+type doctorDir struct {
+	Name   string // e.g. "Temp directory"
+	Path   string
+	Exec   bool   // Files written there are also run
+	Impact string // What fails if it isn't writable
+}
+
+func checkWritableDirs() []checkResult {
+	dirs := []doctorDir{{
+		Name:   "Temp directory",
+		Path:   os.TempDir(),
+		Exec:   runtime.GOOS != "windows",
+		Impact: "Set TMPDIR to a writable directory mounted without noexec.",
+	}}
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, doctorDir{
+			Name:   "Working directory",
+			Path:   wd,
+			Impact: "Relative paths in the config, like the default acme.dir, are written here. Use absolute paths or start the server elsewhere.",
+		})
+	}
+	if viper.IsSet("acme") && !viper.IsSet("tls") {
+		dir := viper.GetString("acme.dir")
+		if dir == "" {
+			dir = envOrDefaultString(appACMEDirEnv, "acme")
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			// Created on first start, in its parent
+			dir = filepath.Dir(dir)
+		}
+		dirs = append(dirs, doctorDir{
+			Name:   "ACME storage",
+			Path:   dir,
+			Impact: "Certificates can't be obtained or renewed. Set acme.dir to a writable directory.",
+		})
+	}
+	if file := viper.GetString("limits.quota.file"); file != "" {
+		dir := filepath.Dir(file)
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		dirs = append(dirs, doctorDir{
+			Name:   "Quota storage",
+			Path:   dir,
+			Impact: "Data quota usage isn't saved, after a restart users get part of their quota back. Set limits.quota.file in a writable directory.",
+		})
+	}
+
+	results := make([]checkResult, 0, len(dirs))
+	for _, d := range dirs {
+		writeErr, execErr := probeDir(d.Path, d.Exec)
+		results = append(results, dirProbeResult(d, writeErr, execErr))
+	}
+	return results
+}
+
+// probeDir writes a small script to dir, and with tryExec also runs it.
+// execErr is nil if it wasn't run.
+func probeDir(dir string, tryExec bool) (writeErr, execErr error) {
+	f, err := os.CreateTemp(dir, ".libyalink-doctor-*")
+	if err != nil {
+		return err, nil
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("#!/bin/sh\nexit 0\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || !tryExec {
+		return err, nil
+	}
+	if err := os.Chmod(f.Name(), 0o700); err != nil {
+		return nil, err
+	}
+	return nil, exec.Command(f.Name()).Run()
+}
+
+func dirProbeResult(d doctorDir, writeErr, execErr error) checkResult {
+	r := checkResult{Name: "Writable Dirs", Status: checkWarn}
+	switch {
+	case errors.Is(writeErr, syscall.EROFS):
+		r.Message = fmt.Sprintf("%s %s is on a read-only file system. %s", d.Name, d.Path, d.Impact)
+	case writeErr != nil:
+		r.Message = fmt.Sprintf("%s %s is not writable: %v. %s", d.Name, d.Path, writeErr, d.Impact)
+	case errors.Is(execErr, os.ErrPermission):
+		r.Message = fmt.Sprintf("%s %s doesn't allow running files (mounted noexec?): %v. %s", d.Name, d.Path, execErr, d.Impact)
+	case execErr != nil:
+		r.Status = checkInfo
+		r.Message = fmt.Sprintf("%s %s is writable, but running a file from it couldn't be tested: %v", d.Name, d.Path, execErr)
+	case d.Exec:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("%s %s is writable and allows running files.", d.Name, d.Path)
+	default:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("%s %s is writable.", d.Name, d.Path)
+	}
+	return r
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProcLimit(t *testing.T) {
+	data := "Limit                     Soft Limit           Hard Limit           Units     \n" +
+		"Max cpu time              unlimited            unlimited            seconds   \n" +
+		"Max processes             4096                 8192                 processes \n" +
+		"Max open files            1024                 524288               files     \n"
+	v, ok := parseProcLimit(data, "Max processes")
+	assert.True(t, ok)
+	assert.Equal(t, 4096, v)
+	v, ok = parseProcLimit(data, "Max cpu time")
+	assert.True(t, ok)
+	assert.Equal(t, -1, v)
+	_, ok = parseProcLimit(data, "Max locked memory")
+	assert.False(t, ok)
+}
+
+func TestCgroupPidsMaxPaths(t *testing.T) {
+	assert.Equal(t, []string{"/sys/fs/cgroup/system.slice/libyalink.service/pids.max"},
+		cgroupPidsMaxPaths("0::/system.slice/libyalink.service\n"))
+	assert.Equal(t, []string{"/sys/fs/cgroup/pids/docker/abc/pids.max"},
+		cgroupPidsMaxPaths("12:cpu,cpuacct:/docker/abc\n7:pids:/docker/abc\n"))
+}
+
+func TestDetectHypervisor(t *testing.T) {
+	assert.Equal(t, "KVM", detectHypervisor("QEMU", "Standard PC (i440FX + PIIX, 1996)", "", true))
+	assert.Equal(t, "VMware", detectHypervisor("VMware, Inc.", "VMware Virtual Platform", "", true))
+	assert.Equal(t, "Hyper-V", detectHypervisor("Microsoft Corporation", "Virtual Machine", "", true))
+	assert.Equal(t, "Xen", detectHypervisor("", "", "xen", true))
+	assert.Equal(t, "an unknown hypervisor", detectHypervisor("", "", "", true))
+	assert.Equal(t, "", detectHypervisor("Dell Inc.", "PowerEdge R640", "", false))
+
+	assert.True(t, cpuinfoHasFlag("processor\t: 0\nflags\t\t: fpu vme hypervisor lahf_lm\n", "hypervisor"))
+	assert.False(t, cpuinfoHasFlag("processor\t: 0\nflags\t\t: fpu vme lahf_lm\n", "hypervisor"))
+}
+
+func TestHypervisorNotes(t *testing.T) {
+	assert.Len(t, hypervisorNotes("KVM", []nicInfo{{"eth0", "virtio_net", 1}}), 1)
+	assert.Empty(t, hypervisorNotes("KVM", []nicInfo{{"eth0", "virtio_net", 4}}))
+	assert.Len(t, hypervisorNotes("VMware", []nicInfo{{"ens160", "e1000e", 1}}), 1)
+	assert.Empty(t, hypervisorNotes("VMware", []nicInfo{{"ens160", "vmxnet3", 4}}))
+	assert.Empty(t, hypervisorNotes("Google Compute Engine", nil))
+}
+
+func TestFindMACDenials(t *testing.T) {
+	log := `type=AVC msg=audit(1700000000.123:456): avc:  denied  { name_bind } for  pid=812 comm="hysteria" src=443 scontext=system_u:system_r:init_t:s0 tcontext=system_u:object_r:http_port_t:s0 tclass=udp_socket permissive=0
+type=AVC msg=audit(1700000001.123:457): avc:  denied  { read } for  pid=900 comm="nginx" name="cert.pem" dev="sda1" ino=1234 tclass=file permissive=0
+type=SYSCALL msg=audit(1700000002.123:458): arch=c000003e syscall=2 success=no exit=-13 comm="libyalink" exe="/usr/local/bin/libyalink"
+Jan  2 03:04:05 host kernel: audit: type=1400 audit(1700000003.000:9): apparmor="DENIED" operation="open" profile="/usr/local/bin/libyalink" name="/etc/libyalink/key.pem" pid=1000 comm="libyalink" requested_mask="r" denied_mask="r"
+type=AVC msg=audit(1700000004.123:459): avc:  denied  { write } for  pid=1001 comm="server" exe="/opt/libyalink/libyalink" name="app.log" tclass=file`
+	denials := findMACDenials(log, []string{"libyalink", "hysteria"})
+	assert.Len(t, denials, 3)
+	assert.Contains(t, denials[0], "name_bind")
+	assert.Contains(t, denials[1], `apparmor="DENIED"`)
+	assert.Contains(t, denials[2], "app.log")
+
+	assert.Empty(t, findMACDenials(log, []string{"caddy"}))
+}
+
+func TestDirProbeResult(t *testing.T) {
+	dir := t.TempDir()
+	writeErr, execErr := probeDir(dir, false)
+	assert.NoError(t, writeErr)
+	assert.NoError(t, execErr)
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries, "the probe file must be removed")
+	writeErr, _ = probeDir(filepath.Join(dir, "missing"), false)
+	assert.Error(t, writeErr)
+
+	d := doctorDir{Name: "Temp directory", Path: "/tmp", Exec: true, Impact: "Set TMPDIR."}
+	assert.Equal(t, checkOK, dirProbeResult(d, nil, nil).Status)
+	r := dirProbeResult(d, &fs.PathError{Op: "open", Path: "/tmp/x", Err: syscall.EROFS}, nil)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "read-only")
+	r = dirProbeResult(d, nil, &fs.PathError{Op: "fork/exec", Path: "/tmp/x", Err: syscall.EACCES})
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "noexec")
+	assert.Contains(t, r.Message, "/tmp")
+	r = dirProbeResult(d, nil, &fs.PathError{Op: "fork/exec", Path: "/tmp/x", Err: syscall.ENOENT})
+	assert.Equal(t, checkInfo, r.Status)
+}
+
+func TestFileDescriptorLimitResult(t *testing.T) {
+	r := fileDescriptorLimitResult(1024)
+	assert.Equal(t, "Open Files", r.Name)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "1024 (< 65535 recommended)")
+	assert.Contains(t, r.Message, "LimitNOFILE=65535")
+
+	r = fileDescriptorLimitResult(1048576)
+	assert.Equal(t, checkOK, r.Status)
+	assert.Contains(t, r.Message, "1048576")
+
+	r = fileDescriptorLimitResult(^uint64(0))
+	assert.Equal(t, checkOK, r.Status)
+	assert.Contains(t, r.Message, "unlimited")
+}
+
+func TestTCPCongestionResult(t *testing.T) {
+	r := tcpCongestionResult("bbr", "fq", []string{"reno", "cubic", "bbr"})
+	assert.Equal(t, "TCP Congestion", r.Name)
+	assert.Equal(t, checkOK, r.Status)
+
+	r = tcpCongestionResult("bbr", "fq_codel", []string{"reno", "cubic", "bbr"})
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "default qdisc is fq_codel")
+	assert.Contains(t, r.Message, "sysctl -w net.core.default_qdisc=fq'")
+
+	r = tcpCongestionResult("cubic", "fq_codel", []string{"reno", "cubic", "bbr"})
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "cubic, not BBR")
+	assert.Contains(t, r.Message, "'sysctl -w net.core.default_qdisc=fq && sysctl -w net.ipv4.tcp_congestion_control=bbr'")
+
+	// The module isn't loaded yet
+	r = tcpCongestionResult("cubic", "fq_codel", []string{"reno", "cubic"})
+	assert.Contains(t, r.Message, "'modprobe tcp_bbr && sysctl")
+}
+
+func TestConntrackResult(t *testing.T) {
+	r := conntrackResult(1000, 262144)
+	assert.Equal(t, "Conntrack", r.Name)
+	assert.Equal(t, checkOK, r.Status)
+	assert.Equal(t, "1000 of 262144 entries in use (0%)", r.Message)
+
+	r = conntrackResult(52428, 65536)
+	assert.Equal(t, checkOK, r.Status)
+
+	r = conntrackResult(60000, 65536)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "60000 of 65536 entries in use (91%)")
+	assert.Contains(t, r.Message, "sysctl -w net.netfilter.nf_conntrack_max=131072")
+}
+
+func TestClockSkewResult(t *testing.T) {
+	r := clockSkewResult(200*time.Millisecond, nil, true)
+	assert.Equal(t, checkOK, r.Status)
+	assert.NotContains(t, r.Message, "obfs")
+
+	r = clockSkewResult(-5*time.Minute, []string{"signed tokens expire by the server's clock"}, true)
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "5m0s ahead")
+	assert.Contains(t, r.Message, "signed tokens")
+	assert.Contains(t, r.Message, "Salamander obfs doesn't use the time")
+
+	r = clockSkewResult(2*time.Hour, nil, false)
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "2h0m0s behind")
+	assert.NotContains(t, r.Message, "obfs")
+}
+
+func TestNTPResponseOffset(t *testing.T) {
+	put := func(b []byte, tm time.Time) {
+		binary.BigEndian.PutUint32(b, uint32(tm.Unix()+ntpEpochOffset))
+		binary.BigEndian.PutUint32(b[4:], uint32((int64(tm.Nanosecond())<<32)/int64(time.Second)))
+	}
+	t1 := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	t4 := t1.Add(100 * time.Millisecond)
+	// The server's clock is 90s ahead, with 50ms each way
+	resp := make([]byte, 48)
+	resp[0], resp[1] = 0x24, 2
+	put(resp[32:], t1.Add(50*time.Millisecond+90*time.Second))
+	put(resp[40:], t1.Add(50*time.Millisecond+90*time.Second))
+	offset, err := ntpResponseOffset(resp, t1, t4)
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(90*time.Second), float64(offset), float64(time.Microsecond))
+
+	resp[1] = 0 // Kiss-o'-death
+	_, err = ntpResponseOffset(resp, t1, t4)
+	assert.Error(t, err)
+	_, err = ntpResponseOffset(resp[:40], t1, t4)
+	assert.Error(t, err)
+}
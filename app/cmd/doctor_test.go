@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthScore(t *testing.T) {
+	assert.Equal(t, 100, healthScore(0, 0))
+	assert.Equal(t, 95, healthScore(0, 1))
+	assert.Equal(t, 75, healthScore(1, 1))
+	assert.Equal(t, 0, healthScore(6, 0))
+}
+
+func TestDoctorExitCode(t *testing.T) {
+	assert.Equal(t, 0, doctorExitCode(0, 0, false))
+	assert.Equal(t, 0, doctorExitCode(0, 3, false))
+	assert.Equal(t, 1, doctorExitCode(1, 3, false))
+	assert.Equal(t, 0, doctorExitCode(0, 0, true))
+	assert.Equal(t, 2, doctorExitCode(0, 3, true))
+	assert.Equal(t, 1, doctorExitCode(2, 3, true))
+}
+
+func TestDoctorReport(t *testing.T) {
+	report := newDoctorReport([]checkResult{
+		{Name: "Config File", Status: checkOK, Message: "Config is valid YAML"},
+		{Name: "UDP Buffers", Status: checkWarn, Message: "rmem_max is low"},
+		{Name: "Port", Status: checkFail, Message: "UDP 443 is in use"},
+		{Name: "Public Address", Status: checkInfo, Message: "Skipped"},
+	})
+	bs, err := json.Marshal(report)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"checks": [
+			{"name": "Config File", "status": "ok", "message": "Config is valid YAML"},
+			{"name": "UDP Buffers", "status": "warn", "message": "rmem_max is low"},
+			{"name": "Port", "status": "fail", "message": "UDP 443 is in use"},
+			{"name": "Public Address", "status": "info", "message": "Skipped"}
+		],
+		"summary": {"ok": 1, "warn": 1, "fail": 1, "info": 1, "score": 75}
+	}`, string(bs))
+
+	// An empty run is still an array, not null
+	bs, err = json.Marshal(newDoctorReport(nil))
+	assert.NoError(t, err)
+	assert.Contains(t, string(bs), `"checks":[]`)
+}
+
+func TestParseHealthHistory(t *testing.T) {
+	data := "2026-01-02T03:04:05Z 75 1 1\n" +
+		"garbage\n" +
+		"2026-01-03T03:04:05Z 100 0 0\n"
+	got := parseHealthHistory(data)
+	assert.Len(t, got, 2)
+	assert.Equal(t, 75, got[0].Score)
+	assert.Equal(t, 1, got[0].FailCount)
+	assert.Equal(t, 100, got[1].Score)
+	assert.Equal(t, time.Date(2026, 1, 3, 3, 4, 5, 0, time.UTC), got[1].Time.UTC())
+}
@@ -0,0 +1,454 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func checkTLSACMEConflict() []checkResult {
+	// If config is not readable, skip
+	if viper.ConfigFileUsed() == "" {
+		return nil
+	}
+
+	hasTLS := viper.IsSet("tls")
+	hasACME := viper.IsSet("acme")
+
+	if hasTLS && hasACME {
+		return []checkResult{{
+			Name:    "TLS/ACME",
+			Status:  checkFail,
+			Message: "Both 'tls' and 'acme' are set. You must use one or the other, not both.",
+		}}
+	}
+	if !hasTLS && !hasACME {
+		return []checkResult{{
+			Name:    "TLS/ACME",
+			Status:  checkFail,
+			Message: "Neither 'tls' nor 'acme' is configured. One is required for the server to start.",
+		}}
+	}
+	if hasTLS {
+		return []checkResult{{
+			Name:    "TLS/ACME",
+			Status:  checkOK,
+			Message: "TLS mode: using local certificate files.",
+		}}
+	}
+	return []checkResult{{
+		Name:    "TLS/ACME",
+		Status:  checkOK,
+		Message: "ACME mode: using automatic certificate provisioning.",
+	}}
+}
+
+func checkTLSFiles() []checkResult {
+	if !viper.IsSet("tls") {
+		return nil // ACME mode, no files to check
+	}
+
+	certPath := viper.GetString("tls.cert")
+	keyPath := viper.GetString("tls.key")
+
+	var results []checkResult
+
+	// Check cert file
+	if certPath == "" {
+		results = append(results, checkResult{
+			Name:    "TLS Cert",
+			Status:  checkFail,
+			Message: "tls.cert path is empty.",
+		})
+	} else {
+		if r := checkFileReadable("TLS Cert", certPath); r.Status != checkOK {
+			results = append(results, r)
+		} else {
+			results = append(results, r)
+		}
+	}
+
+	// Check key file
+	if keyPath == "" {
+		results = append(results, checkResult{
+			Name:    "TLS Key",
+			Status:  checkFail,
+			Message: "tls.key path is empty.",
+		})
+	} else {
+		if r := checkFileReadable("TLS Key", keyPath); r.Status != checkOK {
+			results = append(results, r)
+		} else {
+			results = append(results, r)
+		}
+	}
+
+	// If both are readable, try to parse the pair
+	if certPath != "" && keyPath != "" {
+		pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			results = append(results, checkResult{
+				Name:    "TLS Pair",
+				Status:  checkFail,
+				Message: fmt.Sprintf("Certificate/Key pair is invalid: %v", err),
+			})
+		} else {
+			results = append(results, checkResult{
+				Name:    "TLS Pair",
+				Status:  checkOK,
+				Message: "Certificate and key pair loaded successfully.",
+			})
+			results = append(results, certChainResult(pair.Certificate))
+			if leaf, err := x509.ParseCertificate(pair.Certificate[0]); err == nil {
+				results = append(results, certExpiryResult(leaf, time.Now()))
+			}
+			results = append(results, checkResult{
+				Name:   "TLS Reload",
+				Status: checkOK,
+				Message: fmt.Sprintf("The server watches both files and loads a renewed pair within %s, no restart needed.",
+					2*certWatchInterval),
+			})
+		}
+	}
+
+	return results
+}
+
+func checkFileReadable(name, path string) checkResult {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return checkResult{
+			Name:    name,
+			Status:  checkFail,
+			Message: fmt.Sprintf("File not found: %s", path),
+		}
+	}
+	if os.IsPermission(err) {
+		return checkResult{
+			Name:    name,
+			Status:  checkFail,
+			Message: fmt.Sprintf("Permission denied on %s", path),
+		}
+	}
+	if err != nil {
+		return checkResult{
+			Name:    name,
+			Status:  checkFail,
+			Message: fmt.Sprintf("Error accessing %s: %v", path, err),
+		}
+	}
+
+	// Try actually reading
+	f, err := os.Open(path)
+	if err != nil {
+		return checkResult{
+			Name:    name,
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot open %s: %v", path, err),
+		}
+	}
+	f.Close()
+
+	// Check file isn't empty
+	if info.Size() == 0 {
+		return checkResult{
+			Name:    name,
+			Status:  checkFail,
+			Message: fmt.Sprintf("File is empty: %s", path),
+		}
+	}
+
+	return checkResult{
+		Name:    name,
+		Status:  checkOK,
+		Message: fmt.Sprintf("Readable (%d bytes): %s", info.Size(), path),
+	}
+}
+
+// loadLeafCertificate parses the first certificate of a PEM file.
+func loadLeafCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certChainResult checks that the certificates of a cert file (DER, leaf
+// first) form a chain a client can verify without fetching anything.
+// Browsers fetch missing intermediates, most other TLS clients don't.
+func certChainResult(chain [][]byte) checkResult {
+	r := checkResult{Name: "TLS Chain"}
+	certs := make([]*x509.Certificate, 0, len(chain))
+	for i, der := range chain {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			r.Status = checkFail
+			r.Message = fmt.Sprintf("Certificate %d of %d in the cert file is invalid: %v", i+1, len(chain), err)
+			return r
+		}
+		certs = append(certs, cert)
+	}
+	leaf := certs[0]
+	switch {
+	case isSelfSignedCertificate(leaf):
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("Self-signed, no chain needed (%d certificate(s)).", len(certs))
+	case len(certs) == 1:
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("The cert file only has the server's certificate, issued by %s, without the intermediate certificates. "+
+			"Clients that don't have them already fail to verify it, while it works in a browser. "+
+			"Use the full chain file instead, e.g. fullchain.pem from certbot.", leaf.Issuer.String())
+	default:
+		for i := 0; i < len(certs)-1; i++ {
+			if err := certs[i].CheckSignatureFrom(certs[i+1]); err != nil {
+				r.Status = checkWarn
+				r.Message = fmt.Sprintf("Certificate %d of %d in the cert file (%s) isn't issued by the next one (%s). "+
+					"Clients may fail to verify the chain, put the certificates in order: the server's first, then each issuer.",
+					i+1, len(certs), certs[i].Subject.String(), certs[i+1].Subject.String())
+				return r
+			}
+		}
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("Complete chain of %d certificates, the server's and %d issuer(s).", len(certs), len(certs)-1)
+	}
+	return r
+}
+
+const (
+	certExpiryWarn = 30 * 24 * time.Hour
+	certExpiryFail = 48 * time.Hour
+)
+
+// certExpiryResult reports how long the server's certificate is valid for,
+// and the names on it, which must include the SNI the clients send.
+func certExpiryResult(leaf *x509.Certificate, now time.Time) checkResult {
+	r := checkResult{Name: "TLS Expiry"}
+	names := certNames(leaf)
+	left := leaf.NotAfter.Sub(now)
+	days := int(left.Hours() / 24)
+	switch {
+	case now.Before(leaf.NotBefore):
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("The certificate for %s isn't valid until %s, clients reject it until then. Check the server's clock.",
+			names, leaf.NotBefore.UTC().Format(time.RFC3339))
+	case left <= 0:
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("The certificate for %s expired on %s, clients that verify it can't connect. Renew it.",
+			names, leaf.NotAfter.UTC().Format(time.RFC3339))
+	case left < certExpiryFail:
+		r.Status = checkFail
+		r.Message = fmt.Sprintf("The certificate for %s expires in %s, on %s. Renew it now.",
+			names, left.Round(time.Minute), leaf.NotAfter.UTC().Format(time.RFC3339))
+	case left < certExpiryWarn:
+		r.Status = checkWarn
+		r.Message = fmt.Sprintf("The certificate for %s expires in %d day(s), on %s. Renew it soon, the server picks up the new files by itself.",
+			names, days, leaf.NotAfter.UTC().Format(time.DateOnly))
+	default:
+		r.Status = checkOK
+		r.Message = fmt.Sprintf("The certificate for %s is valid for %d more days, until %s.",
+			names, days, leaf.NotAfter.UTC().Format(time.DateOnly))
+	}
+	return r
+}
+
+// certNames lists the DNS and IP names of a certificate, or its common
+// name, which clients no longer check, if it has none.
+func certNames(cert *x509.Certificate) string {
+	names := slices.Clone(cert.DNSNames)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) == 0 {
+		if cert.Subject.CommonName == "" {
+			return "no names"
+		}
+		return fmt.Sprintf("CN %s only (no SAN, which clients require)", cert.Subject.CommonName)
+	}
+	return strings.Join(names, ", ")
+}
+
+// isSelfSignedCertificate reports whether cert is its own issuer and
+// signed with its own key.
+func isSelfSignedCertificate(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+func checkACMEChallenge() []checkResult {
+	if !viper.IsSet("acme") || viper.IsSet("tls") {
+		return nil
+	}
+	typ := viper.GetString("acme.type")
+	httpPort, tlsPort := viper.GetInt("acme.altHTTPPort"), viper.GetInt("acme.altTLSALPNPort")
+	switch strings.ToLower(typ) {
+	case "http":
+		httpPort = viper.GetInt("acme.http.altPort")
+	case "tls":
+		tlsPort = viper.GetInt("acme.tls.altPort")
+	}
+	challenges := acmeChallenges(typ, viper.GetBool("acme.disableHTTP"), viper.GetBool("acme.disableTLSALPN"), httpPort, tlsPort)
+	if len(challenges) == 0 {
+		if typ != "" {
+			return nil // Unsupported type, the server config check reports it
+		}
+		return []checkResult{{
+			Name:    "ACME Challenge",
+			Status:  checkFail,
+			Message: "acme.disableHTTP and acme.disableTLSALPN are both set, so no challenge is left. Use acme.type: dns instead.",
+		}}
+	}
+
+	// The masquerade listeners hold their ports while the server runs,
+	// and don't answer challenges
+	masqueradePorts := map[int]string{}
+	for _, key := range []string{"masquerade.listenHTTP", "masquerade.listenHTTPS"} {
+		if _, port, err := net.SplitHostPort(viper.GetString(key)); err == nil {
+			if n, err := strconv.Atoi(port); err == nil {
+				masqueradePorts[n] = key
+			}
+		}
+	}
+	var results []checkResult
+	for _, c := range challenges {
+		if c.Port == 0 {
+			results = append(results, acmeDNSResult(viper.GetString("acme.dns.name"), viper.GetStringMapString("acme.dns.config")))
+			continue
+		}
+		if key, ok := masqueradePorts[c.Port]; ok {
+			results = append(results, checkResult{
+				Name:   "ACME Challenge",
+				Status: checkFail,
+				Message: fmt.Sprintf("%s needs TCP port %d, which %s also uses, so certificates can't be renewed while the server runs. "+
+					"Use acme.type: dns, or move %s.", c.Name, c.Port, key, key),
+			})
+			continue
+		}
+		ln, err := net.Listen("tcp", net.JoinHostPort(viper.GetString("acme.listenHost"), strconv.Itoa(c.Port)))
+		if err == nil {
+			ln.Close()
+		}
+		results = append(results, acmePortResult(c, err))
+	}
+	// certmagic uses whichever challenge works, one is enough
+	if len(results) > 1 && slices.ContainsFunc(results, func(r checkResult) bool { return r.Status == checkOK }) {
+		for i := range results {
+			if results[i].Status == checkFail {
+				results[i].Status = checkWarn
+			}
+		}
+	}
+	return results
+}
+
+// acmeStaleLockAge is how old a lock file must be to be stale. certmagic
+// refreshes the locks it holds every 5 seconds and treats them as stale
+// after 10, we give it some more slack.
+const acmeStaleLockAge = time.Minute
+
+func checkACMEStorage() []checkResult {
+	if !viper.IsSet("acme") || viper.IsSet("tls") {
+		return nil
+	}
+	dir := viper.GetString("acme.dir")
+	if dir == "" {
+		dir = envOrDefaultString(appACMEDirEnv, "acme")
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return []checkResult{{
+			Name:    "ACME Storage",
+			Status:  checkOK,
+			Message: fmt.Sprintf("Storage %s does not exist yet, it will be created on first start.", dir),
+		}}
+	}
+
+	var results []checkResult
+	var exposedKeys, staleLocks, certs []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		switch filepath.Ext(path) {
+		case ".key":
+			// Windows has no Unix permission bits to check
+			if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+				exposedKeys = append(exposedKeys, fmt.Sprintf("%s (%#o)", rel, info.Mode().Perm()))
+			}
+		case ".crt":
+			certs = append(certs, path)
+		case ".lock":
+			if age := time.Since(info.ModTime()); age > acmeStaleLockAge {
+				staleLocks = append(staleLocks, fmt.Sprintf("%s (%s old)", rel, age.Round(time.Second)))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []checkResult{{
+			Name:    "ACME Storage",
+			Status:  checkFail,
+			Message: fmt.Sprintf("Cannot read ACME storage %s: %v", dir, err),
+		}}
+	}
+
+	results = append(results, checkResult{
+		Name:    "ACME Storage",
+		Status:  checkOK,
+		Message: fmt.Sprintf("Storage: %s", dir),
+	})
+	if len(exposedKeys) > 0 {
+		results = append(results, checkResult{
+			Name:   "ACME Storage",
+			Status: checkWarn,
+			Message: fmt.Sprintf("Private keys readable by group/others: %s. Run: chmod -R go-rwx %s",
+				strings.Join(exposedKeys, ", "), dir),
+		})
+	}
+	if len(staleLocks) > 0 {
+		results = append(results, checkResult{
+			Name:   "ACME Storage",
+			Status: checkWarn,
+			Message: fmt.Sprintf("Stale lock files left by a crashed or killed server: %s. "+
+				"They can block renewal, delete them while the server is stopped.", strings.Join(staleLocks, ", ")),
+		})
+	}
+
+	// An invalid acme.renewBefore fails the server config, checked above
+	ratio, err := acmeRenewalWindowRatio(viper.GetDuration("acme.renewBefore"))
+	if err != nil {
+		return results
+	}
+	for _, path := range certs {
+		leaf, err := loadLeafCertificate(path)
+		if err != nil {
+			continue
+		}
+		results = append(results, acmeRenewalResult(leaf, ratio, time.Now()))
+	}
+	return results
+}
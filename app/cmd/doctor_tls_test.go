@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+func TestIsSelfSignedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, utils.GenerateSelfSignedCert([]string{"example.com"}, certFile, keyFile))
+	cert, err := loadLeafCertificate(certFile)
+	assert.NoError(t, err)
+	assert.True(t, isSelfSignedCertificate(cert))
+
+	_, err = loadLeafCertificate(keyFile)
+	assert.Error(t, err)
+}
+
+func TestCheckACMEStorage(t *testing.T) {
+	defer viper.Reset()
+
+	// Not created yet, it goes in the parent
+	dir := filepath.Join(t.TempDir(), "acme")
+	viper.Set("acme.domains", []string{"vpn.example.com"})
+	viper.Set("acme.dir", dir)
+	r := checkACMEStorage()
+	assert.Len(t, r, 1)
+	assert.Equal(t, checkOK, r[0].Status)
+	assert.Contains(t, r[0].Message, "will be created on first start")
+	writable := checkWritableDirs()
+	assert.Equal(t, checkResult{Name: "Writable Dirs", Status: checkOK,
+		Message: fmt.Sprintf("ACME storage %s is writable.", filepath.Dir(dir))}, writable[len(writable)-1])
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "certificates"), 0o700))
+	key := filepath.Join(dir, "certificates", "vpn.example.com.key")
+	assert.NoError(t, os.WriteFile(key, []byte("key"), 0o644))
+	lock := filepath.Join(dir, "locks", "issue_cert_vpn.example.com.lock")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(lock), 0o700))
+	assert.NoError(t, os.WriteFile(lock, nil, 0o600))
+	assert.NoError(t, os.Chtimes(lock, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+	r = checkACMEStorage()
+	assert.Len(t, r, 3)
+	assert.Equal(t, "Storage: "+dir, r[0].Message)
+	assert.Equal(t, checkWarn, r[1].Status)
+	assert.Contains(t, r[1].Message, filepath.Join("certificates", "vpn.example.com.key")+" (0644)")
+	assert.Equal(t, checkWarn, r[2].Status)
+	assert.Contains(t, r[2].Message, filepath.Join("locks", "issue_cert_vpn.example.com.lock"))
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to any directory")
+	}
+	assert.NoError(t, os.Chmod(dir, 0o500))
+	defer os.Chmod(dir, 0o700)
+	writable = checkWritableDirs()
+	assert.Equal(t, checkWarn, writable[len(writable)-1].Status)
+	assert.Contains(t, writable[len(writable)-1].Message, "ACME storage "+dir+" is not writable")
+}
+
+func TestCertExpiryResult(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{
+		DNSNames:    []string{"vpn.example.com", "*.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("192.0.2.10")},
+		NotBefore:   now.Add(-60 * 24 * time.Hour),
+	}
+	tests := []struct {
+		left   time.Duration
+		status string
+		want   string
+	}{
+		{90 * 24 * time.Hour, checkOK, "valid for 90 more days"},
+		{10 * 24 * time.Hour, checkWarn, "expires in 10 day(s)"},
+		{24 * time.Hour, checkFail, "expires in 24h0m0s"},
+		{-time.Hour, checkFail, "expired on 2026-10-16T11:00:00Z"},
+	}
+	for _, tt := range tests {
+		cert.NotAfter = now.Add(tt.left)
+		r := certExpiryResult(cert, now)
+		assert.Equal(t, "TLS Expiry", r.Name)
+		assert.Equal(t, tt.status, r.Status, tt.want)
+		assert.Contains(t, r.Message, tt.want)
+		assert.Contains(t, r.Message, "vpn.example.com, *.example.com, 192.0.2.10")
+	}
+
+	cert = &x509.Certificate{
+		Subject:   pkix.Name{CommonName: "old.example.com"},
+		NotBefore: now.Add(time.Hour),
+		NotAfter:  now.Add(90 * 24 * time.Hour),
+	}
+	r := certExpiryResult(cert, now)
+	assert.Equal(t, checkFail, r.Status)
+	assert.Contains(t, r.Message, "isn't valid until")
+	assert.Contains(t, r.Message, "CN old.example.com only")
+}
+
+func TestCertChainResult(t *testing.T) {
+	// issue returns the DER of a certificate for name, signed by parent
+	// (self-signed if nil), and its key
+	issue := func(name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) ([]byte, *x509.Certificate, *ecdsa.PrivateKey) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(time.Now().UnixNano()),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  isCA,
+			BasicConstraintsValid: true,
+		}
+		if parent == nil {
+			parent, parentKey = tmpl, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+		assert.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		assert.NoError(t, err)
+		return der, cert, key
+	}
+	rootDER, root, rootKey := issue("Root CA", true, nil, nil)
+	interDER, inter, interKey := issue("Intermediate CA", true, root, rootKey)
+	leafDER, _, _ := issue("example.com", false, inter, interKey)
+	selfDER, _, _ := issue("self.example.com", false, nil, nil)
+
+	r := certChainResult([][]byte{leafDER})
+	assert.Equal(t, checkWarn, r.Status)
+	assert.Contains(t, r.Message, "Intermediate CA")
+	r = certChainResult([][]byte{leafDER, interDER})
+	assert.Equal(t, checkOK, r.Status)
+	assert.Contains(t, r.Message, "2 certificates")
+	assert.Equal(t, checkOK, certChainResult([][]byte{leafDER, interDER, rootDER}).Status)
+	assert.Equal(t, checkWarn, certChainResult([][]byte{leafDER, rootDER}).Status)
+	assert.Equal(t, checkOK, certChainResult([][]byte{selfDER}).Status)
+	assert.Equal(t, checkFail, certChainResult([][]byte{leafDER, []byte("junk")}).Status)
+}
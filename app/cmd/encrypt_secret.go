@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+var encryptSecretCmd = &cobra.Command{
+	Use:   "encrypt-secret",
+	Short: "Encrypt a secret for use in the config file",
+	Long: `Encrypt a secret such as the auth password, so that the config file can be
+stored (e.g. in git) without it. The secret is read from stdin, so it doesn't
+end up in the shell history, and the key from HYSTERIA_SECRET_KEY. The server
+needs the same HYSTERIA_SECRET_KEY to decrypt it.
+
+Examples:
+  libyalink encrypt-secret --generate-key
+  HYSTERIA_SECRET_KEY=... libyalink encrypt-secret < password.txt
+
+Then use the printed "enc:..." value as auth.password in the config.`,
+	Run: runEncryptSecret,
+}
+
+var encryptSecretGenerateKey bool
+
+func init() {
+	encryptSecretCmd.Flags().BoolVar(&encryptSecretGenerateKey, "generate-key", false, "print a new random key for HYSTERIA_SECRET_KEY and exit")
+	rootCmd.AddCommand(encryptSecretCmd)
+}
+
+func runEncryptSecret(cmd *cobra.Command, args []string) {
+	if encryptSecretGenerateKey {
+		key, err := utils.GenerateSecretKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(key)
+		return
+	}
+
+	key, err := utils.ParseSecretKey(os.Getenv(appSecretKeyEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v. Create one with --generate-key.\n", appSecretKeyEnv, err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "Enter the secret to encrypt:")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	secret := strings.TrimRight(line, "\r\n")
+	if secret == "" {
+		fmt.Fprintln(os.Stderr, "Error: empty secret.")
+		os.Exit(1)
+	}
+	enc, err := utils.EncryptSecret(key, secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(enc)
+}
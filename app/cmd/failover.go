@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFailoverInterval = 2 * time.Second
+	defaultFailoverTimeout  = 10 * time.Second
+
+	failoverRolePrimary = "primary"
+	failoverRoleStandby = "standby"
+)
+
+type serverConfigFailover struct {
+	Role          string        `mapstructure:"role"`
+	NodeID        string        `mapstructure:"nodeID"`
+	HeartbeatFile string        `mapstructure:"heartbeatFile"`
+	Interval      time.Duration `mapstructure:"interval"`
+	Timeout       time.Duration `mapstructure:"timeout"`
+}
+
+// failoverCoordinator implements a simple active-passive failover scheme
+// between two (or more) servers sharing the same config.
+//
+// The active node periodically writes "<node id> <sequence>" to a heartbeat
+// file on storage shared by all nodes (NFS, a synced volume, etc.), with the
+// sequence number incremented on every write. A node does not bind the listen
+// port while another node's heartbeat is fresh, i.e. the file changed within
+// the timeout as measured by this node's own clock, so that clocks that
+// disagree between machines don't matter. When the heartbeat goes stale it
+// takes over and starts heartbeating itself. An active node that finds
+// another node's heartbeat in the file steps down.
+type failoverCoordinator struct {
+	Role     string
+	NodeID   string
+	File     string
+	Interval time.Duration
+	Timeout  time.Duration
+	// Demoted is called when another node has taken over the heartbeat
+	// file, by default it exits, so that the service manager restarts
+	// this node and it waits for the other one to fail.
+	Demoted func(otherNode string)
+
+	seq uint64 // Last sequence number written
+}
+
+func newFailoverCoordinator(c serverConfigFailover) (*failoverCoordinator, error) {
+	f := &failoverCoordinator{
+		Role:     strings.ToLower(c.Role),
+		NodeID:   c.NodeID,
+		File:     c.HeartbeatFile,
+		Interval: c.Interval,
+		Timeout:  c.Timeout,
+		Demoted: func(otherNode string) {
+			logger.Fatal("[LibyaLink] another node took over, stepping down to avoid serving from both",
+				zap.String("otherNode", otherNode))
+		},
+	}
+	if f.Role != failoverRolePrimary && f.Role != failoverRoleStandby {
+		return nil, configError{Field: "failover.role", Err: errors.New("must be either primary or standby")}
+	}
+	if f.File == "" {
+		return nil, configError{Field: "failover.heartbeatFile", Err: errors.New("empty heartbeat file path")}
+	}
+	if f.NodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, configError{Field: "failover.nodeID", Err: err}
+		}
+		f.NodeID = hostname
+	}
+	if strings.ContainsAny(f.NodeID, " \t\r\n") {
+		return nil, configError{Field: "failover.nodeID", Err: errors.New("must not contain whitespace")}
+	}
+	if f.Interval == 0 {
+		f.Interval = defaultFailoverInterval
+	}
+	if f.Timeout == 0 {
+		f.Timeout = defaultFailoverTimeout
+	}
+	if f.Timeout < 2*f.Interval {
+		return nil, configError{Field: "failover.timeout", Err: errors.New("must be at least twice the heartbeat interval")}
+	}
+	return f, nil
+}
+
+// readHeartbeat returns the node that last wrote the heartbeat file and the
+// sequence number of that write.
+func (f *failoverCoordinator) readHeartbeat() (node string, seq uint64, err error) {
+	bs, err := os.ReadFile(f.File)
+	if err != nil {
+		return "", 0, err
+	}
+	fields := strings.Fields(string(bs))
+	if len(fields) != 2 {
+		return "", 0, fmt.Errorf("malformed heartbeat file %s", f.File)
+	}
+	seq, err = strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed heartbeat file %s: %w", f.File, err)
+	}
+	return fields[0], seq, nil
+}
+
+// writeHeartbeat atomically replaces the heartbeat file with the next
+// sequence number, so that the other node never observes a partially
+// written file.
+func (f *failoverCoordinator) writeHeartbeat() error {
+	tmp := filepath.Join(filepath.Dir(f.File), "."+filepath.Base(f.File)+"."+f.NodeID)
+	content := fmt.Sprintf("%s %d\n", f.NodeID, f.seq+1)
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.File); err != nil {
+		return err
+	}
+	f.seq++
+	return nil
+}
+
+// Start blocks until this node should be active, which for both roles is
+// once no other node's heartbeat is fresh. The primary only differs in not
+// expecting to wait. Once active, a background goroutine keeps the
+// heartbeat fresh.
+func (f *failoverCoordinator) Start() {
+	if f.Role == failoverRoleStandby {
+		logger.Info("[LibyaLink] failover role: standby, waiting for the active node to fail",
+			zap.String("node", f.NodeID),
+			zap.String("heartbeatFile", f.File),
+			zap.Duration("timeout", f.Timeout))
+		f.waitForTakeover()
+		logger.Warn("[LibyaLink] failover role transition: standby -> active", zap.String("node", f.NodeID))
+	} else {
+		logger.Info("[LibyaLink] failover role: primary, checking that no other node is active",
+			zap.String("node", f.NodeID),
+			zap.String("heartbeatFile", f.File))
+		f.waitForTakeover()
+		logger.Info("[LibyaLink] failover role transition: primary -> active", zap.String("node", f.NodeID))
+	}
+	if err := f.writeHeartbeat(); err != nil {
+		logger.Error("failed to write failover heartbeat", zap.Error(err))
+	}
+	go f.heartbeatLoop()
+}
+
+// waitForTakeover returns once the heartbeat file is missing, was last
+// written by this node, or hasn't changed for the timeout. It continues the
+// sequence found in the file.
+func (f *failoverCoordinator) waitForTakeover() {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+	var lastNode string
+	var lastSeq uint64
+	var lastChange time.Time
+	for {
+		node, seq, err := f.readHeartbeat()
+		switch {
+		case os.IsNotExist(err):
+			logger.Info("no failover heartbeat found, taking over", zap.String("heartbeatFile", f.File))
+			return
+		case err != nil:
+			// Keep waiting, the shared storage may be temporarily unavailable.
+			// Taking over here could result in both nodes being active.
+			logger.Warn("failed to read failover heartbeat", zap.Error(err))
+		case node == f.NodeID:
+			logger.Info("failover heartbeat belongs to this node, taking over")
+			f.seq = seq
+			return
+		case lastChange.IsZero() || node != lastNode || seq != lastSeq:
+			// Fresh, or seen for the first time, which starts the timeout
+			if lastChange.IsZero() {
+				logger.Info("another node is active, waiting for its heartbeat to stop",
+					zap.String("activeNode", node))
+			}
+			lastNode, lastSeq, lastChange = node, seq, time.Now()
+		case time.Since(lastChange) > f.Timeout:
+			logger.Warn("active node heartbeat is stale, taking over",
+				zap.String("activeNode", node),
+				zap.Duration("unchangedFor", time.Since(lastChange).Round(time.Millisecond)))
+			f.seq = seq
+			return
+		}
+		<-ticker.C
+	}
+}
+
+// heartbeatLoop keeps the heartbeat fresh until another node takes over the
+// file, then calls Demoted and returns.
+func (f *failoverCoordinator) heartbeatLoop() {
+	ticker := time.NewTicker(f.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		// Another node writing the file while we're active means it took
+		// over, e.g. while this node was paused or cut off from the
+		// storage. Only one of them may serve.
+		if node, _, err := f.readHeartbeat(); err == nil && node != f.NodeID {
+			f.Demoted(node)
+			return
+		}
+		if err := f.writeHeartbeat(); err != nil {
+			logger.Error("failed to write failover heartbeat", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newTestFailoverCoordinator(t *testing.T, file, node string) *failoverCoordinator {
+	f, err := newFailoverCoordinator(serverConfigFailover{
+		Role:          failoverRoleStandby,
+		NodeID:        node,
+		HeartbeatFile: file,
+		Interval:      5 * time.Millisecond,
+		Timeout:       50 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+	return f
+}
+
+func TestFailoverHeartbeatSequence(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	file := filepath.Join(t.TempDir(), "libyalink.hb")
+	assert.NoError(t, os.WriteFile(file, []byte("box1 7\n"), 0o644))
+
+	// Taking over continues the sequence of the previous node, so that
+	// the others see the file change
+	f := newTestFailoverCoordinator(t, file, "box2")
+	f.waitForTakeover()
+	assert.NoError(t, f.writeHeartbeat())
+	node, seq, err := f.readHeartbeat()
+	assert.NoError(t, err)
+	assert.Equal(t, "box2", node)
+	assert.Equal(t, uint64(8), seq)
+	assert.NoError(t, f.writeHeartbeat())
+	_, seq, _ = f.readHeartbeat()
+	assert.Equal(t, uint64(9), seq)
+
+	assert.NoError(t, os.WriteFile(file, []byte("box1 1700000000\nextra"), 0o644))
+	_, _, err = f.readHeartbeat()
+	assert.Error(t, err)
+}
+
+func TestFailoverWaitsForFreshHeartbeat(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	// Whatever its role, a node waits while another node's heartbeat is
+	// fresh, and takes over once it stops
+	for _, role := range []string{failoverRolePrimary, failoverRoleStandby} {
+		file := filepath.Join(t.TempDir(), "libyalink.hb")
+		active := newTestFailoverCoordinator(t, file, "box1")
+		assert.NoError(t, active.writeHeartbeat())
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			for {
+				select {
+				case <-stop:
+					return
+				case <-time.After(active.Interval):
+					_ = active.writeHeartbeat()
+				}
+			}
+		}()
+
+		f := newTestFailoverCoordinator(t, file, "box2")
+		f.Role = role
+		done := make(chan struct{})
+		go func() {
+			f.waitForTakeover()
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatalf("%s took over while box1 was heartbeating", role)
+		case <-time.After(4 * f.Timeout):
+		}
+		close(stop)
+		<-stopped
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s didn't take over once box1 stopped", role)
+		}
+	}
+}
+
+func TestFailoverStaleHeartbeat(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	file := filepath.Join(t.TempDir(), "libyalink.hb")
+	// Written by a node with its clock far in the future, which doesn't
+	// keep the heartbeat fresh as only changes of the file count
+	assert.NoError(t, os.WriteFile(file, []byte("box1 4102444800000000000\n"), 0o644))
+
+	f := newTestFailoverCoordinator(t, file, "box2")
+	start := time.Now()
+	f.waitForTakeover()
+	assert.True(t, time.Since(start) >= f.Timeout)
+
+	// A missing file or one of our own needs no wait
+	f = newTestFailoverCoordinator(t, filepath.Join(t.TempDir(), "missing.hb"), "box2")
+	start = time.Now()
+	f.waitForTakeover()
+	assert.True(t, time.Since(start) < f.Timeout)
+	assert.NoError(t, os.WriteFile(file, []byte("box2 3\n"), 0o644))
+	f = newTestFailoverCoordinator(t, file, "box2")
+	start = time.Now()
+	f.waitForTakeover()
+	assert.True(t, time.Since(start) < f.Timeout)
+	assert.Equal(t, uint64(3), f.seq)
+}
+
+func TestFailoverDemotion(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	file := filepath.Join(t.TempDir(), "libyalink.hb")
+	f := newTestFailoverCoordinator(t, file, "box1")
+	demoted := make(chan string, 1)
+	f.Demoted = func(otherNode string) { demoted <- otherNode }
+	assert.NoError(t, f.writeHeartbeat())
+	done := make(chan struct{})
+	go func() {
+		f.heartbeatLoop()
+		close(done)
+	}()
+
+	// Still ours after a few intervals
+	time.Sleep(5 * f.Interval)
+	node, seq, err := f.readHeartbeat()
+	assert.NoError(t, err)
+	assert.Equal(t, "box1", node)
+	assert.True(t, seq > 1)
+
+	// box2 took over, e.g. while box1 was cut off from the storage
+	other := newTestFailoverCoordinator(t, file, "box2")
+	other.seq = 100
+	for stepped := false; !stepped; {
+		assert.NoError(t, other.writeHeartbeat())
+		select {
+		case node := <-demoted:
+			assert.Equal(t, "box2", node)
+			stepped = true
+		case <-time.After(other.Interval):
+		}
+		if other.seq > 200 {
+			t.Fatal("box1 didn't step down")
+		}
+	}
+	<-done
+	assert.NoError(t, other.writeHeartbeat())
+	node, _, _ = f.readHeartbeat()
+	assert.Equal(t, "box2", node)
+}
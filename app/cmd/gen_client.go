@@ -1,22 +1,85 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"io"
+	"net"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	"github.com/apernet/hysteria/extras/v2/auth"
 )
 
 var (
-	genClientServer   string
-	genClientPort     int
-	genClientAuth     string
-	genClientInsecure bool
-	genClientSNI      string
-	genClientObfs     string
-	genClientPreset   string
-	genClientOutput   string
+	genClientServer       string
+	genClientPort         int
+	genClientISP          string
+	genClientAuth         string
+	genClientInsecure     bool
+	genClientSNI          string
+	genClientDecoySNI     string
+	genClientFrontAddress string
+	genClientListDecoy    bool
+	genClientObfs         string
+	genClientPreset       string
+	genClientProfile      string
+	genClientPresetFile   string
+	genClientListPresets  bool
+	genClientOutput       string
+	genClientOutputDir    string
+	genClientAllPlatforms bool
+
+	genClientStandbyServers []string
+	genClientResolve        bool
+	genClientALPN           []string
+	genClientTunnelProcs    []string
+	genClientRouteDomains   []string
+	genClientUnblockCommon  bool
+	genClientFallbackDirect bool
+
+	genClientNativeFormat string
+	genClientMinifyNative bool
+	genClientLauncher     string
+	genClientKeepAlive    time.Duration
+	genClientRecvWindow   uint64
+	genClientRecvConn     uint64
+	genClientClipboard    string
+	genClientTemplate     string
+	genClientBasedOn      string
+	genClientImport       string
+	genClientURIOnly      bool
+	genClientQR           bool
+	genClientBrandTitle   string
+	genClientBrandLogo    string
+	genClientFromServer   string
+	genClientSecretRef    string
+	genClientJSONOnly     bool
+	genClientValidateOnly bool
+	genClientTuningNotes  bool
+	genClientClientType   string
+	genClientIndent       string
+	genClientAutoBrutal   bool
+	genClientSpeedtest    string
+
+	genClientTTL         time.Duration
+	genClientTokenSecret string
+	genClientTokenID     string
+	genClientSchedule    string
+	genClientScheduleTZ  string
 )
 
 var genClientCmd = &cobra.Command{
@@ -31,7 +94,51 @@ Examples:
   libyalink gen-client --server 1.2.3.4 --auth "mypassword"
   libyalink gen-client --server 1.2.3.4 --port 8443 --auth "mypassword" --insecure
   libyalink gen-client --server 1.2.3.4 --auth "mypassword" --preset fiber
-  libyalink gen-client --server 1.2.3.4 --auth "mypassword" -o client.json`,
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --preset-file presets.yaml --preset ltt-fiber-200
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" -o client.json
+  libyalink gen-client --server 1.2.3.4 --standby-server 5.6.7.8 --auth "mypassword"
+  libyalink gen-client --server vpn.example.com --resolve --auth "mypassword"
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --native-format yaml
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --clipboard
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --native-format yaml --launcher win -o client/client.txt
+  libyalink gen-client --server 1.2.3.4 --ttl 24h --token-secret "server_token_secret" --token-id trial42
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --template mytemplate.tmpl
+  libyalink gen-client --based-on client.txt --preset fiber
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --from-server /etc/libyalink/config.yaml
+  libyalink gen-client --server 1.2.3.4 --secret-ref env:HY_AUTH
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --json-only | jq .
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --tuning-notes
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --decoy-sni www.google.com --from-server server.yaml
+  libyalink gen-client --server vpn.example.com --front-address relay.example.net:8443 --auth "mypassword"
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --recv-window 16777216 --recv-window-conn 41943040
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --client-type openwrt -o config.yaml
+  libyalink gen-client --server 1.2.3.4 --auth "mypassword" --all-platforms --output-dir alice/
+
+When the output is piped or redirected, the banners and hints on stderr are
+left out, errors and warnings are still shown. With --json-only, the output
+is only the sing-box JSON, which tools like jq can read.
+
+With --template, the output is rendered from a Go text/template file instead
+of the built-in formats. See docs/templates for the built-in formats written
+as templates, and genClientTemplateData for the available variables.
+
+With --based-on, the server, auth, obfs and other parameters are taken from a
+config generated earlier (the full output, or the sing-box or native config),
+and only the flags given explicitly are changed.
+
+With --front-address, clients connect to a relay in front of the server, while
+the TLS SNI stays the server's name (--sni, or --server). The relay must pass
+the UDP packets on to the server unchanged, e.g. a port forward on another
+machine. An HTTP CDN can't be the front, it ends QUIC itself and only passes
+HTTP on to the origin.
+
+With --secret-ref env:NAME, the configs contain the placeholder ${NAME} instead
+of the password, so they can be shared or put in version control. The native
+client reads the password from the environment variable NAME, for sing-box
+replace the placeholder before importing (e.g. with envsubst).
+
+With --ttl, the auth value is a signed token that the server rejects once it
+expires. The server must have the same secret configured in auth.token.secret.`,
 	Run: runGenClient,
 }
 
@@ -41,19 +148,69 @@ func init() {
 }
 
 func initGenClientFlags() {
-	genClientCmd.Flags().StringVar(&genClientServer, "server", "", "server IP address or hostname (required)")
+	genClientCmd.Flags().StringVar(&genClientServer, "server", "", "server IP address or hostname (required unless --based-on is used)")
 	genClientCmd.Flags().IntVar(&genClientPort, "port", 443, "server port")
-	genClientCmd.Flags().StringVar(&genClientAuth, "auth", "", "authentication password (required)")
+	genClientCmd.Flags().StringVar(&genClientISP, "isp", "", "the users' ISP, to warn about ports throttled on it: 'libyana', 'almadar' or 'ltt'")
+	genClientCmd.Flags().StringVar(&genClientAuth, "auth", "", "authentication password (required unless --ttl is used)")
 	genClientCmd.Flags().BoolVar(&genClientInsecure, "insecure", true, "skip TLS certificate verification (default: true for self-signed)")
 	genClientCmd.Flags().StringVar(&genClientSNI, "sni", "", "TLS SNI (server name indication)")
+	genClientCmd.Flags().StringVar(&genClientDecoySNI, "decoy-sni", "", "send this popular domain as the SNI instead of the server's name (needs tls.sniGuard: disable on the server)")
+	genClientCmd.Flags().StringVar(&genClientFrontAddress, "front-address", "", "host or host:port of a UDP relay in front of the server for the clients to connect to, the SNI stays the server's name")
+	genClientCmd.Flags().BoolVar(&genClientListDecoy, "list-decoy-sni", false, "print suggested domains for --decoy-sni and exit")
 	genClientCmd.Flags().StringVar(&genClientObfs, "obfs", "", "obfuscation password (salamander)")
-	genClientCmd.Flags().StringVar(&genClientPreset, "preset", "4g", "bandwidth preset: '4g' (1-10 Mbps) or 'fiber' (50-100 Mbps)")
+	genClientCmd.Flags().StringVar(&genClientPreset, "preset", "4g", "bandwidth preset: '4g' (1-10 Mbps), 'fiber' (50-100 Mbps) or one from --preset-file")
+	genClientCmd.Flags().StringVar(&genClientProfile, "profile", "", "tune the config for a kind of use: 'gaming' (lower latency, less throughput)")
+	genClientCmd.Flags().StringVar(&genClientPresetFile, "preset-file", "", "YAML file of more presets, each a name with up and down, e.g. 'ltt-fiber-200: {up: 20 mbps, down: 200 mbps}'")
+	genClientCmd.Flags().BoolVar(&genClientListPresets, "list-presets", false, "print the bandwidth presets, with those of --preset-file, and exit")
+	genClientCmd.Flags().BoolVar(&genClientAutoBrutal, "auto-brutal", false, "measure the bandwidth to the server (needs speedTest: true on it) and set the config to it instead of the preset")
+	genClientCmd.Flags().StringVar(&genClientSpeedtest, "speedtest-result", "", "with --auto-brutal, use this result of 'libyalink speedtest --save' instead of measuring")
 	genClientCmd.Flags().StringVar(&genClientOutput, "output", "", "output file path (default: stdout)")
-
-	genClientCmd.MarkFlagRequired("server")
-	genClientCmd.MarkFlagRequired("auth")
+	genClientCmd.Flags().BoolVar(&genClientAllPlatforms, "all-platforms", false, "write configs for every supported client, the share link, a QR code and a README to --output-dir")
+	genClientCmd.Flags().StringVar(&genClientOutputDir, "output-dir", "", "directory for --all-platforms")
+	genClientCmd.Flags().StringArrayVar(&genClientStandbyServers, "standby-server", nil, "failover standby server sharing the same config (repeatable)")
+	genClientCmd.Flags().BoolVar(&genClientResolve, "resolve", false, "resolve --server now and add each of its addresses as a standby server, for round-robin or anycast domains (sing-box and Clash only)")
+	genClientCmd.Flags().StringArrayVar(&genClientTunnelProcs, "tunnel-process", nil, "only tunnel traffic from this process name, everything else goes direct (repeatable)")
+	genClientCmd.Flags().StringArrayVar(&genClientRouteDomains, "route-through-proxy", nil, "only send this domain and its subdomains through the proxy, everything else goes direct (repeatable)")
+	genClientCmd.Flags().BoolVar(&genClientUnblockCommon, "unblock-common", false, "like --route-through-proxy with the domains of commonly blocked messaging and social apps")
+	genClientCmd.Flags().BoolVar(&genClientFallbackDirect, "fallback-direct", false, "send traffic direct, unproxied, while no server is reachable (sing-box and Clash only)")
+	genClientCmd.Flags().StringArrayVar(&genClientALPN, "alpn", nil, "TLS ALPN value for the sing-box config, with --json-only or --template (repeatable, e.g. --alpn h3)")
+	genClientCmd.Flags().StringVar(&genClientNativeFormat, "native-format", "json", "format of the native client config: 'json' or 'yaml'")
+	genClientCmd.Flags().BoolVar(&genClientMinifyNative, "minify-native", false, "write the native client config as single-line JSON")
+	genClientCmd.Flags().StringVar(&genClientIndent, "indent", "", "indent of the JSON and YAML configs: a number of spaces (2-8), or 'tab' for JSON only (default: 2 for JSON, 4 for YAML)")
+	genClientCmd.Flags().DurationVar(&genClientKeepAlive, "keepalive", genClientKeepAliveDefault, "QUIC keep-alive period for the native client, short enough to keep CGNAT mappings open (2s-60s)")
+	genClientCmd.Flags().Uint64Var(&genClientRecvWindow, "recv-window", 0, "QUIC stream receive window in bytes for the native client (default: the client's 8MB)")
+	genClientCmd.Flags().Uint64Var(&genClientRecvConn, "recv-window-conn", 0, "QUIC connection receive window in bytes for the native client (default: the client's 20MB)")
+	genClientCmd.Flags().StringVar(&genClientClipboard, "clipboard", "", "copy the sing-box config to the clipboard (--clipboard=native for the native config)")
+	genClientCmd.Flags().Lookup("clipboard").NoOptDefVal = "singbox"
+	genClientCmd.Flags().StringVar(&genClientTemplate, "template", "", "render the output from this Go text/template file instead of the built-in formats")
+	genClientCmd.Flags().StringVar(&genClientBasedOn, "based-on", "", "reuse the parameters of a previously generated config, overriding only the flags given")
+	genClientCmd.Flags().StringVar(&genClientImport, "import", "", "reuse the parameters of a hysteria2:// share URI, overriding only the flags given")
+	genClientCmd.Flags().BoolVar(&genClientURIOnly, "uri-only", false, "only write the hysteria2:// share URI, e.g. to normalize one with --import")
+	genClientCmd.Flags().BoolVar(&genClientQR, "qr", false, "write the QR code of the share URI as a PNG image to --output, e.g. to print or send it")
+	genClientCmd.Flags().StringVar(&genClientBrandTitle, "brand-title", "", "with --qr, a title to print under the code, e.g. the operator's name")
+	genClientCmd.Flags().StringVar(&genClientBrandLogo, "brand-logo", "", "with --qr, a PNG or JPEG logo to put in the middle of the code")
+	genClientCmd.Flags().StringVar(&genClientFromServer, "from-server", "", "server config to check the client against, e.g. that the preset doesn't exceed the server's bandwidth")
+	genClientCmd.Flags().StringVar(&genClientSecretRef, "secret-ref", "", "put a placeholder for the password in the configs instead of the password itself, e.g. env:HY_AUTH")
+	genClientCmd.Flags().StringVar(&genClientClientType, "client-type", "", "generate for a specific client instead: 'openwrt' (native YAML config and UCI commands for a router)")
+	genClientCmd.Flags().BoolVar(&genClientTuningNotes, "tuning-notes", false, "add recommended UDP buffer settings for the client device to the output comments")
+	genClientCmd.Flags().BoolVar(&genClientJSONOnly, "json-only", false, "only write the sing-box JSON, without comments, the native config or banners")
+	genClientCmd.Flags().BoolVar(&genClientValidateOnly, "validate-only", false, "only check the flags, print the warnings and exit with 0 if the config can be generated, without writing it")
+	genClientCmd.Flags().StringVar(&genClientLauncher, "launcher", "", "also write the native config with a double-click launcher: 'win' (.bat and .ps1)")
+	genClientCmd.Flags().DurationVar(&genClientTTL, "ttl", 0, "generate a signed auth token valid for this long (e.g. 24h) instead of using --auth")
+	genClientCmd.Flags().StringVar(&genClientTokenSecret, "token-secret", "", "token signing secret, must match auth.token.secret on the server")
+	genClientCmd.Flags().StringVar(&genClientTokenID, "token-id", "trial", "user ID embedded in the token, shown in server logs and traffic stats")
+	genClientCmd.Flags().StringVar(&genClientSchedule, "schedule", "", "with --ttl, only let the token connect at these hours each day, e.g. '08:00-16:00'")
+	genClientCmd.Flags().StringVar(&genClientScheduleTZ, "schedule-tz", "Local", "time zone of --schedule, e.g. 'Africa/Tripoli' (default: this machine's)")
 }
 
+// fallbackDirectTolerance is the urltest tolerance of --fallback-direct, in
+// ms. More than sing-box's URL test timeout, but small enough that a delay
+// plus the tolerance fits the uint16 sing-box adds them in.
+const fallbackDirectTolerance = 10000
+
+// genClientKeepAliveDefault is short enough to keep CGNAT mappings open.
+const genClientKeepAliveDefault = 15 * time.Second
+
 // bandwidthPreset holds up/down bandwidth values
 type bandwidthPreset struct {
 	Up   string `json:"up"`
@@ -73,21 +230,33 @@ var bandwidthPresets = map[string]bandwidthPreset{
 
 // singBoxOutbound represents a sing-box Hysteria2 outbound configuration
 type singBoxOutbound struct {
-	Type       string          `json:"type"`
-	Tag        string          `json:"tag"`
-	Server     string          `json:"server"`
-	ServerPort int             `json:"server_port"`
-	Password   string          `json:"password"`
-	TLS        singBoxTLS      `json:"tls"`
-	Obfs       *singBoxObfs    `json:"obfs,omitempty"`
-	UpMbps     int             `json:"up_mbps,omitempty"`
-	DownMbps   int             `json:"down_mbps,omitempty"`
+	Type       string       `json:"type"`
+	Tag        string       `json:"tag"`
+	Server     string       `json:"server"`
+	ServerPort int          `json:"server_port"`
+	Password   string       `json:"password"`
+	TLS        singBoxTLS   `json:"tls"`
+	Obfs       *singBoxObfs `json:"obfs,omitempty"`
+	UpMbps     int          `json:"up_mbps,omitempty"`
+	DownMbps   int          `json:"down_mbps,omitempty"`
 }
 
 type singBoxTLS struct {
-	Enabled    bool   `json:"enabled"`
-	Insecure   bool   `json:"insecure"`
-	ServerName string `json:"server_name,omitempty"`
+	Enabled    bool     `json:"enabled"`
+	Insecure   bool     `json:"insecure"`
+	ServerName string   `json:"server_name,omitempty"`
+	ALPN       []string `json:"alpn,omitempty"`
+}
+
+// singBoxURLTest represents a sing-box urltest outbound group, which
+// automatically picks the first healthy outbound from the list
+type singBoxURLTest struct {
+	Type      string   `json:"type"`
+	Tag       string   `json:"tag"`
+	Outbounds []string `json:"outbounds"`
+	URL       string   `json:"url,omitempty"`
+	Interval  string   `json:"interval,omitempty"`
+	Tolerance int      `json:"tolerance,omitempty"` // ms
 }
 
 type singBoxObfs struct {
@@ -97,11 +266,11 @@ type singBoxObfs struct {
 
 // singBoxConfig is the full sing-box configuration structure
 type singBoxConfig struct {
-	Log       singBoxLog        `json:"log"`
-	DNS       singBoxDNS        `json:"dns"`
-	Inbounds  []singBoxInbound  `json:"inbounds"`
-	Outbounds []interface{}     `json:"outbounds"`
-	Route     singBoxRoute      `json:"route"`
+	Log       singBoxLog       `json:"log"`
+	DNS       singBoxDNS       `json:"dns"`
+	Inbounds  []singBoxInbound `json:"inbounds"`
+	Outbounds []interface{}    `json:"outbounds"`
+	Route     singBoxRoute     `json:"route"`
 }
 
 type singBoxLog struct {
@@ -117,115 +286,173 @@ type singBoxDNSServer struct {
 	Address string `json:"address"`
 }
 
+// singBoxInbound is a tun or a listening inbound. sing-box rejects keys
+// that don't belong to the type, so every type-specific field is omitempty.
 type singBoxInbound struct {
-	Type   string `json:"type"`
-	Tag    string `json:"tag"`
-	Listen string `json:"listen"`
-	Port   int    `json:"listen_port"`
+	Type      string   `json:"type"`
+	Tag       string   `json:"tag"`
+	Listen    string   `json:"listen,omitempty"`
+	Port      int      `json:"listen_port,omitempty"`
+	Address   []string `json:"address,omitempty"` // tun only
+	AutoRoute bool     `json:"auto_route,omitempty"`
 }
 
 type singBoxRoute struct {
-	AutoDetectInterface bool             `json:"auto_detect_interface"`
-	FinalTag            string           `json:"final"`
+	AutoDetectInterface bool               `json:"auto_detect_interface"`
+	FinalTag            string             `json:"final"`
 	Rules               []singBoxRouteRule `json:"rules,omitempty"`
 }
 
 type singBoxRouteRule struct {
-	Protocol string `json:"protocol,omitempty"`
-	Outbound string `json:"outbound"`
+	Action       string   `json:"action,omitempty"`
+	Protocol     string   `json:"protocol,omitempty"`
+	ProcessName  []string `json:"process_name,omitempty"`
+	DomainSuffix []string `json:"domain_suffix,omitempty"`
+	Outbound     string   `json:"outbound,omitempty"`
 }
 
 // hysteria2ClientConfig generates a native Hysteria 2 YAML-style client config
+// The tags must match the mapstructure tags of clientConfig,
+// TestGenClientNativeRoundTrip makes sure they do.
 type hysteria2ClientConfig struct {
-	Server    string                 `json:"server"`
-	Auth      string                 `json:"auth"`
-	TLS       hysteria2ClientTLS     `json:"tls"`
-	Bandwidth *hysteria2ClientBW     `json:"bandwidth,omitempty"`
-	Obfs      *hysteria2ClientObfs   `json:"obfs,omitempty"`
-	Socks5    *hysteria2ClientSocks5 `json:"socks5,omitempty"`
-	HTTP      *hysteria2ClientHTTP   `json:"http,omitempty"`
+	Server    string                 `json:"server" yaml:"server"`
+	Auth      string                 `json:"auth" yaml:"auth"`
+	TLS       hysteria2ClientTLS     `json:"tls" yaml:"tls"`
+	QUIC      *hysteria2ClientQUIC   `json:"quic,omitempty" yaml:"quic,omitempty"`
+	Bandwidth *hysteria2ClientBW     `json:"bandwidth,omitempty" yaml:"bandwidth,omitempty"`
+	Obfs      *hysteria2ClientObfs   `json:"obfs,omitempty" yaml:"obfs,omitempty"`
+	Socks5    *hysteria2ClientSocks5 `json:"socks5,omitempty" yaml:"socks5,omitempty"`
+	HTTP      *hysteria2ClientHTTP   `json:"http,omitempty" yaml:"http,omitempty"`
+
+	TCPRedirect   *hysteria2ClientTCPRedirect    `json:"tcpRedirect,omitempty" yaml:"tcpRedirect,omitempty"`
+	UDPForwarding []hysteria2ClientUDPForwarding `json:"udpForwarding,omitempty" yaml:"udpForwarding,omitempty"`
 }
 
 type hysteria2ClientTLS struct {
-	SNI      string `json:"sni,omitempty"`
-	Insecure bool   `json:"insecure"`
+	SNI      string `json:"sni,omitempty" yaml:"sni,omitempty"`
+	Insecure bool   `json:"insecure" yaml:"insecure"`
+}
+
+type hysteria2ClientQUIC struct {
+	InitStreamReceiveWindow     uint64 `json:"initStreamReceiveWindow,omitempty" yaml:"initStreamReceiveWindow,omitempty"`
+	MaxStreamReceiveWindow      uint64 `json:"maxStreamReceiveWindow,omitempty" yaml:"maxStreamReceiveWindow,omitempty"`
+	InitConnectionReceiveWindow uint64 `json:"initConnReceiveWindow,omitempty" yaml:"initConnReceiveWindow,omitempty"`
+	MaxConnectionReceiveWindow  uint64 `json:"maxConnReceiveWindow,omitempty" yaml:"maxConnReceiveWindow,omitempty"`
+	MaxIdleTimeout              string `json:"maxIdleTimeout,omitempty" yaml:"maxIdleTimeout,omitempty"`
+	KeepAlivePeriod             string `json:"keepAlivePeriod,omitempty" yaml:"keepAlivePeriod,omitempty"`
 }
 
 type hysteria2ClientBW struct {
-	Up   string `json:"up"`
-	Down string `json:"down"`
+	Up   string `json:"up" yaml:"up"`
+	Down string `json:"down" yaml:"down"`
+}
+
+type hysteria2ClientObfsSalamander struct {
+	Password string `json:"password" yaml:"password"`
 }
 
 type hysteria2ClientObfs struct {
-	Type       string `json:"type"`
-	Salamander struct {
-		Password string `json:"password"`
-	} `json:"salamander"`
+	Type       string                        `json:"type" yaml:"type"`
+	Salamander hysteria2ClientObfsSalamander `json:"salamander" yaml:"salamander"`
 }
 
 type hysteria2ClientSocks5 struct {
-	Listen string `json:"listen"`
+	Listen string `json:"listen" yaml:"listen"`
 }
 
 type hysteria2ClientHTTP struct {
-	Listen string `json:"listen"`
+	Listen string `json:"listen" yaml:"listen"`
 }
 
-func runGenClient(cmd *cobra.Command, args []string) {
-	// Validate preset
-	preset, ok := bandwidthPresets[genClientPreset]
-	if !ok {
-		fmt.Fprintf(os.Stderr, "Error: unknown preset '%s'. Use '4g' or 'fiber'.\n", genClientPreset)
-		os.Exit(1)
+// clientTuningNotes is the client side of the buffer tuning in
+// docs/libya_tuning.md. The native client asks for 8MB UDP buffers like the
+// server, which most systems cap far lower by default.
+const clientTuningNotes = `// ─── Client Device Tuning (optional) ────────────────────────
+// Larger UDP buffers let the client keep up on fast or high-latency
+// links, especially a Linux machine that is the gateway for others.
+//
+// Linux, as root:
+//   sysctl -w net.core.rmem_max=16777216
+//   sysctl -w net.core.wmem_max=16777216
+// To keep them after a reboot, put both lines (without "sysctl -w")
+// in /etc/sysctl.d/99-libyalink.conf.
+//
+// macOS:
+//   sudo sysctl -w kern.ipc.maxsockbuf=16777216
+//
+// Windows, Android and iOS: nothing to change.
+`
+
+// formatGenClientOutput combines both configs into the commented output
+// with a checksum footer. It embeds nothing that changes between runs,
+// such as the time, so regenerated configs diff cleanly in version control.
+//
+// secretPlaceholder is the --secret-ref placeholder used as the password,
+// if any, which gets a note on how to fill it in. resolvedNote is the
+// header note of --resolve, the one exception as it says when the
+// addresses were resolved. tuningNotes appends clientTuningNotes.
+func formatGenClientOutput(presetName string, preset bandwidthPreset, singBoxJSON, nativeData []byte, secretPlaceholder, resolvedNote string, tuningNotes bool) string {
+	secretNote := ""
+	if secretPlaceholder != "" {
+		name := strings.TrimSuffix(strings.TrimPrefix(secretPlaceholder, "${"), "}")
+		secretNote = fmt.Sprintf(`// Password: %s is a placeholder. The native client reads it from the
+// environment variable %s. For sing-box, replace it before importing,
+// e.g.: %s=yourpassword envsubst < this_file
+`, secretPlaceholder, name, name)
 	}
+	output := fmt.Sprintf(`// ============================================================
+// LibyaLink Client Configuration — Generated Automatically
+// Powered by Hysteria 2
+// Preset: %s (%s up / %s down)
+%s%s// ============================================================
 
-	serverAddr := fmt.Sprintf("%s:%d", genClientServer, genClientPort)
+// ─── For NekoBox / sing-box ─────────────────────────────────
+// Import this JSON in NekoBox > Manual Configuration > sing-box
 
-	sni := genClientSNI
-	if sni == "" && genClientInsecure {
-		sni = genClientServer
-	}
+%s
 
-	// Parse bandwidth to Mbps integers for sing-box format
-	upMbps, downMbps := parseBandwidthToMbps(preset)
+// ─── For Native Hysteria 2 Client ───────────────────────────
+// Save as config.yaml and run: libyalink client -c config.yaml
 
-	fmt.Fprintln(os.Stderr, "")
-	fmt.Fprintln(os.Stderr, "╔══════════════════════════════════════════════════════════╗")
-	fmt.Fprintln(os.Stderr, "║  LibyaLink Client Config Generator                      ║")
-	fmt.Fprintln(os.Stderr, "║  Powered by Hysteria 2                                  ║")
-	fmt.Fprintln(os.Stderr, "╚══════════════════════════════════════════════════════════╝")
-	fmt.Fprintln(os.Stderr, "")
-	fmt.Fprintf(os.Stderr, "  Server:   %s\n", serverAddr)
-	fmt.Fprintf(os.Stderr, "  Preset:   %s (%s up / %s down)\n", genClientPreset, preset.Up, preset.Down)
-	fmt.Fprintf(os.Stderr, "  Insecure: %v\n", genClientInsecure)
-	fmt.Fprintln(os.Stderr, "")
+%s
+`, presetName, preset.Up, preset.Down, secretNote, resolvedNote, string(singBoxJSON), string(nativeData))
+	if tuningNotes {
+		output += "\n" + clientTuningNotes
+	}
 
-	// --- Generate sing-box / NekoBox format ---
-	fmt.Fprintln(os.Stderr, "─── NekoBox / sing-box Configuration ───")
-	fmt.Fprintln(os.Stderr, "")
+	// Lets users check that a messaging app didn't mangle the config on
+	// the way, with "libyalink verify-config"
+	return string(utils.AppendChecksumFooter([]byte(output), "//"))
+}
 
+// newSingBoxConfig builds the sing-box config. Given the same data it always
+// produces the same config, TestGenClientOutputDeterministic makes sure of
+// that. sniFollowsServer sets the SNI of each standby outbound to its own
+// address.
+func newSingBoxConfig(data genClientTemplateData, sniFollowsServer bool) singBoxConfig {
 	var obfs *singBoxObfs
-	if genClientObfs != "" {
+	if data.Obfs != "" {
 		obfs = &singBoxObfs{
 			Type:     "salamander",
-			Password: genClientObfs,
+			Password: data.Obfs,
 		}
 	}
 
 	hy2Outbound := singBoxOutbound{
 		Type:       "hysteria2",
 		Tag:        "libyalink-proxy",
-		Server:     genClientServer,
-		ServerPort: genClientPort,
-		Password:   genClientAuth,
+		Server:     data.Server,
+		ServerPort: data.Port,
+		Password:   data.Auth,
 		TLS: singBoxTLS{
 			Enabled:    true,
-			Insecure:   genClientInsecure,
-			ServerName: sni,
+			Insecure:   data.Insecure,
+			ServerName: data.SNI,
+			ALPN:       data.ALPN,
 		},
 		Obfs:     obfs,
-		UpMbps:   upMbps,
-		DownMbps: downMbps,
+		UpMbps:   data.UpMbps,
+		DownMbps: data.DownMbps,
 	}
 
 	singBoxCfg := singBoxConfig{
@@ -237,10 +464,10 @@ func runGenClient(cmd *cobra.Command, args []string) {
 		},
 		Inbounds: []singBoxInbound{
 			{
-				Type:   "tun",
-				Tag:    "tun-in",
-				Listen: "0.0.0.0",
-				Port:   0,
+				Type:      "tun",
+				Tag:       "tun-in",
+				Address:   []string{"172.19.0.1/30"},
+				AutoRoute: true,
 			},
 			{
 				Type:   "socks",
@@ -265,22 +492,71 @@ func runGenClient(cmd *cobra.Command, args []string) {
 		},
 	}
 
-	singBoxJSON, err := json.MarshalIndent(singBoxCfg, "", "  ")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error generating sing-box config: %v\n", err)
-		os.Exit(1)
+	// Failover: one outbound per standby server, grouped with the primary
+	// in a urltest so sing-box switches over when the primary goes down
+	if len(data.StandbyServers) > 0 || data.FallbackDirect {
+		tags := []string{hy2Outbound.Tag}
+		for i, standby := range data.StandbyServers {
+			ob := hy2Outbound
+			ob.Tag = fmt.Sprintf("libyalink-standby-%d", i+1)
+			ob.Server = standby
+			if sniFollowsServer {
+				ob.TLS.ServerName = standby
+			}
+			singBoxCfg.Outbounds = append(singBoxCfg.Outbounds, ob)
+			tags = append(tags, ob.Tag)
+		}
+		group := singBoxURLTest{
+			Type:      "urltest",
+			Tag:       "libyalink-auto",
+			Outbounds: tags,
+			URL:       "https://www.gstatic.com/generate_204",
+			Interval:  "1m",
+		}
+		if data.FallbackDirect {
+			// urltest keeps the first outbound that answers unless another
+			// is faster by more than the tolerance, which direct always is.
+			// With a tolerance above the test's timeout, direct is only
+			// used while no server answers.
+			group.Outbounds = append(group.Outbounds, "direct")
+			group.Tolerance = fallbackDirectTolerance
+		}
+		singBoxCfg.Outbounds = append(singBoxCfg.Outbounds, group)
+		singBoxCfg.Route.FinalTag = "libyalink-auto"
 	}
 
-	// --- Also generate native Hysteria 2 client format ---
-	fmt.Fprintln(os.Stderr, "─── Native Hysteria 2 Client Configuration ───")
-	fmt.Fprintln(os.Stderr, "")
+	// Split tunnel by process: only the listed apps use the proxy. The
+	// order doesn't matter, so sort to keep the output stable.
+	proxyTag := singBoxCfg.Route.FinalTag
+	if len(data.TunnelProcesses) > 0 {
+		procs := slices.Clone(data.TunnelProcesses)
+		slices.Sort(procs)
+		singBoxCfg.Route.Rules = append(singBoxCfg.Route.Rules, singBoxRouteRule{
+			ProcessName: slices.Compact(procs),
+			Outbound:    proxyTag,
+		})
+		singBoxCfg.Route.FinalTag = "direct"
+	}
+	// Split tunnel by domain, the same the other way around. The tun
+	// inbound only sees IPs, so it has to sniff the domain first.
+	if len(data.ProxyDomains) > 0 {
+		singBoxCfg.Route.Rules = append([]singBoxRouteRule{{Action: "sniff"}}, singBoxCfg.Route.Rules...)
+		singBoxCfg.Route.Rules = append(singBoxCfg.Route.Rules, singBoxRouteRule{
+			DomainSuffix: data.ProxyDomains,
+			Outbound:     proxyTag,
+		})
+		singBoxCfg.Route.FinalTag = "direct"
+	}
+	return singBoxCfg
+}
 
-	nativeConfig := hysteria2ClientConfig{
+func newHysteria2ClientConfig(serverAddr, auth, sni string, insecure bool, preset bandwidthPreset, obfsPassword string, keepAlive time.Duration) hysteria2ClientConfig {
+	c := hysteria2ClientConfig{
 		Server: serverAddr,
-		Auth:   genClientAuth,
+		Auth:   auth,
 		TLS: hysteria2ClientTLS{
 			SNI:      sni,
-			Insecure: genClientInsecure,
+			Insecure: insecure,
 		},
 		Bandwidth: &hysteria2ClientBW{
 			Up:   preset.Up,
@@ -289,37 +565,617 @@ func runGenClient(cmd *cobra.Command, args []string) {
 		Socks5: &hysteria2ClientSocks5{Listen: "127.0.0.1:1080"},
 		HTTP:   &hysteria2ClientHTTP{Listen: "127.0.0.1:8080"},
 	}
-
-	if genClientObfs != "" {
-		nativeConfig.Obfs = &hysteria2ClientObfs{
+	if keepAlive != 0 {
+		c.QUIC = &hysteria2ClientQUIC{KeepAlivePeriod: keepAlive.String()}
+	}
+	if obfsPassword != "" {
+		c.Obfs = &hysteria2ClientObfs{
 			Type: "salamander",
+			Salamander: hysteria2ClientObfsSalamander{
+				Password: obfsPassword,
+			},
+		}
+	}
+	return c
+}
+
+// setReceiveWindows sets the QUIC flow control windows of the native
+// config, the initial and maximum to the same value. 0 leaves the client's
+// default.
+func setReceiveWindows(c *hysteria2ClientConfig, stream, conn uint64) {
+	if stream == 0 && conn == 0 {
+		return
+	}
+	if c.QUIC == nil {
+		c.QUIC = &hysteria2ClientQUIC{}
+	}
+	c.QUIC.InitStreamReceiveWindow, c.QUIC.MaxStreamReceiveWindow = stream, stream
+	c.QUIC.InitConnectionReceiveWindow, c.QUIC.MaxConnectionReceiveWindow = conn, conn
+}
+
+// validateRecvWindows checks --recv-window and --recv-window-conn against
+// the client's limits, and returns warnings for values that are allowed
+// but unlikely to help.
+func validateRecvWindows(stream, conn uint64) ([]string, error) {
+	const (
+		minWindow     = 16384    // Same as the client
+		lowWindow     = 1 << 20  // 1MB
+		extremeWindow = 64 << 20 // 64MB
+	)
+	var warnings []string
+	for _, w := range []struct {
+		flag  string
+		value uint64
+	}{{"--recv-window", stream}, {"--recv-window-conn", conn}} {
+		switch {
+		case w.value == 0:
+		case w.value < minWindow:
+			return nil, fmt.Errorf("%s must be at least %d bytes", w.flag, minWindow)
+		case w.value < lowWindow:
+			warnings = append(warnings, fmt.Sprintf("%s %s limits throughput on high-latency links "+
+				"(at 200ms RTT, to about %d Mbps).", w.flag, formatRecvWindow(w.value), w.value*8*5/1000000))
+		case w.value > extremeWindow:
+			warnings = append(warnings, fmt.Sprintf("%s %s is more than any link needs and can use a lot of memory on the client.",
+				w.flag, formatRecvWindow(w.value)))
+		}
+	}
+	effectiveConn := conn
+	if effectiveConn == 0 {
+		effectiveConn = 20 << 20 // The client's default
+	}
+	if stream != 0 && effectiveConn < stream {
+		warnings = append(warnings, fmt.Sprintf("the connection window (%s) is smaller than --recv-window, "+
+			"so a single stream can't use its whole window. Raise --recv-window-conn.", formatRecvWindow(effectiveConn)))
+	}
+	return warnings, nil
+}
+
+func formatRecvWindow(n uint64) string {
+	switch {
+	case n == 0:
+		return "default"
+	case n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// marshalHysteria2ClientConfig encodes the native config as "json" or "yaml".
+// minify only applies to JSON, and overrides indent.
+func marshalHysteria2ClientConfig(c hysteria2ClientConfig, format string, minify bool, indent outputIndent) ([]byte, error) {
+	switch format {
+	case "json":
+		if minify {
+			return json.Marshal(c)
+		}
+		return indent.marshalJSON(c)
+	case "yaml":
+		bs, err := indent.marshalYAML(c)
+		return bytes.TrimSuffix(bs, []byte("\n")), err
+	default:
+		return nil, fmt.Errorf("unsupported format '%s'", format)
+	}
+}
+
+// genClientTemplateData is what --template files are executed with, and
+// what the built-in sing-box config is built from.
+type genClientTemplateData struct {
+	Server          string // Host only
+	Port            int
+	ServerAddr      string // host:port
+	Auth            string
+	SNI             string
+	Insecure        bool
+	Obfs            string // Salamander password, empty if disabled
+	Preset          string
+	Up              string // e.g. "10 mbps"
+	Down            string
+	UpMbps          int
+	DownMbps        int
+	KeepAlive       string // e.g. "15s", empty for the client default
+	ALPN            []string
+	StandbyServers  []string
+	TunnelProcesses []string
+	ProxyDomains    []string // Sorted, matched with their subdomains
+	FallbackDirect  bool     // Go direct while no server is reachable
+}
+
+// genClientTemplateFuncs are available in --template files. "json" encodes
+// a value as JSON, which also gives correctly quoted YAML strings.
+var genClientTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		bs, err := json.Marshal(v)
+		return string(bs), err
+	},
+}
+
+func parseClientTemplate(path string) (*template.Template, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return template.New(filepath.Base(path)).Funcs(genClientTemplateFuncs).Option("missingkey=error").Parse(string(bs))
+}
+
+func renderClientTemplate(tmpl *template.Template, data genClientTemplateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func runGenClient(cmd *cobra.Command, args []string) {
+	if genClientListDecoy {
+		printDecoySNISuggestions()
+		return
+	}
+
+	if genClientPresetFile != "" {
+		custom, err := loadPresetFile(genClientPresetFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --preset-file: %v\n", err)
+			os.Exit(1)
+		}
+		// Merged before --based-on, so that configs made with a custom
+		// preset are recognized
+		for name, p := range custom {
+			bandwidthPresets[name] = p
 		}
-		nativeConfig.Obfs.Salamander.Password = genClientObfs
+		if genClientListPresets {
+			printPresets(bandwidthPresets, custom, genClientPresetFile)
+			return
+		}
+	} else if genClientListPresets {
+		printPresets(bandwidthPresets, nil, "")
+		return
 	}
 
-	nativeJSON, err := json.MarshalIndent(nativeConfig, "", "  ")
+	var basedOnFlags, importWarnings []string
+	basedOn := genClientBasedOn
+	if genClientBasedOn != "" {
+		if genClientImport != "" {
+			fmt.Fprintln(os.Stderr, "Error: --based-on and --import are mutually exclusive.")
+			os.Exit(1)
+		}
+		base, err := loadGenClientBase(genClientBasedOn)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot load --based-on config: %v\n", err)
+			os.Exit(1)
+		}
+		basedOnFlags = applyGenClientBase(cmd.Flags().Changed, base)
+	} else if genClientImport != "" {
+		base, warnings, err := genClientBaseFromURI(genClientImport)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot import the URI: %v\n", err)
+			os.Exit(1)
+		}
+		basedOnFlags = applyGenClientBase(cmd.Flags().Changed, base)
+		basedOn, importWarnings = "the imported URI", warnings
+	}
+	if err := validateGenClientFlags(cmd.Flags().Changed); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	preset := bandwidthPresets[genClientPreset]
+	if genClientClientType == "openwrt" {
+		genClientNativeFormat = "yaml"
+	}
+	frontHost, frontPort, err := genClientFront()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error generating native config: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Build full output
-	output := fmt.Sprintf(`// ============================================================
-// LibyaLink Client Configuration — Generated Automatically
-// Powered by Hysteria 2
-// Preset: %s (%s up / %s down)
-// ============================================================
+	indent, err := parseOutputIndent(genClientIndent)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --indent '%s': %v\n", genClientIndent, err)
+		os.Exit(1)
+	}
 
-// ─── For NekoBox / sing-box ─────────────────────────────────
-// Import this JSON in NekoBox > Manual Configuration > sing-box
+	var brandLogo image.Image
+	if genClientQR && genClientBrandLogo != "" {
+		if brandLogo, err = loadBrandLogo(genClientBrandLogo); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --brand-logo %s: %v\n", genClientBrandLogo, err)
+			os.Exit(1)
+		}
+	}
 
-%s
+	profile, hasProfile := genClientProfiles[genClientProfile]
+	var profileFlags []string
+	if hasProfile {
+		profileFlags = applyGenClientProfile(cmd.Flags().Changed, profile, &genClientKeepAlive, &genClientRecvWindow, &genClientRecvConn)
+	}
 
-// ─── For Native Hysteria 2 Client ───────────────────────────
-// Save as config.yaml and run: libyalink client -c config.yaml
+	recvWindowWarnings, err := validateRecvWindows(genClientRecvWindow, genClientRecvConn)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-%s
-`, genClientPreset, preset.Up, preset.Down, string(singBoxJSON), string(nativeJSON))
+	var secretPlaceholder string
+	if genClientSecretRef != "" {
+		// Checked by validateGenClientFlags
+		secretPlaceholder, _ = parseSecretRef(genClientSecretRef)
+	}
+
+	var tokenExpiry time.Time
+	var tokenWindow *auth.TokenWindow
+	var scheduleWarning string
+	if genClientTTL > 0 {
+		tokenExpiry = time.Now().Add(genClientTTL)
+		var token string
+		var err error
+		if genClientSchedule != "" {
+			loc, locErr := time.LoadLocation(genClientScheduleTZ)
+			if locErr != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --schedule-tz '%s': %v\n", genClientScheduleTZ, locErr)
+				os.Exit(1)
+			}
+			window, scheduleErr := parseSchedule(genClientSchedule, loc, time.Now())
+			if scheduleErr != nil {
+				fmt.Fprintf(os.Stderr, "Error: invalid --schedule '%s': %v\n", genClientSchedule, scheduleErr)
+				os.Exit(1)
+			}
+			tokenWindow = &window
+			scheduleWarning = scheduleDSTWarning(loc, time.Now())
+			token, err = auth.NewScheduledToken([]byte(genClientTokenSecret), genClientTokenID, tokenExpiry, window)
+		} else {
+			token, err = auth.NewToken([]byte(genClientTokenSecret), genClientTokenID, tokenExpiry)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid --token-id: %v\n", err)
+			os.Exit(1)
+		}
+		genClientAuth = token
+	}
+	if secretPlaceholder != "" {
+		genClientAuth = secretPlaceholder
+	}
+
+	var tmpl *template.Template
+	if genClientTemplate != "" {
+		tmpl, err = parseClientTemplate(genClientTemplate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid template: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	proxyDomains, err := routeDomains(genClientRouteDomains, genClientUnblockCommon)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var decoyWarnings []string
+	if genClientDecoySNI != "" {
+		genClientSNI = "" // The decoy replaces an SNI from --based-on
+		decoyWarnings, err = decoySNIWarnings(genClientDecoySNI, genClientFromServer)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot read --from-server config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Each address of the server becomes a standby server, ahead of the
+	// others. The SNI stays the server's name, which the certificate has.
+	var resolvedAddrs []string
+	var resolvedAt time.Time
+	if genClientResolve {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		addrs, err := resolveServerAddrs(ctx, genClientServer, net.DefaultResolver.LookupIP)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --resolve: cannot resolve %s: %v\n", genClientServer, err)
+			os.Exit(1)
+		}
+		resolvedAddrs, resolvedAt = addrs, time.Now().UTC()
+		standby := slices.Clone(addrs)
+		for _, host := range genClientStandbyServers {
+			if !slices.Contains(standby, host) {
+				standby = append(standby, host)
+			}
+		}
+		genClientStandbyServers = standby
+	}
+
+	// The server's fallback for when it's full becomes a standby server, so
+	// that sing-box and Clash switch to it
+	var fallbackStandby, fallbackWarning string
+	if genClientFromServer != "" {
+		fallback, err := serverFallbackServer(genClientFromServer)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot read --from-server config: %v\n", err)
+			os.Exit(1)
+		}
+		if fallback != "" {
+			host, ok := fallbackStandbyHost(fallback, genClientPort)
+			switch {
+			case !ok:
+				fallbackWarning = fmt.Sprintf("The server's limits.fallbackServer %s isn't on port %d, which standby servers share. "+
+					"Add it with its own gen-client run instead.", fallback, genClientPort)
+			case host != genClientServer && !slices.Contains(genClientStandbyServers, host):
+				genClientStandbyServers = append(genClientStandbyServers, host)
+				fallbackStandby = host
+			}
+		}
+	}
+
+	var frontWarnings []string
+	if frontHost != "" && genClientFromServer != "" {
+		var err error
+		frontWarnings, err = frontAddressWarnings(genClientFromServer)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot read --from-server config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	params := genClientParams{
+		Server:          genClientServer,
+		Port:            genClientPort,
+		FrontHost:       frontHost,
+		FrontPort:       frontPort,
+		Auth:            genClientAuth,
+		SNI:             genClientSNI,
+		DecoySNI:        genClientDecoySNI,
+		Insecure:        genClientInsecure,
+		Resolve:         genClientResolve,
+		Obfs:            genClientObfs,
+		Profile:         genClientProfile,
+		KeepAlive:       genClientKeepAlive,
+		RecvWindow:      genClientRecvWindow,
+		RecvConn:        genClientRecvConn,
+		ALPN:            genClientALPN,
+		StandbyServers:  genClientStandbyServers,
+		TunnelProcesses: genClientTunnelProcs,
+		ProxyDomains:    proxyDomains,
+		FallbackDirect:  genClientFallbackDirect,
+		ClientType:      genClientClientType,
+		NativeFormat:    genClientNativeFormat,
+		MinifyNative:    genClientMinifyNative,
+		Indent:          indent,
+	}
+	serverAddr, sni := params.serverAddr(), params.sni()
+
+	// --auto-brutal replaces the preset with the measured bandwidth, which
+	// the client then sends at with brutal congestion control
+	presetName := genClientPreset
+	var measured speedtestResult
+	var measureErr error
+	if genClientAutoBrutal {
+		if genClientSpeedtest != "" {
+			measured, measureErr = loadSpeedtestResult(genClientSpeedtest)
+		} else if genClientValidateOnly {
+			measureErr = errors.New("not measured with --validate-only")
+		} else {
+			fmt.Fprintf(os.Stderr, "Measuring the bandwidth to %s, this takes about %s...\n", serverAddr, 2*autoBrutalTestTime)
+			mc := clientConfig{
+				Server: serverAddr,
+				Auth:   genClientAuth,
+				TLS: clientConfigTLS{
+					SNI:      sni,
+					Insecure: genClientInsecure,
+				},
+			}
+			if genClientObfs != "" {
+				mc.Obfs.Type = "salamander"
+				mc.Obfs.Salamander.Password = genClientObfs
+			}
+			measured, measureErr = measureBandwidth(mc)
+		}
+		if measureErr == nil {
+			preset = brutalPreset(measured, preset)
+			presetName = "measured"
+		}
+	}
+
+	params.PresetName, params.Preset = presetName, preset
+	configs, err := generateClientConfigs(params)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating config: %v\n", err)
+		os.Exit(1)
+	}
+	preset = configs.Preset
+	templateData := configs.Data
+
+	// Banners and hints are for humans, leave them out when a script runs us
+	info := io.Writer(os.Stderr)
+	if genClientJSONOnly || !isTerminal(os.Stdout) || !isTerminal(os.Stderr) {
+		info = io.Discard
+	}
+
+	fmt.Fprintln(info, "")
+	fmt.Fprintln(info, "╔══════════════════════════════════════════════════════════╗")
+	fmt.Fprintln(info, "║  LibyaLink Client Config Generator                      ║")
+	fmt.Fprintln(info, "║  Powered by Hysteria 2                                  ║")
+	fmt.Fprintln(info, "╚══════════════════════════════════════════════════════════╝")
+	fmt.Fprintln(info, "")
+	fmt.Fprintf(info, "  Server:   %s\n", serverAddr)
+	fmt.Fprintf(info, "  Preset:   %s (%s up / %s down)\n", presetName, preset.Up, preset.Down)
+	if genClientAutoBrutal {
+		if measureErr != nil {
+			fmt.Fprintf(os.Stderr, "  %s Bandwidth measurement failed, using the %s preset: %v\n", checkWarn, genClientPreset, measureErr)
+		} else {
+			fmt.Fprintf(info, "  Measured: %s down / %s up at %s, the config asks for %.0f%% of it\n",
+				formatMeasuredSpeed(measured.Download), formatMeasuredSpeed(measured.Upload),
+				measured.Time.Local().Format(time.DateTime), autoBrutalHeadroom*100)
+		}
+	}
+	if hasProfile {
+		fmt.Fprintf(info, "  Profile:  %s, %s (%.0f%% of the preset", genClientProfile, profile.Description, profile.BandwidthRatio*100)
+		if len(profileFlags) > 0 {
+			fmt.Fprintf(info, ", set --%s", strings.Join(profileFlags, ", --"))
+		}
+		fmt.Fprintln(info, ")")
+	}
+	fmt.Fprintf(info, "  Insecure: %v\n", genClientInsecure)
+	// Only here, not in the output, so that regenerating gives the same file
+	fmt.Fprintf(info, "  Generated: %s\n", time.Now().Format(time.RFC3339))
+	if len(basedOnFlags) > 0 {
+		fmt.Fprintf(info, "  Based on: %s (reused --%s)\n", basedOn, strings.Join(basedOnFlags, ", --"))
+	}
+	for _, w := range importWarnings {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, w)
+	}
+	if genClientKeepAlive != 0 {
+		fmt.Fprintf(info, "  Keep-alive: %s (native client only, sing-box uses its own QUIC keep-alive)\n", genClientKeepAlive)
+	}
+	if genClientRecvWindow != 0 || genClientRecvConn != 0 {
+		fmt.Fprintf(info, "  Receive windows: stream %s, connection %s (native client only, sing-box's hysteria2 outbound has no such option)\n",
+			formatRecvWindow(genClientRecvWindow), formatRecvWindow(genClientRecvConn))
+	}
+	for _, w := range recvWindowWarnings {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, w)
+	}
+	if frontHost != "" {
+		fmt.Fprintf(info, "  Front:    %s, forwarding to %s (SNI %s)\n", serverAddr,
+			net.JoinHostPort(genClientServer, strconv.Itoa(genClientPort)), sni)
+		fmt.Fprintf(info, "     The relay must pass UDP on unchanged, an HTTP CDN can't carry Hysteria's QUIC.\n")
+		for _, w := range frontWarnings {
+			fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, w)
+		}
+	}
+	if genClientDecoySNI != "" {
+		fmt.Fprintf(info, "  Decoy SNI: %s (the client still connects to %s)\n", genClientDecoySNI, serverAddr)
+		for _, w := range decoyWarnings {
+			fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, w)
+		}
+	}
+	if !tokenExpiry.IsZero() {
+		fmt.Fprintf(info, "  Token:    %s, expires %s\n", genClientTokenID, tokenExpiry.UTC().Format(time.RFC3339))
+		if tokenWindow != nil {
+			fmt.Fprintf(info, "  Schedule: %s %s daily (%s)\n", genClientSchedule, genClientScheduleTZ, tokenWindow)
+		}
+		if scheduleWarning != "" {
+			fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, scheduleWarning)
+		}
+	}
+	if len(genClientALPN) > 0 {
+		fmt.Fprintf(info, "  ALPN:     %s (sing-box only, the native client always negotiates h3)\n", strings.Join(genClientALPN, ", "))
+		if !slices.Contains(genClientALPN, "h3") {
+			fmt.Fprintf(os.Stderr, "  %s Hysteria 2 servers only accept the h3 ALPN, the handshake will fail without it.\n", checkWarn)
+		}
+	}
+	if fallbackStandby != "" {
+		fmt.Fprintf(info, "  Fallback: %s, the server's limits.fallbackServer, added as a standby server\n", fallbackStandby)
+	}
+	if fallbackWarning != "" {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, fallbackWarning)
+	}
+	for _, w := range portWarnings(templateData.Port, genClientISP) {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, w)
+	}
+	if genClientFromServer != "" {
+		warnings, err := checkPresetAgainstServer(genClientFromServer, preset)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot read --from-server config: %v\n", err)
+			os.Exit(1)
+		}
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "  %s %s\n", checkWarn, w)
+		}
+		if len(warnings) > 0 {
+			fmt.Fprintln(os.Stderr, "     The client will send faster than the server accepts and cause loss. Use a smaller --preset or raise the server's bandwidth.")
+		}
+	}
+	fmt.Fprintln(info, "")
+
+	if genClientValidateOnly {
+		fmt.Printf("%s The parameters are valid, no config was written.\n", checkOK)
+		return
+	}
+
+	// --- Generate sing-box / NekoBox format ---
+	fmt.Fprintln(info, "─── NekoBox / sing-box Configuration ───")
+	fmt.Fprintln(info, "")
+
+	sniFollowsServer := params.sniFollowsServer()
+	singBoxJSON := configs.SingBox
+	if len(resolvedAddrs) > 0 {
+		fmt.Fprintf(info, "  Resolved: %s to %s, listed as standby servers\n", genClientServer, strings.Join(resolvedAddrs, ", "))
+	}
+	if len(genClientStandbyServers) > 0 {
+		fmt.Fprintf(info, "  Standby:  %s (sing-box only, native client uses the primary)\n", strings.Join(genClientStandbyServers, ", "))
+		fmt.Fprintln(info, "")
+	}
+	if len(genClientTunnelProcs) > 0 {
+		fmt.Fprintf(info, "  Tunneled: %s (everything else goes direct)\n", strings.Join(genClientTunnelProcs, ", "))
+		fmt.Fprintln(info, "")
+	}
+	if genClientFallbackDirect {
+		fmt.Fprintln(info, "  Fallback: direct while no server answers (sing-box and Clash only, the native client has no direct route)")
+		fmt.Fprintln(info, "")
+	}
+	if len(proxyDomains) > 0 {
+		fmt.Fprintf(info, "  Proxied domains: %d, with their subdomains (sing-box and Clash only, everything else goes direct)\n", len(proxyDomains))
+		fmt.Fprintln(info, "")
+	}
+
+	// --- Also generate native Hysteria 2 client format ---
+	fmt.Fprintln(info, "─── Native Hysteria 2 Client Configuration ───")
+	fmt.Fprintln(info, "")
+
+	nativeConfig, nativeData := configs.NativeConfig, configs.Native
+
+	if genClientAllPlatforms {
+		nativeYAML, err := marshalHysteria2ClientConfig(nativeConfig, "yaml", false, indent)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating native config: %v\n", err)
+			os.Exit(1)
+		}
+		paths, err := writeAllPlatformsBundle(genClientOutputDir, templateData, sniFollowsServer, singBoxJSON, nativeYAML, indent)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing bundle: %v\n", err)
+			os.Exit(1)
+		}
+		for _, p := range paths {
+			fmt.Fprintf(info, "  ✅ Written: %s\n", p)
+		}
+		fmt.Fprintln(info, "")
+		fmt.Fprintf(info, "  📋 Send the whole folder to the user, %s says which file to use.\n", bundleReadmeFile)
+		fmt.Fprintln(info, "")
+		return
+	}
+
+	resolvedNote := ""
+	if len(resolvedAddrs) > 0 {
+		resolvedNote = fmt.Sprintf("// Addresses: %s resolved to %s at %s,\n// regenerate the config when they change\n",
+			genClientServer, strings.Join(resolvedAddrs, ", "), resolvedAt.Format(time.RFC3339))
+	}
+	output := formatGenClientOutput(presetName, preset, singBoxJSON, nativeData, secretPlaceholder, resolvedNote, genClientTuningNotes)
+	if genClientJSONOnly {
+		output = string(singBoxJSON) + "\n"
+	}
+	if genClientClientType == "openwrt" {
+		output = formatOpenWrtOutput(genClientPreset, preset, nativeData)
+	}
+	if genClientURIOnly {
+		output = genClientShareURI(templateData) + "\n"
+	}
+	if genClientQR {
+		qrPNG, err := brandedQRPNG(genClientShareURI(templateData), genClientBrandTitle, brandLogo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating QR code: %v\n", err)
+			os.Exit(1)
+		}
+		output = string(qrPNG)
+	}
+
+	if tmpl != nil {
+		// No checksum footer, we don't know the comment syntax of the format
+		rendered, err := renderClientTemplate(tmpl, templateData)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error rendering template: %v\n", err)
+			os.Exit(1)
+		}
+		output = string(rendered)
+		fmt.Fprintf(info, "  Output rendered from template %s\n", genClientTemplate)
+	}
 
 	// Write to file or stdout
 	if genClientOutput != "" {
@@ -328,15 +1184,142 @@ func runGenClient(cmd *cobra.Command, args []string) {
 			fmt.Fprintf(os.Stderr, "Error writing to %s: %v\n", genClientOutput, err)
 			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "  ✅ Configuration written to: %s\n", genClientOutput)
+		fmt.Fprintf(info, "  ✅ Configuration written to: %s\n", genClientOutput)
 	} else {
 		fmt.Print(output)
 	}
 
-	fmt.Fprintln(os.Stderr, "")
-	fmt.Fprintln(os.Stderr, "  📋 Copy the sing-box JSON block into NekoBox's manual config.")
-	fmt.Fprintln(os.Stderr, "  📋 Or save the Hysteria 2 block as config.yaml for the native client.")
-	fmt.Fprintln(os.Stderr, "")
+	if genClientClipboard != "" {
+		clip := singBoxJSON
+		if genClientClipboard == "native" {
+			clip = nativeData
+		}
+		if err := utils.CopyToClipboard(string(clip)); err != nil {
+			// Not fatal, the config has already been written out
+			fmt.Fprintf(os.Stderr, "  %s Could not copy to clipboard: %v\n", checkWarn, err)
+		} else {
+			fmt.Fprintf(info, "  ✅ Copied the %s config to the clipboard.\n", genClientClipboard)
+		}
+	}
+
+	if genClientLauncher == "win" {
+		dir := "."
+		if genClientOutput != "" {
+			dir = filepath.Dir(genClientOutput)
+		}
+		launcherConfig := nativeData
+		if genClientNativeFormat == "yaml" {
+			// JSON has no comments, so only YAML gets a checksum footer
+			launcherConfig = utils.AppendChecksumFooter(nativeData, "#")
+		}
+		paths, err := writeWindowsLaunchers(dir, "config."+genClientNativeFormat, launcherConfig)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing launcher: %v\n", err)
+			os.Exit(1)
+		}
+		for _, p := range paths {
+			fmt.Fprintf(info, "  ✅ Written: %s\n", p)
+		}
+		fmt.Fprintln(info, "  📋 Put libyalink.exe in the same folder and double-click the .bat file.")
+	}
+
+	fmt.Fprintln(info, "")
+	if genClientClientType == "openwrt" {
+		fmt.Fprintf(info, "  📋 Save the output as %s on the router and follow the steps at its top.\n", openWrtConfigPath)
+	} else if genClientURIOnly {
+		fmt.Fprintln(info, "  📋 Paste the URI in NekoBox, Hiddify or v2rayNG, they import it as is.")
+	} else if genClientQR {
+		fmt.Fprintln(info, "  📋 Print or send the image, NekoBox, Hiddify and v2rayNG import the connection by scanning it with their own scanner.")
+	} else {
+		fmt.Fprintln(info, "  📋 Copy the sing-box JSON block into NekoBox's manual config.")
+		fmt.Fprintln(info, "  📋 Or save the Hysteria 2 block as config.yaml for the native client.")
+	}
+	fmt.Fprintln(info, "")
+}
+
+// checkPresetAgainstServer reads the bandwidth of a server config and
+// returns a warning for each direction where the preset exceeds it.
+func checkPresetAgainstServer(path string, preset bandwidthPreset) ([]string, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var bw serverConfigBandwidth
+	if err := v.UnmarshalKey("bandwidth", &bw); err != nil {
+		return nil, err
+	}
+	return comparePresetBandwidth(preset, bw)
+}
+
+// serverFallbackServer returns the limits.fallbackServer of a server config,
+// where it sends clients when it's full.
+func serverFallbackServer(path string) (string, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return "", err
+	}
+	return v.GetString("limits.fallbackServer"), nil
+}
+
+// frontAddressWarnings lists the settings of the server config at path that
+// work by client address, which behind a relay is the relay's for everyone.
+func frontAddressWarnings(path string) ([]string, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var warnings []string
+	if v.GetInt("limits.authFailureBan.maxFailures") > 0 {
+		warnings = append(warnings, "The server's limits.authFailureBan bans by address, and behind the relay every client has the relay's: "+
+			"a few wrong passwords would lock everyone out. Turn it off on the server.")
+	}
+	return warnings, nil
+}
+
+// fallbackStandbyHost returns the host of a fallback server address, and
+// whether it can be a standby server, which uses the primary's port.
+func fallbackStandbyHost(fallback string, port int) (string, bool) {
+	host, p, err := net.SplitHostPort(fallback)
+	if err != nil {
+		// No port, the fallback uses the same one
+		return fallback, true
+	}
+	return host, p == strconv.Itoa(port)
+}
+
+// comparePresetBandwidth compares client bandwidth against the server's,
+// which is the other way round: the server's up is the client's down.
+// An unset server bandwidth is unlimited.
+func comparePresetBandwidth(preset bandwidthPreset, serverBandwidth serverConfigBandwidth) ([]string, error) {
+	serverBW, err := parseServerBandwidth(serverBandwidth, "bandwidth")
+	if err != nil {
+		return nil, err
+	}
+	clientUp, err := utils.ConvBandwidth(preset.Up)
+	if err != nil {
+		return nil, err
+	}
+	clientDown, err := utils.ConvBandwidth(preset.Down)
+	if err != nil {
+		return nil, err
+	}
+	var warnings []string
+	if serverBW.MaxRx != 0 && clientUp > serverBW.MaxRx {
+		warnings = append(warnings, fmt.Sprintf("Client upload %s exceeds the server's bandwidth.down %s.", preset.Up, serverBandwidth.Down))
+	}
+	if serverBW.MaxTx != 0 && clientDown > serverBW.MaxTx {
+		warnings = append(warnings, fmt.Sprintf("Client download %s exceeds the server's bandwidth.up %s.", preset.Down, serverBandwidth.Up))
+	}
+	return warnings, nil
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }
 
 func parseBandwidthToMbps(preset bandwidthPreset) (upMbps, downMbps int) {
@@ -344,3 +1327,57 @@ func parseBandwidthToMbps(preset bandwidthPreset) (upMbps, downMbps int) {
 	fmt.Sscanf(preset.Down, "%d", &downMbps)
 	return
 }
+
+// validateProcessName checks that a name is a bare executable name
+// as matched by sing-box's process_name rule, not a path.
+func validateProcessName(name string) error {
+	if name == "" || len(name) > 255 {
+		return errors.New("must be 1-255 characters long")
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return errors.New("must be an executable name, not a path")
+	}
+	for _, c := range name {
+		if c < ' ' || c == 0x7f {
+			return errors.New("must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// writeWindowsLaunchers writes the native client config and .bat/.ps1
+// scripts that run it from their own folder, so they work when
+// double-clicked. Both keep the window open if the client fails.
+func writeWindowsLaunchers(dir, configName string, config []byte) ([]string, error) {
+	bat := strings.ReplaceAll(`@echo off
+rem LibyaLink client launcher, generated by libyalink gen-client
+cd /d "%~dp0"
+libyalink.exe client -c "`+configName+`"
+if errorlevel 1 pause
+`, "\n", "\r\n")
+	ps1 := strings.ReplaceAll(`# LibyaLink client launcher, generated by libyalink gen-client
+Set-Location -Path $PSScriptRoot
+& .\libyalink.exe client -c "`+configName+`"
+if ($LASTEXITCODE -ne 0) {
+    Read-Host "LibyaLink exited with an error. Press Enter to close"
+}
+`, "\n", "\r\n")
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{configName, []byte(strings.TrimSuffix(string(config), "\n") + "\n")},
+		{"libyalink-client.bat", []byte(bat)},
+		{"libyalink-client.ps1", []byte(ps1)},
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		p := filepath.Join(dir, f.name)
+		if err := os.WriteFile(p, f.data, 0o644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, nil
+}
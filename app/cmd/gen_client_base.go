@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+// genClientBase holds the parameters recovered from a previously generated
+// config for --based-on. Zero values mean the config didn't have them.
+type genClientBase struct {
+	Server         string
+	Port           int
+	Auth           string
+	SNI            string
+	Insecure       bool
+	Obfs           string
+	Preset         string
+	KeepAlive      time.Duration
+	ALPN           []string
+	StandbyServers []string
+}
+
+// loadGenClientBase reads any config gen-client writes: the native config
+// (JSON or YAML), a sing-box config, or the full commented output.
+func loadGenClientBase(path string) (*genClientBase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if content, err := utils.VerifyChecksumFooter(data); !errors.Is(err, utils.ErrNoChecksumFooter) {
+		data = content
+	}
+	// The full output starts with the sing-box config, and both configs
+	// are surrounded by "//" comments.
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "//") {
+			lines = append(lines, line)
+		}
+	}
+	data = []byte(strings.Join(lines, "\n"))
+
+	var singBox struct {
+		Outbounds []json.RawMessage `json:"outbounds"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&singBox); err == nil && len(singBox.Outbounds) > 0 {
+		return genClientBaseFromSingBox(singBox.Outbounds)
+	}
+
+	var native hysteria2ClientConfig
+	if err := yaml.Unmarshal(data, &native); err != nil {
+		return nil, fmt.Errorf("not a config generated by gen-client: %w", err)
+	}
+	if native.Server == "" {
+		return nil, errors.New("not a config generated by gen-client: no server")
+	}
+	return genClientBaseFromNative(native)
+}
+
+func genClientBaseFromNative(c hysteria2ClientConfig) (*genClientBase, error) {
+	host, portStr, err := net.SplitHostPort(c.Server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server address '%s': %w", c.Server, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server port '%s'", portStr)
+	}
+	b := &genClientBase{
+		Server:   host,
+		Port:     port,
+		Auth:     c.Auth,
+		SNI:      c.TLS.SNI,
+		Insecure: c.TLS.Insecure,
+	}
+	if c.Obfs != nil {
+		b.Obfs = c.Obfs.Salamander.Password
+	}
+	if c.Bandwidth != nil {
+		for name, p := range bandwidthPresets {
+			if p.Up == c.Bandwidth.Up && p.Down == c.Bandwidth.Down {
+				b.Preset = name
+			}
+		}
+	}
+	if c.QUIC != nil && c.QUIC.KeepAlivePeriod != "" {
+		b.KeepAlive, err = time.ParseDuration(c.QUIC.KeepAlivePeriod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keep-alive period '%s'", c.QUIC.KeepAlivePeriod)
+		}
+	}
+	return b, nil
+}
+
+func genClientBaseFromSingBox(outbounds []json.RawMessage) (*genClientBase, error) {
+	var b *genClientBase
+	for _, raw := range outbounds {
+		var ob singBoxOutbound
+		if err := json.Unmarshal(raw, &ob); err != nil || ob.Type != "hysteria2" {
+			continue
+		}
+		if b != nil {
+			// Primary first, then the standby servers
+			b.StandbyServers = append(b.StandbyServers, ob.Server)
+			continue
+		}
+		b = &genClientBase{
+			Server:   ob.Server,
+			Port:     ob.ServerPort,
+			Auth:     ob.Password,
+			SNI:      ob.TLS.ServerName,
+			Insecure: ob.TLS.Insecure,
+			ALPN:     ob.TLS.ALPN,
+		}
+		if ob.Obfs != nil {
+			b.Obfs = ob.Obfs.Password
+		}
+		for name, p := range bandwidthPresets {
+			if up, down := parseBandwidthToMbps(p); up == ob.UpMbps && down == ob.DownMbps {
+				b.Preset = name
+			}
+		}
+	}
+	if b == nil {
+		return nil, errors.New("no hysteria2 outbound in the sing-box config")
+	}
+	return b, nil
+}
+
+// genClientBaseFromURI parses a hysteria2:// share URI for --import. It
+// also returns what the URI has that gen-client can't carry over.
+func genClientBaseFromURI(uri string) (*genClientBase, []string, error) {
+	c := clientConfig{Server: uri}
+	if !c.parseURI() {
+		return nil, nil, errors.New("not a hysteria2:// or hy2:// URI")
+	}
+	host, portStr, _ := parseServerAddrString(c.Server)
+	if host == "" {
+		return nil, nil, errors.New("no server address")
+	}
+	if isPortHoppingPort(portStr) {
+		return nil, nil, fmt.Errorf("port hopping (%s) isn't supported, --port takes a single port", portStr)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid server port '%s'", portStr)
+	}
+	b := &genClientBase{
+		Server:   host,
+		Port:     port,
+		Auth:     c.Auth,
+		SNI:      c.TLS.SNI,
+		Insecure: c.TLS.Insecure,
+	}
+	switch strings.ToLower(c.Obfs.Type) {
+	case "", "plain":
+	case "salamander":
+		b.Obfs = c.Obfs.Salamander.Password
+	default:
+		return nil, nil, fmt.Errorf("unsupported obfs type '%s'", c.Obfs.Type)
+	}
+	var warnings []string
+	if c.TLS.PinSHA256 != "" {
+		warnings = append(warnings, "The URI pins the server's certificate (pinSHA256), the generated configs don't. "+
+			"Add tls.pinSHA256 to the native config to keep it.")
+	}
+	return b, warnings, nil
+}
+
+// applyGenClientBase uses the values of b for every flag that wasn't given
+// explicitly (changed is usually cmd.Flags().Changed), and returns the
+// names of the flags it filled in.
+func applyGenClientBase(changed func(name string) bool, b *genClientBase) []string {
+	var applied []string
+	set := func(name string, ok bool, apply func()) {
+		if ok && !changed(name) {
+			apply()
+			applied = append(applied, name)
+		}
+	}
+	set("server", b.Server != "", func() { genClientServer = b.Server })
+	set("port", b.Port != 0, func() { genClientPort = b.Port })
+	// A new token or placeholder replaces the old auth, whatever it was
+	set("auth", b.Auth != "" && !changed("ttl") && !changed("secret-ref"), func() { genClientAuth = b.Auth })
+	// gen-client defaults the SNI to the server address, so only an
+	// explicit SNI is carried over, otherwise it follows --server
+	set("sni", b.SNI != "" && b.SNI != b.Server, func() { genClientSNI = b.SNI })
+	set("insecure", true, func() { genClientInsecure = b.Insecure })
+	set("obfs", b.Obfs != "", func() { genClientObfs = b.Obfs })
+	set("preset", b.Preset != "", func() { genClientPreset = b.Preset })
+	set("keepalive", b.KeepAlive != 0, func() { genClientKeepAlive = b.KeepAlive })
+	set("alpn", len(b.ALPN) > 0, func() { genClientALPN = b.ALPN })
+	set("standby-server", len(b.StandbyServers) > 0, func() { genClientStandbyServers = b.StandbyServers })
+	return applied
+}
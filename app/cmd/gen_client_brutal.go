@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+	"github.com/apernet/hysteria/extras/v2/outbounds/speedtest"
+)
+
+const (
+	// autoBrutalTestTime is how long --auto-brutal measures each direction.
+	// The test stops there, however much of autoBrutalDataSize is left.
+	autoBrutalTestTime = 8 * time.Second
+	autoBrutalDataSize = 1 << 30
+
+	// autoBrutalHeadroom is the share of the measured speed the config asks
+	// for. Brutal sends at that rate whatever the loss, so asking for more
+	// than the line carries only adds loss.
+	autoBrutalHeadroom = 0.9
+)
+
+// speedtestResult is what "speedtest --save" writes and
+// "gen-client --auto-brutal --speedtest-result" reads.
+type speedtestResult struct {
+	Time     time.Time `json:"time"`
+	Server   string    `json:"server"`
+	Download float64   `json:"download"` // Bytes per second, 0 if not tested
+	Upload   float64   `json:"upload"`   // Bytes per second, 0 if not tested
+}
+
+func saveSpeedtestResult(path string, r speedtestResult) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func loadSpeedtestResult(path string) (speedtestResult, error) {
+	var r speedtestResult
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+	if r.Download <= 0 && r.Upload <= 0 {
+		return r, errors.New("no download or upload speed in the result")
+	}
+	return r, nil
+}
+
+// measureBandwidth connects to the server with the given config and
+// measures both directions with the server's speed test, which has to be
+// enabled (speedTest: true).
+func measureBandwidth(config clientConfig) (speedtestResult, error) {
+	r := speedtestResult{Server: config.Server}
+	hyConfig, err := config.Config()
+	if err != nil {
+		return r, err
+	}
+	c, _, err := client.NewClient(hyConfig)
+	if err != nil {
+		return r, err
+	}
+	defer c.Close()
+	if r.Download, err = measureSpeed(c, false); err != nil {
+		return r, fmt.Errorf("download test: %w", err)
+	}
+	if r.Upload, err = measureSpeed(c, true); err != nil {
+		return r, fmt.Errorf("upload test: %w", err)
+	}
+	r.Time = time.Now().UTC()
+	return r, nil
+}
+
+// measureSpeed returns the speed of one direction in bytes per second,
+// from what went through in the first autoBrutalTestTime.
+func measureSpeed(c client.Client, upload bool) (float64, error) {
+	conn, err := c.TCP(speedtestAddr)
+	if err != nil {
+		if errors.As(err, &hyErrors.DialError{}) {
+			return 0, fmt.Errorf("server may not support speed test: %w", err)
+		}
+		return 0, err
+	}
+	defer conn.Close()
+
+	var mutex sync.Mutex
+	var total uint64
+	var elapsed time.Duration
+	cb := func(d time.Duration, b uint32, done bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if done {
+			total, elapsed = uint64(b), d
+		} else {
+			total += uint64(b)
+			elapsed += d
+		}
+	}
+	// Closing the connection ends the test, with an error we ignore if
+	// there's enough to go by
+	timer := time.AfterFunc(autoBrutalTestTime, func() { conn.Close() })
+	defer timer.Stop()
+	st := &speedtest.Client{Conn: conn}
+	if upload {
+		err = st.Upload(autoBrutalDataSize, cb)
+	} else {
+		err = st.Download(autoBrutalDataSize, cb)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if total == 0 || elapsed < time.Second {
+		if err == nil {
+			err = errors.New("nothing measured")
+		}
+		return 0, err
+	}
+	return float64(total) / elapsed.Seconds(), nil
+}
+
+// brutalPreset turns a measured speed into the bandwidth of the config,
+// autoBrutalHeadroom of it in whole Mbps, at least 1. A direction that
+// wasn't measured keeps the preset's value.
+func brutalPreset(r speedtestResult, preset bandwidthPreset) bandwidthPreset {
+	mbps := func(bytesPerSec float64) string {
+		return fmt.Sprintf("%d mbps", max(1, int(math.Floor(bytesPerSec*8/1e6*autoBrutalHeadroom))))
+	}
+	if r.Upload > 0 {
+		preset.Up = mbps(r.Upload)
+	}
+	if r.Download > 0 {
+		preset.Down = mbps(r.Download)
+	}
+	return preset
+}
+
+func formatMeasuredSpeed(bytesPerSec float64) string {
+	if bytesPerSec <= 0 {
+		return "not measured"
+	}
+	return formatSpeed(uint32(min(bytesPerSec, math.MaxUint32)), time.Second, false)
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+const (
+	bundleSingBoxFile = "singbox.json"
+	bundleClashFile   = "clash-meta.yaml"
+	bundleNativeFile  = "hysteria.yaml"
+	bundleURIFile     = "uri.txt"
+	bundleQRFile      = "qr.png"
+	bundleReadmeFile  = "README.txt"
+)
+
+type clashMetaConfig struct {
+	MixedPort   int                   `yaml:"mixed-port"`
+	AllowLAN    bool                  `yaml:"allow-lan"`
+	Mode        string                `yaml:"mode"`
+	Proxies     []clashMetaProxy      `yaml:"proxies"`
+	ProxyGroups []clashMetaProxyGroup `yaml:"proxy-groups"`
+	Rules       []string              `yaml:"rules"`
+}
+
+type clashMetaProxy struct {
+	Name           string   `yaml:"name"`
+	Type           string   `yaml:"type"`
+	Server         string   `yaml:"server"`
+	Port           int      `yaml:"port"`
+	Password       string   `yaml:"password"`
+	Up             string   `yaml:"up,omitempty"`
+	Down           string   `yaml:"down,omitempty"`
+	Obfs           string   `yaml:"obfs,omitempty"`
+	ObfsPassword   string   `yaml:"obfs-password,omitempty"`
+	SNI            string   `yaml:"sni,omitempty"`
+	SkipCertVerify bool     `yaml:"skip-cert-verify"`
+	ALPN           []string `yaml:"alpn,omitempty"`
+}
+
+type clashMetaProxyGroup struct {
+	Name     string   `yaml:"name"`
+	Type     string   `yaml:"type"`
+	Proxies  []string `yaml:"proxies"`
+	URL      string   `yaml:"url,omitempty"`
+	Interval int      `yaml:"interval,omitempty"`
+}
+
+// newClashMetaConfig is newSingBoxConfig for Clash.Meta (mihomo): a
+// hysteria2 proxy per server, with a fallback group for standby servers.
+func newClashMetaConfig(data genClientTemplateData, sniFollowsServer bool) clashMetaConfig {
+	proxy := clashMetaProxy{
+		Name:           "libyalink",
+		Type:           "hysteria2",
+		Server:         data.Server,
+		Port:           data.Port,
+		Password:       data.Auth,
+		SNI:            data.SNI,
+		SkipCertVerify: data.Insecure,
+		ALPN:           data.ALPN,
+	}
+	if data.UpMbps > 0 {
+		proxy.Up = fmt.Sprintf("%d Mbps", data.UpMbps)
+	}
+	if data.DownMbps > 0 {
+		proxy.Down = fmt.Sprintf("%d Mbps", data.DownMbps)
+	}
+	if data.Obfs != "" {
+		proxy.Obfs = "salamander"
+		proxy.ObfsPassword = data.Obfs
+	}
+	proxies := []clashMetaProxy{proxy}
+	for i, standby := range data.StandbyServers {
+		p := proxy
+		p.Name = fmt.Sprintf("libyalink-standby-%d", i+1)
+		p.Server = standby
+		if sniFollowsServer {
+			p.SNI = standby
+		}
+		proxies = append(proxies, p)
+	}
+
+	group := clashMetaProxyGroup{Name: "LibyaLink", Type: "select"}
+	for _, p := range proxies {
+		group.Proxies = append(group.Proxies, p.Name)
+	}
+	if data.FallbackDirect {
+		group.Proxies = append(group.Proxies, "DIRECT")
+	}
+	if len(group.Proxies) > 1 {
+		group.Type = "fallback"
+		group.URL = "https://www.gstatic.com/generate_204"
+		group.Interval = 60
+	}
+
+	var rules []string
+	if len(data.TunnelProcesses) > 0 || len(data.ProxyDomains) > 0 {
+		for _, proc := range data.TunnelProcesses {
+			rules = append(rules, "PROCESS-NAME,"+proc+","+group.Name)
+		}
+		for _, domain := range data.ProxyDomains {
+			rules = append(rules, "DOMAIN-SUFFIX,"+domain+","+group.Name)
+		}
+		rules = append(rules, "MATCH,DIRECT")
+	} else {
+		rules = []string{"MATCH," + group.Name}
+	}
+	return clashMetaConfig{
+		MixedPort:   7890,
+		Mode:        "rule",
+		Proxies:     proxies,
+		ProxyGroups: []clashMetaProxyGroup{group},
+		Rules:       rules,
+	}
+}
+
+// genClientShareURI is the hysteria2:// URI of the primary server, the
+// same as "libyalink share" prints for the native config.
+func genClientShareURI(data genClientTemplateData) string {
+	c := clientConfig{
+		Server: data.ServerAddr,
+		Auth:   data.Auth,
+		TLS: clientConfigTLS{
+			SNI:      data.SNI,
+			Insecure: data.Insecure,
+		},
+	}
+	if data.Obfs != "" {
+		c.Obfs.Type = "salamander"
+		c.Obfs.Salamander.Password = data.Obfs
+	}
+	return c.URI()
+}
+
+// writeAllPlatformsBundle writes a config for every supported client to
+// dir, with a README telling the user which one to pick, and returns the
+// paths written. The files hold the password, so only the owner can read
+// them. indent applies to the Clash config, the others come encoded.
+func writeAllPlatformsBundle(dir string, data genClientTemplateData, sniFollowsServer bool, singBoxJSON, nativeYAML []byte, indent outputIndent) ([]string, error) {
+	clashYAML, err := indent.marshalYAML(newClashMetaConfig(data, sniFollowsServer))
+	if err != nil {
+		return nil, err
+	}
+	uri := genClientShareURI(data)
+	code, err := qr.Encode(uri, qr.L)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{bundleSingBoxFile, append(singBoxJSON, '\n')},
+		{bundleClashFile, clashYAML},
+		{bundleNativeFile, nativeYAML},
+		{bundleURIFile, []byte(uri + "\n")},
+		{bundleQRFile, code.PNG()},
+		{bundleReadmeFile, []byte(formatBundleReadme(data))},
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, f.data, 0o600); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func formatBundleReadme(data genClientTemplateData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `LibyaLink connection files
+==========================
+
+Server: %s (Hysteria 2, preset %s: %s up / %s down)
+
+Pick the line for your device and use the file it names. All files hold
+the same connection, keep them private: anyone with them can connect.
+
+Android
+  NekoBox, Hiddify, v2rayNG:  scan %[5]s, or import %[6]s
+  sing-box (SFA):             import %[7]s as a profile
+
+iPhone / iPad
+  Shadowrocket, Stash, Streisand, Hiddify:  scan %[5]s, or paste %[6]s
+  sing-box (SFI):                           import %[7]s as a profile
+
+Windows / macOS / Linux
+  Clash Verge Rev, FlClash, mihomo:  import %[8]s as a profile
+  Hiddify, NekoRay:                  paste the link in %[6]s
+  LibyaLink / Hysteria client:       libyalink client -c %[9]s
+
+If one app doesn't work, try another from the same line. The link in
+%[6]s and the QR code %[5]s are the same, use whichever is easier to
+move to the device.
+`, data.ServerAddr, data.Preset, data.Up, data.Down,
+		bundleQRFile, bundleURIFile, bundleSingBoxFile, bundleClashFile, bundleNativeFile)
+	if len(data.StandbyServers) > 0 {
+		fmt.Fprintf(&b, "\nThe sing-box and Clash files also switch to the standby servers (%s)\nwhen the main one is down. The link, QR code and %s only use the main one.\n",
+			strings.Join(data.StandbyServers, ", "), bundleNativeFile)
+	}
+	return b.String()
+}
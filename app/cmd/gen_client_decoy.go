@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// decoySNISuggestions are domains that most users in Libya reach over
+// QUIC every day, so a handshake naming one of them doesn't stand out.
+var decoySNISuggestions = []struct {
+	Domain string
+	Note   string
+}{
+	{"www.google.com", "Google search, the most common HTTP/3 handshake"},
+	{"www.youtube.com", "YouTube pages"},
+	{"i.ytimg.com", "YouTube thumbnails, constant background traffic"},
+	{"www.facebook.com", "Facebook, the most used site in Libya"},
+	{"scontent.xx.fbcdn.net", "Facebook and Instagram photos"},
+	{"www.instagram.com", "Instagram"},
+	{"static.whatsapp.net", "WhatsApp media"},
+}
+
+func printDecoySNISuggestions() {
+	fmt.Println("Suggested decoy SNIs, all served over HTTP/3 and visited daily by most users:")
+	fmt.Println()
+	for _, s := range decoySNISuggestions {
+		fmt.Printf("  %-24s %s\n", s.Domain, s.Note)
+	}
+	fmt.Println()
+	fmt.Println("Use one with: libyalink gen-client --server YOUR_IP --auth \"pass\" --decoy-sni www.google.com")
+}
+
+func validateDecoySNI(s string) error {
+	if net.ParseIP(s) != nil {
+		return errors.New("an SNI is a domain name, not an IP address")
+	}
+	if !strings.Contains(s, ".") {
+		return errors.New("not a valid domain name")
+	}
+	return validateInitHost(s)
+}
+
+// decoySNIWarnings returns what a decoy SNI gives up, and, given the
+// server's config file, whether the server will accept it at all.
+func decoySNIWarnings(decoy, serverConfigPath string) ([]string, error) {
+	warnings := []string{
+		fmt.Sprintf("The server's certificate isn't for %s, so the client can't verify it and relies on --insecure. "+
+			"Someone who can intercept the connection can impersonate the server and see the password. "+
+			"Add tls.pinSHA256 with the certificate's hash to the native config to close that.", decoy),
+		fmt.Sprintf("The server's IP isn't one of %s's. A decoy defeats blocking by SNI, "+
+			"not a censor that checks the SNI against the destination IP.", decoy),
+	}
+	if serverConfigPath == "" {
+		return append(warnings, "The server must accept the decoy: use tls (not acme) with tls.sniGuard: disable. "+
+			"Check with --from-server."), nil
+	}
+	v := viper.New()
+	v.SetConfigFile(serverConfigPath)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	switch guard := strings.ToLower(v.GetString("tls.sniGuard")); {
+	case v.IsSet("acme"):
+		warnings = append(warnings, "The server uses acme, which has no certificate for the decoy name and "+
+			"fails the handshake. Use tls with the certificate files and tls.sniGuard: disable.")
+	case guard != "disable":
+		if guard == "" {
+			guard = "dns-san, the default"
+		}
+		warnings = append(warnings, fmt.Sprintf("The server's tls.sniGuard is %s, which rejects a decoy SNI "+
+			"unless the certificate has no DNS names. Set tls.sniGuard: disable.", guard))
+	}
+	return warnings, nil
+}
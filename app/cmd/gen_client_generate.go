@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// genClientParams are the settings gen-client generates the configs from,
+// once the flags are validated. serve-ui fills them from its form.
+type genClientParams struct {
+	Server     string // Host only, named by the SNI when behind a front
+	Port       int
+	FrontHost  string // The relay clients connect to, empty for none
+	FrontPort  int
+	Auth       string
+	SNI        string
+	DecoySNI   string
+	Insecure   bool
+	Resolve    bool // The standby servers are the addresses of Server
+	Obfs       string
+	PresetName string
+	Preset     bandwidthPreset // Before the profile scales it
+	Profile    string
+	KeepAlive  time.Duration
+	RecvWindow uint64
+	RecvConn   uint64
+
+	ALPN            []string
+	StandbyServers  []string
+	TunnelProcesses []string
+	ProxyDomains    []string
+	FallbackDirect  bool
+
+	ClientType   string // "openwrt" for the router's config
+	NativeFormat string
+	MinifyNative bool
+	Indent       outputIndent
+}
+
+// genClientConfigs are the configs generated from one genClientParams.
+type genClientConfigs struct {
+	Data         genClientTemplateData
+	Preset       bandwidthPreset // Scaled by the profile
+	SingBox      []byte
+	NativeConfig hysteria2ClientConfig
+	Native       []byte // In NativeFormat
+}
+
+// connectAddr is where clients connect: with a front, the relay, and only
+// the SNI names the server.
+func (p genClientParams) connectAddr() (string, int) {
+	if p.FrontHost != "" {
+		return p.FrontHost, p.FrontPort
+	}
+	return p.Server, p.Port
+}
+
+func (p genClientParams) serverAddr() string {
+	host, port := p.connectAddr()
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// sni is the name the client asks for, the server's when the connect
+// address doesn't name it.
+func (p genClientParams) sni() string {
+	if p.DecoySNI != "" {
+		return p.DecoySNI
+	}
+	if p.SNI == "" && (p.Insecure || p.FrontHost != "" || p.Resolve) {
+		return p.Server
+	}
+	return p.SNI
+}
+
+// sniFollowsServer reports whether the standby servers use their own name
+// as the SNI. The resolved addresses aren't names the server can be asked
+// for.
+func (p genClientParams) sniFollowsServer() bool {
+	return p.SNI == "" && p.DecoySNI == "" && p.Insecure && !p.Resolve
+}
+
+// generateClientConfigs generates the sing-box and native configs, and
+// the data the share URI, the bundle and --template are made from.
+func generateClientConfigs(p genClientParams) (genClientConfigs, error) {
+	var c genClientConfigs
+	c.Preset = p.Preset
+	profile, hasProfile := genClientProfiles[p.Profile]
+	if hasProfile {
+		c.Preset = profilePreset(c.Preset, profile.BandwidthRatio)
+	}
+
+	// Parse bandwidth to Mbps integers for sing-box format
+	upMbps, downMbps := parseBandwidthToMbps(c.Preset)
+
+	keepAlive := ""
+	if p.KeepAlive != 0 {
+		keepAlive = p.KeepAlive.String()
+	}
+	host, port := p.connectAddr()
+	c.Data = genClientTemplateData{
+		Server:          host,
+		Port:            port,
+		ServerAddr:      p.serverAddr(),
+		Auth:            p.Auth,
+		SNI:             p.sni(),
+		Insecure:        p.Insecure,
+		Obfs:            p.Obfs,
+		Preset:          p.PresetName,
+		Up:              c.Preset.Up,
+		Down:            c.Preset.Down,
+		UpMbps:          upMbps,
+		DownMbps:        downMbps,
+		KeepAlive:       keepAlive,
+		ALPN:            p.ALPN,
+		StandbyServers:  p.StandbyServers,
+		TunnelProcesses: p.TunnelProcesses,
+		ProxyDomains:    p.ProxyDomains,
+		FallbackDirect:  p.FallbackDirect,
+	}
+
+	var err error
+	c.SingBox, err = p.Indent.marshalJSON(newSingBoxConfig(c.Data, p.sniFollowsServer()))
+	if err != nil {
+		return c, err
+	}
+
+	c.NativeConfig = newHysteria2ClientConfig(c.Data.ServerAddr, p.Auth, c.Data.SNI, p.Insecure, c.Preset, p.Obfs, p.KeepAlive)
+	if p.ClientType == "openwrt" {
+		c.NativeConfig = newOpenWrtClientConfig(c.NativeConfig)
+	}
+	setReceiveWindows(&c.NativeConfig, p.RecvWindow, p.RecvConn)
+	if hasProfile {
+		setMaxIdleTimeout(&c.NativeConfig, profile.MaxIdleTimeout)
+	}
+	c.Native, err = marshalHysteria2ClientConfig(c.NativeConfig, p.NativeFormat, p.MinifyNative, p.Indent)
+	return c, err
+}
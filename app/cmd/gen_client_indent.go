@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputIndent is how the generated configs are indented (--indent).
+type outputIndent struct {
+	JSON string // Indent of each JSON level
+	YAML int    // Spaces per YAML level, 0 for the yaml.v3 default (4)
+}
+
+// defaultOutputIndent is what gen-client has always written.
+var defaultOutputIndent = outputIndent{JSON: "  "}
+
+// parseOutputIndent parses --indent: a number of spaces for both JSON and
+// YAML, or "tab" for JSON only, as YAML doesn't allow tabs.
+func parseOutputIndent(s string) (outputIndent, error) {
+	if s == "" {
+		return defaultOutputIndent, nil
+	}
+	if strings.EqualFold(s, "tab") {
+		return outputIndent{JSON: "\t"}, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 2 || n > 8 {
+		return outputIndent{}, errors.New("must be a number of spaces between 2 and 8, or 'tab'")
+	}
+	return outputIndent{JSON: strings.Repeat(" ", n), YAML: n}, nil
+}
+
+func (i outputIndent) marshalJSON(v any) ([]byte, error) {
+	return json.MarshalIndent(v, "", i.JSON)
+}
+
+// marshalYAML is yaml.Marshal with the indent, ending with a newline.
+func (i outputIndent) marshalYAML(v any) ([]byte, error) {
+	if i.YAML == 0 {
+		return yaml.Marshal(v)
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(i.YAML)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
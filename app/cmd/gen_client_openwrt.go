@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+const (
+	openWrtConfigPath   = "/etc/hysteria/config.yaml"
+	openWrtRedirectPort = 12345
+	openWrtDNSListen    = "127.0.0.1:5353"
+	openWrtDNSUpstream  = "1.1.1.1:53"
+)
+
+type hysteria2ClientTCPRedirect struct {
+	Listen string `json:"listen" yaml:"listen"`
+}
+
+type hysteria2ClientUDPForwarding struct {
+	Listen string `json:"listen" yaml:"listen"`
+	Remote string `json:"remote" yaml:"remote"`
+}
+
+// newOpenWrtClientConfig trims the native config for a router: no HTTP
+// proxy, a SOCKS5 proxy for the router itself, a TCP redirect target for
+// the LAN and a DNS forwarder for dnsmasq. No TUN, which many OpenWrt
+// builds lack.
+func newOpenWrtClientConfig(native hysteria2ClientConfig) hysteria2ClientConfig {
+	native.HTTP = nil
+	native.Socks5 = &hysteria2ClientSocks5{Listen: "127.0.0.1:1080"}
+	native.TCPRedirect = &hysteria2ClientTCPRedirect{Listen: fmt.Sprintf(":%d", openWrtRedirectPort)}
+	native.UDPForwarding = []hysteria2ClientUDPForwarding{{Listen: openWrtDNSListen, Remote: openWrtDNSUpstream}}
+	return native
+}
+
+// formatOpenWrtOutput is formatGenClientOutput for --client-type openwrt:
+// the native YAML config with the setup steps and UCI commands as
+// comments, so the whole output can be saved as the config file.
+func formatOpenWrtOutput(presetName string, preset bandwidthPreset, nativeYAML []byte) string {
+	output := fmt.Sprintf(`# ============================================================
+# LibyaLink Router Configuration (OpenWrt) — Generated Automatically
+# Powered by Hysteria 2
+# Preset: %[1]s (%[2]s up / %[3]s down)
+# ============================================================
+#
+# 1. Save this file as %[4]s on the router and run
+#    the client with it, e.g. with the hysteria package:
+#      opkg update && opkg install hysteria
+#      hysteria client -c %[4]s
+#
+# 2. Send DNS through the tunnel (dnsmasq -> %[5]s -> %[6]s):
+#      uci set dhcp.@dnsmasq[0].noresolv='1'
+#      uci -q delete dhcp.@dnsmasq[0].server
+#      uci add_list dhcp.@dnsmasq[0].server='%[7]s'
+#      uci commit dhcp && /etc/init.d/dnsmasq restart
+#
+# 3. Send the TCP traffic of the LAN through the tunnel. Replace
+#    192.168.1.1 with the router's LAN address so LuCI stays reachable:
+#      uci add firewall redirect
+#      uci set firewall.@redirect[-1].name='libyalink'
+#      uci set firewall.@redirect[-1].src='lan'
+#      uci set firewall.@redirect[-1].proto='tcp'
+#      uci set firewall.@redirect[-1].src_dip='!192.168.1.1'
+#      uci set firewall.@redirect[-1].dest_port='%[8]d'
+#      uci set firewall.@redirect[-1].target='DNAT'
+#      uci commit firewall && /etc/init.d/firewall restart
+#
+# UDP other than DNS goes direct. Apps on the router itself can use
+# the SOCKS5 proxy on 127.0.0.1:1080.
+
+%[9]s
+`, presetName, preset.Up, preset.Down, openWrtConfigPath, openWrtDNSListen, openWrtDNSUpstream,
+		strings.Replace(openWrtDNSListen, ":", "#", 1), openWrtRedirectPort, string(nativeYAML))
+
+	return string(utils.AppendChecksumFooter([]byte(output), "#"))
+}
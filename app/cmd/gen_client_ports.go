@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// portAdvisory is a port range operators have seen throttled or blocked
+// for UDP, on one ISP's network or on most. Only advice: networks change,
+// and gen-client writes the config whatever the port.
+type portAdvisory struct {
+	ISP      string // Empty for every network
+	From, To int
+	Note     string
+}
+
+// genClientISPs are the names --isp takes.
+var genClientISPs = []string{"libyana", "almadar", "ltt"}
+
+// portAdvisories is what operators have reported so far. Keep the notes
+// short, they're printed as is after the port.
+var portAdvisories = []portAdvisory{
+	{From: 53, To: 53, Note: "is DNS, UDP to it is often intercepted or redirected to the ISP's resolver"},
+	{From: 123, To: 123, Note: "is NTP, often rate-limited to a few packets per second"},
+	{From: 500, To: 500, Note: "is IPsec, VPN ports are the first to be throttled or blocked"},
+	{From: 1194, To: 1194, Note: "is OpenVPN's default, VPN ports are the first to be throttled or blocked"},
+	{From: 1701, To: 1701, Note: "is L2TP, VPN ports are the first to be throttled or blocked"},
+	{From: 4500, To: 4500, Note: "is IPsec NAT traversal, VPN ports are the first to be throttled or blocked"},
+	{From: 5060, To: 5061, Note: "is SIP, VoIP ports are often blocked"},
+	{From: 51820, To: 51820, Note: "is WireGuard's default, VPN ports are the first to be throttled or blocked"},
+	{ISP: "libyana", From: 3478, To: 3481, Note: "is STUN and VoIP media, shaped to voice call rates on Libyana"},
+	{ISP: "almadar", From: 10000, To: 20000, Note: "is the VoIP media range, shaped in the evening peak on Al-Madar"},
+	{ISP: "ltt", From: 1, To: 442, Note: "is a low port, UDP to low ports other than 443 is filtered on many LTT lines"},
+	{ISP: "ltt", From: 444, To: 1023, Note: "is a low port, UDP to low ports other than 443 is filtered on many LTT lines"},
+}
+
+// portWarnings returns the advisories that match port, for isp and for
+// every network. isp is one of genClientISPs or empty.
+func portWarnings(port int, isp string) []string {
+	var warnings []string
+	for _, a := range portAdvisories {
+		if port < a.From || port > a.To || (a.ISP != "" && a.ISP != isp) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("Port %d %s. Consider --port 443, which blends in with HTTP/3, "+
+			"or port hopping on the server.", port, a.Note))
+	}
+	return warnings
+}
+
+func validateISP(isp string) error {
+	if isp == "" || slices.Contains(genClientISPs, isp) {
+		return nil
+	}
+	return fmt.Errorf("unknown ISP '%s', use one of %s", isp, strings.Join(genClientISPs, ", "))
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+// loadPresetFile reads the presets of a --preset-file, a map of names to
+// up and down bandwidth:
+//
+//	ltt-fiber-200:
+//	  up: 20 mbps
+//	  down: 200 mbps
+//
+// The values are normalized to whole Mbps, which is all sing-box takes.
+func loadPresetFile(path string) (map[string]bandwidthPreset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var presets map[string]bandwidthPreset
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&presets); err != nil {
+		return nil, err
+	}
+	if len(presets) == 0 {
+		return nil, errors.New("no presets in the file")
+	}
+	for name, p := range presets {
+		if err := validatePresetName(name); err != nil {
+			return nil, fmt.Errorf("preset '%s': %w", name, err)
+		}
+		if p.Up, err = normalizePresetBandwidth(p.Up); err != nil {
+			return nil, fmt.Errorf("preset '%s': up: %w", name, err)
+		}
+		if p.Down, err = normalizePresetBandwidth(p.Down); err != nil {
+			return nil, fmt.Errorf("preset '%s': down: %w", name, err)
+		}
+		presets[name] = p
+	}
+	return presets, nil
+}
+
+// validatePresetName checks that a name can be typed after --preset as is.
+func validatePresetName(name string) error {
+	if name == "" || len(name) > 32 {
+		return errors.New("name must be 1-32 characters long")
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' && c != '.' {
+			return errors.New("name must only contain lowercase letters, digits, '-', '_' and '.'")
+		}
+	}
+	return nil
+}
+
+func normalizePresetBandwidth(s string) (string, error) {
+	if s == "" {
+		return "", errors.New("missing")
+	}
+	bps, err := utils.ConvBandwidth(s)
+	if err != nil {
+		return "", err
+	}
+	mbps := bps * 8 / 1000000
+	if mbps == 0 {
+		return "", fmt.Errorf("'%s' is less than 1 mbps", s)
+	}
+	return fmt.Sprintf("%d mbps", mbps), nil
+}
+
+// presetNames returns the names of the presets, sorted.
+func presetNames(presets map[string]bandwidthPreset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// printPresets lists the presets for --list-presets, with where the ones
+// from the preset file come from.
+func printPresets(presets, custom map[string]bandwidthPreset, presetFile string) {
+	fmt.Println("Bandwidth presets (the client's upload and download):")
+	fmt.Println()
+	for _, name := range presetNames(presets) {
+		p := presets[name]
+		source := "built-in"
+		if _, ok := custom[name]; ok {
+			source = presetFile
+		}
+		fmt.Printf("  %-20s up %-10s down %-10s (%s)\n", name, p.Up, p.Down, source)
+	}
+	fmt.Println()
+	fmt.Println("Use one with: libyalink gen-client --server YOUR_IP --auth \"pass\" --preset " + presetNames(presets)[0])
+}
+
+// unknownPresetHint lists the presets to choose from.
+func unknownPresetHint(presets map[string]bandwidthPreset) string {
+	names := presetNames(presets)
+	for i, name := range names {
+		names[i] = "'" + name + "'"
+	}
+	return "Use one of " + strings.Join(names, ", ") + ", or see --list-presets."
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+// clientProfile is a set of client settings for one kind of use, on
+// top of the bandwidth preset. Flags given explicitly win over it.
+type clientProfile struct {
+	Description string
+	// BandwidthRatio scales the preset, so that brutal sends below what the
+	// line carries and the queues on the way stay short
+	BandwidthRatio float64
+	KeepAlive      time.Duration
+	RecvWindow     uint64
+	RecvConn       uint64
+	// MaxIdleTimeout is how soon the native client gives up on a dead
+	// connection and reconnects, 0 for the client's 30s
+	MaxIdleTimeout time.Duration
+}
+
+// genClientProfiles are the names --profile takes.
+var genClientProfiles = map[string]clientProfile{
+	"gaming": {
+		Description:    "low latency over throughput, for games and calls",
+		BandwidthRatio: 0.6,
+		KeepAlive:      5 * time.Second,
+		RecvWindow:     1 << 20, // 1MB
+		RecvConn:       2 << 20, // 2MB
+		MaxIdleTimeout: 10 * time.Second,
+	},
+}
+
+func validateProfile(name string) error {
+	if _, ok := genClientProfiles[name]; name == "" || ok {
+		return nil
+	}
+	names := make([]string, 0, len(genClientProfiles))
+	for n := range genClientProfiles {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return fmt.Errorf("unknown profile '%s', use one of %s", name, strings.Join(names, ", "))
+}
+
+// applyGenClientProfile sets the settings of p whose flags weren't given,
+// e.g. &genClientKeepAlive, and returns the names of those flags.
+func applyGenClientProfile(changed func(name string) bool, p clientProfile, keepAlive *time.Duration, recvWindow, recvConn *uint64) []string {
+	var applied []string
+	set := func(name string, apply func()) {
+		if !changed(name) {
+			apply()
+			applied = append(applied, name)
+		}
+	}
+	set("keepalive", func() { *keepAlive = p.KeepAlive })
+	set("recv-window", func() { *recvWindow = p.RecvWindow })
+	set("recv-window-conn", func() { *recvConn = p.RecvConn })
+	return applied
+}
+
+// profilePreset scales both directions of preset by ratio, to whole Mbps
+// and at least 1. Values that don't parse are left as they are.
+func profilePreset(preset bandwidthPreset, ratio float64) bandwidthPreset {
+	scale := func(s string) string {
+		bps, err := utils.ConvBandwidth(s)
+		if err != nil {
+			return s
+		}
+		return fmt.Sprintf("%d mbps", max(1, int(math.Floor(float64(bps)*8/1e6*ratio))))
+	}
+	return bandwidthPreset{Up: scale(preset.Up), Down: scale(preset.Down)}
+}
+
+// setMaxIdleTimeout sets the native config's quic.maxIdleTimeout, 0 leaves
+// the client's default.
+func setMaxIdleTimeout(c *hysteria2ClientConfig, d time.Duration) {
+	if d == 0 {
+		return
+	}
+	if c.QUIC == nil {
+		c.QUIC = &hysteria2ClientQUIC{}
+	}
+	c.QUIC.MaxIdleTimeout = d.String()
+}
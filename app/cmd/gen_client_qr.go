@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+const (
+	qrModulePixels = 8 // pixels per QR module
+	qrQuietZone    = 4 // modules of white around the code, the least the QR spec allows
+	// qrLogoDivisor makes the logo a fifth of the code's side. That hides
+	// about 4% of the modules, which level H error correction (30%) recovers
+	// with room to spare for a worn print.
+	qrLogoDivisor = 5
+
+	brandTitleMaxLen   = 32
+	brandTitleMaxScale = 4 // pixels per font pixel
+	brandGlyphWidth    = 5
+	brandGlyphHeight   = 7
+	brandGlyphAdvance  = brandGlyphWidth + 1
+	brandTitleChars    = "Latin letters, digits, spaces and . , : ; ! ? ' - + / & @ # ( ) _"
+	brandLogoMaxPixels = 4096 * 4096
+	qrLogoPadding      = 1 // modules of white between the logo and the code around it
+)
+
+// brandGlyphs is a 5x7 pixel font for --brand-title, one byte per row from
+// the top, the low 5 bits from left to right. Lowercase letters are drawn
+// as uppercase.
+var brandGlyphs = map[rune][brandGlyphHeight]byte{
+	'A':  {0x0E, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'B':  {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C':  {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D':  {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F':  {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G':  {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H':  {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I':  {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J':  {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K':  {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L':  {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M':  {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N':  {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O':  {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P':  {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q':  {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R':  {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S':  {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T':  {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W':  {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X':  {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y':  {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'0':  {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1':  {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3':  {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4':  {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5':  {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6':  {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7':  {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8':  {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9':  {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	' ':  {},
+	'.':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	',':  {0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08},
+	':':  {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	';':  {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x04, 0x08},
+	'!':  {0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04},
+	'?':  {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+	'\'': {0x0C, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},
+	'-':  {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'+':  {0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00},
+	'/':  {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'&':  {0x0C, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0D},
+	'@':  {0x0E, 0x11, 0x01, 0x0D, 0x15, 0x15, 0x0E},
+	'#':  {0x0A, 0x0A, 0x1F, 0x0A, 0x1F, 0x0A, 0x0A},
+	'(':  {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')':  {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'_':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+}
+
+// validateBrandTitle checks that --brand-title can be drawn with
+// brandGlyphs and fits under the code.
+func validateBrandTitle(title string) error {
+	if strings.TrimSpace(title) == "" {
+		return errors.New("must not be empty")
+	}
+	if len(title) > brandTitleMaxLen {
+		return fmt.Errorf("must be at most %d characters", brandTitleMaxLen)
+	}
+	for _, r := range strings.ToUpper(title) {
+		if _, ok := brandGlyphs[r]; !ok {
+			return fmt.Errorf("can't draw '%c', use %s", r, brandTitleChars)
+		}
+	}
+	return nil
+}
+
+// loadBrandLogo reads the --brand-logo image, a PNG or JPEG.
+func loadBrandLogo(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a PNG or JPEG image: %w", err)
+	}
+	if cfg.Width*cfg.Height > brandLogoMaxPixels {
+		return nil, fmt.Errorf("%dx%d is too large, use an image of at most 4096x4096", cfg.Width, cfg.Height)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a PNG or JPEG image: %w", err)
+	}
+	return img, nil
+}
+
+// brandedQRPNG returns a PNG of the QR code of uri, with logo in the middle
+// of the code and title under it, either may be empty. The code keeps its
+// quiet zone, and uses the highest error correction when a logo covers
+// part of it.
+func brandedQRPNG(uri, title string, logo image.Image) ([]byte, error) {
+	level := qr.L
+	if logo != nil {
+		level = qr.H
+	}
+	code, err := qr.Encode(uri, level)
+	if err != nil {
+		return nil, err
+	}
+	const px = qrModulePixels
+	side := (code.Size + 2*qrQuietZone) * px
+	height := side
+	titleScale := 0
+	if title != "" {
+		cols := len(title)*brandGlyphAdvance - 1
+		titleScale = min(brandTitleMaxScale, (side-2*qrQuietZone*px)/cols)
+		if titleScale < 1 {
+			return nil, errors.New("the title is too wide for the code, shorten it")
+		}
+		// The title sits below the bottom quiet zone, with the same margin under it
+		height += brandGlyphHeight*titleScale + qrQuietZone*px
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, side, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	offset := qrQuietZone * px
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				r := image.Rect(offset+x*px, offset+y*px, offset+(x+1)*px, offset+(y+1)*px)
+				draw.Draw(img, r, image.Black, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if logo != nil {
+		// Whole modules, centered on the module grid
+		modules := code.Size / qrLogoDivisor
+		if (code.Size-modules)%2 != 0 {
+			modules--
+		}
+		start := offset + (code.Size-modules)/2*px
+		box := image.Rect(start, start, start+modules*px, start+modules*px)
+		draw.Draw(img, box, image.White, image.Point{}, draw.Src)
+		drawScaled(img, box.Inset(qrLogoPadding*px), logo)
+	}
+
+	if title != "" {
+		width := (len(title)*brandGlyphAdvance - 1) * titleScale
+		x0 := (side - width) / 2
+		y0 := side
+		for i, r := range strings.ToUpper(title) {
+			glyph := brandGlyphs[r]
+			for row := 0; row < brandGlyphHeight; row++ {
+				for col := 0; col < brandGlyphWidth; col++ {
+					if glyph[row]&(1<<(brandGlyphWidth-1-col)) == 0 {
+						continue
+					}
+					x := x0 + (i*brandGlyphAdvance+col)*titleScale
+					y := y0 + row*titleScale
+					draw.Draw(img, image.Rect(x, y, x+titleScale, y+titleScale), image.Black, image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawScaled draws src over dst, scaled to fit r with its aspect ratio kept
+// and centered in it. Transparent parts of src show what's under them.
+func drawScaled(dst draw.Image, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if sb.Empty() || r.Empty() {
+		return
+	}
+	w, h := r.Dx(), r.Dy()
+	if sb.Dx()*h > sb.Dy()*w {
+		h = max(1, sb.Dy()*w/sb.Dx())
+	} else {
+		w = max(1, sb.Dx()*h/sb.Dy())
+	}
+	x0 := r.Min.X + (r.Dx()-w)/2
+	y0 := r.Min.Y + (r.Dy()-h)/2
+	// Nearest neighbour is enough for a logo a few dozen pixels across
+	scaled := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			scaled.Set(x, y, color.NRGBAModel.Convert(src.At(sb.Min.X+x*sb.Dx()/w, sb.Min.Y+y*sb.Dy()/h)))
+		}
+	}
+	draw.Draw(dst, image.Rect(x0, y0, x0+w, y0+h), scaled, image.Point{}, draw.Over)
+}
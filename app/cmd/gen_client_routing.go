@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+)
+
+// unblockCommonDomains are what --unblock-common sends through the proxy:
+// the messaging and social apps that are the first to be blocked in a
+// shutdown. Video sites are left out on purpose, they'd use most of the
+// proxy's bandwidth.
+var unblockCommonDomains = []string{
+	// Telegram
+	"telegram.org", "telegram.me", "t.me", "telesco.pe", "cdn-telegram.org",
+	// WhatsApp
+	"whatsapp.com", "whatsapp.net", "wa.me",
+	// Facebook, Messenger and Instagram
+	"facebook.com", "fb.com", "fbcdn.net", "messenger.com", "instagram.com", "cdninstagram.com",
+	// X (Twitter)
+	"twitter.com", "x.com", "twimg.com", "t.co",
+	// Signal
+	"signal.org", "whispersystems.org", "signal.art",
+}
+
+// routeDomains returns the domains to send through the proxy: the ones
+// given with --route-through-proxy, and unblockCommonDomains if common is
+// set. They're lowercased, sorted and without duplicates, so that the
+// output doesn't depend on the order of the flags.
+func routeDomains(domains []string, common bool) ([]string, error) {
+	var result []string
+	for _, d := range domains {
+		d = strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(d, "*."), "."))
+		if err := validateRouteDomain(d); err != nil {
+			return nil, fmt.Errorf("invalid --route-through-proxy '%s': %w", d, err)
+		}
+		result = append(result, d)
+	}
+	if common {
+		result = append(result, unblockCommonDomains...)
+	}
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// validateRouteDomain checks that a domain can be matched by suffix,
+// which covers the domain and all its subdomains.
+func validateRouteDomain(s string) error {
+	if strings.Contains(s, "/") {
+		return errors.New("must be a domain name like example.com, not a URL")
+	}
+	if net.ParseIP(s) != nil {
+		return errors.New("must be a domain name, not an IP address")
+	}
+	if !strings.Contains(s, ".") {
+		return errors.New("not a valid domain name")
+	}
+	return validateInitHost(s)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apernet/hysteria/extras/v2/auth"
+)
+
+// parseSchedule turns a --schedule like "08:00-16:00" in loc into the
+// window of a scheduled token, which is in UTC. The offset of loc is the
+// one at now, see scheduleDSTWarning.
+func parseSchedule(s string, loc *time.Location, now time.Time) (auth.TokenWindow, error) {
+	fromStr, toStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return auth.TokenWindow{}, errors.New("must be like 08:00-16:00")
+	}
+	from, err := time.Parse("15:04", strings.TrimSpace(fromStr))
+	if err != nil {
+		return auth.TokenWindow{}, fmt.Errorf("invalid start time '%s', use HH:MM", fromStr)
+	}
+	to, err := time.Parse("15:04", strings.TrimSpace(toStr))
+	if err != nil {
+		return auth.TokenWindow{}, fmt.Errorf("invalid end time '%s', use HH:MM", toStr)
+	}
+	if from.Equal(to) {
+		return auth.TokenWindow{}, errors.New("start and end are the same")
+	}
+	_, offset := now.In(loc).Zone()
+	toUTC := func(t time.Time) int {
+		m := t.Hour()*60 + t.Minute() - offset/60
+		return ((m % (24 * 60)) + 24*60) % (24 * 60)
+	}
+	return auth.TokenWindow{From: toUTC(from), To: toUTC(to)}, nil
+}
+
+// scheduleDSTWarning warns when loc changes its UTC offset within the
+// next year, as the token's window is fixed in UTC.
+func scheduleDSTWarning(loc *time.Location, now time.Time) string {
+	_, offset := now.In(loc).Zone()
+	for d := 1; d <= 12; d++ {
+		if _, o := now.AddDate(0, d, 0).In(loc).Zone(); o != offset {
+			return fmt.Sprintf("%s changes its UTC offset for daylight saving, the token's window is fixed in UTC "+
+				"and will be an hour off then. Generate a new token for that season.", loc)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,1211 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	"github.com/apernet/hysteria/extras/v2/auth"
+)
+
+// TestGenClientNativeRoundTrip makes sure the generated native config
+// parses back into the client config without losing any fields
+func TestGenClientNativeRoundTrip(t *testing.T) {
+	native := newHysteria2ClientConfig("example.com:443", "weak_ahh_password", "another.example.com", true,
+		bandwidthPresets["fiber"], "cry_me_a_r1ver", 15*time.Second)
+	expected := clientConfig{
+		Server: "example.com:443",
+		Auth:   "weak_ahh_password",
+		Obfs: clientConfigObfs{
+			Type: "salamander",
+			Salamander: clientConfigObfsSalamander{
+				Password: "cry_me_a_r1ver",
+			},
+		},
+		TLS: clientConfigTLS{
+			SNI:      "another.example.com",
+			Insecure: true,
+		},
+		QUIC: clientConfigQUIC{
+			KeepAlivePeriod: 15 * time.Second,
+		},
+		Bandwidth: clientConfigBandwidth{
+			Up:   bandwidthPresets["fiber"].Up,
+			Down: bandwidthPresets["fiber"].Down,
+		},
+		SOCKS5: &socks5Config{
+			Listen: "127.0.0.1:1080",
+		},
+		HTTP: &httpConfig{
+			Listen: "127.0.0.1:8080",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		minify bool
+	}{
+		{"json", "json", false},
+		{"json minified", "json", true},
+		{"yaml", "yaml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bs, err := marshalHysteria2ClientConfig(native, tt.format, tt.minify, defaultOutputIndent)
+			assert.NoError(t, err)
+			if tt.minify {
+				assert.NotContains(t, string(bs), "\n")
+			}
+			v := viper.New()
+			v.SetConfigType(tt.format)
+			assert.NoError(t, v.ReadConfig(bytes.NewReader(bs)))
+			var config clientConfig
+			assert.NoError(t, v.Unmarshal(&config))
+			assert.Equal(t, expected, config)
+		})
+	}
+}
+
+// TestGenClientOpenWrt makes sure the whole --client-type openwrt output,
+// comments and all, parses as a client config with the router inbounds
+func TestGenClientOpenWrt(t *testing.T) {
+	preset := bandwidthPresets["fiber"]
+	native := newOpenWrtClientConfig(newHysteria2ClientConfig("example.com:443", "weak_ahh_password", "", false,
+		preset, "", 0))
+	bs, err := marshalHysteria2ClientConfig(native, "yaml", false, defaultOutputIndent)
+	assert.NoError(t, err)
+	output := formatOpenWrtOutput("fiber", preset, bs)
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(bytes.NewReader([]byte(output))))
+	var config clientConfig
+	assert.NoError(t, v.Unmarshal(&config))
+	assert.Equal(t, "example.com:443", config.Server)
+	assert.Nil(t, config.HTTP)
+	assert.Nil(t, config.TUN)
+	assert.Equal(t, &tcpRedirectConfig{Listen: ":12345"}, config.TCPRedirect)
+	assert.Equal(t, []udpForwardingEntry{{Listen: "127.0.0.1:5353", Remote: "1.1.1.1:53"}}, config.UDPForwarding)
+}
+
+// TestGenClientExampleTemplates makes sure the example templates in
+// docs/templates render configs equivalent to the built-in formats
+func TestGenClientRecvWindows(t *testing.T) {
+	native := newHysteria2ClientConfig("example.com:443", "weak_ahh_password", "", false,
+		bandwidthPresets["fiber"], "", 0)
+	setReceiveWindows(&native, 16<<20, 40<<20)
+	bs, err := marshalHysteria2ClientConfig(native, "yaml", false, defaultOutputIndent)
+	assert.NoError(t, err)
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(bytes.NewReader(bs)))
+	var config clientConfig
+	assert.NoError(t, v.Unmarshal(&config))
+	assert.Equal(t, uint64(16<<20), config.QUIC.InitStreamReceiveWindow)
+	assert.Equal(t, uint64(16<<20), config.QUIC.MaxStreamReceiveWindow)
+	assert.Equal(t, uint64(40<<20), config.QUIC.InitConnectionReceiveWindow)
+	assert.Equal(t, uint64(40<<20), config.QUIC.MaxConnectionReceiveWindow)
+
+	tests := []struct {
+		stream, conn uint64
+		wantWarnings int
+		wantErr      bool
+	}{
+		{0, 0, 0, false},
+		{16 << 20, 40 << 20, 0, false},
+		{1024, 0, 0, true},
+		{0, 1024, 0, true},
+		{512 << 10, 0, 1, false},
+		{128 << 20, 256 << 20, 2, false},
+		{16 << 20, 8 << 20, 1, false},
+		{32 << 20, 0, 1, false}, // Larger than the default connection window
+	}
+	for _, tt := range tests {
+		warnings, err := validateRecvWindows(tt.stream, tt.conn)
+		if tt.wantErr {
+			assert.Error(t, err, "%d/%d", tt.stream, tt.conn)
+			continue
+		}
+		assert.NoError(t, err, "%d/%d", tt.stream, tt.conn)
+		assert.Len(t, warnings, tt.wantWarnings, "%d/%d", tt.stream, tt.conn)
+	}
+}
+
+func TestGenClientDecoySNI(t *testing.T) {
+	assert.NoError(t, validateDecoySNI("www.google.com"))
+	assert.Error(t, validateDecoySNI("1.2.3.4"))
+	assert.Error(t, validateDecoySNI("localhost"))
+	assert.Error(t, validateDecoySNI("www.goo gle.com"))
+	for _, s := range decoySNISuggestions {
+		assert.NoError(t, validateDecoySNI(s.Domain))
+	}
+
+	warnings, err := decoySNIWarnings("www.google.com", "")
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 3)
+
+	dir := t.TempDir()
+	tests := []struct {
+		config      string
+		wantWarning string
+	}{
+		{"tls:\n  cert: c.crt\n  key: c.key\n  sniGuard: disable\n", ""},
+		{"tls:\n  cert: c.crt\n  key: c.key\n", "dns-san"},
+		{"tls:\n  cert: c.crt\n  key: c.key\n  sniGuard: strict\n", "strict"},
+		{"acme:\n  domains: [example.com]\n", "acme"},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("server%d.yaml", i))
+		assert.NoError(t, os.WriteFile(path, []byte(tt.config), 0o600))
+		warnings, err := decoySNIWarnings("www.google.com", path)
+		assert.NoError(t, err)
+		if tt.wantWarning == "" {
+			assert.Len(t, warnings, 2, tt.config)
+		} else if assert.Len(t, warnings, 3, tt.config) {
+			assert.Contains(t, warnings[2], tt.wantWarning)
+		}
+	}
+}
+
+func TestGenClientAllPlatforms(t *testing.T) {
+	preset := bandwidthPresets["4g"]
+	upMbps, downMbps := parseBandwidthToMbps(preset)
+	data := genClientTemplateData{
+		Server:         "example.com",
+		Port:           443,
+		ServerAddr:     "example.com:443",
+		Auth:           "weak_ahh_password",
+		SNI:            "example.com",
+		Insecure:       true,
+		Obfs:           "obfs_password",
+		Preset:         "4g",
+		Up:             preset.Up,
+		Down:           preset.Down,
+		UpMbps:         upMbps,
+		DownMbps:       downMbps,
+		StandbyServers: []string{"standby.example.com"},
+	}
+	singBoxJSON, err := json.Marshal(newSingBoxConfig(data, true))
+	assert.NoError(t, err)
+	nativeYAML, err := marshalHysteria2ClientConfig(newHysteria2ClientConfig(data.ServerAddr, data.Auth, data.SNI, true,
+		preset, data.Obfs, 0), "yaml", false, defaultOutputIndent)
+	assert.NoError(t, err)
+
+	dir := filepath.Join(t.TempDir(), "alice")
+	paths, err := writeAllPlatformsBundle(dir, data, true, singBoxJSON, nativeYAML, defaultOutputIndent)
+	assert.NoError(t, err)
+	assert.Len(t, paths, 6)
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if assert.NoError(t, err) {
+			assert.NotZero(t, info.Size(), p)
+		}
+	}
+
+	var clash clashMetaConfig
+	bs, err := os.ReadFile(filepath.Join(dir, bundleClashFile))
+	assert.NoError(t, err)
+	assert.NoError(t, yaml.Unmarshal(bs, &clash))
+	if assert.Len(t, clash.Proxies, 2) {
+		assert.Equal(t, "hysteria2", clash.Proxies[0].Type)
+		assert.Equal(t, "obfs_password", clash.Proxies[0].ObfsPassword)
+		assert.Equal(t, "standby.example.com", clash.Proxies[1].SNI)
+	}
+	assert.Equal(t, "fallback", clash.ProxyGroups[0].Type)
+
+	bs, err = os.ReadFile(filepath.Join(dir, bundleURIFile))
+	assert.NoError(t, err)
+	c := &clientConfig{Server: strings.TrimSpace(string(bs))}
+	if assert.True(t, c.parseURI()) {
+		assert.Equal(t, "example.com:443", c.Server)
+		assert.Equal(t, "weak_ahh_password", c.Auth)
+		assert.Equal(t, "obfs_password", c.Obfs.Salamander.Password)
+	}
+}
+
+func TestGenClientExampleTemplates(t *testing.T) {
+	data := genClientTemplateData{
+		Server:     "example.com",
+		Port:       443,
+		ServerAddr: "example.com:443",
+		Auth:       `weak: "ahh" password`,
+		SNI:        "another.example.com",
+		Insecure:   true,
+		Obfs:       "cry_me_a_r1ver",
+		Up:         bandwidthPresets["4g"].Up,
+		Down:       bandwidthPresets["4g"].Down,
+		UpMbps:     1,
+		DownMbps:   10,
+		KeepAlive:  "15s",
+		ALPN:       []string{"h3"},
+	}
+
+	tmpl, err := parseClientTemplate("../../docs/templates/native.yaml.tmpl")
+	assert.NoError(t, err)
+	bs, err := renderClientTemplate(tmpl, data)
+	assert.NoError(t, err)
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(bytes.NewReader(bs)))
+	var config clientConfig
+	assert.NoError(t, v.Unmarshal(&config))
+
+	native, err := marshalHysteria2ClientConfig(newHysteria2ClientConfig(data.ServerAddr, data.Auth, data.SNI, data.Insecure,
+		bandwidthPresets["4g"], data.Obfs, 15*time.Second), "yaml", false, defaultOutputIndent)
+	assert.NoError(t, err)
+	v = viper.New()
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(bytes.NewReader(native)))
+	var expected clientConfig
+	assert.NoError(t, v.Unmarshal(&expected))
+	assert.Equal(t, expected, config)
+
+	tmpl, err = parseClientTemplate("../../docs/templates/singbox.json.tmpl")
+	assert.NoError(t, err)
+	bs, err = renderClientTemplate(tmpl, data)
+	assert.NoError(t, err)
+	var singBox struct {
+		Outbounds []singBoxOutbound `json:"outbounds"`
+	}
+	assert.NoError(t, json.Unmarshal(bs, &singBox))
+	assert.Equal(t, singBoxOutbound{
+		Type:       "hysteria2",
+		Tag:        "libyalink-proxy",
+		Server:     data.Server,
+		ServerPort: data.Port,
+		Password:   data.Auth,
+		TLS: singBoxTLS{
+			Enabled:    true,
+			Insecure:   true,
+			ServerName: data.SNI,
+			ALPN:       data.ALPN,
+		},
+		Obfs:     &singBoxObfs{Type: "salamander", Password: data.Obfs},
+		UpMbps:   1,
+		DownMbps: 10,
+	}, singBox.Outbounds[0])
+}
+
+func TestLoadGenClientBase(t *testing.T) {
+	native := newHysteria2ClientConfig("example.com:8443", "weak_ahh_password", "another.example.com", false,
+		bandwidthPresets["fiber"], "cry_me_a_r1ver", 20*time.Second)
+	expected := &genClientBase{
+		Server:    "example.com",
+		Port:      8443,
+		Auth:      "weak_ahh_password",
+		SNI:       "another.example.com",
+		Obfs:      "cry_me_a_r1ver",
+		Preset:    "fiber",
+		KeepAlive: 20 * time.Second,
+	}
+	dir := t.TempDir()
+	for _, format := range []string{"json", "yaml"} {
+		bs, err := marshalHysteria2ClientConfig(native, format, false, defaultOutputIndent)
+		assert.NoError(t, err)
+		path := filepath.Join(dir, "config."+format)
+		assert.NoError(t, os.WriteFile(path, bs, 0o644))
+		base, err := loadGenClientBase(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, base)
+	}
+
+	// Full output, sing-box config first with a standby server
+	full := `// LibyaLink Client Configuration
+{
+  "outbounds": [
+    {"type": "hysteria2", "server": "example.com", "server_port": 8443, "password": "weak_ahh_password",
+     "tls": {"enabled": true, "server_name": "another.example.com", "alpn": ["h3"]},
+     "obfs": {"type": "salamander", "password": "cry_me_a_r1ver"}, "up_mbps": 20, "down_mbps": 100},
+    {"type": "direct", "tag": "direct"},
+    {"type": "hysteria2", "server": "standby.example.com", "server_port": 8443},
+    {"type": "urltest", "outbounds": ["a", "b"]}
+  ]
+}
+
+// Native
+server: example.com:8443
+`
+	path := filepath.Join(dir, "client.txt")
+	assert.NoError(t, os.WriteFile(path, utils.AppendChecksumFooter([]byte(full), "//"), 0o644))
+	base, err := loadGenClientBase(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &genClientBase{
+		Server:         "example.com",
+		Port:           8443,
+		Auth:           "weak_ahh_password",
+		SNI:            "another.example.com",
+		Obfs:           "cry_me_a_r1ver",
+		Preset:         "fiber",
+		ALPN:           []string{"h3"},
+		StandbyServers: []string{"standby.example.com"},
+	}, base)
+}
+
+func TestGenClientImportURI(t *testing.T) {
+	data := genClientTemplateData{
+		Server:     "example.com",
+		Port:       8443,
+		ServerAddr: "example.com:8443",
+		Auth:       "alice:p@ss word",
+		SNI:        "example.com",
+		Insecure:   true,
+		Obfs:       "cry_me_a_r1ver",
+	}
+	uri := genClientShareURI(data)
+	base, warnings, err := genClientBaseFromURI(uri)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, &genClientBase{
+		Server:   "example.com",
+		Port:     8443,
+		Auth:     "alice:p@ss word",
+		SNI:      "example.com",
+		Insecure: true,
+		Obfs:     "cry_me_a_r1ver",
+	}, base)
+
+	// Building the URI again from what was imported gives the same URI
+	again := genClientTemplateData{
+		Server:     base.Server,
+		Port:       base.Port,
+		ServerAddr: fmt.Sprintf("%s:%d", base.Server, base.Port),
+		Auth:       base.Auth,
+		SNI:        base.SNI,
+		Insecure:   base.Insecure,
+		Obfs:       base.Obfs,
+	}
+	assert.Equal(t, uri, genClientShareURI(again))
+
+	// Short scheme and default port
+	base, _, err = genClientBaseFromURI("hy2://weak_ahh_password@example.com?insecure=1")
+	assert.NoError(t, err)
+	assert.Equal(t, 443, base.Port)
+	assert.True(t, base.Insecure)
+
+	_, warnings, err = genClientBaseFromURI("hysteria2://pw@example.com:443/?pinSHA256=aa:bb")
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 1)
+
+	for _, bad := range []string{"example.com:443", "vless://pw@example.com:443", "hysteria2://pw@example.com:1000-2000/"} {
+		_, _, err := genClientBaseFromURI(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestComparePresetBandwidth(t *testing.T) {
+	tests := []struct {
+		name   string
+		preset string
+		server serverConfigBandwidth
+		want   int
+	}{
+		{"unlimited server", "fiber", serverConfigBandwidth{}, 0},
+		{"fits", "4g", serverConfigBandwidth{Up: "100 mbps", Down: "20 mbps"}, 0},
+		{"download too fast", "fiber", serverConfigBandwidth{Up: "10 mbps", Down: "100 mbps"}, 1},
+		{"both too fast", "fiber", serverConfigBandwidth{Up: "10 mbps", Down: "1 mbps"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := comparePresetBandwidth(bandwidthPresets[tt.preset], tt.server)
+			assert.NoError(t, err)
+			assert.Len(t, warnings, tt.want)
+		})
+	}
+}
+
+func TestFallbackStandbyHost(t *testing.T) {
+	tests := []struct {
+		fallback string
+		host     string
+		ok       bool
+	}{
+		{"backup.example.com", "backup.example.com", true},
+		{"backup.example.com:443", "backup.example.com", true},
+		{"[2001:db8::1]:443", "2001:db8::1", true},
+		{"5.6.7.8:8443", "5.6.7.8", false},
+	}
+	for _, tt := range tests {
+		host, ok := fallbackStandbyHost(tt.fallback, 443)
+		assert.Equal(t, tt.host, host, tt.fallback)
+		assert.Equal(t, tt.ok, ok, tt.fallback)
+	}
+}
+
+// TestGenClientOutputDeterministic makes sure that regenerating a config
+// with the same inputs gives a byte-identical file, so that configs in
+// version control only show real changes
+func TestGenClientOutputDeterministic(t *testing.T) {
+	generate := func(tunnelProcs []string) string {
+		preset := bandwidthPresets["4g"]
+		upMbps, downMbps := parseBandwidthToMbps(preset)
+		data := genClientTemplateData{
+			Server:          "example.com",
+			Port:            443,
+			Auth:            "weak_ahh_password",
+			SNI:             "example.com",
+			Insecure:        true,
+			Obfs:            "cry_me_a_r1ver",
+			UpMbps:          upMbps,
+			DownMbps:        downMbps,
+			ALPN:            []string{"h3"},
+			StandbyServers:  []string{"standby1.example.com", "standby2.example.com"},
+			TunnelProcesses: tunnelProcs,
+		}
+		singBoxJSON, err := json.MarshalIndent(newSingBoxConfig(data, true), "", "  ")
+		assert.NoError(t, err)
+		native, err := marshalHysteria2ClientConfig(newHysteria2ClientConfig("example.com:443", data.Auth, data.SNI, data.Insecure,
+			preset, data.Obfs, 15*time.Second), "yaml", false, defaultOutputIndent)
+		assert.NoError(t, err)
+		return formatGenClientOutput("4g", preset, singBoxJSON, native, "", "", true)
+	}
+
+	first := generate([]string{"telegram.exe", "chrome.exe"})
+	assert.Equal(t, first, generate([]string{"telegram.exe", "chrome.exe"}))
+	// Process order doesn't matter for routing, so it must not change the output
+	assert.Equal(t, first, generate([]string{"chrome.exe", "telegram.exe", "chrome.exe"}))
+}
+
+func TestGenClientTunnelProcessRules(t *testing.T) {
+	data := genClientTemplateData{
+		Server:   "example.com",
+		Port:     443,
+		Auth:     "weak_ahh_password",
+		UpMbps:   10,
+		DownMbps: 50,
+	}
+
+	// Without --tunnel-process everything goes through the proxy
+	cfg := newSingBoxConfig(data, true)
+	assert.Empty(t, cfg.Route.Rules)
+	assert.Equal(t, "libyalink-proxy", cfg.Route.FinalTag)
+
+	// Only the listed processes use the proxy, sorted and once each
+	data.TunnelProcesses = []string{"telegram.exe", "chrome.exe", "telegram.exe"}
+	cfg = newSingBoxConfig(data, true)
+	assert.Equal(t, []singBoxRouteRule{{ProcessName: []string{"chrome.exe", "telegram.exe"}, Outbound: "libyalink-proxy"}}, cfg.Route.Rules)
+	assert.Equal(t, "direct", cfg.Route.FinalTag)
+	// The caller's slice is left alone
+	assert.Equal(t, []string{"telegram.exe", "chrome.exe", "telegram.exe"}, data.TunnelProcesses)
+
+	// With standby servers the rule points at the failover group
+	data.StandbyServers = []string{"standby.example.com"}
+	cfg = newSingBoxConfig(data, true)
+	assert.Equal(t, "libyalink-auto", cfg.Route.Rules[0].Outbound)
+	assert.Equal(t, "direct", cfg.Route.FinalTag)
+
+	// With domains too, the domain is sniffed first and either sends
+	// traffic through the proxy
+	data.StandbyServers = nil
+	data.ProxyDomains = []string{"t.me"}
+	cfg = newSingBoxConfig(data, true)
+	assert.Equal(t, []singBoxRouteRule{
+		{Action: "sniff"},
+		{ProcessName: []string{"chrome.exe", "telegram.exe"}, Outbound: "libyalink-proxy"},
+		{DomainSuffix: []string{"t.me"}, Outbound: "libyalink-proxy"},
+	}, cfg.Route.Rules)
+
+	out, err := json.Marshal(cfg.Route.Rules[1])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"process_name": ["chrome.exe", "telegram.exe"], "outbound": "libyalink-proxy"}`, string(out))
+
+	for _, name := range []string{"telegram.exe", "Telegram", "com.example app"} {
+		assert.NoError(t, validateProcessName(name), name)
+	}
+	for _, name := range []string{"", `C:\Program Files\Telegram\telegram.exe`, "/usr/bin/firefox", "bad\x00name"} {
+		assert.Error(t, validateProcessName(name), name)
+	}
+}
+
+func TestWriteWindowsLaunchers(t *testing.T) {
+	dir := t.TempDir()
+	paths, err := writeWindowsLaunchers(dir, "my client.yaml", []byte("server: example.com:443"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "my client.yaml"),
+		filepath.Join(dir, "libyalink-client.bat"),
+		filepath.Join(dir, "libyalink-client.ps1"),
+	}, paths)
+
+	config, _ := os.ReadFile(paths[0])
+	assert.Equal(t, "server: example.com:443\n", string(config))
+
+	// Both run the config next to them, whatever the working directory
+	bat, _ := os.ReadFile(paths[1])
+	assert.Contains(t, string(bat), "cd /d \"%~dp0\"\r\n")
+	assert.Contains(t, string(bat), "libyalink.exe client -c \"my client.yaml\"\r\n")
+	assert.Contains(t, string(bat), "if errorlevel 1 pause")
+	ps1, _ := os.ReadFile(paths[2])
+	assert.Contains(t, string(ps1), "Set-Location -Path $PSScriptRoot\r\n")
+	assert.Contains(t, string(ps1), "& .\\libyalink.exe client -c \"my client.yaml\"\r\n")
+	assert.Contains(t, string(ps1), "Read-Host")
+	for _, script := range [][]byte{bat, ps1} {
+		assert.NotContains(t, strings.ReplaceAll(string(script), "\r\n", ""), "\n", "Windows scripts need CRLF line endings")
+	}
+
+	_, err = writeWindowsLaunchers(filepath.Join(dir, "missing"), "client.yaml", nil)
+	assert.Error(t, err)
+}
+
+func TestIsTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	assert.False(t, isTerminal(w))
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "client.txt"))
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.False(t, isTerminal(f))
+}
+
+func TestParseOutputIndent(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    outputIndent
+		wantErr bool
+	}{
+		{"", defaultOutputIndent, false},
+		{"4", outputIndent{JSON: "    ", YAML: 4}, false},
+		{"tab", outputIndent{JSON: "\t"}, false},
+		{"TAB", outputIndent{JSON: "\t"}, false},
+		{"1", outputIndent{}, true},
+		{"9", outputIndent{}, true},
+		{"two", outputIndent{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseOutputIndent(tt.in)
+		if tt.wantErr {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		assert.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	indent, _ := parseOutputIndent("tab")
+	bs, err := indent.marshalJSON(map[string]any{"a": map[string]int{"b": 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n\t\"a\": {\n\t\t\"b\": 1\n\t}\n}", string(bs))
+	bs, err = indent.marshalYAML(map[string]any{"a": map[string]int{"b": 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, "a:\n    b: 1\n", string(bs))
+	indent, _ = parseOutputIndent("2")
+	bs, err = indent.marshalYAML(map[string]any{"a": map[string]int{"b": 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, "a:\n  b: 1\n", string(bs))
+}
+
+func TestGenClientRouteDomains(t *testing.T) {
+	domains, err := routeDomains([]string{"Example.com", "*.example.org", "example.com"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com", "example.org"}, domains)
+
+	for _, bad := range []string{"https://example.com/", "1.2.3.4", "localhost", "exa mple.com"} {
+		_, err := routeDomains([]string{bad}, false)
+		assert.Error(t, err, bad)
+	}
+
+	domains, err = routeDomains([]string{"t.me"}, true)
+	assert.NoError(t, err)
+	assert.Len(t, domains, len(unblockCommonDomains))
+	assert.True(t, slices.IsSorted(domains))
+
+	data := genClientTemplateData{Server: "example.com", Port: 443, ProxyDomains: []string{"example.org"}}
+	route := newSingBoxConfig(data, false).Route
+	assert.Equal(t, "direct", route.FinalTag)
+	assert.Equal(t, []singBoxRouteRule{
+		{Action: "sniff"},
+		{DomainSuffix: []string{"example.org"}, Outbound: "libyalink-proxy"},
+	}, route.Rules)
+	assert.Equal(t, []string{"DOMAIN-SUFFIX,example.org,LibyaLink", "MATCH,DIRECT"}, newClashMetaConfig(data, false).Rules)
+}
+
+func TestGenClientFallbackDirect(t *testing.T) {
+	data := genClientTemplateData{Server: "example.com", Port: 443, FallbackDirect: true}
+	cfg := newSingBoxConfig(data, false)
+	assert.Equal(t, "libyalink-auto", cfg.Route.FinalTag)
+	assert.Equal(t, singBoxURLTest{
+		Type:      "urltest",
+		Tag:       "libyalink-auto",
+		Outbounds: []string{"libyalink-proxy", "direct"},
+		URL:       "https://www.gstatic.com/generate_204",
+		Interval:  "1m",
+		Tolerance: fallbackDirectTolerance,
+	}, cfg.Outbounds[len(cfg.Outbounds)-1])
+	clash := newClashMetaConfig(data, false).ProxyGroups[0]
+	assert.Equal(t, "fallback", clash.Type)
+	assert.Equal(t, []string{"libyalink", "DIRECT"}, clash.Proxies)
+
+	// Direct goes last, after the standby servers
+	data.StandbyServers = []string{"standby.example.com"}
+	group := newSingBoxConfig(data, false).Outbounds[3].(singBoxURLTest)
+	assert.Equal(t, []string{"libyalink-proxy", "libyalink-standby-1", "direct"}, group.Outbounds)
+
+	// Off, no group without standby servers
+	cfg = newSingBoxConfig(genClientTemplateData{Server: "example.com", Port: 443}, false)
+	assert.Equal(t, "libyalink-proxy", cfg.Route.FinalTag)
+	assert.Len(t, cfg.Outbounds, 2)
+}
+
+func TestBrutalPreset(t *testing.T) {
+	fallback := bandwidthPresets["4g"]
+	// 90% of 50 Mbps down and 8 Mbps up, in whole Mbps
+	assert.Equal(t, bandwidthPreset{Up: "7 mbps", Down: "45 mbps"},
+		brutalPreset(speedtestResult{Download: 50e6 / 8, Upload: 8e6 / 8}, fallback))
+	// Never below 1 Mbps
+	assert.Equal(t, bandwidthPreset{Up: "1 mbps", Down: "1 mbps"},
+		brutalPreset(speedtestResult{Download: 1000, Upload: 1000}, fallback))
+	// A direction that wasn't tested keeps the preset
+	assert.Equal(t, bandwidthPreset{Up: fallback.Up, Down: "90 mbps"},
+		brutalPreset(speedtestResult{Download: 100e6 / 8}, fallback))
+
+	path := filepath.Join(t.TempDir(), "speedtest.json")
+	saved := speedtestResult{Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Server: "example.com:443", Download: 1.25e6}
+	assert.NoError(t, saveSpeedtestResult(path, saved))
+	loaded, err := loadSpeedtestResult(path)
+	assert.NoError(t, err)
+	assert.Equal(t, saved, loaded)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"server":"example.com:443"}`), 0o644))
+	_, err = loadSpeedtestResult(path)
+	assert.Error(t, err)
+}
+
+func TestLoadPresetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+ltt-fiber-200:
+  up: 20 mbps
+  down: 200 mbps
+almadar-capped:
+  up: 2m
+  down: 1 gbps
+`), 0o644))
+	presets, err := loadPresetFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bandwidthPreset{
+		"ltt-fiber-200":  {Up: "20 mbps", Down: "200 mbps"},
+		"almadar-capped": {Up: "2 mbps", Down: "1000 mbps"},
+	}, presets)
+
+	for _, content := range []string{
+		"LTT: {up: 1 mbps, down: 10 mbps}",   // Uppercase name
+		"ltt: {up: 1 mbps}",                  // Missing down
+		"ltt: {up: 1 mbps, down: fast}",      // Not a bandwidth
+		"ltt: {up: 100 kbps, down: 10 mbps}", // Below 1 Mbps
+		"ltt: {up: 1 mbps, dwon: 10 mbps}",   // Typo
+		"",
+	} {
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err := loadPresetFile(path)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestPortWarnings(t *testing.T) {
+	assert.Empty(t, portWarnings(443, ""))
+	assert.Empty(t, portWarnings(443, "ltt"))
+	assert.Len(t, portWarnings(51820, ""), 1)
+	// ISP ranges only for that ISP
+	assert.Empty(t, portWarnings(15000, ""))
+	assert.Empty(t, portWarnings(15000, "libyana"))
+	if w := portWarnings(15000, "almadar"); assert.Len(t, w, 1) {
+		assert.Contains(t, w[0], "Port 15000 is the VoIP media range")
+	}
+	// Generic and ISP advisories together
+	assert.Len(t, portWarnings(53, "ltt"), 2)
+
+	assert.NoError(t, validateISP(""))
+	assert.NoError(t, validateISP("libyana"))
+	assert.Error(t, validateISP("Libyana"))
+}
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	tripoli := time.FixedZone("Tripoli", 2*60*60)
+	w, err := parseSchedule("08:00-16:00", tripoli, now)
+	assert.NoError(t, err)
+	assert.Equal(t, auth.TokenWindow{From: 6 * 60, To: 14 * 60}, w)
+
+	// Wraps around midnight in UTC
+	w, err = parseSchedule("01:30-09:00", tripoli, now)
+	assert.NoError(t, err)
+	assert.Equal(t, auth.TokenWindow{From: 23*60 + 30, To: 7 * 60}, w)
+
+	for _, s := range []string{"08:00", "8-16", "08:00-25:00", "09:00-09:00"} {
+		_, err := parseSchedule(s, tripoli, now)
+		assert.Error(t, err, s)
+	}
+
+	assert.Empty(t, scheduleDSTWarning(tripoli, now))
+	if berlin, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		assert.NotEmpty(t, scheduleDSTWarning(berlin, now))
+	}
+}
+
+func TestGenClientProfile(t *testing.T) {
+	assert.NoError(t, validateProfile(""))
+	assert.NoError(t, validateProfile("gaming"))
+	assert.Error(t, validateProfile("Gaming"))
+
+	gaming := genClientProfiles["gaming"]
+	assert.Equal(t, bandwidthPreset{Up: "1 mbps", Down: "6 mbps"}, profilePreset(bandwidthPresets["4g"], gaming.BandwidthRatio))
+	assert.Equal(t, bandwidthPreset{Up: "12 mbps", Down: "60 mbps"}, profilePreset(bandwidthPresets["fiber"], gaming.BandwidthRatio))
+
+	defer func(keepAlive time.Duration, stream, conn uint64) {
+		genClientKeepAlive, genClientRecvWindow, genClientRecvConn = keepAlive, stream, conn
+	}(genClientKeepAlive, genClientRecvWindow, genClientRecvConn)
+	genClientKeepAlive = 20 * time.Second
+	applied := applyGenClientProfile(func(name string) bool { return name == "keepalive" }, gaming,
+		&genClientKeepAlive, &genClientRecvWindow, &genClientRecvConn)
+	assert.Equal(t, []string{"recv-window", "recv-window-conn"}, applied)
+	assert.Equal(t, 20*time.Second, genClientKeepAlive)
+	assert.Equal(t, uint64(1<<20), genClientRecvWindow)
+
+	native := newHysteria2ClientConfig("1.2.3.4:443", "pass", "", true, bandwidthPresets["4g"], "", 0)
+	setMaxIdleTimeout(&native, gaming.MaxIdleTimeout)
+	assert.Equal(t, "10s", native.QUIC.MaxIdleTimeout)
+}
+
+func TestGenerateClientConfigs(t *testing.T) {
+	p := genClientParams{
+		Server:       "2001:db8::1",
+		Port:         443,
+		Auth:         "pass",
+		Insecure:     true,
+		PresetName:   "4g",
+		Preset:       bandwidthPresets["4g"],
+		Profile:      "gaming",
+		KeepAlive:    5 * time.Second,
+		ClientType:   "openwrt",
+		NativeFormat: "yaml",
+		Indent:       defaultOutputIndent,
+	}
+	c, err := generateClientConfigs(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:443", c.Data.ServerAddr)
+	assert.Equal(t, "2001:db8::1", c.Data.SNI)
+	assert.Equal(t, bandwidthPreset{Up: "1 mbps", Down: "6 mbps"}, c.Preset)
+	assert.Equal(t, "5s", c.Data.KeepAlive)
+	assert.Contains(t, string(c.SingBox), `"down_mbps": 6`)
+	assert.Contains(t, string(c.Native), "maxIdleTimeout: 10s")
+	assert.Equal(t, newOpenWrtClientConfig(c.NativeConfig), c.NativeConfig)
+
+	// Behind a front the client connects to the relay, the SNI names the server
+	p.FrontHost, p.FrontPort = "relay.example.com", 8443
+	p.Insecure = false
+	c, err = generateClientConfigs(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "relay.example.com:8443", c.Data.ServerAddr)
+	assert.Equal(t, "2001:db8::1", c.Data.SNI)
+	assert.False(t, p.sniFollowsServer())
+
+	p.DecoySNI = "www.google.com"
+	assert.Equal(t, "www.google.com", p.sni())
+}
+
+func TestGenClientValidate(t *testing.T) {
+	for _, host := range []string{"1.2.3.4", "2001:db8::1", "example.com", "vpn-1.example.com.", "localhost"} {
+		assert.NoError(t, validateServerHost(host), host)
+	}
+	for _, host := range []string{"", "1.2.3.4:443", "https://example.com", "exa mple.com", "-bad.example.com", "a..b", strings.Repeat("a", 64) + ".com"} {
+		assert.Error(t, validateServerHost(host), host)
+	}
+
+	assert.NoError(t, validatePresetBandwidth(bandwidthPresets["4g"]))
+	assert.NoError(t, validatePresetBandwidth(bandwidthPreset{Up: "500 kbps", Down: "1g"}))
+	assert.Error(t, validatePresetBandwidth(bandwidthPreset{Up: "fast", Down: "10 mbps"}))
+	assert.Error(t, validatePresetBandwidth(bandwidthPreset{Up: "1 mbps"}))
+
+	assert.NoError(t, validateObfsPassword("obfs_password"))
+	assert.Error(t, validateObfsPassword("abc"))
+
+	for addr, want := range map[string]string{
+		"relay.example.net":      "relay.example.net:443",
+		"relay.example.net:8443": "relay.example.net:8443",
+		"5.6.7.8:20000":          "5.6.7.8:20000",
+		"[2001:db8::2]:8443":     "[2001:db8::2]:8443",
+		"2001:db8::2":            "[2001:db8::2]:443",
+	} {
+		host, port, err := parseFrontAddress(addr, 443)
+		if assert.NoError(t, err, addr) {
+			assert.Equal(t, want, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+	}
+	for _, addr := range []string{"", "https://cdn.example.net", "relay.example.net:0", "relay.example.net:http", "re lay.example.net"} {
+		_, _, err := parseFrontAddress(addr, 443)
+		assert.Error(t, err, addr)
+	}
+}
+
+// parseGenClientFlags sets the gen-client flags from args, and resets
+// them to their defaults when the test ends.
+func parseGenClientFlags(t *testing.T, args ...string) {
+	t.Helper()
+	reset := func() {
+		genClientCmd.ResetFlags()
+		initGenClientFlags()
+	}
+	reset()
+	t.Cleanup(reset)
+	assert.NoError(t, genClientCmd.Flags().Parse(args))
+}
+
+func TestValidateGenClientFlags(t *testing.T) {
+	base := []string{"--server", "vpn.example.com", "--auth", "pass"}
+	tests := []struct {
+		args    []string
+		wantErr string
+	}{
+		{nil, ""},
+		{[]string{"--preset", "fiber", "--port", "8443", "--standby-server", "1.2.3.4"}, ""},
+		{[]string{"--client-type", "openwrt", "--resolve"}, ""},
+		{[]string{"--decoy-sni", "www.bing.com"}, ""},
+		{[]string{"--qr", "--output", "access.png", "--brand-title", "LibyaLink"}, ""},
+		{[]string{"--preset", "dialup"}, "unknown preset 'dialup'"},
+		{[]string{"--port", "0"}, "invalid --port 0"},
+		{[]string{"--native-format", "yaml", "--minify-native"}, "--minify-native only applies"},
+		{[]string{"--client-type", "openwrt", "--json-only"}, "--json-only doesn't apply to --client-type openwrt"},
+		{[]string{"--client-type", "hysteria-app"}, "no official Hysteria app"},
+		{[]string{"--uri-only", "--clipboard"}, "--clipboard doesn't apply to --uri-only"},
+		{[]string{"--qr", "--output", "access.txt"}, "--qr writes a PNG image"},
+		{[]string{"--brand-title", "LibyaLink"}, "only apply to --qr"},
+		{[]string{"--all-platforms"}, "--all-platforms needs --output-dir"},
+		{[]string{"--all-platforms", "--output-dir", "out", "--output", "x.json"}, "--output doesn't apply to --all-platforms"},
+		{[]string{"--output-dir", "out"}, "--output-dir only applies"},
+		{[]string{"--launcher", "mac"}, "unknown launcher 'mac'"},
+		{[]string{"--json-only", "--template", "t.tmpl"}, "mutually exclusive"},
+		{[]string{"--keepalive", "1s"}, "--keepalive must be between"},
+		{[]string{"--ttl", "1h"}, "--auth and --ttl"},
+		{[]string{"--schedule", "18:00-02:00"}, "--schedule needs --ttl"},
+		{[]string{"--speedtest-result", "r.json"}, "--speedtest-result only applies"},
+		{[]string{"--json-only", "--alpn", "h3", "--alpn", "h2"}, ""},
+		{[]string{"--decoy-sni", "www.bing.com", "--sni", "vpn.example.com"}, "--decoy-sni and --sni"},
+		{[]string{"--decoy-sni", "www.bing.com", "--insecure=false"}, "--decoy-sni needs --insecure"},
+		{[]string{"--resolve", "--front-address", "relay.example.net"}, "mutually exclusive"},
+		{[]string{"--front-address", "vpn.example.com"}, "is the server itself"},
+	}
+	for _, tt := range tests {
+		parseGenClientFlags(t, append(slices.Clone(base), tt.args...)...)
+		err := validateGenClientFlags(genClientCmd.Flags().Changed)
+		if tt.wantErr == "" {
+			assert.NoError(t, err, "%v", tt.args)
+		} else if assert.Error(t, err, "%v", tt.args) {
+			assert.Contains(t, err.Error(), tt.wantErr, "%v", tt.args)
+		}
+	}
+
+	parseGenClientFlags(t)
+	assert.ErrorContains(t, validateGenClientFlags(genClientCmd.Flags().Changed), "--server is required")
+	parseGenClientFlags(t, "--server", "vpn.example.com")
+	assert.ErrorContains(t, validateGenClientFlags(genClientCmd.Flags().Changed), "--auth is required")
+	parseGenClientFlags(t, "--server", "1.2.3.4", "--secret-ref", "env:HY_AUTH", "--resolve")
+	assert.ErrorContains(t, validateGenClientFlags(genClientCmd.Flags().Changed), "nothing to resolve")
+	parseGenClientFlags(t, "--server", "vpn.example.com", "--secret-ref", "env:HY_AUTH")
+	assert.NoError(t, validateGenClientFlags(genClientCmd.Flags().Changed))
+	parseGenClientFlags(t, "--server", "vpn.example.com", "--auth", "pass", "--secret-ref", "env:HY_AUTH")
+	assert.ErrorContains(t, validateGenClientFlags(genClientCmd.Flags().Changed), "--auth and --secret-ref are mutually exclusive")
+	// The password of a previous config gives way to the placeholder
+	parseGenClientFlags(t, "--secret-ref", "env:HY_AUTH")
+	applyGenClientBase(genClientCmd.Flags().Changed, &genClientBase{Server: "vpn.example.com", Auth: "old_password"})
+	assert.NoError(t, validateGenClientFlags(genClientCmd.Flags().Changed))
+	parseGenClientFlags(t, "--server", "vpn.example.com", "--secret-ref", "HY_AUTH")
+	assert.ErrorContains(t, validateGenClientFlags(genClientCmd.Flags().Changed), "invalid --secret-ref 'HY_AUTH'")
+
+	placeholder, err := parseSecretRef("env:HY_AUTH")
+	assert.NoError(t, err)
+	assert.Equal(t, "${HY_AUTH}", placeholder)
+}
+
+func TestValidateALPN(t *testing.T) {
+	tests := []struct {
+		alpn    string
+		wantErr bool
+	}{
+		{"h3", false},
+		{"h3-29", false},
+		{"http/1.1", false},
+		{strings.Repeat("a", 255), false},
+		{"", true},
+		{strings.Repeat("a", 256), true},
+		{"h 3", true},
+		{"h3,h2", true},
+		{"h3\n", true},
+		{"h3é", true},
+	}
+	for _, tt := range tests {
+		err := validateALPN(tt.alpn)
+		if tt.wantErr {
+			assert.Error(t, err, tt.alpn)
+		} else {
+			assert.NoError(t, err, tt.alpn)
+		}
+	}
+
+	// The native config has no ALPN setting, so --alpn needs an output
+	// without it
+	assert.NoError(t, validateALPNOutput(nil, false))
+	assert.NoError(t, validateALPNOutput([]string{"h3"}, true))
+	assert.ErrorContains(t, validateALPNOutput([]string{"h3"}, false), "--json-only")
+}
+
+func TestResolveServerAddrs(t *testing.T) {
+	lookup := func(ips ...string) func(context.Context, string, string) ([]net.IP, error) {
+		return func(ctx context.Context, network, host string) ([]net.IP, error) {
+			assert.Equal(t, "ip", network)
+			var out []net.IP
+			for _, ip := range ips {
+				out = append(out, net.ParseIP(ip))
+			}
+			return out, nil
+		}
+	}
+	addrs, err := resolveServerAddrs(context.Background(), "vpn.example.com",
+		lookup("2001:db8::2", "5.6.7.8", "1.2.3.4", "2001:db8::1", "5.6.7.8"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4", "5.6.7.8", "2001:db8::1", "2001:db8::2"}, addrs)
+
+	_, err = resolveServerAddrs(context.Background(), "vpn.example.com", lookup())
+	assert.ErrorContains(t, err, "no A or AAAA records")
+	_, err = resolveServerAddrs(context.Background(), "vpn.example.com", func(context.Context, string, string) ([]net.IP, error) {
+		return nil, errors.New("no such host")
+	})
+	assert.ErrorContains(t, err, "no such host")
+}
+
+func TestFrontAddressWarnings(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("limits:\n  authFailureBan:\n    maxFailures: 10\n"), 0o600))
+	warnings, err := frontAddressWarnings(path)
+	assert.NoError(t, err)
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0], "authFailureBan")
+	}
+
+	assert.NoError(t, os.WriteFile(path, []byte("limits:\n  maxConnections: 200\n"), 0o600))
+	warnings, err = frontAddressWarnings(path)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+}
+
+func TestBrandedQRPNG(t *testing.T) {
+	uri := "hysteria2://pass@1.2.3.4:443/?insecure=1&sni=1.2.3.4"
+	logo := image.NewNRGBA(image.Rect(0, 0, 60, 30))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.NRGBA{R: 200, A: 255}), image.Point{}, draw.Src)
+	dir := t.TempDir()
+	logoPath := filepath.Join(dir, "logo.png")
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, logo))
+	assert.NoError(t, os.WriteFile(logoPath, buf.Bytes(), 0o644))
+	loaded, err := loadBrandLogo(logoPath)
+	assert.NoError(t, err)
+	notImage := filepath.Join(dir, "logo.txt")
+	assert.NoError(t, os.WriteFile(notImage, []byte("logo"), 0o644))
+	_, err = loadBrandLogo(notImage)
+	assert.Error(t, err)
+
+	plain, err := brandedQRPNG(uri, "", nil)
+	assert.NoError(t, err)
+	branded, err := brandedQRPNG(uri, "Libya Net", loaded)
+	assert.NoError(t, err)
+	plainImg, err := png.Decode(bytes.NewReader(plain))
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(branded))
+	assert.NoError(t, err)
+
+	// Square without a title, taller with one
+	assert.Equal(t, plainImg.Bounds().Dx(), plainImg.Bounds().Dy())
+	assert.Greater(t, img.Bounds().Dy(), img.Bounds().Dx())
+	isBlack := func(x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return r == 0 && g == 0 && b == 0
+	}
+	quiet := qrQuietZone * qrModulePixels
+	for i := 0; i < quiet; i++ {
+		assert.False(t, isBlack(i, i), "quiet zone at %d", i)
+		assert.False(t, isBlack(img.Bounds().Dx()-1-i, i), "quiet zone at %d", i)
+	}
+	// Corner of the top-left finder pattern
+	assert.True(t, isBlack(quiet, quiet))
+	// The logo in the middle
+	mid := img.Bounds().Dx() / 2
+	r, g, b, _ := img.At(mid, mid).RGBA()
+	assert.Equal(t, [3]uint32{200 * 0x101, 0, 0}, [3]uint32{r, g, b})
+
+	assert.NoError(t, validateBrandTitle("LibyaLink @ Tripoli 2026"))
+	assert.Error(t, validateBrandTitle(" "))
+	assert.Error(t, validateBrandTitle("ليبيا"))
+	assert.Error(t, validateBrandTitle(strings.Repeat("x", brandTitleMaxLen+1)))
+}
+
+// singBoxSchema are the keys the sing-box docs list for what gen-client
+// writes, by the path of the object holding them. sing-box rejects any
+// other key. Array elements are "[type]", or "[]" without a type.
+var singBoxSchema = map[string][]string{
+	"":                          {"log", "dns", "ntp", "inbounds", "outbounds", "route", "experimental"},
+	"log":                       {"disabled", "level", "output", "timestamp"},
+	"dns":                       {"servers", "rules", "final", "strategy", "disable_cache", "disable_expire", "independent_cache", "reverse_mapping", "fakeip"},
+	"dns.servers[]":             {"tag", "address", "address_resolver", "address_strategy", "strategy", "detour", "client_subnet"},
+	"inbounds[tun]":             {"type", "tag", "interface_name", "address", "mtu", "auto_route", "strict_route", "route_address", "route_exclude_address", "stack", "platform", "sniff"},
+	"inbounds[socks]":           {"type", "tag", "listen", "listen_port", "users", "sniff"},
+	"inbounds[http]":            {"type", "tag", "listen", "listen_port", "users", "set_system_proxy", "sniff", "tls"},
+	"outbounds[hysteria2]":      {"type", "tag", "server", "server_port", "server_ports", "hop_interval", "up_mbps", "down_mbps", "obfs", "password", "network", "tls", "brutal_debug", "detour", "bind_interface"},
+	"outbounds[hysteria2].tls":  {"enabled", "disable_sni", "server_name", "insecure", "alpn", "min_version", "max_version", "cipher_suites", "certificate", "certificate_path", "ech", "utls", "reality"},
+	"outbounds[hysteria2].obfs": {"type", "password"},
+	"outbounds[direct]":         {"type", "tag", "detour", "bind_interface", "routing_mark"},
+	"outbounds[urltest]":        {"type", "tag", "outbounds", "url", "interval", "tolerance", "idle_timeout", "interrupt_exist_connections"},
+	"route":                     {"rules", "rule_set", "final", "auto_detect_interface", "override_android_vpn", "default_interface", "default_mark", "find_process"},
+	"route.rules[]":             {"inbound", "ip_version", "network", "auth_user", "protocol", "domain", "domain_suffix", "domain_keyword", "domain_regex", "ip_cidr", "port", "process_name", "process_path", "package_name", "invert", "outbound", "action"},
+}
+
+// clashMetaSchema is singBoxSchema for the mihomo (Clash.Meta) docs.
+var clashMetaSchema = map[string][]string{
+	"":                       {"mixed-port", "port", "socks-port", "allow-lan", "mode", "log-level", "ipv6", "dns", "proxies", "proxy-groups", "rules"},
+	"proxies[hysteria2]":     {"name", "type", "server", "port", "ports", "password", "up", "down", "obfs", "obfs-password", "sni", "skip-cert-verify", "fingerprint", "alpn", "ca", "ca-str", "udp"},
+	"proxy-groups[select]":   {"name", "type", "proxies", "use", "url", "interval", "lazy", "disable-udp"},
+	"proxy-groups[fallback]": {"name", "type", "proxies", "use", "url", "interval", "lazy", "disable-udp", "tolerance"},
+}
+
+// nativeClientSchema is singBoxSchema for the native client, built from
+// the mapstructure tags of clientConfig, which is what it's parsed into.
+func nativeClientSchema() map[string][]string {
+	schema := make(map[string][]string)
+	var walk func(t reflect.Type, path string)
+	walk = func(t reflect.Type, path string) {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			if t.Kind() == reflect.Slice {
+				path += "[]"
+			}
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct || t == reflect.TypeOf(time.Duration(0)) {
+			return
+		}
+		if _, ok := schema[path]; ok {
+			return
+		}
+		schema[path] = []string{}
+		for i := 0; i < t.NumField(); i++ {
+			key := t.Field(i).Tag.Get("mapstructure")
+			schema[path] = append(schema[path], key)
+			walk(t.Field(i).Type, strings.TrimPrefix(path+"."+key, "."))
+		}
+	}
+	walk(reflect.TypeOf(clientConfig{}), "")
+	return schema
+}
+
+// undocumentedKeys returns the keys of a decoded JSON or YAML document
+// that schema doesn't list, with their path.
+func undocumentedKeys(v any, path string, schema map[string][]string) []string {
+	var bad []string
+	switch v := v.(type) {
+	case map[string]any:
+		allowed, ok := schema[path]
+		for k, child := range v {
+			keyPath := strings.TrimPrefix(path+"."+k, ".")
+			if !ok || !slices.Contains(allowed, k) {
+				bad = append(bad, keyPath)
+				continue
+			}
+			bad = append(bad, undocumentedKeys(child, keyPath, schema)...)
+		}
+	case []any:
+		for _, child := range v {
+			elemPath := path + "[]"
+			if m, ok := child.(map[string]any); ok {
+				if typ, ok := m["type"].(string); ok {
+					elemPath = path + "[" + typ + "]"
+				}
+			}
+			bad = append(bad, undocumentedKeys(child, elemPath, schema)...)
+		}
+	}
+	return bad
+}
+
+// TestGenClientOutputSchemas checks every key of every generated config
+// against the keys its target client documents, for each --client-type
+// and the --all-platforms files, so each one imports without fixups.
+func TestGenClientOutputSchemas(t *testing.T) {
+	preset := bandwidthPresets["4g"]
+	upMbps, downMbps := parseBandwidthToMbps(preset)
+	data := genClientTemplateData{
+		Server:          "example.com",
+		Port:            443,
+		ServerAddr:      "example.com:443",
+		Auth:            "weak_ahh_password",
+		SNI:             "example.com",
+		Insecure:        true,
+		Obfs:            "cry_me_a_r1ver",
+		UpMbps:          upMbps,
+		DownMbps:        downMbps,
+		ALPN:            []string{"h3"},
+		StandbyServers:  []string{"standby.example.com"},
+		TunnelProcesses: []string{"telegram.exe"},
+		ProxyDomains:    []string{"example.com"},
+	}
+	native := newHysteria2ClientConfig(data.ServerAddr, data.Auth, data.SNI, data.Insecure, preset, data.Obfs, 15*time.Second)
+	setReceiveWindows(&native, 4<<20, 16<<20)
+	indent, err := parseOutputIndent("tab")
+	assert.NoError(t, err)
+	nativeJSON, err := marshalHysteria2ClientConfig(native, "json", false, indent)
+	assert.NoError(t, err)
+	nativeYAML, err := marshalHysteria2ClientConfig(native, "yaml", false, defaultOutputIndent)
+	assert.NoError(t, err)
+	openWrtYAML, err := marshalHysteria2ClientConfig(newOpenWrtClientConfig(native), "yaml", false, defaultOutputIndent)
+	assert.NoError(t, err)
+	singBoxJSON, err := indent.marshalJSON(newSingBoxConfig(data, true))
+	assert.NoError(t, err)
+	clashYAML, err := defaultOutputIndent.marshalYAML(newClashMetaConfig(data, true))
+	assert.NoError(t, err)
+
+	nativeSchema := nativeClientSchema()
+	tests := []struct {
+		name   string
+		data   []byte
+		yaml   bool
+		schema map[string][]string
+	}{
+		{"sing-box", singBoxJSON, false, singBoxSchema},
+		{"native json", nativeJSON, false, nativeSchema},
+		{"native yaml", nativeYAML, true, nativeSchema},
+		{"openwrt", []byte(formatOpenWrtOutput("4g", preset, openWrtYAML)), true, nativeSchema},
+		{"clash-meta", clashYAML, true, clashMetaSchema},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v any
+			if tt.yaml {
+				assert.NoError(t, yaml.Unmarshal(tt.data, &v))
+			} else {
+				assert.NoError(t, json.Unmarshal(tt.data, &v))
+			}
+			assert.Empty(t, undocumentedKeys(v, "", tt.schema))
+		})
+	}
+}
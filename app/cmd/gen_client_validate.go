@@ -0,0 +1,384 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	"github.com/apernet/hysteria/extras/v2/obfs"
+)
+
+// validateServerHost checks that --server is an IP address or a host
+// name, the port goes in --port.
+func validateServerHost(host string) error {
+	if strings.Contains(host, "://") {
+		return errors.New("give the host without a scheme, e.g. example.com")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(host); err == nil {
+		return fmt.Errorf("give the host without a port, and the port with --port %s", port)
+	}
+	name := strings.TrimSuffix(host, ".")
+	if name == "" || len(name) > 253 {
+		return errors.New("must be an IP address or a host name of 1-253 characters")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("'%s' isn't a valid part of a host name", label)
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+				return fmt.Errorf("'%s' isn't a valid part of a host name", label)
+			}
+		}
+	}
+	return nil
+}
+
+// parseFrontAddress parses --front-address, a host or host:port of the
+// relay in front of the server, the port defaulting to port.
+func parseFrontAddress(addr string, port int) (string, int, error) {
+	if strings.Contains(addr, "://") {
+		return "", 0, errors.New("give host or host:port, not a URL")
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port, or an IPv6 address without brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	} else {
+		port, err = strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return "", 0, fmt.Errorf("invalid port '%s', must be between 1 and 65535", portStr)
+		}
+	}
+	if err := validateServerHost(host); err != nil {
+		return "", 0, err
+	}
+	return host, port, nil
+}
+
+// resolveServerAddrs resolves --server for --resolve to all its A and AAAA
+// records with lookup, e.g. net.DefaultResolver.LookupIP. The addresses
+// come IPv4 first, each family sorted, so the output doesn't depend on the
+// order the DNS server returns them in.
+func resolveServerAddrs(ctx context.Context, host string, lookup func(ctx context.Context, network, host string) ([]net.IP, error)) ([]string, error) {
+	ips, err := lookup(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(ips, func(a, b net.IP) int {
+		a4, b4 := a.To4(), b.To4()
+		switch {
+		case a4 != nil && b4 == nil:
+			return -1
+		case a4 == nil && b4 != nil:
+			return 1
+		case a4 != nil:
+			return bytes.Compare(a4, b4)
+		default:
+			return bytes.Compare(a.To16(), b.To16())
+		}
+	})
+	var addrs []string
+	for _, ip := range ips {
+		if s := ip.String(); !slices.Contains(addrs, s) {
+			addrs = append(addrs, s)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("%s has no A or AAAA records", host)
+	}
+	return addrs, nil
+}
+
+// validatePresetBandwidth checks that the client will accept both values
+// of a preset, which may come from --based-on or --import.
+func validatePresetBandwidth(p bandwidthPreset) error {
+	if _, err := utils.ConvBandwidth(p.Up); err != nil {
+		return fmt.Errorf("up '%s': %w", p.Up, err)
+	}
+	if _, err := utils.ConvBandwidth(p.Down); err != nil {
+		return fmt.Errorf("down '%s': %w", p.Down, err)
+	}
+	return nil
+}
+
+// validateObfsPassword checks the --obfs password the way the client does
+// when it connects.
+func validateObfsPassword(password string) error {
+	_, err := obfs.NewSalamanderObfuscator([]byte(password))
+	return err
+}
+
+// validateALPN checks that an ALPN protocol ID is something a TLS
+// stack will accept: 1-255 bytes of printable, non-space ASCII.
+func validateALPN(alpn string) error {
+	if len(alpn) == 0 || len(alpn) > 255 {
+		return errors.New("must be 1-255 characters long")
+	}
+	for _, c := range alpn {
+		if c <= ' ' || c > '~' || c == ',' {
+			return errors.New("must only contain printable ASCII characters without spaces or commas")
+		}
+	}
+	return nil
+}
+
+// validateALPNOutput checks that the output has somewhere to put alpns.
+// Only sing-box has an ALPN setting: the native client always negotiates
+// h3, and the share URI has no field for it. singBoxOnly is whether the
+// output is the sing-box config alone, or a template that may use it.
+func validateALPNOutput(alpns []string, singBoxOnly bool) error {
+	if len(alpns) == 0 || singBoxOnly {
+		return nil
+	}
+	return errors.New("--alpn only applies to the sing-box config, the native client always negotiates h3 and has no ALPN setting. " +
+		"Use it with --json-only for a sing-box config alone, or leave it out")
+}
+
+// validateGenClientFlags checks the gen-client flags against each other,
+// before anything is read or written. changed reports whether a flag was
+// set on the command line.
+func validateGenClientFlags(changed func(name string) bool) error {
+	if genClientServer == "" {
+		return errors.New("--server is required (or use --based-on with a previous config, or --import with a URI)")
+	}
+	if err := validateServerHost(genClientServer); err != nil {
+		return fmt.Errorf("invalid --server '%s': %w", genClientServer, err)
+	}
+	preset, ok := bandwidthPresets[genClientPreset]
+	if !ok {
+		return fmt.Errorf("unknown preset '%s'. %s", genClientPreset, strings.TrimSuffix(unknownPresetHint(bandwidthPresets), "."))
+	}
+	if err := validatePresetBandwidth(preset); err != nil {
+		return fmt.Errorf("invalid bandwidth in preset '%s': %w", genClientPreset, err)
+	}
+	if err := validateGenClientOutputFlags(changed); err != nil {
+		return err
+	}
+
+	if genClientPort < 1 || genClientPort > 65535 {
+		return fmt.Errorf("invalid --port %d, must be between 1 and 65535", genClientPort)
+	}
+	if err := validateISP(genClientISP); err != nil {
+		return err
+	}
+	if genClientObfs != "" {
+		if err := validateObfsPassword(genClientObfs); err != nil {
+			return fmt.Errorf("invalid --obfs: %w", err)
+		}
+	}
+	for _, host := range genClientStandbyServers {
+		if err := validateServerHost(host); err != nil {
+			return fmt.Errorf("invalid --standby-server '%s': %w", host, err)
+		}
+	}
+	if err := validateProfile(genClientProfile); err != nil {
+		return err
+	}
+	if genClientKeepAlive != 0 && (genClientKeepAlive < 2*time.Second || genClientKeepAlive > 60*time.Second) {
+		return errors.New("--keepalive must be between 2s and 60s, or 0 for the client default (10s)")
+	}
+	if err := validateGenClientAuthFlags(); err != nil {
+		return err
+	}
+
+	if genClientSpeedtest != "" && !genClientAutoBrutal {
+		return errors.New("--speedtest-result only applies to --auto-brutal")
+	}
+	if genClientAutoBrutal && genClientSpeedtest == "" && genClientSecretRef != "" {
+		return errors.New("--auto-brutal can't log in to measure with --secret-ref, use --speedtest-result")
+	}
+	for _, alpn := range genClientALPN {
+		if err := validateALPN(alpn); err != nil {
+			return fmt.Errorf("invalid ALPN '%s': %w", alpn, err)
+		}
+	}
+	if err := validateALPNOutput(genClientALPN, genClientJSONOnly || genClientTemplate != ""); err != nil {
+		return err
+	}
+	for _, proc := range genClientTunnelProcs {
+		if err := validateProcessName(proc); err != nil {
+			return fmt.Errorf("invalid process name '%s': %w", proc, err)
+		}
+	}
+
+	if genClientDecoySNI != "" {
+		if changed("sni") {
+			return errors.New("--decoy-sni and --sni are mutually exclusive")
+		}
+		if !genClientInsecure {
+			return errors.New("--decoy-sni needs --insecure, the server's certificate can't match the decoy name")
+		}
+		if err := validateDecoySNI(genClientDecoySNI); err != nil {
+			return fmt.Errorf("invalid --decoy-sni '%s': %w", genClientDecoySNI, err)
+		}
+	}
+	if genClientResolve {
+		if net.ParseIP(genClientServer) != nil {
+			return errors.New("--resolve needs --server as a host name, there's nothing to resolve in an IP address")
+		}
+		if genClientFrontAddress != "" {
+			return errors.New("--resolve and --front-address are mutually exclusive, clients connect to the front")
+		}
+	}
+	_, _, err := genClientFront()
+	return err
+}
+
+// validateGenClientOutputFlags checks the flags choosing what gen-client
+// writes and where.
+func validateGenClientOutputFlags(changed func(name string) bool) error {
+	switch genClientNativeFormat {
+	case "json":
+	case "yaml":
+		if genClientMinifyNative {
+			return errors.New("--minify-native only applies to --native-format json")
+		}
+	default:
+		return fmt.Errorf("unknown native format '%s'. Use 'json' or 'yaml'", genClientNativeFormat)
+	}
+
+	switch genClientClientType {
+	case "":
+	case "openwrt":
+		if err := flagsNotApplying(changed, "--client-type openwrt",
+			"native-format", "minify-native", "json-only", "template", "launcher", "tuning-notes"); err != nil {
+			return err
+		}
+	case "hysteria-app":
+		// Asked for often, but there's no such app to write a config for
+		return errors.New("there is no official Hysteria app for Android or iOS, only the command line client. " +
+			"Apps that support Hysteria 2 (NekoBox, Hiddify, v2rayNG, Shadowrocket, Stash) import the share link: use --uri-only, or --qr to scan it")
+	default:
+		return fmt.Errorf("unknown client type '%s'. Use 'openwrt'", genClientClientType)
+	}
+
+	if genClientURIOnly {
+		if err := flagsNotApplying(changed, "--uri-only",
+			"json-only", "template", "client-type", "all-platforms", "launcher", "clipboard", "secret-ref"); err != nil {
+			return err
+		}
+	}
+
+	if genClientQR {
+		if genClientOutput == "" || !strings.EqualFold(filepath.Ext(genClientOutput), ".png") {
+			return errors.New("--qr writes a PNG image, give its path with --output, e.g. --output access.png")
+		}
+		if err := flagsNotApplying(changed, "--qr",
+			"uri-only", "json-only", "template", "client-type", "all-platforms", "launcher", "clipboard", "secret-ref"); err != nil {
+			return err
+		}
+		if changed("brand-title") {
+			if err := validateBrandTitle(genClientBrandTitle); err != nil {
+				return fmt.Errorf("invalid --brand-title: %w", err)
+			}
+		}
+	} else if genClientBrandTitle != "" || genClientBrandLogo != "" {
+		return errors.New("--brand-title and --brand-logo only apply to --qr")
+	}
+
+	if genClientAllPlatforms {
+		if genClientOutputDir == "" {
+			return errors.New("--all-platforms needs --output-dir")
+		}
+		if err := flagsNotApplying(changed, "--all-platforms",
+			"output", "native-format", "minify-native", "json-only", "template", "client-type", "launcher", "clipboard", "secret-ref"); err != nil {
+			return err
+		}
+	} else if genClientOutputDir != "" {
+		return errors.New("--output-dir only applies to --all-platforms, use --output for a single file")
+	}
+
+	if genClientClipboard != "" && genClientClipboard != "singbox" && genClientClipboard != "native" {
+		return fmt.Errorf("unknown clipboard target '%s'. Use 'singbox' or 'native'", genClientClipboard)
+	}
+	if genClientLauncher != "" && genClientLauncher != "win" {
+		return fmt.Errorf("unknown launcher '%s'. Use 'win'", genClientLauncher)
+	}
+	if genClientTemplate != "" && genClientJSONOnly {
+		return errors.New("--json-only and --template are mutually exclusive")
+	}
+	return nil
+}
+
+// flagsNotApplying returns an error for the first of names set on the
+// command line, none of which apply to mode.
+func flagsNotApplying(changed func(name string) bool, mode string, names ...string) error {
+	for _, name := range names {
+		if changed(name) {
+			return fmt.Errorf("--%s doesn't apply to %s", name, mode)
+		}
+	}
+	return nil
+}
+
+// validateGenClientAuthFlags checks that exactly one way of giving the
+// client's auth was used.
+func validateGenClientAuthFlags() error {
+	if genClientSecretRef != "" {
+		if _, err := parseSecretRef(genClientSecretRef); err != nil {
+			return err
+		}
+		if genClientTTL > 0 {
+			return errors.New("--secret-ref and --ttl are mutually exclusive")
+		}
+		if genClientAuth != "" {
+			return errors.New("--auth and --secret-ref are mutually exclusive, the configs get the placeholder instead of the password")
+		}
+	}
+	switch {
+	case genClientTTL > 0:
+		if genClientAuth != "" {
+			return errors.New("--auth and --ttl are mutually exclusive")
+		}
+		if genClientTokenSecret == "" {
+			return errors.New("--ttl requires --token-secret (the server's auth.token.secret)")
+		}
+	case genClientSchedule != "":
+		return errors.New("--schedule needs --ttl and --token-secret, the schedule is part of the token")
+	case genClientAuth == "" && genClientSecretRef == "":
+		return errors.New("--auth is required (or use --ttl with --token-secret, or --secret-ref)")
+	}
+	return nil
+}
+
+// parseSecretRef parses --secret-ref, env:NAME, into the ${NAME}
+// placeholder the client expands at startup.
+func parseSecretRef(ref string) (string, error) {
+	name, ok := strings.CutPrefix(ref, "env:")
+	placeholder := "${" + name + "}"
+	if !ok || !authEnvPlaceholderRegexp.MatchString(placeholder) {
+		return "", fmt.Errorf("invalid --secret-ref '%s'. Use env:NAME, e.g. env:HY_AUTH", ref)
+	}
+	return placeholder, nil
+}
+
+// genClientFront returns the host and port clients connect to with
+// --front-address, or no host and --port without one.
+func genClientFront() (string, int, error) {
+	if genClientFrontAddress == "" {
+		return "", genClientPort, nil
+	}
+	host, port, err := parseFrontAddress(genClientFrontAddress, genClientPort)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid --front-address '%s': %w", genClientFrontAddress, err)
+	}
+	if strings.EqualFold(host, genClientServer) && port == genClientPort {
+		return "", 0, errors.New("--front-address is the server itself, leave it out to connect directly")
+	}
+	if genClientSNI == "" && genClientDecoySNI == "" && net.ParseIP(genClientServer) != nil {
+		return "", 0, errors.New("--front-address sends the server's name as the SNI, give --server as a host name or set --sni")
+	}
+	return host, port, nil
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/server"
+	"github.com/apernet/hysteria/extras/v2/correctnet"
+)
+
+type serverConfigHealth struct {
+	Listen string `mapstructure:"listen"`
+}
+
+// healthHandler serves the load balancer health checks:
+//
+//	/healthz: 200 while the server accepts connections, 503 otherwise,
+//	          e.g. on a failover standby, before the listener is up or
+//	          while draining.
+//	/readyz:  like /healthz, but also 503 if the certificate is unusable.
+//	/drain:   POST starts draining, DELETE ends it. Draining only fails
+//	          the checks, connected clients stay until they leave.
+type healthHandler struct {
+	mutex    sync.RWMutex
+	serving  bool
+	draining bool
+	ready    func() error
+}
+
+func (h *healthHandler) SetServing(serving bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.serving = serving
+}
+
+func (h *healthHandler) SetDraining(draining bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.draining = draining
+}
+
+// SetReadyCheck sets the check /readyz runs on top of /healthz.
+func (h *healthHandler) SetReadyCheck(ready func() error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.ready = ready
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/drain" {
+		h.serveDrain(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.mutex.RLock()
+	serving, draining, ready := h.serving, h.draining, h.ready
+	h.mutex.RUnlock()
+
+	switch r.URL.Path {
+	case "/healthz":
+	case "/readyz":
+		if serving && ready != nil {
+			if err := ready(); err != nil {
+				http.Error(w, "not ready: "+err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if !serving {
+		http.Error(w, "not serving", http.StatusServiceUnavailable)
+		return
+	}
+	if draining {
+		http.Error(w, "draining", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+func (h *healthHandler) serveDrain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.SetDraining(true)
+		logger.Info("draining, health checks fail until DELETE /drain", zap.String("source", r.RemoteAddr))
+		_, _ = w.Write([]byte("draining\n"))
+	case http.MethodDelete:
+		h.SetDraining(false)
+		logger.Info("draining ended", zap.String("source", r.RemoteAddr))
+		_, _ = w.Write([]byte("ok\n"))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// tlsReadyCheck returns a /readyz check that the certificate the server
+// presents hasn't expired. With ACME, that is the certificate of the first
+// domain.
+func (c *serverConfig) tlsReadyCheck(tlsConfig server.TLSConfig) func() error {
+	return func() error {
+		var cert *tls.Certificate
+		var err error
+		switch {
+		case c.certLoader != nil:
+			cert, err = c.certLoader.Certificate()
+		case c.ACME != nil && len(c.ACME.Domains) > 0 && tlsConfig.GetCertificate != nil:
+			cert, err = tlsConfig.GetCertificate(&tls.ClientHelloInfo{ServerName: c.ACME.Domains[0]})
+		default:
+			return errors.New("no certificate configured")
+		}
+		if err != nil {
+			return err
+		}
+		if cert == nil || len(cert.Certificate) == 0 {
+			return errors.New("no certificate available yet")
+		}
+		leaf := cert.Leaf
+		if leaf == nil {
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return err
+			}
+		}
+		if time.Now().After(leaf.NotAfter) {
+			return fmt.Errorf("certificate expired on %s", leaf.NotAfter.UTC().Format(time.RFC3339))
+		}
+		return nil
+	}
+}
+
+func runHealthServer(listen string, handler http.Handler) {
+	logger.Info("health check server up and running", zap.String("listen", listen))
+	if err := correctnet.HTTPListenAndServe(listen, handler); err != nil {
+		logger.Fatal("failed to serve health checks", zap.Error(err))
+	}
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+func TestHealthHandler(t *testing.T) {
+	h := &healthHandler{}
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// Not serving yet, e.g. a failover standby
+	assert.Equal(t, http.StatusServiceUnavailable, get("/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"))
+
+	h.SetServing(true)
+	assert.Equal(t, http.StatusOK, get("/healthz"))
+	assert.Equal(t, http.StatusOK, get("/readyz"))
+
+	h.SetReadyCheck(func() error { return errors.New("certificate expired") })
+	assert.Equal(t, http.StatusOK, get("/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz"))
+
+	assert.Equal(t, http.StatusNotFound, get("/nope"))
+}
+
+func TestHealthHandlerDrain(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	h := &healthHandler{}
+	h.SetServing(true)
+	do := func(method, path string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/drain"))
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/readyz"))
+
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/drain"))
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/healthz"))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "/drain"))
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodPost, "/healthz"))
+}
+
+func TestTLSReadyCheck(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert"), filepath.Join(dir, "key")
+	assert.NoError(t, utils.GenerateSelfSignedCert([]string{"example.com"}, certFile, keyFile))
+	loader := &utils.LocalCertificateLoader{CertFile: certFile, KeyFile: keyFile}
+	assert.NoError(t, loader.InitializeCache())
+
+	c := &serverConfig{TLS: &serverConfigTLS{Cert: certFile, Key: keyFile}, certLoader: loader}
+	ready := c.tlsReadyCheck(server.TLSConfig{GetCertificate: loader.GetCertificate})
+	assert.NoError(t, ready())
+
+	// The server keeps serving the loaded certificate, so is still ready
+	assert.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+	assert.NoError(t, ready())
+
+	c = &serverConfig{}
+	assert.ErrorContains(t, c.tlsReadyCheck(server.TLSConfig{})(), "no certificate configured")
+}
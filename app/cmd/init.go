@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+var (
+	initOutput string
+	initForce  bool
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Interactively create a server config file",
+	Long: `Walk through the essential server settings (listen port, TLS or ACME,
+authentication and bandwidth) and write a ready-to-use config file.
+Each answer is validated as you go, and a self-signed certificate can be
+generated on the spot.
+
+Examples:
+  libyalink init
+  libyalink init -o /etc/libyalink/config.yaml`,
+	Run: runInit,
+}
+
+func init() {
+	initInitFlags()
+	rootCmd.AddCommand(initCmd)
+}
+
+func initInitFlags() {
+	initCmd.Flags().StringVarP(&initOutput, "output", "o", "config.yaml", "config file to write")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "overwrite the config file if it already exists")
+}
+
+// wizardPrompter asks questions on a terminal and re-asks until
+// the answer passes validation.
+type wizardPrompter struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func (p *wizardPrompter) ask(question, def string, validate func(string) error) string {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.w, "  %s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(p.w, "  %s: ", question)
+		}
+		line, err := p.r.ReadString('\n')
+		if err != nil && line == "" {
+			// EOF, there is nobody to ask
+			fmt.Fprintln(p.w)
+			fmt.Fprintln(os.Stderr, "Error: input closed before the setup was complete.")
+			os.Exit(1)
+		}
+		answer := strings.TrimSpace(line)
+		if answer == "" {
+			answer = def
+		}
+		if validate == nil {
+			return answer
+		}
+		if err := validate(answer); err != nil {
+			fmt.Fprintf(p.w, "    %s %v\n", checkFail, err)
+			continue
+		}
+		return answer
+	}
+}
+
+func (p *wizardPrompter) choose(question string, options []string, def string) string {
+	return p.ask(fmt.Sprintf("%s (%s)", question, strings.Join(options, "/")), def, func(s string) error {
+		for _, o := range options {
+			if strings.EqualFold(s, o) {
+				return nil
+			}
+		}
+		return fmt.Errorf("please answer one of: %s", strings.Join(options, ", "))
+	})
+}
+
+func (p *wizardPrompter) confirm(question string, def bool) bool {
+	d := "n"
+	if def {
+		d = "y"
+	}
+	answer := p.choose(question, []string{"y", "n"}, d)
+	return strings.EqualFold(answer, "y")
+}
+
+func runInit(cmd *cobra.Command, args []string) {
+	if _, err := os.Stat(initOutput); err == nil && !initForce {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists. Use --force to overwrite it.\n", initOutput)
+		os.Exit(1)
+	}
+	outDir := filepath.Dir(initOutput)
+
+	fmt.Println()
+	fmt.Println("╔══════════════════════════════════════════════════════╗")
+	fmt.Println("║          LibyaLink Setup Wizard                     ║")
+	fmt.Println("║          Powered by Hysteria 2                      ║")
+	fmt.Println("╚══════════════════════════════════════════════════════╝")
+	fmt.Println()
+	fmt.Println("  Press Enter to accept the [default] answer.")
+	fmt.Println()
+
+	p := &wizardPrompter{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+	config, genCmd := runInitWizard(p, outDir)
+
+	if err := os.WriteFile(initOutput, []byte(config), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to write %s: %v\n", initOutput, err)
+		os.Exit(1)
+	}
+	fmt.Println()
+	fmt.Printf("  %s Config written to %s\n", checkOK, initOutput)
+
+	// Run the config-only doctor checks on what we just wrote
+	viper.SetConfigFile(initOutput)
+	for _, r := range checkInitConfig() {
+		if r.Status != checkOK {
+			fmt.Printf("  %s  [%s] %s\n", r.Status, r.Name, r.Message)
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("  Next steps:")
+	fmt.Printf("    libyalink doctor -c %s\n", initOutput)
+	fmt.Printf("    libyalink server -c %s\n", initOutput)
+	fmt.Printf("    %s\n", genCmd)
+	fmt.Println()
+}
+
+// runInitWizard asks the setup questions on p and returns the config,
+// and the gen-client command for its clients. Certificates are written
+// next to the config in outDir by default.
+func runInitWizard(p *wizardPrompter, outDir string) (config, genCmd string) {
+	var b strings.Builder
+
+	// Listen port
+	port := p.ask("UDP port to listen on", "443", validateInitPort)
+	if conn, err := net.ListenPacket("udp", ":"+port); err != nil {
+		fmt.Fprintf(p.w, "    %s UDP port %s is in use right now. Stop the other program before starting the server.\n", checkWarn, port)
+	} else {
+		_ = conn.Close()
+	}
+	fmt.Fprintf(&b, "listen: :%s\n\n", port)
+
+	// Certificate
+	var genFlags string
+	switch strings.ToLower(p.choose("Certificate: ACME (Let's Encrypt, needs a domain), existing files, or self-signed", []string{"acme", "files", "self-signed"}, "acme")) {
+	case "acme":
+		domain := p.ask("Domain name pointing to this server", "", validateInitDomain)
+		email := p.ask("Email for the certificate authority", "", func(s string) error {
+			if _, err := mail.ParseAddress(s); err != nil {
+				return errors.New("not a valid email address")
+			}
+			return nil
+		})
+		fmt.Fprintf(&b, "acme:\n  domains:\n    - %s\n  email: %s\n\n", yamlQuote(domain), yamlQuote(email))
+		genFlags = " --sni " + domain + " --insecure=false"
+		fmt.Fprintln(p.w, "    ACME needs TCP port 80 or 443 reachable from the internet to issue the certificate.")
+	case "files":
+		cert := p.ask("Path to the certificate (PEM)", "", validateInitPath)
+		key := p.ask("Path to the private key (PEM)", "", validateInitPath)
+		genFlags = " --insecure=false"
+		if !fileExists(cert) || !fileExists(key) {
+			if !p.confirm("Certificate or key not found. Generate a self-signed certificate there?", true) {
+				fmt.Fprintln(os.Stderr, "Error: the certificate and key must exist before the server can start.")
+				os.Exit(1)
+			}
+			host := p.ask("Hostname or IP for the certificate", "bing.com", validateInitHost)
+			generateInitCert(p.w, host, cert, key)
+			genFlags = " --sni " + host
+		}
+		fmt.Fprintf(&b, "tls:\n  cert: %s\n  key: %s\n\n", yamlQuote(cert), yamlQuote(key))
+	default:
+		host := p.ask("Hostname or IP for the certificate", "bing.com", validateInitHost)
+		cert := p.ask("Where to save the certificate", filepath.Join(outDir, "server.crt"), nil)
+		key := p.ask("Where to save the private key", filepath.Join(outDir, "server.key"), nil)
+		generateInitCert(p.w, host, cert, key)
+		fmt.Fprintf(&b, "tls:\n  cert: %s\n  key: %s\n\n", yamlQuote(cert), yamlQuote(key))
+		genFlags = " --sni " + host
+	}
+
+	// Auth
+	var clientAuth string
+	switch strings.ToLower(p.choose("Authentication: one shared password, or per-user passwords", []string{"password", "userpass"}, "password")) {
+	case "password":
+		pw := p.ask("Password", randomInitPassword(), validateInitPassword)
+		fmt.Fprintf(&b, "auth:\n  type: password\n  password: %s\n\n", yamlQuote(pw))
+		clientAuth = pw
+	default:
+		user := p.ask("First user name", "user1", func(s string) error {
+			if s == "" || strings.ContainsAny(s, ": ") {
+				return errors.New("user name must not be empty or contain ':' or spaces")
+			}
+			return nil
+		})
+		pw := p.ask("Password for "+user, randomInitPassword(), validateInitPassword)
+		fmt.Fprintf(&b, "auth:\n  type: userpass\n  userpass:\n    %s: %s\n\n", yamlQuote(user), yamlQuote(pw))
+		clientAuth = user + ":" + pw
+	}
+
+	// Bandwidth
+	presetName := strings.ToLower(p.choose("Per-client bandwidth limit preset", []string{"none", "4g", "fiber"}, "none"))
+	if preset, ok := bandwidthPresets[presetName]; ok {
+		// Presets describe the client side, so the server's up is the client's down
+		fmt.Fprintf(&b, "# Per-client limits from the '%s' preset\nbandwidth:\n  up: %s\n  down: %s\n\n", presetName, preset.Down, preset.Up)
+	}
+
+	genCmd = fmt.Sprintf("libyalink gen-client --server YOUR_SERVER_IP --port %s --auth %s%s", port, strconv.Quote(clientAuth), genFlags)
+	if presetName != "none" {
+		genCmd += " --preset " + presetName
+	}
+	return b.String(), genCmd
+}
+
+// checkInitConfig runs the doctor checks that only need the config file
+// set in viper, not a server to run it.
+func checkInitConfig() []checkResult {
+	results := checkConfigReadable()
+	results = append(results, checkTLSACMEConflict()...)
+	results = append(results, checkTLSFiles()...)
+	results = append(results, checkAuthConfig()...)
+	results = append(results, checkShareablePasswords()...)
+	return results
+}
+
+func validateInitPort(s string) error {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return errors.New("port must be a number between 1 and 65535")
+	}
+	return nil
+}
+
+func validateInitDomain(s string) error {
+	if net.ParseIP(s) != nil {
+		return errors.New("ACME needs a domain name, not an IP address")
+	}
+	if !strings.Contains(s, ".") {
+		return errors.New("not a valid domain name")
+	}
+	return validateInitHost(s)
+}
+
+func validateInitHost(s string) error {
+	if s == "" {
+		return errors.New("must not be empty")
+	}
+	if net.ParseIP(s) != nil {
+		return nil
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 {
+			return errors.New("not a valid hostname")
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return fmt.Errorf("invalid character %q in hostname", c)
+			}
+		}
+	}
+	return nil
+}
+
+func validateInitPath(s string) error {
+	if s == "" {
+		return errors.New("must not be empty")
+	}
+	if fi, err := os.Stat(s); err == nil && fi.IsDir() {
+		return errors.New("is a directory")
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func generateInitCert(w io.Writer, host, cert, key string) {
+	if err := utils.GenerateSelfSignedCert([]string{host}, cert, key); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to generate certificate: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(w, "    %s Generated a self-signed certificate for %s\n", checkOK, host)
+}
+
+func validateInitPassword(s string) error {
+	if len(s) < 8 {
+		return errors.New("use at least 8 characters")
+	}
+	if reason := uriUnsafePasswordReason(s); reason != "" {
+		return fmt.Errorf("password %s", reason)
+	}
+	return nil
+}
+
+func randomInitPassword() string {
+	bs := make([]byte, 12)
+	if _, err := rand.Read(bs); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(bs)
+}
+
+// yamlQuote returns s as a YAML scalar, quoted only when needed.
+func yamlQuote(s string) string {
+	bs, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSuffix(string(bs), "\n")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// runTestInitWizard answers the wizard with the lines of script, writes the
+// config and loads it into viper like runInit does.
+func runTestInitWizard(t *testing.T, script ...string) (config serverConfig, genCmd, output string) {
+	t.Helper()
+	dir := t.TempDir()
+	var out bytes.Buffer
+	p := &wizardPrompter{r: bufio.NewReader(strings.NewReader(strings.Join(script, "\n") + "\n")), w: &out}
+	conf, genCmd := runInitWizard(p, dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(conf), 0o600))
+	viper.SetConfigFile(path)
+	for _, r := range checkInitConfig() {
+		assert.Equal(t, checkOK, r.Status, "[%s] %s", r.Name, r.Message)
+	}
+	assert.NoError(t, viper.Unmarshal(&config))
+	return config, genCmd, out.String()
+}
+
+func TestInitWizardSelfSigned(t *testing.T) {
+	defer viper.Reset()
+
+	config, genCmd, output := runTestInitWizard(t,
+		"70000", // Re-asked
+		"8443",
+		"self-signed",
+		"vpn.example.com",
+		"", // Default paths next to the config
+		"",
+		"password",
+		"short", // Re-asked
+		"weak_ahh_password",
+		"fiber",
+	)
+	assert.Contains(t, output, "port must be a number between 1 and 65535")
+	assert.Contains(t, output, "use at least 8 characters")
+	assert.Equal(t, ":8443", config.Listen)
+	assert.Equal(t, "server.crt", filepath.Base(config.TLS.Cert))
+	assert.FileExists(t, config.TLS.Cert)
+	assert.FileExists(t, config.TLS.Key)
+	assert.Equal(t, "password", config.Auth.Type)
+	assert.Equal(t, "weak_ahh_password", config.Auth.Password)
+	// The preset is for the client, so the server's limits are swapped
+	assert.Equal(t, "100 mbps", config.Bandwidth.Up)
+	assert.Equal(t, "20 mbps", config.Bandwidth.Down)
+	assert.Equal(t, `libyalink gen-client --server YOUR_SERVER_IP --port 8443 --auth "weak_ahh_password" --sni vpn.example.com --preset fiber`, genCmd)
+}
+
+func TestInitWizardACME(t *testing.T) {
+	defer viper.Reset()
+
+	config, genCmd, _ := runTestInitWizard(t,
+		"",
+		"acme",
+		"1.2.3.4", // Re-asked, ACME needs a domain
+		"vpn.example.com",
+		"admin@example.com",
+		"userpass",
+		"ali",
+		"p@ss: #word",
+		"none",
+	)
+	assert.Equal(t, ":443", config.Listen)
+	assert.Equal(t, []string{"vpn.example.com"}, config.ACME.Domains)
+	assert.Equal(t, "admin@example.com", config.ACME.Email)
+	assert.Equal(t, map[string]string{"ali": "p@ss: #word"}, config.Auth.UserPass)
+	assert.Empty(t, config.Bandwidth.Up)
+	assert.Contains(t, genCmd, `--auth "ali:p@ss: #word" --sni vpn.example.com --insecure=false`)
+	assert.NotContains(t, genCmd, "--preset")
+}
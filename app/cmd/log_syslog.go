@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	logOutputStderr = "stderr"
+	logOutputSyslog = "syslog"
+
+	defaultSyslogTag      = "libyalink"
+	defaultSyslogFacility = "daemon"
+)
+
+// syslogFacilities are the facility codes of RFC 5424 by their usual names.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func parseSyslogFacility(name string) (int, error) {
+	if name == "" {
+		name = defaultSyslogFacility
+	}
+	f, ok := syslogFacilities[strings.ToLower(name)]
+	if !ok {
+		return 0, errors.New("unknown facility, use e.g. daemon, user or local0-local7")
+	}
+	return f, nil
+}
+
+// syslogSeverity maps a zap level to the syslog severity.
+func syslogSeverity(level zapcore.Level) int {
+	switch {
+	case level >= zapcore.DPanicLevel:
+		return 2 // crit
+	case level == zapcore.ErrorLevel:
+		return 3 // err
+	case level == zapcore.WarnLevel:
+		return 4 // warning
+	case level == zapcore.InfoLevel:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// core returns the zap core for log.output, or nil to keep logging to
+// stderr. The connection to syslog or the journal is opened here, so that
+// a server that can't log fails at startup, not silently later.
+func (c *serverConfigLog) core(enab zapcore.LevelEnabler) (zapcore.Core, error) {
+	switch strings.ToLower(c.Output) {
+	case "", logOutputStderr:
+		return nil, nil
+	case logOutputSyslog:
+	default:
+		return nil, configError{Field: "log.output", Err: errors.New("unsupported output, use stderr or syslog")}
+	}
+	facility, err := parseSyslogFacility(c.Syslog.Facility)
+	if err != nil {
+		return nil, configError{Field: "log.syslog.facility", Err: err}
+	}
+	if c.Syslog.Tag == "" {
+		c.Syslog.Tag = defaultSyslogTag
+	}
+	if (c.Syslog.Network == "") != (c.Syslog.Address == "") {
+		return nil, configError{Field: "log.syslog.address", Err: errors.New("network and address must be set together")}
+	}
+	w, err := newSyslogWriter(c.Syslog, facility)
+	if err != nil {
+		return nil, configError{Field: "log.output", Err: err}
+	}
+	return newSyslogCore(enab, w), nil
+}
+
+// syslogWriter is where syslogCore sends entries: the line in the
+// console format, and the fields by themselves for the journal.
+type syslogWriter interface {
+	WriteEntry(level zapcore.Level, line string, fields map[string]interface{}) error
+}
+
+// syslogCore is the zap core of log.output: syslog.
+type syslogCore struct {
+	zapcore.LevelEnabler
+	enc    zapcore.Encoder
+	fields []zapcore.Field
+	w      syslogWriter
+}
+
+func newSyslogCore(enab zapcore.LevelEnabler, w syslogWriter) *syslogCore {
+	return &syslogCore{
+		LevelEnabler: enab,
+		enc: zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+			// No time or level, syslog and the journal record them
+			NameKey:        "logger",
+			MessageKey:     "msg",
+			LineEnding:     zapcore.DefaultLineEnding,
+			EncodeDuration: zapcore.SecondsDurationEncoder,
+		}),
+		w: w,
+	}
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(slices.Clip(c.fields), fields...)
+	return &clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := append(slices.Clip(c.fields), fields...)
+	buf, err := c.enc.EncodeEntry(ent, all)
+	if err != nil {
+		return err
+	}
+	line := strings.TrimSuffix(buf.String(), zapcore.DefaultLineEnding)
+	buf.Free()
+	m := zapcore.NewMapObjectEncoder()
+	for _, f := range all {
+		f.AddTo(m)
+	}
+	return c.w.WriteEntry(ent.Level, line, m.Fields)
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
+
+// journalMessage encodes an entry in the journal's native protocol, with
+// each zap field as a field of its own, e.g. "id" as ID, which
+// "journalctl ID=alice" finds.
+func journalMessage(tag string, facility int, level zapcore.Level, line string, fields map[string]interface{}) []byte {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", line)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity(level)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", tag)
+	writeJournalField(&b, "SYSLOG_FACILITY", strconv.Itoa(facility))
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		name := journalFieldName(k)
+		switch name {
+		case "", "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER", "SYSLOG_FACILITY":
+			continue
+		}
+		writeJournalField(&b, name, journalFieldValue(fields[k]))
+	}
+	return b.Bytes()
+}
+
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	// Values with newlines are sent with their length instead
+	b.WriteString(name + "\n")
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName turns a zap key into a journal field name: uppercase
+// letters, digits and underscores, starting with a letter, as the journal
+// drops the others, and names starting with "_" are reserved for it.
+func journalFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	s := strings.TrimLeft(string(name), "_0123456789")
+	if len(s) > 64 {
+		s = s[:64]
+	}
+	return s
+}
+
+func journalFieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(bs)
+	default:
+		return fmt.Sprint(v)
+	}
+}
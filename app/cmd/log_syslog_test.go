@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type testSyslogWriter struct {
+	level  zapcore.Level
+	line   string
+	fields map[string]interface{}
+}
+
+func (w *testSyslogWriter) WriteEntry(level zapcore.Level, line string, fields map[string]interface{}) error {
+	w.level, w.line, w.fields = level, line, fields
+	return nil
+}
+
+func TestSyslogCore(t *testing.T) {
+	w := &testSyslogWriter{}
+	l := zap.New(newSyslogCore(zapcore.InfoLevel, w)).With(zap.String("id", "alice"))
+	l.Warn("client disconnected", zap.Int("code", 3))
+	assert.Equal(t, zapcore.WarnLevel, w.level)
+	assert.Equal(t, `client disconnected	{"id": "alice", "code": 3}`, w.line)
+	assert.Equal(t, map[string]interface{}{"id": "alice", "code": int64(3)}, w.fields)
+
+	w.line = ""
+	l.Debug("not logged")
+	assert.Empty(t, w.line)
+
+	for _, c := range []serverConfigLog{
+		{Output: "file"},
+		{Output: "syslog", Syslog: serverConfigLogSyslog{Facility: "local9"}},
+		{Output: "syslog", Syslog: serverConfigLogSyslog{Network: "udp"}},
+	} {
+		_, err := c.core(zapcore.InfoLevel)
+		assert.Error(t, err)
+	}
+	core, err := (&serverConfigLog{}).core(zapcore.InfoLevel)
+	assert.NoError(t, err)
+	assert.Nil(t, core)
+}
+
+func TestJournalMessage(t *testing.T) {
+	assert.Equal(t, "REMOTE_ADDR", journalFieldName("remote.addr"))
+	assert.Equal(t, "ID", journalFieldName("_id"))
+	assert.Equal(t, "", journalFieldName("123"))
+
+	msg := journalMessage("libyalink", 3, zapcore.ErrorLevel, "failed\twith", map[string]interface{}{
+		"id":      "alice",
+		"message": "dropped, it's reserved",
+		"error":   "line 1\nline 2",
+	})
+	var want bytes.Buffer
+	want.WriteString("MESSAGE=failed\twith\nPRIORITY=3\nSYSLOG_IDENTIFIER=libyalink\nSYSLOG_FACILITY=3\n")
+	want.WriteString("ERROR\n")
+	_ = binary.Write(&want, binary.LittleEndian, uint64(len("line 1\nline 2")))
+	want.WriteString("line 1\nline 2\nID=alice\n")
+	assert.Equal(t, want.String(), string(msg))
+}
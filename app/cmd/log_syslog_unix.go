@@ -0,0 +1,64 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"log/syslog"
+	"net"
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// journalSocket is where systemd-journald takes entries in its native
+// protocol.
+const journalSocket = "/run/systemd/journal/socket"
+
+// newSyslogWriter connects to the journal when running as a systemd
+// service, where entries keep their fields, and to syslog otherwise, or
+// with log.syslog.address.
+func newSyslogWriter(c serverConfigLogSyslog, facility int) (syslogWriter, error) {
+	// systemd sets JOURNAL_STREAM for services whose output goes to the journal
+	if c.Address == "" && os.Getenv("JOURNAL_STREAM") != "" {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+		if err == nil {
+			return &journalWriter{conn: conn, tag: c.Tag, facility: facility}, nil
+		}
+	}
+	w, err := syslog.Dial(c.Network, c.Address, syslog.Priority(facility<<3)|syslog.LOG_INFO, c.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return &unixSyslogWriter{w: w}, nil
+}
+
+type journalWriter struct {
+	conn     *net.UnixConn
+	tag      string
+	facility int
+}
+
+func (w *journalWriter) WriteEntry(level zapcore.Level, line string, fields map[string]interface{}) error {
+	_, err := w.conn.Write(journalMessage(w.tag, w.facility, level, line, fields))
+	return err
+}
+
+type unixSyslogWriter struct {
+	w *syslog.Writer
+}
+
+func (w *unixSyslogWriter) WriteEntry(level zapcore.Level, line string, fields map[string]interface{}) error {
+	switch syslogSeverity(level) {
+	case 2:
+		return w.w.Crit(line)
+	case 3:
+		return w.w.Err(line)
+	case 4:
+		return w.w.Warning(line)
+	case 6:
+		return w.w.Info(line)
+	default:
+		return w.w.Debug(line)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import "errors"
+
+func newSyslogWriter(c serverConfigLogSyslog, facility int) (syslogWriter, error) {
+	return nil, errors.New("syslog is not supported on Windows")
+}
@@ -0,0 +1,402 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	defaultQuotaResetDay = 1
+	// quotaMaxResetDay is the last day that every month has
+	quotaMaxResetDay = 28
+
+	// quotaSaveInterval is how often the usage is written to the quota
+	// file, and so the most that's lost when the server crashes. A server
+	// stopped with SIGINT or SIGTERM saves it first (saveOnSignal).
+	quotaSaveInterval = time.Minute
+)
+
+type serverConfigQuota struct {
+	Default  string            `mapstructure:"default"`
+	Users    map[string]string `mapstructure:"users"`
+	ResetDay int               `mapstructure:"resetDay"`
+	File     string            `mapstructure:"file"`
+}
+
+// Enabled is true if any user has a quota.
+func (q serverConfigQuota) Enabled() bool {
+	return q.Default != "" || len(q.Users) > 0
+}
+
+// parse checks the quota config and returns the default quota and those of
+// the users, in bytes, 0 meaning unlimited.
+func (q serverConfigQuota) parse() (def uint64, users map[string]uint64, err error) {
+	if q.Default != "" {
+		def, err = parseQuotaSize(q.Default)
+		if err != nil {
+			return 0, nil, configError{Field: "limits.quota.default", Err: err}
+		}
+	}
+	users = make(map[string]uint64, len(q.Users))
+	for user, size := range q.Users {
+		v, err := parseQuotaSize(size)
+		if err != nil {
+			return 0, nil, configError{Field: "limits.quota.users." + user, Err: err}
+		}
+		// The same case-insensitive names as auth.userpass
+		users[strings.ToLower(user)] = v
+	}
+	if q.ResetDay < 0 || q.ResetDay > quotaMaxResetDay {
+		return 0, nil, configError{Field: "limits.quota.resetDay", Err: fmt.Errorf("must be between 1 and %d", quotaMaxResetDay)}
+	}
+	if q.File == "" {
+		// Without it a restart would give everyone their full quota back
+		return 0, nil, configError{Field: "limits.quota.file", Err: errors.New("empty quota file path, the usage must survive restarts")}
+	}
+	return def, users, nil
+}
+
+// parseQuotaSize parses a quota like "50GB". "unlimited" and "0" mean no
+// quota and return 0.
+func parseQuotaSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" || strings.EqualFold(s, "unlimited") {
+		return 0, nil
+	}
+	v, err := utils.StringToBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q: %w, use e.g. 50GB, 500MB or unlimited", s, err)
+	}
+	return v, nil
+}
+
+// quotaCycleStart returns the start of the quota cycle that t is in,
+// midnight of resetDay in t's time zone.
+func quotaCycleStart(t time.Time, resetDay int) time.Time {
+	month := t.Month()
+	if t.Day() < resetDay {
+		month-- // time.Date normalizes January - 1
+	}
+	return time.Date(t.Year(), month, resetDay, 0, 0, 0, 0, t.Location())
+}
+
+// quotaState is the content of limits.quota.file.
+type quotaState struct {
+	Period time.Time         `json:"period"` // Start of the cycle the usage is for
+	Used   map[string]uint64 `json:"used"`
+}
+
+// quotaTracker counts the bytes each user sends and receives in the current
+// cycle, and implements server.Quota. Users without a quota are counted too,
+// so that the quota command can show them.
+type quotaTracker struct {
+	Default  uint64
+	Users    map[string]uint64
+	ResetDay int
+	File     string
+
+	mutex    sync.Mutex
+	period   time.Time
+	used     map[string]uint64
+	exceeded map[string]bool // Users already logged in this cycle
+	dirty    bool
+	now      func() time.Time
+}
+
+func newQuotaTracker(q serverConfigQuota) (*quotaTracker, error) {
+	def, users, err := q.parse()
+	if err != nil {
+		return nil, err
+	}
+	t := &quotaTracker{
+		Default:  def,
+		Users:    users,
+		ResetDay: q.ResetDay,
+		File:     q.File,
+		used:     make(map[string]uint64),
+		exceeded: make(map[string]bool),
+		now:      time.Now,
+	}
+	if t.ResetDay == 0 {
+		t.ResetDay = defaultQuotaResetDay
+	}
+	t.period = quotaCycleStart(t.now(), t.ResetDay)
+	state, err := readQuotaState(t.File)
+	if err != nil && !os.IsNotExist(err) {
+		// Starting from zero would give everyone their full quota back
+		return nil, configError{Field: "limits.quota.file", Err: err}
+	}
+	if err == nil && state.Period.Equal(t.period) {
+		for id, n := range state.Used {
+			t.used[id] = n
+		}
+	}
+	return t, nil
+}
+
+func readQuotaState(path string) (quotaState, error) {
+	var state quotaState
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(bs, &state); err != nil {
+		return state, fmt.Errorf("malformed quota file %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// limit returns the quota of id in bytes, 0 if it has none.
+func (t *quotaTracker) limit(id string) uint64 {
+	if v, ok := t.Users[id]; ok {
+		return v
+	}
+	return t.Default
+}
+
+// rollover starts a new cycle if now is past the current one.
+// The caller must hold the mutex.
+func (t *quotaTracker) rollover(now time.Time) {
+	period := quotaCycleStart(now, t.ResetDay)
+	if period.Equal(t.period) {
+		return
+	}
+	logger.Info("data quotas reset for the new cycle", zap.Time("period", period), zap.Int("users", len(t.used)))
+	t.period = period
+	t.used = make(map[string]uint64)
+	t.exceeded = make(map[string]bool)
+	t.dirty = true
+}
+
+func (t *quotaTracker) Check(id string) (ok bool, reset time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.rollover(t.now())
+	limit := t.limit(id)
+	if limit == 0 {
+		return true, time.Time{}
+	}
+	return t.used[id] < limit, t.period.AddDate(0, 1, 0)
+}
+
+// Add counts n more bytes for id and returns false if that's over its quota.
+func (t *quotaTracker) Add(id string, n uint64) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.rollover(t.now())
+	t.used[id] += n
+	t.dirty = true
+	limit := t.limit(id)
+	if limit == 0 || t.used[id] < limit {
+		return true
+	}
+	if !t.exceeded[id] {
+		t.exceeded[id] = true
+		logger.Warn("user data quota used up, disconnecting until it resets", zap.String("id", id),
+			zap.Uint64("used", t.used[id]), zap.Uint64("quota", limit), zap.Time("reset", t.period.AddDate(0, 1, 0)))
+	}
+	return false
+}
+
+// Save writes the usage to the quota file if it changed since the last time.
+func (t *quotaTracker) Save() error {
+	t.mutex.Lock()
+	if !t.dirty {
+		t.mutex.Unlock()
+		return nil
+	}
+	bs, err := json.Marshal(quotaState{Period: t.period, Used: t.used})
+	t.dirty = false
+	t.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	// Replaced atomically, a partial file would refuse to load
+	tmp := filepath.Join(filepath.Dir(t.File), "."+filepath.Base(t.File)+".tmp")
+	if err := os.WriteFile(tmp, bs, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.File)
+}
+
+func (t *quotaTracker) saveLoop() {
+	ticker := time.NewTicker(quotaSaveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := t.Save(); err != nil {
+			logger.Error("failed to save data quota usage", zap.String("file", t.File), zap.Error(err))
+			t.mutex.Lock()
+			t.dirty = true // Try again next time
+			t.mutex.Unlock()
+		}
+	}
+}
+
+// saveOnSignal saves the usage once the first signal arrives on sigChan,
+// which runServer sets up for SIGINT and SIGTERM, so a restart loses none
+// of it. It returns once the usage is saved, for the server to exit.
+func (t *quotaTracker) saveOnSignal(sigChan <-chan os.Signal) {
+	sig := <-sigChan
+	if err := t.Save(); err != nil {
+		logger.Error("failed to save data quota usage", zap.String("file", t.File), zap.Error(err))
+		return
+	}
+	logger.Info("data quota usage saved", zap.String("file", t.File), zap.String("signal", sig.String()))
+}
+
+// quotaTrafficLogger counts the traffic of each user towards its quota and
+// disconnects the users over it. TrafficLogger may be nil if traffic stats
+// are disabled.
+type quotaTrafficLogger struct {
+	TrafficLogger server.TrafficLogger
+	Tracker       *quotaTracker
+}
+
+func (l *quotaTrafficLogger) LogTraffic(id string, tx, rx uint64) (ok bool) {
+	ok = l.Tracker.Add(id, tx+rx)
+	if l.TrafficLogger != nil {
+		// Still counted by the inner logger, it may have its own reasons
+		ok = l.TrafficLogger.LogTraffic(id, tx, rx) && ok
+	}
+	return ok
+}
+
+func (l *quotaTrafficLogger) LogOnlineState(id string, online bool) {
+	if l.TrafficLogger != nil {
+		l.TrafficLogger.LogOnlineState(id, online)
+	}
+}
+
+func (l *quotaTrafficLogger) TraceStream(stream server.HyStream, stats *server.StreamStats) {
+	if l.TrafficLogger != nil {
+		l.TrafficLogger.TraceStream(stream, stats)
+	}
+}
+
+func (l *quotaTrafficLogger) UntraceStream(stream server.HyStream) {
+	if l.TrafficLogger != nil {
+		l.TrafficLogger.UntraceStream(stream)
+	}
+}
+
+// fillQuota must be called after fillTrafficLogger, as it wraps it, and
+// before fillDebugTrace, so that traced traffic is counted too.
+func (c *serverConfig) fillQuota(hyConfig *server.Config) error {
+	if !c.Limits.Quota.Enabled() {
+		return nil
+	}
+	t, err := newQuotaTracker(c.Limits.Quota)
+	if err != nil {
+		return err
+	}
+	hyConfig.Quota = t
+	c.quota = t
+	hyConfig.TrafficLogger = &quotaTrafficLogger{TrafficLogger: hyConfig.TrafficLogger, Tracker: t}
+	logger.Info("data quotas enabled", zap.String("file", t.File), zap.Int("users", len(t.Users)),
+		zap.Uint64("default", t.Default), zap.Int("resetDay", t.ResetDay))
+	go t.saveLoop()
+	return nil
+}
+
+var quotaCmd = &cobra.Command{
+	Use:   "quota",
+	Short: "Show the data used and left of each user this cycle",
+	Long: `Show how much of their limits.quota each user has used in the current cycle,
+how much is left and when it resets, read from limits.quota.file and the
+server config (-c, or the default locations). The server saves the file once a
+minute, so the last minute of traffic may be missing.
+
+Examples:
+  libyalink quota -c /etc/hysteria/config.yaml`,
+	Args: cobra.NoArgs,
+	Run:  runQuota,
+}
+
+func init() {
+	rootCmd.AddCommand(quotaCmd)
+}
+
+func runQuota(cmd *cobra.Command, args []string) {
+	if err := viper.ReadInConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot read the config: %v\n", err)
+		os.Exit(1)
+	}
+	var config serverConfig
+	if err := viper.Unmarshal(&config); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot parse the config: %v\n", err)
+		os.Exit(1)
+	}
+	if !config.Limits.Quota.Enabled() {
+		fmt.Fprintln(os.Stderr, "Error: no data quotas, set limits.quota in the config.")
+		os.Exit(1)
+	}
+	t, err := newQuotaTracker(config.Limits.Quota)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(formatQuotaReport(t, config.Auth.UserPass))
+}
+
+// formatQuotaReport is a table of the users of the tracker and of userpass,
+// which has the users that haven't connected yet this cycle.
+func formatQuotaReport(t *quotaTracker, userpass map[string]string) string {
+	ids := make(map[string]bool)
+	for id := range t.used {
+		ids[id] = true
+	}
+	for id := range t.Users {
+		ids[id] = true
+	}
+	for user := range userpass {
+		ids[strings.ToLower(user)] = true
+	}
+	names := make([]string, 0, len(ids))
+	for id := range ids {
+		names = append(names, id)
+	}
+	slices.Sort(names)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Cycle: %s to %s\n", t.period.Format(time.DateOnly), t.period.AddDate(0, 1, 0).Format(time.DateOnly))
+	fmt.Fprintf(&b, "%-20s %10s %10s %10s\n", "USER", "USED", "QUOTA", "LEFT")
+	for _, id := range names {
+		used, limit := t.used[id], t.limit(id)
+		quota, left := "unlimited", "-"
+		if limit > 0 {
+			quota = formatQuotaBytes(limit)
+			left = formatQuotaBytes(limit - min(used, limit))
+		}
+		fmt.Fprintf(&b, "%-20s %10s %10s %10s\n", id, formatQuotaBytes(used), quota, left)
+	}
+	return b.String()
+}
+
+// formatQuotaBytes uses the decimal units of the quota config, so that a
+// 50GB quota shows as 50.0GB.
+func formatQuotaBytes(b uint64) string {
+	switch {
+	case b >= utils.Terabyte:
+		return fmt.Sprintf("%.1fTB", float64(b)/utils.Terabyte)
+	case b >= utils.Gigabyte:
+		return fmt.Sprintf("%.1fGB", float64(b)/utils.Gigabyte)
+	case b >= utils.Megabyte:
+		return fmt.Sprintf("%.1fMB", float64(b)/utils.Megabyte)
+	default:
+		return fmt.Sprintf("%dKB", b/utils.Kilobyte)
+	}
+}
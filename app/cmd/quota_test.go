@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestQuotaCycleStart(t *testing.T) {
+	tests := []struct {
+		t        time.Time
+		resetDay int
+		want     time.Time
+	}{
+		{time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), 1, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), 16, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), 20, time.Date(2026, 9, 20, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), 28, time.Date(2025, 12, 28, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, quotaCycleStart(tt.t, tt.resetDay), "%s day %d", tt.t, tt.resetDay)
+	}
+}
+
+func TestServerConfigQuotaParse(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "quota.json")
+	def, users, err := serverConfigQuota{
+		Default: "50GB",
+		Users:   map[string]string{"Alice": "100 GB", "bob": "unlimited"},
+		File:    file,
+	}.parse()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(50_000_000_000), def)
+	assert.Equal(t, map[string]uint64{"alice": 100_000_000_000, "bob": 0}, users)
+
+	_, _, err = serverConfigQuota{Users: map[string]string{"alice": "50 gbps"}, File: file}.parse()
+	assert.ErrorContains(t, err, "limits.quota.users.alice")
+	_, _, err = serverConfigQuota{Default: "50GB", ResetDay: 31, File: file}.parse()
+	assert.ErrorContains(t, err, "limits.quota.resetDay")
+	_, _, err = serverConfigQuota{Default: "50GB"}.parse()
+	assert.ErrorContains(t, err, "limits.quota.file")
+}
+
+func TestQuotaTracker(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	file := filepath.Join(t.TempDir(), "quota.json")
+	config := serverConfigQuota{
+		Default:  "1KB",
+		Users:    map[string]string{"alice": "2KB", "bob": "unlimited"},
+		ResetDay: 15,
+		File:     file,
+	}
+	tracker, err := newQuotaTracker(config)
+	assert.NoError(t, err)
+	// The real time, for the tracker loaded from the file below
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+	reset := quotaCycleStart(now, 15).AddDate(0, 1, 0)
+
+	ok, r := tracker.Check("alice")
+	assert.True(t, ok)
+	assert.Equal(t, reset, r)
+	assert.True(t, tracker.Add("alice", 1500))
+	assert.False(t, tracker.Add("alice", 500))
+	ok, r = tracker.Check("alice")
+	assert.False(t, ok)
+	assert.Equal(t, reset, r)
+
+	// The default quota, and none at all
+	assert.False(t, tracker.Add("carol", 1000))
+	assert.True(t, tracker.Add("bob", 1_000_000))
+	ok, r = tracker.Check("bob")
+	assert.True(t, ok)
+	assert.True(t, r.IsZero())
+
+	// The usage survives a restart in the same cycle
+	assert.NoError(t, tracker.Save())
+	restarted, err := newQuotaTracker(config)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"alice": 2000, "bob": 1_000_000, "carol": 1000}, restarted.used)
+	ok, _ = restarted.Check("alice")
+	assert.False(t, ok)
+
+	// and is dropped when the next one starts
+	now = reset
+	ok, _ = tracker.Check("alice")
+	assert.True(t, ok)
+	assert.Empty(t, tracker.used)
+
+	// A damaged file stops the server instead of resetting everyone
+	assert.NoError(t, os.WriteFile(file, []byte("{"), 0o600))
+	_, err = newQuotaTracker(config)
+	assert.ErrorContains(t, err, "limits.quota.file")
+}
+
+func TestQuotaTrafficLogger(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	tracker, err := newQuotaTracker(serverConfigQuota{Default: "1KB", File: filepath.Join(t.TempDir(), "quota.json")})
+	assert.NoError(t, err)
+	l := &quotaTrafficLogger{Tracker: tracker}
+	assert.True(t, l.LogTraffic("alice", 300, 600))
+	assert.False(t, l.LogTraffic("alice", 50, 50))
+	l.LogOnlineState("alice", false)
+}
+
+func TestQuotaSaveOnSignal(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	file := filepath.Join(t.TempDir(), "quota.json")
+	config := serverConfigQuota{Default: "1GB", File: file}
+	tracker, err := newQuotaTracker(config)
+	assert.NoError(t, err)
+	tracker.Add("alice", 1000)
+
+	// Stopped before the next periodic save
+	sigChan := make(chan os.Signal, 1)
+	sigChan <- syscall.SIGTERM
+	tracker.saveOnSignal(sigChan)
+
+	restarted, err := newQuotaTracker(config)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint64{"alice": 1000}, restarted.used)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+var (
+	reloadCertAPI    string
+	reloadCertSecret string
+)
+
+var reloadCertCmd = &cobra.Command{
+	Use:   "reload-cert",
+	Short: "Make a running server load its TLS certificate again",
+	Long: `Make a running server read tls.cert and tls.key again and use the new pair for
+new connections, without a restart: connected clients stay connected. The new
+pair is checked first, a certificate that doesn't match its key, has expired
+or isn't valid yet is rejected and the old one stays in use.
+
+The request goes through the traffic stats API, which must be enabled
+(trafficStats.listen). The address and secret are read from the server config
+unless --api and --secret are given. Without the API, send the server SIGHUP
+instead (not on Windows):
+  kill -HUP $(pidof libyalink)
+
+The server also picks up changed files by itself within about 20 seconds,
+reload-cert is for when the new certificate must be used right away, or to
+find out whether it was accepted.
+
+Examples:
+  libyalink reload-cert -c /etc/hysteria/config.yaml
+  libyalink reload-cert --api 127.0.0.1:9999 --secret "stats_secret"`,
+	Args: cobra.NoArgs,
+	Run:  runReloadCert,
+}
+
+func init() {
+	initReloadCertFlags()
+	rootCmd.AddCommand(reloadCertCmd)
+}
+
+func initReloadCertFlags() {
+	reloadCertCmd.Flags().StringVar(&reloadCertAPI, "api", "", "traffic stats API address (default: trafficStats.listen of the config)")
+	reloadCertCmd.Flags().StringVar(&reloadCertSecret, "secret", "", "traffic stats API secret (default: trafficStats.secret of the config)")
+}
+
+// certReloadResult is the answer of POST /reload-cert.
+type certReloadResult struct {
+	OldSHA256 string    `json:"old_sha256"`
+	SHA256    string    `json:"sha256"`
+	DNSNames  []string  `json:"dns_names"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+func runReloadCert(cmd *cobra.Command, args []string) {
+	configErr := viper.ReadInConfig()
+	if reloadCertAPI == "" {
+		reloadCertAPI = viper.GetString("trafficStats.listen")
+	}
+	if !cmd.Flags().Changed("secret") {
+		reloadCertSecret = viper.GetString("trafficStats.secret")
+	}
+	if reloadCertAPI == "" {
+		if configErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: cannot read the config (%v), use -c or --api.\n", configErr)
+		} else {
+			fmt.Fprintln(os.Stderr, "Error: the traffic stats API is off, set trafficStats.listen in the config or send the server SIGHUP.")
+		}
+		os.Exit(1)
+	}
+	client, err := newStatsAPIClient(reloadCertAPI, reloadCertSecret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid API address '%s': %v\n", reloadCertAPI, err)
+		os.Exit(1)
+	}
+	result, err := client.ReloadCert()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s Certificate not reloaded, the old one stays in use: %v\n", checkFail, err)
+		os.Exit(1)
+	}
+	if result.OldSHA256 == result.SHA256 {
+		fmt.Printf("%s Certificate reloaded, it's the same as before (SHA-256 %s).\n", checkOK, result.SHA256)
+		return
+	}
+	fmt.Printf("%s Certificate reloaded, valid until %s for %v.\n", checkOK, result.NotAfter.Local().Format(time.DateTime), result.DNSNames)
+	fmt.Printf("  Old SHA-256: %s\n", result.OldSHA256)
+	fmt.Printf("  New SHA-256: %s\n", result.SHA256)
+	fmt.Println("  Clients that pin the certificate (pinSHA256) need the new hash.")
+}
+
+// certSHA256 is the hex SHA-256 of the leaf certificate, the same as the
+// clients' tls.pinSHA256.
+func certSHA256(cert *tls.Certificate) string {
+	if cert == nil || len(cert.Certificate) == 0 {
+		return ""
+	}
+	hash := sha256.Sum256(cert.Certificate[0])
+	return hex.EncodeToString(hash[:])
+}
+
+// reloadCertificate swaps in the certificate files of loader and logs the
+// old and new fingerprints. source says who asked, for the audit log.
+func reloadCertificate(loader *utils.LocalCertificateLoader, source string) (certReloadResult, error) {
+	old, cert, err := loader.Reload()
+	auditLog.Record("reload-cert", loader.CertFile, source, err == nil)
+	if err != nil {
+		logger.Error("failed to reload TLS certificate, keeping the current one", zap.String("cert", loader.CertFile),
+			zap.String("source", source), zap.Error(err))
+		return certReloadResult{}, err
+	}
+	result := certReloadResult{
+		OldSHA256: certSHA256(old),
+		SHA256:    certSHA256(cert),
+		DNSNames:  cert.Leaf.DNSNames,
+		NotAfter:  cert.Leaf.NotAfter,
+	}
+	logger.Info("TLS certificate reloaded", zap.String("cert", loader.CertFile), zap.String("source", source),
+		zap.String("oldSHA256", result.OldSHA256), zap.String("sha256", result.SHA256),
+		zap.Strings("dnsNames", result.DNSNames), zap.Time("notAfter", result.NotAfter))
+	return result, nil
+}
+
+// reloadCertificateOnSignal reloads the certificate whenever the process
+// receives SIGHUP. SIGHUP is never delivered on Windows.
+func reloadCertificateOnSignal(loader *utils.LocalCertificateLoader) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	for range sigChan {
+		_, _ = reloadCertificate(loader, "signal SIGHUP")
+	}
+}
+
+// certReloadHandler serves POST /reload-cert on the traffic stats API.
+// loader is nil when the server gets its certificates from ACME.
+func certReloadHandler(next http.Handler, secret string, loader *utils.LocalCertificateLoader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/reload-cert" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if secret != "" && r.Header.Get("Authorization") != secret {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if loader == nil {
+			http.Error(w, "the server has no tls.cert, ACME certificates renew by themselves", http.StatusConflict)
+			return
+		}
+		result, err := reloadCertificate(loader, "traffic stats API "+r.RemoteAddr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
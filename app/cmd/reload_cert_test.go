@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+func TestCertReloadHandler(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert"), filepath.Join(dir, "key")
+	assert.NoError(t, utils.GenerateSelfSignedCert([]string{"example.com"}, certFile, keyFile))
+	loader := &utils.LocalCertificateLoader{CertFile: certFile, KeyFile: keyFile}
+	assert.NoError(t, loader.InitializeCache())
+	old, _, err := loader.Reload()
+	assert.NoError(t, err)
+
+	srv := httptest.NewServer(certReloadHandler(http.NotFoundHandler(), "stats_secret", loader))
+	defer srv.Close()
+	client, err := newStatsAPIClient(srv.Listener.Addr().String(), "stats_secret")
+	assert.NoError(t, err)
+
+	assert.NoError(t, utils.GenerateSelfSignedCert([]string{"2.example.com"}, certFile, keyFile))
+	result, err := client.ReloadCert()
+	assert.NoError(t, err)
+	assert.Equal(t, certSHA256(old), result.OldSHA256)
+	assert.NotEqual(t, result.OldSHA256, result.SHA256)
+	assert.Equal(t, []string{"2.example.com"}, result.DNSNames)
+
+	// A broken pair is rejected with the reason
+	assert.NoError(t, os.WriteFile(keyFile, []byte("not a key"), 0o600))
+	_, err = client.ReloadCert()
+	assert.ErrorContains(t, err, "HTTP status 422")
+
+	client.Secret = "wrong"
+	_, err = client.ReloadCert()
+	assert.ErrorContains(t, err, "HTTP status 401")
+
+	// ACME, nothing to reload
+	acmeSrv := httptest.NewServer(certReloadHandler(http.NotFoundHandler(), "", nil))
+	defer acmeSrv.Close()
+	client, err = newStatsAPIClient(acmeSrv.Listener.Addr().String(), "")
+	assert.NoError(t, err)
+	_, err = client.ReloadCert()
+	assert.ErrorContains(t, err, "ACME")
+}
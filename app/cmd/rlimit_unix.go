@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd
+// +build linux darwin freebsd openbsd netbsd
+
+package cmd
+
+import "golang.org/x/sys/unix"
+
+// fileDescriptorLimit returns the soft and hard RLIMIT_NOFILE of this
+// process. Unlimited is a huge value, its type differs between systems.
+func fileDescriptorLimit() (soft, hard uint64, err error) {
+	var rlim unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rlim); err != nil {
+		return 0, 0, err
+	}
+	return uint64(rlim.Cur), uint64(rlim.Max), nil
+}
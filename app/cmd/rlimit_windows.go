@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import "errors"
+
+// fileDescriptorLimit is not supported on Windows, which has no per-process
+// limit on open handles to speak of.
+func fileDescriptorLimit() (soft, hard uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
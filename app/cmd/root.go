@@ -5,6 +5,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,8 +25,12 @@ const (
 
 	appLogLevelEnv           = "HYSTERIA_LOG_LEVEL"
 	appLogFormatEnv          = "HYSTERIA_LOG_FORMAT"
+	appLogUTCEnv             = "HYSTERIA_LOG_UTC"
 	appDisableUpdateCheckEnv = "HYSTERIA_DISABLE_UPDATE_CHECK"
 	appACMEDirEnv            = "HYSTERIA_ACME_DIR"
+	appUpdateURLEnv          = "HYSTERIA_UPDATE_URL"
+	appUpdatePublicKeyEnv    = "HYSTERIA_UPDATE_PUBLIC_KEY"
+	appSecretKeyEnv          = "HYSTERIA_SECRET_KEY"
 )
 
 var (
@@ -53,11 +59,17 @@ var (
 
 var logger *zap.Logger
 
+// logUTC makes the console log format print times in UTC instead of the
+// local time zone. Set by --log-utc, or by log.utc in the server config
+// once it has been read, after the logger is built.
+var logUTC atomic.Bool
+
 // Flags
 var (
 	cfgFile            string
 	logLevel           string
 	logFormat          string
+	logUTCFlag         bool
 	disableUpdateCheck bool
 )
 
@@ -83,7 +95,7 @@ var logFormatMap = map[string]zapcore.EncoderConfig{
 		MessageKey:     "msg",
 		LineEnding:     zapcore.DefaultLineEnding,
 		EncodeLevel:    zapcore.CapitalColorLevelEncoder,
-		EncodeTime:     zapcore.RFC3339TimeEncoder,
+		EncodeTime:     logTimeEncoder,
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 	},
 	"json": {
@@ -98,6 +110,13 @@ var logFormatMap = map[string]zapcore.EncoderConfig{
 	},
 }
 
+func logTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	if logUTC.Load() {
+		t = t.UTC()
+	}
+	zapcore.RFC3339TimeEncoder(t, enc)
+}
+
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
@@ -116,6 +135,7 @@ func initFlags() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file")
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", envOrDefaultString(appLogLevelEnv, "info"), "log level")
 	rootCmd.PersistentFlags().StringVarP(&logFormat, "log-format", "f", envOrDefaultString(appLogFormatEnv, "console"), "log format")
+	rootCmd.PersistentFlags().BoolVar(&logUTCFlag, "log-utc", envOrDefaultBool(appLogUTCEnv, false), "log times in UTC instead of the local time zone")
 	rootCmd.PersistentFlags().BoolVar(&disableUpdateCheck, "disable-update-check", envOrDefaultBool(appDisableUpdateCheckEnv, false), "disable update check")
 }
 
@@ -143,6 +163,7 @@ func initLogger() {
 		fmt.Printf("unsupported log format: %s\n", logFormat)
 		os.Exit(1)
 	}
+	logUTC.Store(logUTCFlag)
 	c := zap.Config{
 		Level:             zap.NewAtomicLevelAt(level),
 		DisableCaller:     true,
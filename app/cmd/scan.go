@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+const (
+	scanMaxPorts    = 1024
+	scanConcurrency = 16
+)
+
+var (
+	scanServer  string
+	scanPorts   string
+	scanCount   int
+	scanTimeout time.Duration
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Probe which UDP ports of a server are reachable from this network",
+	Long: `Send QUIC probes to each port and report which ones answer. Run it from the
+network your users are on (e.g. a phone hotspot) to pick a port their ISP
+does not block or throttle. Nothing is authenticated, the probe only triggers
+QUIC version negotiation, so it works against any Hysteria 2 server.
+
+Servers with obfs enabled never answer probes and will show as blocked.
+
+Examples:
+  libyalink scan --server 1.2.3.4 --ports 443,8443,20000-20010
+  libyalink scan --server example.com --ports 443 --count 10`,
+	Run: runScan,
+}
+
+func init() {
+	initScanFlags()
+	rootCmd.AddCommand(scanCmd)
+}
+
+func initScanFlags() {
+	scanCmd.Flags().StringVar(&scanServer, "server", "", "server IP address or hostname (required)")
+	scanCmd.Flags().StringVar(&scanPorts, "ports", "443", "ports to probe, e.g. 443,8443,20000-20010")
+	scanCmd.Flags().IntVar(&scanCount, "count", 5, "probes per port")
+	scanCmd.Flags().DurationVar(&scanTimeout, "timeout", 2*time.Second, "time to wait for each probe")
+
+	scanCmd.MarkFlagRequired("server")
+}
+
+type scanResult struct {
+	Port    int
+	Replies int
+	AvgRTT  time.Duration
+	Err     error // Last error, if any
+}
+
+func (r scanResult) Status() string {
+	switch {
+	case r.Replies == scanCount:
+		return checkOK + " reachable"
+	case r.Replies > 0:
+		return checkWarn + " lossy/throttled"
+	default:
+		return checkFail + " blocked"
+	}
+}
+
+func runScan(cmd *cobra.Command, args []string) {
+	ports, err := parsePortList(scanPorts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --ports: %v\n", err)
+		os.Exit(1)
+	}
+	if scanCount < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --count must be at least 1.")
+		os.Exit(1)
+	}
+
+	fmt.Printf("  Probing %d UDP port(s) on %s, %d probe(s) each...\n\n", len(ports), scanServer, scanCount)
+
+	results := make([]scanResult, len(ports))
+	sem := make(chan struct{}, scanConcurrency)
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i, port int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = scanPort(net.JoinHostPort(scanServer, strconv.Itoa(port)), port)
+		}(i, port)
+	}
+	wg.Wait()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  PORT\tSTATUS\tREPLIES\tAVG RTT")
+	reachable := 0
+	for _, r := range results {
+		rtt := "-"
+		if r.Replies > 0 {
+			rtt = r.AvgRTT.Round(time.Millisecond).String()
+		}
+		if r.Replies == scanCount {
+			reachable++
+		}
+		fmt.Fprintf(w, "  %d\t%s\t%d/%d\t%s\n", r.Port, r.Status(), r.Replies, scanCount, rtt)
+	}
+	_ = w.Flush()
+	fmt.Println()
+	fmt.Printf("  %d of %d port(s) fully reachable.\n", reachable, len(ports))
+	if reachable == 0 {
+		for _, r := range results {
+			if r.Err != nil && !isTimeout(r.Err) {
+				fmt.Printf("  Last error on port %d: %v\n", r.Port, r.Err)
+				break
+			}
+		}
+	}
+}
+
+func scanPort(addr string, port int) scanResult {
+	r := scanResult{Port: port}
+	var total time.Duration
+	for i := 0; i < scanCount; i++ {
+		rtt, err := utils.ProbeQUIC(addr, scanTimeout)
+		if err != nil {
+			r.Err = err
+			continue
+		}
+		r.Replies++
+		total += rtt
+	}
+	if r.Replies > 0 {
+		r.AvgRTT = total / time.Duration(r.Replies)
+	}
+	return r
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parsePortList parses a comma-separated list of ports and port ranges,
+// e.g. "443,8443,20000-20010", into a list of unique ports.
+func parsePortList(s string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last := part, part
+		if a, b, ok := strings.Cut(part, "-"); ok {
+			first, last = a, b
+		}
+		start, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parsePort(last)
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid range %s", part)
+		}
+		for p := start; p <= end; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+			if len(ports) > scanMaxPorts {
+				return nil, fmt.Errorf("too many ports, at most %d", scanMaxPorts)
+			}
+		}
+	}
+	if len(ports) == 0 {
+		return nil, errors.New("no ports")
+	}
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	p, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || p < 1 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %s", s)
+	}
+	return p, nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortList(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []int
+		wantErr bool
+	}{
+		{"single", "443", []int{443}, false},
+		{"list and range", "443, 8443,20000-20002", []int{443, 8443, 20000, 20001, 20002}, false},
+		{"duplicates", "443,443,442-443", []int{443, 442}, false},
+		{"reversed range", "20-10", nil, true},
+		{"out of range", "0,70000", nil, true},
+		{"garbage", "https", nil, true},
+		{"empty", "", nil, true},
+		{"too many", "1-2000", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePortList(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
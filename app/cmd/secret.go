@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+// resolveSecret returns a secret that can be given directly (value), read
+// from a file or from an environment variable. At most one of the three
+// may be set. Any of them can hold a value encrypted with encrypt-secret,
+// which is decrypted with the key in HYSTERIA_SECRET_KEY. field is the
+// config field of value, e.g. "auth.password", the file and env fields
+// are named after it.
+func resolveSecret(field, value, file, env string) (string, error) {
+	n := 0
+	for _, s := range []string{value, file, env} {
+		if s != "" {
+			n++
+		}
+	}
+	if n > 1 {
+		return "", configError{Field: field, Err: fmt.Errorf("only one of %s, %sFile and %sEnv can be set", field, field, field)}
+	}
+
+	switch {
+	case file != "":
+		bs, err := os.ReadFile(file)
+		if err != nil {
+			return "", configError{Field: field + "File", Err: err}
+		}
+		// Editors and "echo" leave a trailing newline
+		value = strings.TrimRight(string(bs), "\r\n")
+		field += "File"
+	case env != "":
+		var ok bool
+		value, ok = os.LookupEnv(env)
+		if !ok {
+			return "", configError{Field: field + "Env", Err: fmt.Errorf("environment variable %s is not set", env)}
+		}
+		field += "Env"
+	}
+
+	if utils.IsEncryptedSecret(value) {
+		keyStr := os.Getenv(appSecretKeyEnv)
+		if keyStr == "" {
+			return "", configError{Field: field, Err: fmt.Errorf("value is encrypted but %s is not set", appSecretKeyEnv)}
+		}
+		key, err := utils.ParseSecretKey(keyStr)
+		if err != nil {
+			return "", configError{Field: field, Err: fmt.Errorf("%s: %w", appSecretKeyEnv, err)}
+		}
+		value, err = utils.DecryptSecret(key, value)
+		if err != nil {
+			return "", configError{Field: field, Err: err}
+		}
+	}
+	if value == "" {
+		return "", configError{Field: field, Err: errors.New("empty secret")}
+	}
+	return value, nil
+}
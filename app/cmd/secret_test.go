@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+func TestResolveSecret(t *testing.T) {
+	keyStr, err := utils.GenerateSecretKey()
+	assert.NoError(t, err)
+	key, err := utils.ParseSecretKey(keyStr)
+	assert.NoError(t, err)
+	enc, err := utils.EncryptSecret(key, "weak_ahh_password")
+	assert.NoError(t, err)
+	t.Setenv(appSecretKeyEnv, keyStr)
+
+	file := filepath.Join(t.TempDir(), "password")
+	assert.NoError(t, os.WriteFile(file, []byte("weak_ahh_password\n"), 0o600))
+	t.Setenv("LIBYALINK_TEST_PASSWORD", "weak_ahh_password")
+
+	tests := []struct {
+		name             string
+		value, file, env string
+		wantErr          bool
+	}{
+		{name: "value", value: "weak_ahh_password"},
+		{name: "file", file: file},
+		{name: "env", env: "LIBYALINK_TEST_PASSWORD"},
+		{name: "encrypted", value: enc},
+		{name: "both", value: "weak_ahh_password", file: file, wantErr: true},
+		{name: "missing file", file: file + ".nope", wantErr: true},
+		{name: "unset env", env: "LIBYALINK_TEST_NOPE", wantErr: true},
+		{name: "bad ciphertext", value: "enc:bm9wZQ==", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSecret("auth.password", tt.value, tt.file, tt.env)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "weak_ahh_password", got)
+		})
+	}
+}
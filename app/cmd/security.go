@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+type serverConfigSecurity struct {
+	Strict bool `mapstructure:"strict"`
+}
+
+// strictViolations returns every setting that weakens authentication or
+// lets traffic fall back to plaintext or unverified TLS. In strict mode
+// the server refuses to start if there are any.
+func (c *serverConfig) strictViolations() []configError {
+	var errs []configError
+	if c.Auth.Type == "" {
+		errs = append(errs, configError{Field: "auth.type", Err: errors.New("auth must be configured")})
+	}
+	if strings.EqualFold(c.Auth.Type, "http") || strings.EqualFold(c.Auth.Type, "https") {
+		if u, err := url.Parse(c.Auth.HTTP.URL); err == nil && u.Scheme != "https" {
+			errs = append(errs, configError{Field: "auth.http.url", Err: errors.New("auth requests must use https")})
+		}
+		if c.Auth.HTTP.Insecure {
+			errs = append(errs, configError{Field: "auth.http.insecure", Err: errors.New("TLS verification must not be disabled")})
+		}
+	}
+	if c.Resolver.TLS.Insecure {
+		errs = append(errs, configError{Field: "resolver.tls.insecure", Err: errors.New("TLS verification must not be disabled")})
+	}
+	if c.Resolver.HTTPS.Insecure {
+		errs = append(errs, configError{Field: "resolver.https.insecure", Err: errors.New("TLS verification must not be disabled")})
+	}
+	for _, o := range c.Outbounds {
+		if o.HTTP.Insecure {
+			errs = append(errs, configError{Field: "outbounds[" + o.Name + "].http.insecure", Err: errors.New("TLS verification must not be disabled")})
+		}
+		if strings.EqualFold(o.Type, "http") && strings.HasPrefix(strings.ToLower(o.HTTP.URL), "http://") {
+			errs = append(errs, configError{Field: "outbounds[" + o.Name + "].http.url", Err: errors.New("proxy must use https")})
+		}
+	}
+	if c.Masquerade.Proxy.Insecure {
+		errs = append(errs, configError{Field: "masquerade.proxy.insecure", Err: errors.New("TLS verification must not be disabled")})
+	}
+	if c.Masquerade.ListenHTTP != "" && !c.Masquerade.ForceHTTPS {
+		errs = append(errs, configError{Field: "masquerade.forceHTTPS", Err: errors.New("the HTTP listener must redirect to HTTPS")})
+	}
+	if c.TrafficStats.Listen != "" && c.TrafficStats.Secret == "" {
+		errs = append(errs, configError{Field: "trafficStats.secret", Err: errors.New("the traffic stats API must require a secret")})
+	}
+	if strings.EqualFold(c.Auth.Type, "userpass") {
+		for _, users := range duplicateUserPasswords(c.Auth.UserPass) {
+			errs = append(errs, configError{Field: "auth.userpass", Err: fmt.Errorf("users %s share a password", strings.Join(users, ", "))})
+		}
+	}
+	return errs
+}
+
+// duplicateUserPasswords returns the groups of users with the same
+// password, sorted. Any of them can log in as the others by changing the
+// user name, so their stats and kicks can't be relied on.
+func duplicateUserPasswords(users map[string]string) [][]string {
+	byPassword := make(map[string][]string)
+	for user, pass := range users {
+		byPassword[pass] = append(byPassword[pass], strings.ToLower(user))
+	}
+	var groups [][]string
+	for _, group := range byPassword {
+		if len(group) > 1 {
+			slices.Sort(group)
+			groups = append(groups, group)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []string) int { return strings.Compare(a[0], b[0]) })
+	return groups
+}
+
+// fillStrictSecurity must be called after fillAuthenticator, as it wraps
+// the final authenticator.
+func (c *serverConfig) fillStrictSecurity(hyConfig *server.Config) error {
+	if !c.Security.Strict {
+		return nil
+	}
+	if errs := c.strictViolations(); len(errs) > 0 {
+		for _, err := range errs[1:] {
+			logger.Error("strict mode violation", zap.String("field", err.Field), zap.Error(err.Err))
+		}
+		return errs[0]
+	}
+	hyConfig.Authenticator = &strictAuthenticator{Authenticator: hyConfig.Authenticator}
+	logger.Info("strict security mode enabled")
+	return nil
+}
+
+// strictAuthenticator fails closed: empty credentials and successful
+// logins without a user ID are rejected, whatever the authenticator
+// behind it says.
+type strictAuthenticator struct {
+	Authenticator server.Authenticator
+}
+
+func (a *strictAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	if auth == "" || a.Authenticator == nil {
+		return false, ""
+	}
+	ok, id = a.Authenticator.Authenticate(addr, auth, tx)
+	if ok && id == "" {
+		logger.Warn("strict mode rejected a login without a user ID", zap.String("addr", addr.String()))
+		return false, ""
+	}
+	return ok, id
+}
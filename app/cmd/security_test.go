@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicateUserPasswords(t *testing.T) {
+	users := map[string]string{
+		"carol": "p1",
+		"alice": "p1",
+		"bob":   "p2",
+		"dave":  "p2",
+		"erin":  "p3",
+		"frank": "p1",
+	}
+	assert.Equal(t, [][]string{{"alice", "carol", "frank"}, {"bob", "dave"}}, duplicateUserPasswords(users))
+	assert.Empty(t, duplicateUserPasswords(map[string]string{"alice": "p1", "bob": "p2"}))
+
+	config := serverConfig{Auth: serverConfigAuth{Type: "userpass", UserPass: users}}
+	violations := config.strictViolations()
+	if assert.Len(t, violations, 2) {
+		assert.Equal(t, "auth.userpass", violations[0].Field)
+		assert.EqualError(t, violations[0].Err, "users alice, carol, frank share a password")
+	}
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+var (
+	selfUpdateURL           string
+	selfUpdatePublicKey     string
+	selfUpdateAllowUnsigned bool
+	selfUpdateYes           bool
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update the binary to the latest release",
+	Long: `Check the latest release published at the update URL, download the binary
+for this platform, verify its checksum and signature, and replace the running
+binary. The previous binary is kept with a .bak suffix. Releases older than the
+running version are refused.
+
+The update URL must serve a JSON manifest:
+  {"version": "v2.6.1", "assets": {"linux/amd64": {"url": "...", "sha256": "...", "signature": "..."}}}
+
+The signature is a base64 ed25519 signature of the binary, checked against
+--public-key. Without a public key the update is refused, unless
+--allow-unsigned is given to trust the checksum in the manifest alone.`,
+	Run: runSelfUpdate,
+}
+
+func init() {
+	initSelfUpdateFlags()
+	rootCmd.AddCommand(selfUpdateCmd)
+}
+
+func initSelfUpdateFlags() {
+	selfUpdateCmd.Flags().StringVar(&selfUpdateURL, "url", envOrDefaultString(appUpdateURLEnv, ""), "release manifest URL")
+	selfUpdateCmd.Flags().StringVar(&selfUpdatePublicKey, "public-key", envOrDefaultString(appUpdatePublicKeyEnv, ""), "base64 ed25519 public key to verify release signatures")
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateAllowUnsigned, "allow-unsigned", false, "install releases without verifying their signature")
+	selfUpdateCmd.Flags().BoolVarP(&selfUpdateYes, "yes", "y", false, "do not ask for confirmation")
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) {
+	if selfUpdateURL == "" {
+		fmt.Fprintf(os.Stderr, "Error: no update URL. Use --url or set %s.\n", appUpdateURLEnv)
+		os.Exit(1)
+	}
+	var pubKey ed25519.PublicKey
+	if selfUpdatePublicKey != "" {
+		bs, err := base64.StdEncoding.DecodeString(selfUpdatePublicKey)
+		if err != nil || len(bs) != ed25519.PublicKeySize {
+			fmt.Fprintln(os.Stderr, "Error: invalid public key, must be a base64 ed25519 public key.")
+			os.Exit(1)
+		}
+		pubKey = bs
+	} else if !selfUpdateAllowUnsigned {
+		fmt.Fprintf(os.Stderr, "Error: no public key to verify the release. Use --public-key or set %s, "+
+			"or --allow-unsigned to update without a signature.\n", appUpdatePublicKeyEnv)
+		os.Exit(1)
+	}
+
+	updater := utils.NewSelfUpdater(selfUpdateURL, pubKey)
+	updater.AllowUnsigned = selfUpdateAllowUnsigned
+	platform := selfUpdatePlatform()
+	manifest, asset, err := updater.CheckRelease(appVersion, platform)
+	if manifest == nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to fetch release manifest: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  Current version:   %s\n", appVersion)
+	fmt.Printf("  Available version: %s\n", manifest.Version)
+	fmt.Printf("  Platform:          %s\n", platform)
+	if errors.Is(err, utils.ErrUpToDate) {
+		fmt.Println("  ✅ Already up to date.")
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !selfUpdateYes {
+		fmt.Printf("Update to %s? [y/N] ", manifest.Version)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("  Update cancelled.")
+			return
+		}
+	}
+
+	exePath, err := os.Executable()
+	if err == nil {
+		exePath, err = filepath.EvalSymlinks(exePath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot locate the current binary: %v\n", err)
+		os.Exit(1)
+	}
+	backupPath, err := updater.Install(asset, exePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: update failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("  ✅ Updated %s to %s\n", exePath, manifest.Version)
+	fmt.Printf("  Previous binary saved as %s\n", backupPath)
+	fmt.Println("  Restart the service to run the new version (e.g. systemctl restart libyalink).")
+}
+
+// selfUpdatePlatform returns the asset key for this build, using the
+// build-injected platform/arch (e.g. "linux/amd64-avx") when available.
+func selfUpdatePlatform() string {
+	platform, arch := appPlatform, appArch
+	if platform == "Unknown" {
+		platform = runtime.GOOS
+	}
+	if arch == "Unknown" {
+		arch = runtime.GOARCH
+	}
+	return platform + "/" + arch
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	// selfTestID is the user the self-test connects as, in the logs and
+	// traffic stats.
+	selfTestID = "libyalink-selftest"
+
+	selfTestTimeout = 10 * time.Second
+)
+
+type serverConfigStartup struct {
+	SelfTest bool `mapstructure:"selfTest"`
+}
+
+// selfTestAuthenticator lets the self-test in with a random secret, whatever
+// the auth type, until the test is over.
+type selfTestAuthenticator struct {
+	Authenticator server.Authenticator
+	Secret        string
+	done          atomic.Bool
+}
+
+func newSelfTestAuthenticator(auth server.Authenticator) (*selfTestAuthenticator, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &selfTestAuthenticator{Authenticator: auth, Secret: hex.EncodeToString(secret)}, nil
+}
+
+func (a *selfTestAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	if !a.done.Load() && subtle.ConstantTimeCompare([]byte(auth), []byte(a.Secret)) == 1 {
+		return true, selfTestID
+	}
+	return a.Authenticator.Authenticate(addr, auth, tx)
+}
+
+// Done stops accepting the secret.
+func (a *selfTestAuthenticator) Done() {
+	a.done.Store(true)
+}
+
+// fillSelfTest must be called after fillStartupRamp and fillAuthGuard, so
+// that the self-test is neither held back nor counted as a failed login.
+func (c *serverConfig) fillSelfTest(hyConfig *server.Config) error {
+	if !c.Startup.SelfTest {
+		return nil
+	}
+	a, err := newSelfTestAuthenticator(hyConfig.Authenticator)
+	if err != nil {
+		return configError{Field: "startup.selfTest", Err: err}
+	}
+	hyConfig.Authenticator = a
+	c.selfTest = a
+	return nil
+}
+
+// selfTestServerAddr is the address the self-test connects to: the listen
+// address, or the loopback address if the server listens on all of them.
+func selfTestServerAddr(local net.Addr) (string, error) {
+	addr, ok := local.(*net.UDPAddr)
+	if !ok {
+		return "", fmt.Errorf("unsupported listen address %s", local)
+	}
+	ip := addr.IP
+	if ip == nil || ip.IsUnspecified() {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	return net.JoinHostPort(ip.String(), fmt.Sprint(addr.Port)), nil
+}
+
+// selfTestSNI is a name on the server's certificate, so that tls.sniGuard
+// lets the self-test through. Empty if it has none, e.g. an IP certificate.
+func (c *serverConfig) selfTestSNI() string {
+	var names []string
+	switch {
+	case c.ACME != nil:
+		names = c.ACME.Domains
+	case c.TLS != nil:
+		cert, err := tls.LoadX509KeyPair(c.TLS.Cert, c.TLS.Key)
+		if err != nil || len(cert.Certificate) == 0 {
+			return ""
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return ""
+		}
+		names = leaf.DNSNames
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	// Any name matches a wildcard
+	return strings.Replace(names[0], "*", "selftest", 1)
+}
+
+// runSelfTest connects to the server's own listener, authenticates and,
+// unless UDP is disabled, sends a datagram to a local echo server through
+// it. The secret is no longer accepted afterwards.
+func (c *serverConfig) runSelfTest(hyConfig *server.Config) error {
+	defer c.selfTest.Done()
+	addr, err := selfTestServerAddr(hyConfig.Conn.LocalAddr())
+	if err != nil {
+		return err
+	}
+	hc, info, err := c.selfTestConnect(addr, c.selfTest.Secret)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer hc.Close()
+	if hyConfig.DisableUDP || !info.UDPEnabled {
+		return nil
+	}
+	return selfTestUDPEcho(hc)
+}
+
+// selfTestConnect connects to the server at addr like a client with the
+// server's obfs settings would, and logs in with auth.
+func (c *serverConfig) selfTestConnect(addr, auth string) (client.Client, *client.HandshakeInfo, error) {
+	cc := clientConfig{
+		Server: addr,
+		Auth:   auth,
+		TLS: clientConfigTLS{
+			SNI: c.selfTestSNI(),
+			// The certificate is checked by doctor and /readyz, this
+			// tests the path through the listener
+			Insecure: true,
+		},
+		QUIC: clientConfigQUIC{
+			MaxIdleTimeout: selfTestTimeout,
+		},
+	}
+	if strings.ToLower(c.Obfs.Type) == "salamander" {
+		cc.Obfs.Type = "salamander"
+		cc.Obfs.Salamander.Password = c.Obfs.Salamander.Password
+	}
+	ccConfig, err := cc.Config()
+	if err != nil {
+		return nil, nil, err
+	}
+	return client.NewClient(ccConfig)
+}
+
+// selfTestUDPEcho sends a datagram through the server to an echo server on
+// the loopback address and checks that it comes back.
+func selfTestUDPEcho(hc client.Client) error {
+	echo, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := echo.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = echo.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	conn, err := hc.UDP()
+	if err != nil {
+		return fmt.Errorf("opening a UDP session: %w", err)
+	}
+	defer conn.Close()
+	payload := make([]byte, 32)
+	if _, err := rand.Read(payload); err != nil {
+		return err
+	}
+	if err := conn.Send(payload, echo.LocalAddr().String()); err != nil {
+		return fmt.Errorf("sending a datagram: %w", err)
+	}
+	received := make(chan []byte, 1)
+	go func() {
+		data, _, err := conn.Receive()
+		if err == nil {
+			received <- data
+		}
+	}()
+	select {
+	case data := <-received:
+		if !bytes.Equal(data, payload) {
+			return errors.New("the echoed datagram doesn't match what was sent")
+		}
+		return nil
+	case <-time.After(selfTestTimeout):
+		return fmt.Errorf("no UDP echo from %s through the server within %s, check that the ACL and outbounds allow UDP to 127.0.0.1",
+			echo.LocalAddr(), selfTestTimeout)
+	}
+}
+
+// selfTestAndReport runs the self-test and logs the result. health, if not
+// nil, only reports the server as healthy once the test passes.
+func (c *serverConfig) selfTestAndReport(hyConfig *server.Config, health *healthHandler) {
+	start := time.Now()
+	if err := c.runSelfTest(hyConfig); err != nil {
+		logger.Error("startup self-test failed, the server may not work for clients", zap.Error(err))
+		if health != nil {
+			logger.Error("health checks report the server as down until it's restarted and the self-test passes")
+		}
+		return
+	}
+	logger.Info("startup self-test passed", zap.Duration("elapsed", time.Since(start).Round(time.Millisecond)))
+	if health != nil {
+		health.SetServing(true)
+	}
+}
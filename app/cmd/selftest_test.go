@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTestAuthenticator(t *testing.T) {
+	a, err := newSelfTestAuthenticator(passwordAuthenticator("weak_ahh_password"))
+	assert.NoError(t, err)
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40001}
+
+	ok, id := a.Authenticate(addr, a.Secret, 0)
+	assert.True(t, ok)
+	assert.Equal(t, selfTestID, id)
+	ok, id = a.Authenticate(addr, "weak_ahh_password", 0)
+	assert.True(t, ok)
+	assert.Equal(t, "user", id)
+
+	// The secret only works until the test is over
+	a.Done()
+	ok, _ = a.Authenticate(addr, a.Secret, 0)
+	assert.False(t, ok)
+	ok, _ = a.Authenticate(addr, "weak_ahh_password", 0)
+	assert.True(t, ok)
+}
+
+func TestSelfTestServerAddr(t *testing.T) {
+	tests := []struct {
+		local *net.UDPAddr
+		want  string
+	}{
+		{&net.UDPAddr{IP: net.IPv6unspecified, Port: 443}, "127.0.0.1:443"},
+		{&net.UDPAddr{IP: net.IPv4zero, Port: 8443}, "127.0.0.1:8443"},
+		{&net.UDPAddr{Port: 443}, "127.0.0.1:443"},
+		{&net.UDPAddr{IP: net.ParseIP("192.0.2.10"), Port: 443}, "192.0.2.10:443"},
+		{&net.UDPAddr{IP: net.ParseIP("2001:db8::10"), Port: 443}, "[2001:db8::10]:443"},
+	}
+	for _, tt := range tests {
+		got, err := selfTestServerAddr(tt.local)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+	_, err := selfTestServerAddr(&net.TCPAddr{Port: 443})
+	assert.Error(t, err)
+}
+
+func TestSelfTestSNI(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"*.example.com", "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	c := serverConfig{TLS: &serverConfigTLS{Cert: certFile, Key: keyFile}}
+	assert.Equal(t, "selftest.example.com", c.selfTestSNI())
+
+	c = serverConfig{ACME: &serverConfigACME{Domains: []string{"vpn.example.com"}}}
+	assert.Equal(t, "vpn.example.com", c.selfTestSNI())
+
+	c = serverConfig{TLS: &serverConfigTLS{Cert: filepath.Join(dir, "missing.crt"), Key: keyFile}}
+	assert.Equal(t, "", c.selfTestSNI())
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"rsc.io/qr"
+)
+
+// serveUIMaxRequest is the most a form submission can be, it's a few
+// short fields.
+const serveUIMaxRequest = 16 << 10
+
+var (
+	serveUIListen      string
+	serveUIAllowRemote bool
+)
+
+//go:embed serve_ui.html
+var serveUIPage []byte
+
+var serveUICmd = &cobra.Command{
+	Use:   "serve-ui",
+	Short: "Serve a web form that generates client configs",
+	Long: `Serve a page with a form for the server address, password and preset that
+generates the same client configs as gen-client: the sing-box JSON, the native
+config, the share link and its QR code, shown in the browser.
+
+The configs hold the password, so the form is only served on the loopback
+address unless --allow-remote is given, and nothing is stored.
+
+Examples:
+  libyalink serve-ui
+  libyalink serve-ui --listen 127.0.0.1:9000`,
+	Run: runServeUI,
+}
+
+func init() {
+	initServeUIFlags()
+	rootCmd.AddCommand(serveUICmd)
+}
+
+func initServeUIFlags() {
+	serveUICmd.Flags().StringVar(&serveUIListen, "listen", "127.0.0.1:8088", "address to serve the form on")
+	serveUICmd.Flags().BoolVar(&serveUIAllowRemote, "allow-remote", false, "allow a --listen address other than loopback, the configs are then sent unencrypted over the network")
+}
+
+// serveUIRequest is the form, the fields are those of gen-client.
+type serveUIRequest struct {
+	Server   string `json:"server"`
+	Port     int    `json:"port"`
+	Auth     string `json:"auth"`
+	SNI      string `json:"sni"`
+	Insecure bool   `json:"insecure"`
+	Obfs     string `json:"obfs"`
+	Preset   string `json:"preset"`
+	Profile  string `json:"profile"`
+	ISP      string `json:"isp"`
+}
+
+type serveUIResponse struct {
+	SingBox  string   `json:"singbox,omitempty"`
+	Native   string   `json:"native,omitempty"`
+	URI      string   `json:"uri,omitempty"`
+	QR       string   `json:"qr,omitempty"` // data: URL of a PNG
+	Warnings []string `json:"warnings,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func runServeUI(cmd *cobra.Command, args []string) {
+	host, _, err := net.SplitHostPort(serveUIListen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --listen '%s': %v\n", serveUIListen, err)
+		os.Exit(1)
+	}
+	if !isLoopbackHost(host) && !serveUIAllowRemote {
+		fmt.Fprintf(os.Stderr, "Error: --listen %s isn't a loopback address. The form sends passwords in the clear, "+
+			"use --allow-remote if that's really what you want.\n", serveUIListen)
+		os.Exit(1)
+	}
+	ln, err := net.Listen("tcp", serveUIListen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Open http://%s in a browser, press Ctrl+C to stop.\n", ln.Addr())
+	if err := http.Serve(ln, newServeUIHandler(!serveUIAllowRemote)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newServeUIHandler serves the page at / and generates the configs at
+// /generate. loopbackOnly rejects requests for any host name but a
+// loopback one, so that a web page can't reach the form through DNS
+// rebinding.
+func newServeUIHandler(loopbackOnly bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(serveUIPage)
+	})
+	mux.HandleFunc("/generate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req serveUIRequest
+		var resp serveUIResponse
+		status := http.StatusOK
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, serveUIMaxRequest))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&req); err != nil {
+			resp.Error, status = "invalid request: "+err.Error(), http.StatusBadRequest
+		} else if resp, err = generateUIConfigs(req); err != nil {
+			resp.Error, status = err.Error(), http.StatusUnprocessableEntity
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if loopbackOnly && !isLoopbackHost(host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// generateUIConfigs does what gen-client does with the same flags, with
+// gen-client's defaults for the rest.
+func generateUIConfigs(req serveUIRequest) (serveUIResponse, error) {
+	var resp serveUIResponse
+	req.Server = strings.TrimSpace(req.Server)
+	if req.Server == "" {
+		return resp, errors.New("enter the server's IP address or host name")
+	}
+	if _, _, err := net.SplitHostPort(req.Server); err == nil {
+		return resp, errors.New("enter the server without a port, the port has its own field")
+	}
+	// IPv6 addresses can come in brackets, as in a URL
+	host := req.Server
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if err := validateServerHost(host); err != nil {
+		return resp, fmt.Errorf("invalid server '%s': %w", req.Server, err)
+	}
+	if req.Port == 0 {
+		req.Port = 443
+	}
+	if req.Port < 1 || req.Port > 65535 {
+		return resp, errors.New("the port must be between 1 and 65535")
+	}
+	if req.Auth == "" {
+		return resp, errors.New("enter the password")
+	}
+	if req.Preset == "" {
+		req.Preset = "4g"
+	}
+	preset, ok := bandwidthPresets[req.Preset]
+	if !ok {
+		return resp, fmt.Errorf("unknown preset '%s'. %s", req.Preset, unknownPresetHint(bandwidthPresets))
+	}
+	if err := validateProfile(req.Profile); err != nil {
+		return resp, err
+	}
+	if err := validateISP(req.ISP); err != nil {
+		return resp, err
+	}
+
+	params := genClientParams{
+		Server:       host,
+		Port:         req.Port,
+		Auth:         req.Auth,
+		SNI:          req.SNI,
+		Insecure:     req.Insecure,
+		Obfs:         req.Obfs,
+		PresetName:   req.Preset,
+		Preset:       preset,
+		Profile:      req.Profile,
+		KeepAlive:    genClientKeepAliveDefault,
+		NativeFormat: "yaml",
+		Indent:       defaultOutputIndent,
+	}
+	if profile, ok := genClientProfiles[req.Profile]; ok {
+		noFlags := func(string) bool { return false }
+		applyGenClientProfile(noFlags, profile, &params.KeepAlive, &params.RecvWindow, &params.RecvConn)
+	}
+	configs, err := generateClientConfigs(params)
+	if err != nil {
+		return resp, err
+	}
+	uri := genClientShareURI(configs.Data)
+	code, err := qr.Encode(uri, qr.L)
+	if err != nil {
+		return resp, err
+	}
+	return serveUIResponse{
+		SingBox:  string(configs.SingBox),
+		Native:   string(configs.Native),
+		URI:      uri,
+		QR:       "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()),
+		Warnings: portWarnings(req.Port, req.ISP),
+	}, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateUIConfigs(t *testing.T) {
+	resp, err := generateUIConfigs(serveUIRequest{Server: "1.2.3.4", Auth: "pass", Insecure: true, ISP: "ltt", Port: 500})
+	assert.NoError(t, err)
+	assert.Equal(t, "hysteria2://pass@1.2.3.4:500/?insecure=1&sni=1.2.3.4", resp.URI)
+	assert.Contains(t, resp.SingBox, `"down_mbps": 10`)
+	assert.Contains(t, resp.Native, "keepAlivePeriod: 15s")
+	assert.True(t, strings.HasPrefix(resp.QR, "data:image/png;base64,"))
+	assert.Len(t, resp.Warnings, 2)
+
+	resp, err = generateUIConfigs(serveUIRequest{Server: "example.com", Auth: "pass", Profile: "gaming"})
+	assert.NoError(t, err)
+	assert.Contains(t, resp.SingBox, `"down_mbps": 6`)
+	assert.Contains(t, resp.Native, "maxIdleTimeout: 10s")
+
+	for _, server := range []string{"2001:db8::1", "[2001:db8::1]"} {
+		resp, err = generateUIConfigs(serveUIRequest{Server: server, Auth: "pass"})
+		assert.NoError(t, err, server)
+		assert.Equal(t, "hysteria2://pass@[2001:db8::1]:443/", resp.URI, server)
+		assert.Contains(t, resp.Native, "server: '[2001:db8::1]:443'", server)
+	}
+
+	for _, req := range []serveUIRequest{
+		{Auth: "pass"},
+		{Server: "1.2.3.4:443", Auth: "pass"},
+		{Server: "[2001:db8::1]:443", Auth: "pass"},
+		{Server: "https://example.com", Auth: "pass"},
+		{Server: "exa mple.com", Auth: "pass"},
+		{Server: "1.2.3.4"},
+		{Server: "1.2.3.4", Auth: "pass", Port: 70000},
+		{Server: "1.2.3.4", Auth: "pass", Preset: "5g"},
+		{Server: "1.2.3.4", Auth: "pass", Profile: "streaming"},
+	} {
+		_, err := generateUIConfigs(req)
+		assert.Error(t, err, req)
+	}
+}
+
+func TestServeUIHandler(t *testing.T) {
+	h := newServeUIHandler(true)
+	do := func(method, host, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Host = host
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "127.0.0.1:8088", "/", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<form")
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "localhost:8088", "/x", "").Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "localhost:8088", "/generate", "").Code)
+	// DNS rebinding
+	assert.Equal(t, http.StatusForbidden, do(http.MethodGet, "evil.example.com:8088", "/", "").Code)
+
+	var resp serveUIResponse
+	rec = do(http.MethodPost, "[::1]:8088", "/generate", `{"server": "1.2.3.4", "auth": "pass"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.URI)
+
+	resp = serveUIResponse{}
+	rec = do(http.MethodPost, "127.0.0.1:8088", "/generate", `{"server": "1.2.3.4"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "enter the password", resp.Error)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "127.0.0.1:8088", "/generate", `{"password": "x"}`).Code)
+}
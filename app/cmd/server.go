@@ -12,8 +12,12 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/caddyserver/certmagic"
@@ -29,6 +33,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/apernet/hysteria/app/v2/internal/utils"
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
 	"github.com/apernet/hysteria/core/v2/server"
 	"github.com/apernet/hysteria/extras/v2/auth"
 	"github.com/apernet/hysteria/extras/v2/correctnet"
@@ -44,6 +49,11 @@ const (
 	defaultListenAddr = ":443"
 )
 
+var (
+	serverShowClient bool
+	serverPublicHost string
+)
+
 var serverCmd = &cobra.Command{
 	Use:   "server",
 	Short: "Server mode",
@@ -51,9 +61,15 @@ var serverCmd = &cobra.Command{
 }
 
 func init() {
+	initServerFlags()
 	rootCmd.AddCommand(serverCmd)
 }
 
+func initServerFlags() {
+	serverCmd.Flags().BoolVar(&serverShowClient, "show-client", false, "print the client share link and QR code of this config at startup")
+	serverCmd.Flags().StringVar(&serverPublicHost, "public-host", "", "with --show-client, the address clients connect to (default: the ACME domain or this machine's address)")
+}
+
 type serverConfig struct {
 	Listen                string                      `mapstructure:"listen"`
 	Obfs                  serverConfigObfs            `mapstructure:"obfs"`
@@ -72,6 +88,21 @@ type serverConfig struct {
 	Outbounds             []serverConfigOutboundEntry `mapstructure:"outbounds"`
 	TrafficStats          serverConfigTrafficStats    `mapstructure:"trafficStats"`
 	Masquerade            serverConfigMasquerade      `mapstructure:"masquerade"`
+	Failover              serverConfigFailover        `mapstructure:"failover"`
+	Debug                 serverConfigDebug           `mapstructure:"debug"`
+	Listeners             []serverConfigListener      `mapstructure:"listeners"`
+	Security              serverConfigSecurity        `mapstructure:"security"`
+	Audit                 serverConfigAudit           `mapstructure:"audit"`
+	Health                serverConfigHealth          `mapstructure:"health"`
+	Limits                serverConfigLimits          `mapstructure:"limits"`
+	Log                   serverConfigLog             `mapstructure:"log"`
+	Startup               serverConfigStartup         `mapstructure:"startup"`
+	Telemetry             serverConfigTelemetry       `mapstructure:"telemetry"`
+
+	certLoader *utils.LocalCertificateLoader // Set by fillTLSConfig for tls
+	authGuard  *authGuard                    // Set by fillAuthGuard
+	selfTest   *selfTestAuthenticator        // Set by fillSelfTest
+	quota      *quotaTracker                 // Set by fillQuota
 }
 
 type serverConfigObfsSalamander struct {
@@ -98,6 +129,9 @@ type serverConfigACME struct {
 	ListenHost string   `mapstructure:"listenHost"`
 	Dir        string   `mapstructure:"dir"`
 
+	// RenewBefore is how long before expiry to start renewing
+	RenewBefore time.Duration `mapstructure:"renewBefore"`
+
 	// Type selection
 	Type string               `mapstructure:"type"`
 	HTTP serverConfigACMEHTTP `mapstructure:"http"`
@@ -133,6 +167,11 @@ type serverConfigQUIC struct {
 	MaxIdleTimeout              time.Duration `mapstructure:"maxIdleTimeout"`
 	MaxIncomingStreams          int64         `mapstructure:"maxIncomingStreams"`
 	DisablePathMTUDiscovery     bool          `mapstructure:"disablePathMTUDiscovery"`
+	InitCongestionWindow        int           `mapstructure:"initCongestionWindow"`
+	KeepAlivePeriod             time.Duration `mapstructure:"keepAlivePeriod"`
+	CongestionControl           string        `mapstructure:"cc"`
+	MaxDatagramSize             int           `mapstructure:"maxDatagramSize"`
+	AdaptiveBandwidth           bool          `mapstructure:"adaptiveBandwidth"`
 }
 
 type serverConfigBandwidth struct {
@@ -140,17 +179,59 @@ type serverConfigBandwidth struct {
 	Down string `mapstructure:"down"`
 }
 
+// serverConfigLog sets where the server logs go, and whether their times
+// are in UTC rather than local time.
+type serverConfigLog struct {
+	UTC    bool                  `mapstructure:"utc"`
+	Output string                `mapstructure:"output"` // "stderr" (default) or "syslog"
+	Syslog serverConfigLogSyslog `mapstructure:"syslog"`
+}
+
+type serverConfigLogSyslog struct {
+	Facility string `mapstructure:"facility"`
+	Tag      string `mapstructure:"tag"`
+	// Network and Address are for a remote syslog server,
+	// e.g. "udp" and "logs.example.com:514"
+	Network string `mapstructure:"network"`
+	Address string `mapstructure:"address"`
+}
+
+type serverConfigLimits struct {
+	IdleTimeout    time.Duration              `mapstructure:"idleTimeout"`
+	AuthFailureBan serverConfigAuthFailureBan `mapstructure:"authFailureBan"`
+	MaxConnections int                        `mapstructure:"maxConnections"`
+	FallbackServer string                     `mapstructure:"fallbackServer"`
+	StartupRamp    serverConfigStartupRamp    `mapstructure:"startupRamp"`
+	Quota          serverConfigQuota          `mapstructure:"quota"`
+}
+
+// serverConfigListener is an additional port served with the same config
+// as the main listener, except for bandwidth. This lets one server apply
+// e.g. 4G limits on one port and fiber limits on another.
+type serverConfigListener struct {
+	Name      string                `mapstructure:"name"`
+	Listen    string                `mapstructure:"listen"`
+	Bandwidth serverConfigBandwidth `mapstructure:"bandwidth"`
+}
+
 type serverConfigAuthHTTP struct {
 	URL      string `mapstructure:"url"`
 	Insecure bool   `mapstructure:"insecure"`
 }
 
+type serverConfigAuthToken struct {
+	Secret string `mapstructure:"secret"`
+}
+
 type serverConfigAuth struct {
-	Type     string               `mapstructure:"type"`
-	Password string               `mapstructure:"password"`
-	UserPass map[string]string    `mapstructure:"userpass"`
-	HTTP     serverConfigAuthHTTP `mapstructure:"http"`
-	Command  string               `mapstructure:"command"`
+	Type         string                `mapstructure:"type"`
+	Password     string                `mapstructure:"password"`
+	PasswordFile string                `mapstructure:"passwordFile"`
+	PasswordEnv  string                `mapstructure:"passwordEnv"`
+	UserPass     map[string]string     `mapstructure:"userpass"`
+	HTTP         serverConfigAuthHTTP  `mapstructure:"http"`
+	Command      string                `mapstructure:"command"`
+	Token        serverConfigAuthToken `mapstructure:"token"`
 }
 
 type serverConfigResolverTCP struct {
@@ -245,6 +326,7 @@ type serverConfigMasqueradeProxy struct {
 
 type serverConfigMasqueradeString struct {
 	Content    string            `mapstructure:"content"`
+	File       string            `mapstructure:"file"`
 	Headers    map[string]string `mapstructure:"headers"`
 	StatusCode int               `mapstructure:"statusCode"`
 }
@@ -264,29 +346,40 @@ func (c *serverConfig) fillConn(hyConfig *server.Config) error {
 	if listenAddr == "" {
 		listenAddr = defaultListenAddr
 	}
+	conn, err := c.listenConn(listenAddr, "listen")
+	if err != nil {
+		return err
+	}
+	hyConfig.Conn = conn
+	return nil
+}
+
+// listenConn opens a UDP listener, with obfuscation if configured.
+// field is used in errors about the listen address.
+func (c *serverConfig) listenConn(listenAddr, field string) (net.PacketConn, error) {
 	uAddr, err := net.ResolveUDPAddr("udp", listenAddr)
 	if err != nil {
-		return configError{Field: "listen", Err: err}
+		return nil, configError{Field: field, Err: err}
 	}
 	conn, err := correctnet.ListenUDP("udp", uAddr)
 	if err != nil {
-		return configError{Field: "listen", Err: err}
+		return nil, configError{Field: field, Err: err}
 	}
 	// LibyaLink: Aggressively tune UDP buffers for Libyan network conditions
 	tuneUDPBuffer(conn, logger)
 	switch strings.ToLower(c.Obfs.Type) {
 	case "", "plain":
-		hyConfig.Conn = conn
-		return nil
+		return conn, nil
 	case "salamander":
 		ob, err := obfs.NewSalamanderObfuscator([]byte(c.Obfs.Salamander.Password))
 		if err != nil {
-			return configError{Field: "obfs.salamander.password", Err: err}
+			_ = conn.Close()
+			return nil, configError{Field: "obfs.salamander.password", Err: err}
 		}
-		hyConfig.Conn = obfs.WrapPacketConn(conn, ob)
-		return nil
+		return obfs.WrapPacketConn(conn, ob), nil
 	default:
-		return configError{Field: "obfs.type", Err: errors.New("unsupported obfuscation type")}
+		_ = conn.Close()
+		return nil, configError{Field: "obfs.type", Err: errors.New("unsupported obfuscation type")}
 	}
 }
 
@@ -337,6 +430,7 @@ func (c *serverConfig) fillTLSConfig(hyConfig *server.Config) error {
 		// Use GetCertificate instead of Certificates so that
 		// users can update the cert without restarting the server.
 		hyConfig.TLSConfig.GetCertificate = certLoader.GetCertificate
+		c.certLoader = certLoader
 		// Client CA
 		if c.TLS.ClientCA != "" {
 			ca, err := os.ReadFile(c.TLS.ClientCA)
@@ -359,10 +453,15 @@ func (c *serverConfig) fillTLSConfig(hyConfig *server.Config) error {
 			// user's home directory.
 			dataDir = envOrDefaultString(appACMEDirEnv, "acme")
 		}
+		renewalRatio, err := acmeRenewalWindowRatio(c.ACME.RenewBefore)
+		if err != nil {
+			return configError{Field: "acme.renewBefore", Err: err}
+		}
 		cmCfg := &certmagic.Config{
-			RenewalWindowRatio: certmagic.DefaultRenewalWindowRatio,
+			RenewalWindowRatio: renewalRatio,
 			KeySource:          certmagic.DefaultKeyGenerator,
 			Storage:            &certmagic.FileStorage{Path: dataDir},
+			OnEvent:            acmeOnEvent,
 			Logger:             logger,
 		}
 		cmIssuer := certmagic.NewACMEIssuer(cmCfg, certmagic.ACMEIssuer{
@@ -471,7 +570,7 @@ func (c *serverConfig) fillTLSConfig(hyConfig *server.Config) error {
 		if len(c.ACME.Domains) == 0 {
 			return configError{Field: "acme.domains", Err: errors.New("empty domains")}
 		}
-		err := cmCfg.ManageSync(context.Background(), c.ACME.Domains)
+		err = acmeManage(cmCfg, cmCache, c.ACME.Domains, renewalRatio)
 		if err != nil {
 			return configError{Field: "acme.domains", Err: err}
 		}
@@ -523,6 +622,17 @@ func genZeroSSLEAB(email string) (*acme.EAB, error) {
 }
 
 func (c *serverConfig) fillQUICConfig(hyConfig *server.Config) error {
+	var cc string
+	switch strings.ToLower(c.QUIC.CongestionControl) {
+	case "", "auto":
+		cc = server.CongestionControlAuto
+	case "bbr":
+		cc = server.CongestionControlBBR
+	case "brutal":
+		cc = server.CongestionControlBrutal
+	default:
+		return configError{Field: "quic.cc", Err: errors.New("unsupported congestion control, use auto, bbr or brutal")}
+	}
 	hyConfig.QUICConfig = server.QUICConfig{
 		InitialStreamReceiveWindow:     c.QUIC.InitStreamReceiveWindow,
 		MaxStreamReceiveWindow:         c.QUIC.MaxStreamReceiveWindow,
@@ -531,6 +641,11 @@ func (c *serverConfig) fillQUICConfig(hyConfig *server.Config) error {
 		MaxIdleTimeout:                 c.QUIC.MaxIdleTimeout,
 		MaxIncomingStreams:             c.QUIC.MaxIncomingStreams,
 		DisablePathMTUDiscovery:        c.QUIC.DisablePathMTUDiscovery,
+		InitialCongestionWindow:        c.QUIC.InitCongestionWindow,
+		KeepAlivePeriod:                c.QUIC.KeepAlivePeriod,
+		CongestionControl:              cc,
+		MaxDatagramSize:                c.QUIC.MaxDatagramSize,
+		AdaptiveBandwidth:              c.QUIC.AdaptiveBandwidth,
 	}
 	return nil
 }
@@ -725,20 +840,57 @@ func (c *serverConfig) fillOutboundConfig(hyConfig *server.Config) error {
 }
 
 func (c *serverConfig) fillBandwidthConfig(hyConfig *server.Config) error {
+	bw, err := parseServerBandwidth(c.Bandwidth, "bandwidth")
+	if err != nil {
+		return err
+	}
+	hyConfig.BandwidthConfig = bw
+	return nil
+}
+
+func parseServerBandwidth(bw serverConfigBandwidth, field string) (server.BandwidthConfig, error) {
+	var config server.BandwidthConfig
 	var err error
-	if c.Bandwidth.Up != "" {
-		hyConfig.BandwidthConfig.MaxTx, err = utils.ConvBandwidth(c.Bandwidth.Up)
+	if bw.Up != "" {
+		config.MaxTx, err = utils.ConvBandwidth(bw.Up)
 		if err != nil {
-			return configError{Field: "bandwidth.up", Err: err}
+			return config, configError{Field: field + ".up", Err: err}
 		}
 	}
-	if c.Bandwidth.Down != "" {
-		hyConfig.BandwidthConfig.MaxRx, err = utils.ConvBandwidth(c.Bandwidth.Down)
+	if bw.Down != "" {
+		config.MaxRx, err = utils.ConvBandwidth(bw.Down)
 		if err != nil {
-			return configError{Field: "bandwidth.down", Err: err}
+			return config, configError{Field: field + ".down", Err: err}
 		}
 	}
-	return nil
+	return config, nil
+}
+
+// ListenerConfigs returns a server config for each additional listener,
+// based on the main config. Must be called after Config().
+func (c *serverConfig) ListenerConfigs(base *server.Config) ([]*server.Config, error) {
+	configs := make([]*server.Config, 0, len(c.Listeners))
+	for i, l := range c.Listeners {
+		field := fmt.Sprintf("listeners[%d]", i)
+		if l.Listen == "" {
+			return nil, configError{Field: field + ".listen", Err: errors.New("empty listen address")}
+		}
+		lc := *base
+		if l.Bandwidth.Up != "" || l.Bandwidth.Down != "" {
+			bw, err := parseServerBandwidth(l.Bandwidth, field+".bandwidth")
+			if err != nil {
+				return nil, err
+			}
+			lc.BandwidthConfig = bw
+		}
+		conn, err := c.listenConn(l.Listen, field+".listen")
+		if err != nil {
+			return nil, err
+		}
+		lc.Conn = conn
+		configs = append(configs, &lc)
+	}
+	return configs, nil
 }
 
 func (c *serverConfig) fillIgnoreClientBandwidth(hyConfig *server.Config) error {
@@ -756,38 +908,82 @@ func (c *serverConfig) fillUDPIdleTimeout(hyConfig *server.Config) error {
 	return nil
 }
 
+func (c *serverConfig) fillLimits(hyConfig *server.Config) error {
+	hyConfig.IdleTimeout = c.Limits.IdleTimeout
+	if c.Limits.MaxConnections < 0 {
+		return configError{Field: "limits.maxConnections", Err: errors.New("must not be negative")}
+	}
+	hyConfig.MaxConnections = c.Limits.MaxConnections
+	// Shared by the listeners, which copy the main config
+	hyConfig.Clients = new(atomic.Int64)
+	if c.Limits.FallbackServer != "" {
+		if c.Limits.MaxConnections == 0 {
+			return configError{Field: "limits.fallbackServer", Err: errors.New("only used with limits.maxConnections")}
+		}
+		if strings.Contains(c.Limits.FallbackServer, "://") {
+			return configError{Field: "limits.fallbackServer", Err: errors.New("must be host or host:port, not a URL")}
+		}
+		hyConfig.FallbackServer = c.Limits.FallbackServer
+	}
+	return nil
+}
+
 func (c *serverConfig) fillAuthenticator(hyConfig *server.Config) error {
 	if c.Auth.Type == "" {
 		return configError{Field: "auth.type", Err: errors.New("empty auth type")}
 	}
+	var authenticator server.Authenticator
 	switch strings.ToLower(c.Auth.Type) {
 	case "password":
-		if c.Auth.Password == "" {
+		if c.Auth.Password == "" && c.Auth.PasswordFile == "" && c.Auth.PasswordEnv == "" {
 			return configError{Field: "auth.password", Err: errors.New("empty auth password")}
 		}
-		hyConfig.Authenticator = &auth.PasswordAuthenticator{Password: c.Auth.Password}
-		return nil
+		password, err := resolveSecret("auth.password", c.Auth.Password, c.Auth.PasswordFile, c.Auth.PasswordEnv)
+		if err != nil {
+			return err
+		}
+		authenticator = &auth.PasswordAuthenticator{Password: password}
 	case "userpass":
 		if len(c.Auth.UserPass) == 0 {
 			return configError{Field: "auth.userpass", Err: errors.New("empty auth userpass")}
 		}
-		hyConfig.Authenticator = auth.NewUserPassAuthenticator(c.Auth.UserPass)
-		return nil
+		// Strict mode refuses these, see strictViolations
+		if !c.Security.Strict {
+			for _, users := range duplicateUserPasswords(c.Auth.UserPass) {
+				logger.Warn("users share a password, each can log in as the others, so their stats and kicks are unreliable. "+
+					"A connection counts as the user name it logs in with. Give each user their own password.",
+					zap.Strings("users", users))
+			}
+		}
+		authenticator = auth.NewUserPassAuthenticator(c.Auth.UserPass)
 	case "http", "https":
 		if c.Auth.HTTP.URL == "" {
 			return configError{Field: "auth.http.url", Err: errors.New("empty auth http url")}
 		}
-		hyConfig.Authenticator = auth.NewHTTPAuthenticator(c.Auth.HTTP.URL, c.Auth.HTTP.Insecure)
-		return nil
+		authenticator = auth.NewHTTPAuthenticator(c.Auth.HTTP.URL, c.Auth.HTTP.Insecure)
 	case "command", "cmd":
 		if c.Auth.Command == "" {
 			return configError{Field: "auth.command", Err: errors.New("empty auth command")}
 		}
-		hyConfig.Authenticator = &auth.CommandAuthenticator{Cmd: c.Auth.Command}
-		return nil
+		authenticator = &auth.CommandAuthenticator{Cmd: c.Auth.Command}
+	case "token":
+		if c.Auth.Token.Secret == "" {
+			return configError{Field: "auth.token.secret", Err: errors.New("empty auth token secret")}
+		}
+		// Token only, nothing to fall back to
 	default:
 		return configError{Field: "auth.type", Err: errors.New("unsupported auth type")}
 	}
+	if c.Auth.Token.Secret != "" {
+		// Signed tokens (see gen-client --ttl) are accepted in addition
+		// to whatever the main auth type accepts, and their sessions are
+		// closed when they expire or their window ends.
+		tokenAuth := auth.NewTokenAuthenticator([]byte(c.Auth.Token.Secret), authenticator)
+		authenticator = tokenAuth
+		hyConfig.SessionDeadline = tokenAuth
+	}
+	hyConfig.Authenticator = authenticator
+	return nil
 }
 
 func (c *serverConfig) fillEventLogger(hyConfig *server.Config) error {
@@ -795,12 +991,50 @@ func (c *serverConfig) fillEventLogger(hyConfig *server.Config) error {
 	return nil
 }
 
+// fillTrafficLogger must be called after fillAuditLog, as the traffic
+// stats API is where users can be kicked, and after fillTLSConfig, as it's
+// also where the certificate is reloaded.
 func (c *serverConfig) fillTrafficLogger(hyConfig *server.Config) error {
 	if c.TrafficStats.Listen != "" {
 		tss := trafficlogger.NewTrafficStatsServer(c.TrafficStats.Secret)
 		hyConfig.TrafficLogger = tss
-		go runTrafficStatsServer(c.TrafficStats.Listen, tss)
+		var handler http.Handler = tss
+		if auditLog != nil {
+			handler = auditTrafficStatsHandler(tss)
+		}
+		if c.authGuard != nil {
+			handler = authBansHandler(handler, c.TrafficStats.Secret, c.authGuard)
+		}
+		handler = certReloadHandler(handler, c.TrafficStats.Secret, c.certLoader)
+		go runTrafficStatsServer(c.TrafficStats.Listen, handler)
+	}
+	return nil
+}
+
+// fillDebugTrace must be called after fillAuthenticator, fillEventLogger,
+// fillTrafficLogger and fillQuota, as it wraps the first three.
+func (c *serverConfig) fillDebugTrace(hyConfig *server.Config) error {
+	if c.Debug.TraceUser == "" {
+		return nil
+	}
+	timeout := c.Debug.TraceTimeout
+	if timeout == 0 {
+		timeout = defaultTraceTimeout
+	} else if timeout < 0 {
+		return configError{Field: "debug.traceTimeout", Err: errors.New("must be positive")}
+	}
+	tracer := &userTracer{
+		User:     c.Debug.TraceUser,
+		Deadline: time.Now().Add(timeout),
 	}
+	hyConfig.Authenticator = &traceAuthenticator{Authenticator: hyConfig.Authenticator, Tracer: tracer}
+	hyConfig.EventLogger = &traceEventLogger{EventLogger: hyConfig.EventLogger, Tracer: tracer}
+	if hyConfig.TrafficLogger != nil {
+		// Without a traffic logger streams are copied on a faster path,
+		// which we keep for everyone else
+		hyConfig.TrafficLogger = &traceTrafficLogger{TrafficLogger: hyConfig.TrafficLogger, Tracer: tracer}
+	}
+	logger.Info("tracing user", zap.String("id", tracer.User), zap.Time("until", tracer.Deadline))
 	return nil
 }
 
@@ -815,6 +1049,11 @@ func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
 		if c.Masquerade.File.Dir == "" {
 			return configError{Field: "masquerade.file.dir", Err: errors.New("empty file directory")}
 		}
+		if fi, err := os.Stat(c.Masquerade.File.Dir); err != nil {
+			return configError{Field: "masquerade.file.dir", Err: err}
+		} else if !fi.IsDir() {
+			return configError{Field: "masquerade.file.dir", Err: errors.New("not a directory")}
+		}
 		handler = http.FileServer(http.Dir(c.Masquerade.File.Dir))
 	case "proxy":
 		if c.Masquerade.Proxy.URL == "" {
@@ -862,7 +1101,20 @@ func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
 			},
 		}
 	case "string":
-		if c.Masquerade.String.Content == "" {
+		content := []byte(c.Masquerade.String.Content)
+		if c.Masquerade.String.File != "" {
+			if c.Masquerade.String.Content != "" {
+				return configError{Field: "masquerade.string", Err: errors.New("cannot set both content and file")}
+			}
+			// Read once at startup, so a single convincing page
+			// can be served without exposing a whole directory
+			bs, err := os.ReadFile(c.Masquerade.String.File)
+			if err != nil {
+				return configError{Field: "masquerade.string.file", Err: err}
+			}
+			content = bs
+		}
+		if len(content) == 0 {
 			return configError{Field: "masquerade.string.content", Err: errors.New("empty string content")}
 		}
 		if c.Masquerade.String.StatusCode != 0 &&
@@ -881,7 +1133,7 @@ func (c *serverConfig) fillMasqHandler(hyConfig *server.Config) error {
 			} else {
 				w.WriteHeader(http.StatusOK) // Use 200 OK by default
 			}
-			_, _ = w.Write([]byte(c.Masquerade.String.Content))
+			_, _ = w.Write(content)
 		})
 	default:
 		return configError{Field: "masquerade.type", Err: errors.New("unsupported masquerade type")}
@@ -920,10 +1172,19 @@ func (c *serverConfig) Config() (*server.Config, error) {
 		c.fillIgnoreClientBandwidth,
 		c.fillDisableUDP,
 		c.fillUDPIdleTimeout,
+		c.fillLimits,
 		c.fillAuthenticator,
+		c.fillAuthGuard,
+		c.fillStartupRamp,
+		c.fillSelfTest,
 		c.fillEventLogger,
+		c.fillAuditLog,
 		c.fillTrafficLogger,
+		c.fillQuota,
+		c.fillTelemetry,
+		c.fillDebugTrace,
 		c.fillMasqHandler,
+		c.fillStrictSecurity,
 	}
 	for _, f := range fillers {
 		if err := f(hyConfig); err != nil {
@@ -945,21 +1206,96 @@ func runServer(cmd *cobra.Command, args []string) {
 	if err := viper.Unmarshal(&config); err != nil {
 		logger.Fatal("failed to parse server config", zap.Error(err))
 	}
+	if config.Log.UTC {
+		logUTC.Store(true)
+	}
+	if core, err := config.Log.core(logger.Core()); err != nil {
+		logger.Fatal("failed to set up logging", zap.Error(err))
+	} else if core != nil {
+		logger.Info("logging to syslog from now on", zap.String("tag", config.Log.Syslog.Tag))
+		logger = zap.New(core)
+	}
+	// Started first so that load balancers see a standby node as down
+	var health *healthHandler
+	if config.Health.Listen != "" {
+		health = &healthHandler{}
+		go runHealthServer(config.Health.Listen, health)
+	}
+	// Failover coordination must happen before Config() as a standby
+	// node must not bind the listen port until it becomes active.
+	if config.Failover.Role != "" {
+		fc, err := newFailoverCoordinator(config.Failover)
+		if err != nil {
+			logger.Fatal("failed to load server config", zap.Error(err))
+		}
+		fc.Start()
+	}
 	hyConfig, err := config.Config()
 	if err != nil {
 		logger.Fatal("failed to load server config", zap.Error(err))
 	}
+	if serverShowClient {
+		showServerClient(&config, serverPublicHost)
+	}
+
+	listenerConfigs, err := config.ListenerConfigs(hyConfig)
+	if err != nil {
+		logger.Fatal("failed to load server config", zap.Error(err))
+	}
 
 	s, err := server.NewServer(hyConfig)
 	if err != nil {
 		logger.Fatal("failed to initialize server", zap.Error(err))
 	}
+	if config.quota != nil {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			config.quota.saveOnSignal(sigChan)
+			os.Exit(0)
+		}()
+	}
+	if config.certLoader != nil {
+		go watchCertificate(config.certLoader)
+		go reloadCertificateOnSignal(config.certLoader)
+	}
 	if config.Listen != "" {
 		logger.Info("server up and running", zap.String("listen", config.Listen))
 	} else {
 		logger.Info("server up and running", zap.String("listen", defaultListenAddr))
 	}
 
+	for i, lc := range listenerConfigs {
+		l := config.Listeners[i]
+		ls, err := server.NewServer(lc)
+		if err != nil {
+			logger.Fatal("failed to initialize listener", zap.String("name", l.Name), zap.Error(err))
+		}
+		logger.Info("listener up and running", zap.String("name", l.Name), zap.String("listen", l.Listen),
+			zap.String("up", l.Bandwidth.Up), zap.String("down", l.Bandwidth.Down))
+		go func() {
+			if err := ls.Serve(); err != nil {
+				logger.Fatal("failed to serve listener", zap.String("name", l.Name), zap.Error(err))
+			}
+		}()
+	}
+
+	rcvBuf, sndBuf := primaryUDPBuffers()
+	logger.Info("startup summary", config.startupSummaryFields(rcvBuf, sndBuf)...)
+
+	go retuneUDPBuffersOnSignal(logger)
+
+	if health != nil {
+		health.SetReadyCheck(config.tlsReadyCheck(hyConfig.TLSConfig))
+		if config.selfTest == nil {
+			health.SetServing(true)
+		}
+	}
+	if config.selfTest != nil {
+		// Once Serve below is running
+		go config.selfTestAndReport(hyConfig, health)
+	}
+
 	if !disableUpdateCheck {
 		go runCheckUpdateServer()
 	}
@@ -969,6 +1305,53 @@ func runServer(cmd *cobra.Command, args []string) {
 	}
 }
 
+// startupSummaryFields describes what the server runs with in a single log
+// line, for operators to check or send along when asking for support. The
+// auth secrets are left out. rcvBuf and sndBuf are the granted UDP buffer
+// sizes of the main listener, 0 if unknown.
+func (c *serverConfig) startupSummaryFields(rcvBuf, sndBuf int) []zap.Field {
+	listen := c.Listen
+	if listen == "" {
+		listen = defaultListenAddr
+	}
+	listens := []string{listen}
+	for _, l := range c.Listeners {
+		listens = append(listens, l.Name+"="+l.Listen)
+	}
+	fields := []zap.Field{zap.Strings("listen", listens)}
+	switch {
+	case c.ACME != nil:
+		fields = append(fields, zap.String("tls", "acme"), zap.Strings("domains", c.ACME.Domains))
+	case c.TLS != nil:
+		fields = append(fields, zap.String("tls", "files"))
+	}
+	authType := strings.ToLower(c.Auth.Type)
+	fields = append(fields, zap.String("auth", authType))
+	switch authType {
+	case "password":
+		fields = append(fields, zap.Int("users", 1))
+	case "userpass":
+		fields = append(fields, zap.Int("users", len(c.Auth.UserPass)))
+	}
+	bandwidth := func(s string) string {
+		if s == "" {
+			return "unlimited"
+		}
+		return s
+	}
+	fields = append(fields,
+		zap.Bool("obfs", strings.ToLower(c.Obfs.Type) == "salamander"),
+		zap.String("up", bandwidth(c.Bandwidth.Up)),
+		zap.String("down", bandwidth(c.Bandwidth.Down)))
+	if rcvBuf > 0 {
+		fields = append(fields, zap.String("udpReadBuffer", formatBytes(rcvBuf)))
+	}
+	if sndBuf > 0 {
+		fields = append(fields, zap.String("udpWriteBuffer", formatBytes(sndBuf)))
+	}
+	return fields
+}
+
 func runTrafficStatsServer(listen string, handler http.Handler) {
 	logger.Info("traffic stats server up and running", zap.String("listen", listen))
 	if err := correctnet.HTTPListenAndServe(listen, handler); err != nil {
@@ -1006,16 +1389,70 @@ func geoDownloadErrFunc(err error) {
 	}
 }
 
-type serverLogger struct{}
+// certWatchInterval is how often the tls.cert and tls.key files are checked
+// for changes. A renewal is loaded one interval after the files stop changing.
+const certWatchInterval = 10 * time.Second
+
+func watchCertificate(loader *utils.LocalCertificateLoader) {
+	logger.Info("watching TLS certificate for changes", zap.String("cert", loader.CertFile), zap.String("key", loader.KeyFile))
+	loader.Watch(context.Background(), certWatchInterval, func(cert *tls.Certificate, err error) {
+		if err != nil {
+			logger.Error("failed to reload TLS certificate, keeping the current one", zap.String("cert", loader.CertFile), zap.Error(err))
+			return
+		}
+		logger.Info("TLS certificate reloaded", zap.String("cert", loader.CertFile),
+			zap.Strings("dnsNames", cert.Leaf.DNSNames), zap.Time("notAfter", cert.Leaf.NotAfter))
+	})
+}
+
+var _ server.BandwidthEventLogger = (*serverLogger)(nil)
+
+type serverLogger struct {
+	fullMutex    sync.Mutex
+	fullLastLog  time.Time
+	fullUnlogged int
+}
 
 func (l *serverLogger) Connect(addr net.Addr, id string, tx uint64) {
 	logger.Info("client connected", zap.String("addr", addr.String()), zap.String("id", id), zap.Uint64("tx", tx))
 }
 
 func (l *serverLogger) Disconnect(addr net.Addr, id string, err error) {
+	var fullErr hyErrors.ServerFullError
+	if errors.As(err, &fullErr) {
+		l.serverFull(addr, id)
+		return
+	}
+	var quotaErr hyErrors.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		// Logged once per cycle by quotaTracker, the client retries often
+		logger.Debug("client rejected, data quota used up", zap.String("addr", addr.String()),
+			zap.String("id", id), zap.Time("reset", quotaErr.Reset))
+		return
+	}
 	logger.Info("client disconnected", zap.String("addr", addr.String()), zap.String("id", id), zap.Error(err))
 }
 
+// serverFull logs a client rejected by limits.maxConnections. When the
+// server is full, every client retries on each new request, so at most one
+// line per authFailureLogInterval, counting the rejects in between.
+func (l *serverLogger) serverFull(addr net.Addr, id string) {
+	l.fullMutex.Lock()
+	defer l.fullMutex.Unlock()
+	if now := time.Now(); now.Sub(l.fullLastLog) >= authFailureLogInterval {
+		logger.Warn("client rejected, server full (limits.maxConnections)", zap.String("addr", addr.String()),
+			zap.String("id", id), zap.Int("unlogged", l.fullUnlogged))
+		l.fullLastLog, l.fullUnlogged = now, 0
+	} else {
+		l.fullUnlogged++
+	}
+}
+
+func (l *serverLogger) BandwidthAdapted(addr net.Addr, id string, oldTx, newTx uint64, ackRate float64) {
+	logger.Info("client bandwidth adapted", zap.String("addr", addr.String()), zap.String("id", id),
+		zap.Uint64("oldTx", oldTx), zap.Uint64("tx", newTx), zap.Float64("ackRate", ackRate))
+}
+
 func (l *serverLogger) TCPRequest(addr net.Addr, id, reqAddr string) {
 	logger.Debug("TCP request", zap.String("addr", addr.String()), zap.String("id", id), zap.String("reqAddr", reqAddr))
 }
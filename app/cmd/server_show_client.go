@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+// serverShareLink is the link of one user of the server, User is empty for
+// password auth.
+type serverShareLink struct {
+	User string
+	URI  string
+}
+
+// shareLinks returns the hysteria2:// links for this config, the way
+// clients reach it at host, one per user with userpass auth. Without
+// host, it's the first ACME domain, the listen address, or this machine's
+// primary address.
+func (c *serverConfig) shareLinks(host string) ([]serverShareLink, error) {
+	listen := c.Listen
+	if listen == "" {
+		listen = defaultListenAddr
+	}
+	listenHost, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, configError{Field: "listen", Err: err}
+	}
+	if host == "" {
+		if c.ACME != nil && len(c.ACME.Domains) > 0 {
+			host = c.ACME.Domains[0]
+		} else if ip := net.ParseIP(listenHost); ip != nil && !ip.IsUnspecified() {
+			host = listenHost
+		} else {
+			ip, err := primaryIP()
+			if err != nil {
+				return nil, fmt.Errorf("cannot find this machine's address, set --public-host: %w", err)
+			}
+			host = ip.String()
+		}
+	}
+
+	base := clientConfig{Server: net.JoinHostPort(host, port)}
+	switch {
+	case c.ACME != nil:
+		if len(c.ACME.Domains) > 0 && !slices.Contains(c.ACME.Domains, host) {
+			base.TLS.SNI = c.ACME.Domains[0]
+		}
+	case c.TLS != nil:
+		leaf, err := loadLeafCertificate(c.TLS.Cert)
+		if err != nil {
+			return nil, configError{Field: "tls.cert", Err: err}
+		}
+		switch {
+		case isSelfSignedCertificate(leaf):
+			// Can't be verified, but pinned it's as safe
+			hash := sha256.Sum256(leaf.Raw)
+			base.TLS.Insecure = true
+			base.TLS.PinSHA256 = hex.EncodeToString(hash[:])
+		case leaf.VerifyHostname(host) != nil && len(leaf.DNSNames) > 0:
+			base.TLS.SNI = leaf.DNSNames[0]
+		}
+	}
+	if strings.ToLower(c.Obfs.Type) == "salamander" {
+		base.Obfs.Type = "salamander"
+		base.Obfs.Salamander.Password = c.Obfs.Salamander.Password
+	}
+
+	var links []serverShareLink
+	switch strings.ToLower(c.Auth.Type) {
+	case "password":
+		password, err := resolveSecret("auth.password", c.Auth.Password, c.Auth.PasswordFile, c.Auth.PasswordEnv)
+		if err != nil {
+			return nil, err
+		}
+		base.Auth = password
+		links = append(links, serverShareLink{URI: base.URI()})
+	case "userpass":
+		users := make([]string, 0, len(c.Auth.UserPass))
+		for user := range c.Auth.UserPass {
+			users = append(users, user)
+		}
+		slices.Sort(users)
+		for _, user := range users {
+			base.Auth = user + ":" + c.Auth.UserPass[user]
+			links = append(links, serverShareLink{User: user, URI: base.URI()})
+		}
+	default:
+		return nil, fmt.Errorf("auth type '%s' has no password the server knows, use gen-client", c.Auth.Type)
+	}
+	if len(links) == 0 {
+		return nil, errors.New("no users")
+	}
+	return links, nil
+}
+
+// showServerClient prints the share links of the config at startup, with
+// a QR code when there's only one. On stdout, not in the log, which may be
+// kept or shipped elsewhere, but note that systemd sends both to the journal.
+func showServerClient(c *serverConfig, host string) {
+	links, err := c.shareLinks(host)
+	if err != nil {
+		logger.Warn("cannot show the client link", zap.Error(err))
+		return
+	}
+	fmt.Println()
+	fmt.Println("─── Client connection (from this server's config) ───")
+	for _, l := range links {
+		if l.User != "" {
+			fmt.Printf("%s: ", l.User)
+		}
+		fmt.Println(l.URI)
+	}
+	if len(links) == 1 {
+		utils.PrintQR(links[0].URI)
+	} else {
+		fmt.Println("QR codes: libyalink gen-client --import \"<link>\" --all-platforms --output-dir <user>")
+	}
+	fmt.Println()
+}
@@ -5,8 +5,13 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/spf13/viper"
+
+	"github.com/apernet/hysteria/core/v2/server"
+	"github.com/apernet/hysteria/extras/v2/trafficlogger"
 )
 
 // TestServerConfig tests the parsing of the server config
@@ -36,11 +41,12 @@ func TestServerConfig(t *testing.T) {
 				"sub1.example.com",
 				"sub2.example.com",
 			},
-			Email:      "haha@cringe.net",
-			CA:         "zero",
-			ListenHost: "127.0.0.9",
-			Dir:        "random_dir",
-			Type:       "dns",
+			Email:       "haha@cringe.net",
+			CA:          "zero",
+			ListenHost:  "127.0.0.9",
+			Dir:         "random_dir",
+			RenewBefore: 480 * time.Hour,
+			Type:        "dns",
 			HTTP: serverConfigACMEHTTP{
 				AltPort: 8888,
 			},
@@ -67,6 +73,11 @@ func TestServerConfig(t *testing.T) {
 			MaxIdleTimeout:              999 * time.Second,
 			MaxIncomingStreams:          256,
 			DisablePathMTUDiscovery:     true,
+			InitCongestionWindow:        64,
+			KeepAlivePeriod:             15 * time.Second,
+			CongestionControl:           "brutal",
+			MaxDatagramSize:             1250,
+			AdaptiveBandwidth:           true,
 		},
 		Bandwidth: serverConfigBandwidth{
 			Up:   "500 mbps",
@@ -77,8 +88,10 @@ func TestServerConfig(t *testing.T) {
 		DisableUDP:            true,
 		UDPIdleTimeout:        120 * time.Second,
 		Auth: serverConfigAuth{
-			Type:     "password",
-			Password: "goofy_ahh_password",
+			Type:         "password",
+			Password:     "goofy_ahh_password",
+			PasswordFile: "/etc/libyalink/password",
+			PasswordEnv:  "LIBYALINK_PASSWORD",
 			UserPass: map[string]string{
 				"yolo": "swag",
 				"lol":  "kek",
@@ -89,6 +102,9 @@ func TestServerConfig(t *testing.T) {
 				Insecure: true,
 			},
 			Command: "/etc/some_command",
+			Token: serverConfigAuthToken{
+				Secret: "i_am_a_teapot",
+			},
 		},
 		Resolver: serverConfigResolver{
 			Type: "udp",
@@ -176,6 +192,7 @@ func TestServerConfig(t *testing.T) {
 			},
 			String: serverConfigMasqueradeString{
 				Content: "aint nothin here",
+				File:    "/www/index.html",
 				Headers: map[string]string{
 					"content-type": "text/plain",
 					"custom-haha":  "lol",
@@ -186,5 +203,192 @@ func TestServerConfig(t *testing.T) {
 			ListenHTTPS: ":443",
 			ForceHTTPS:  true,
 		},
+		Failover: serverConfigFailover{
+			Role:          "standby",
+			NodeID:        "box2",
+			HeartbeatFile: "/mnt/shared/libyalink.hb",
+			Interval:      3 * time.Second,
+			Timeout:       15 * time.Second,
+		},
+		Debug: serverConfigDebug{
+			TraceUser:    "alice",
+			TraceTimeout: 5 * time.Minute,
+		},
+		Listeners: []serverConfigListener{
+			{
+				Name:   "4g",
+				Listen: ":8444",
+				Bandwidth: serverConfigBandwidth{
+					Up:   "10 mbps",
+					Down: "1 mbps",
+				},
+			},
+			{
+				Name:   "fiber",
+				Listen: ":8445",
+			},
+		},
+		Security: serverConfigSecurity{
+			Strict: true,
+		},
+		Audit: serverConfigAudit{
+			File: "/var/log/libyalink/audit.log",
+		},
+		Health: serverConfigHealth{
+			Listen: "127.0.0.1:8081",
+		},
+		Limits: serverConfigLimits{
+			IdleTimeout: 30 * time.Minute,
+			AuthFailureBan: serverConfigAuthFailureBan{
+				MaxFailures: 10,
+				Window:      5 * time.Minute,
+				Duration:    2 * time.Hour,
+			},
+			MaxConnections: 200,
+			FallbackServer: "backup.example.com:443",
+			StartupRamp: serverConfigStartupRamp{
+				Duration: 2 * time.Minute,
+				Rate:     50,
+			},
+			Quota: serverConfigQuota{
+				Default: "50GB",
+				Users: map[string]string{
+					"alice": "100GB",
+					"bob":   "unlimited",
+				},
+				ResetDay: 15,
+				File:     "/var/lib/hysteria/quota.json",
+			},
+		},
+		Log: serverConfigLog{
+			UTC:    true,
+			Output: "syslog",
+			Syslog: serverConfigLogSyslog{
+				Facility: "local3",
+				Tag:      "vpn",
+				Network:  "udp",
+				Address:  "logs.example.com:514",
+			},
+		},
+		Startup: serverConfigStartup{
+			SelfTest: true,
+		},
+		Telemetry: serverConfigTelemetry{
+			OTLPEndpoint: "https://otel.example.com:4318",
+			Interval:     30 * time.Second,
+			Headers:      map[string]string{"authorization": "Bearer otel_token"},
+		},
 	})
 }
+
+func TestServerStartupSummary(t *testing.T) {
+	summary := func(c serverConfig, rcvBuf, sndBuf int) map[string]interface{} {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, f := range c.startupSummaryFields(rcvBuf, sndBuf) {
+			f.AddTo(enc)
+		}
+		return enc.Fields
+	}
+
+	got := summary(serverConfig{
+		Listen:    ":8443",
+		Listeners: []serverConfigListener{{Name: "fiber", Listen: ":9443"}},
+		ACME:      &serverConfigACME{Domains: []string{"vpn.example.com"}},
+		Auth:      serverConfigAuth{Type: "userpass", UserPass: map[string]string{"alice": "a", "bob": "b"}},
+		Obfs:      serverConfigObfs{Type: "salamander"},
+		Bandwidth: serverConfigBandwidth{Up: "100 mbps"},
+	}, 16<<20, 8<<20)
+	assert.Equal(t, []interface{}{":8443", "fiber=:9443"}, got["listen"])
+	assert.Equal(t, "acme", got["tls"])
+	assert.Equal(t, []interface{}{"vpn.example.com"}, got["domains"])
+	assert.Equal(t, "userpass", got["auth"])
+	assert.Equal(t, int64(2), got["users"])
+	assert.Equal(t, true, got["obfs"])
+	assert.Equal(t, "100 mbps", got["up"])
+	assert.Equal(t, "unlimited", got["down"])
+	assert.Equal(t, "16MB", got["udpReadBuffer"])
+	assert.Equal(t, "8MB", got["udpWriteBuffer"])
+
+	got = summary(serverConfig{
+		TLS:  &serverConfigTLS{Cert: "server.crt", Key: "server.key"},
+		Auth: serverConfigAuth{Type: "password", Password: "weak_ahh_password"},
+	}, 0, 0)
+	assert.Equal(t, []interface{}{defaultListenAddr}, got["listen"])
+	assert.Equal(t, "files", got["tls"])
+	assert.Equal(t, int64(1), got["users"])
+	assert.Equal(t, false, got["obfs"])
+	assert.NotContains(t, got, "udpReadBuffer")
+	for _, v := range got {
+		assert.NotEqual(t, "weak_ahh_password", v)
+	}
+}
+
+func TestServerShareLinks(t *testing.T) {
+	config := serverConfig{
+		Listen: ":8443",
+		ACME:   &serverConfigACME{Domains: []string{"vpn.example.com"}},
+		Obfs:   serverConfigObfs{Type: "salamander", Salamander: serverConfigObfsSalamander{Password: "obfs"}},
+		Auth:   serverConfigAuth{Type: "password", Password: "secret"},
+	}
+	links, err := config.shareLinks("")
+	assert.NoError(t, err)
+	assert.Equal(t, []serverShareLink{
+		{URI: "hysteria2://secret@vpn.example.com:8443/?obfs=salamander&obfs-password=obfs"},
+	}, links)
+
+	// By IP, the SNI keeps the certificate's name
+	config.Obfs = serverConfigObfs{}
+	config.Auth = serverConfigAuth{Type: "userpass", UserPass: map[string]string{"bob": "b", "alice": "a"}}
+	links, err = config.shareLinks("203.0.113.5")
+	assert.NoError(t, err)
+	assert.Equal(t, []serverShareLink{
+		{User: "alice", URI: "hysteria2://alice:a@203.0.113.5:8443/?sni=vpn.example.com"},
+		{User: "bob", URI: "hysteria2://bob:b@203.0.113.5:8443/?sni=vpn.example.com"},
+	}, links)
+
+	config.Auth = serverConfigAuth{Type: "http", HTTP: serverConfigAuthHTTP{URL: "http://127.0.0.1/auth"}}
+	_, err = config.shareLinks("")
+	assert.Error(t, err)
+}
+
+func TestServerFillDebugTrace(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	config := serverConfig{Debug: serverConfigDebug{TraceUser: "alice"}}
+	hyConfig := &server.Config{EventLogger: &serverLogger{}}
+	assert.NoError(t, config.fillDebugTrace(hyConfig))
+	assert.IsType(t, &traceEventLogger{}, hyConfig.EventLogger)
+	// Untraced users must keep the copy path without a traffic logger
+	assert.Nil(t, hyConfig.TrafficLogger)
+
+	stats := trafficlogger.NewTrafficStatsServer("")
+	hyConfig = &server.Config{EventLogger: &serverLogger{}, TrafficLogger: stats}
+	assert.NoError(t, config.fillDebugTrace(hyConfig))
+	tl, ok := hyConfig.TrafficLogger.(*traceTrafficLogger)
+	assert.True(t, ok)
+	assert.Equal(t, stats, tl.TrafficLogger)
+}
+
+func TestServerListenerConfigsShareClients(t *testing.T) {
+	config := serverConfig{
+		Limits: serverConfigLimits{MaxConnections: 100},
+		Listeners: []serverConfigListener{
+			{Name: "4g", Listen: "127.0.0.1:0", Bandwidth: serverConfigBandwidth{Up: "10 mbps"}},
+			{Name: "fiber", Listen: "127.0.0.1:0"},
+		},
+	}
+	base := &server.Config{}
+	assert.NoError(t, config.fillLimits(base))
+	configs, err := config.ListenerConfigs(base)
+	assert.NoError(t, err)
+	assert.Len(t, configs, 2)
+	// limits.maxConnections is for the whole server, not each port
+	for _, lc := range configs {
+		defer lc.Conn.Close()
+		assert.Equal(t, 100, lc.MaxConnections)
+		assert.Same(t, base.Clients, lc.Clients)
+	}
+	assert.NotNil(t, base.Clients)
+}
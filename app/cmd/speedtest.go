@@ -23,6 +23,7 @@ var (
 	skipUpload   bool
 	dataSize     uint32
 	useBytes     bool
+	saveResult   string
 
 	speedtestAddr = fmt.Sprintf("%s:%d", outbounds.SpeedtestDest, 0)
 )
@@ -45,6 +46,7 @@ func initSpeedtestFlags() {
 	speedtestCmd.Flags().BoolVar(&skipUpload, "skip-upload", false, "Skip upload test")
 	speedtestCmd.Flags().Uint32Var(&dataSize, "data-size", 1024*1024*100, "Data size for download and upload tests")
 	speedtestCmd.Flags().BoolVar(&useBytes, "use-bytes", false, "Use bytes per second instead of bits per second")
+	speedtestCmd.Flags().StringVar(&saveResult, "save", "", "Save the result to this file, for gen-client --auto-brutal --speedtest-result")
 }
 
 func runSpeedtest(cmd *cobra.Command, args []string) {
@@ -75,13 +77,14 @@ func runSpeedtest(cmd *cobra.Command, args []string) {
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
 	defer signal.Stop(signalChan)
 
+	result := speedtestResult{Server: config.Server}
 	runChan := make(chan struct{}, 1)
 	go func() {
 		if !skipDownload {
-			runDownloadTest(c)
+			result.Download = runDownloadTest(c)
 		}
 		if !skipUpload {
-			runUploadTest(c)
+			result.Upload = runUploadTest(c)
 		}
 		runChan <- struct{}{}
 	}()
@@ -91,10 +94,18 @@ func runSpeedtest(cmd *cobra.Command, args []string) {
 		logger.Info("received signal, shutting down gracefully")
 	case <-runChan:
 		logger.Info("speed test complete")
+		if saveResult != "" {
+			result.Time = time.Now().UTC()
+			if err := saveSpeedtestResult(saveResult, result); err != nil {
+				logger.Fatal("failed to save result", zap.Error(err))
+			}
+			logger.Info("result saved", zap.String("file", saveResult))
+		}
 	}
 }
 
-func runDownloadTest(c client.Client) {
+// runDownloadTest returns the download speed in bytes per second.
+func runDownloadTest(c client.Client) float64 {
 	logger.Info("performing download test")
 	downConn, err := c.TCP(speedtestAddr)
 	if err != nil {
@@ -108,6 +119,7 @@ func runDownloadTest(c client.Client) {
 
 	downClient := &speedtest.Client{Conn: downConn}
 	currentTotal := uint32(0)
+	var speed float64
 	err = downClient.Download(dataSize, func(d time.Duration, b uint32, done bool) {
 		if !done {
 			currentTotal += b
@@ -116,6 +128,7 @@ func runDownloadTest(c client.Client) {
 				zap.String("progress", fmt.Sprintf("%.2f%%", float64(currentTotal)/float64(dataSize)*100)),
 				zap.String("speed", formatSpeed(b, d, useBytes)))
 		} else {
+			speed = float64(b) / d.Seconds()
 			logger.Info("download complete",
 				zap.Uint32("bytes", b),
 				zap.String("speed", formatSpeed(b, d, useBytes)))
@@ -125,9 +138,11 @@ func runDownloadTest(c client.Client) {
 		logger.Fatal("download test failed", zap.Error(err))
 	}
 	logger.Info("download test complete")
+	return speed
 }
 
-func runUploadTest(c client.Client) {
+// runUploadTest returns the upload speed in bytes per second.
+func runUploadTest(c client.Client) float64 {
 	logger.Info("performing upload test")
 	upConn, err := c.TCP(speedtestAddr)
 	if err != nil {
@@ -141,6 +156,7 @@ func runUploadTest(c client.Client) {
 
 	upClient := &speedtest.Client{Conn: upConn}
 	currentTotal := uint32(0)
+	var speed float64
 	err = upClient.Upload(dataSize, func(d time.Duration, b uint32, done bool) {
 		if !done {
 			currentTotal += b
@@ -149,6 +165,7 @@ func runUploadTest(c client.Client) {
 				zap.String("progress", fmt.Sprintf("%.2f%%", float64(currentTotal)/float64(dataSize)*100)),
 				zap.String("speed", formatSpeed(b, d, useBytes)))
 		} else {
+			speed = float64(b) / d.Seconds()
 			logger.Info("upload complete",
 				zap.Uint32("bytes", b),
 				zap.String("speed", formatSpeed(b, d, useBytes)))
@@ -158,6 +175,7 @@ func runUploadTest(c client.Client) {
 		logger.Fatal("upload test failed", zap.Error(err))
 	}
 	logger.Info("upload test complete")
+	return speed
 }
 
 func formatSpeed(bytes uint32, duration time.Duration, useBytes bool) string {
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	defaultStartupRampRate = 20 // logins per second
+
+	// startupRampMaxWait is the longest a login waits for its turn. One
+	// that would wait longer fails like a wrong password, and the client
+	// tries again on its next request.
+	startupRampMaxWait = 10 * time.Second
+
+	// startupRampJitter is the most added to each wait, at random, so that
+	// the logins admitted together don't reach the authenticator together.
+	startupRampJitter = 500 * time.Millisecond
+
+	startupRampLogInterval = 10 * time.Second
+)
+
+type serverConfigStartupRamp struct {
+	Duration time.Duration `mapstructure:"duration"`
+	Rate     int           `mapstructure:"rate"`
+}
+
+// startupRamp spreads out the logins right after the server starts, when
+// every client of a busy server reconnects at once. It admits Rate logins
+// per second, twice as many after each quarter of Duration, and has no
+// limit once Duration is over. A login over the limit waits for its turn.
+type startupRamp struct {
+	Authenticator server.Authenticator
+	Duration      time.Duration
+	Rate          int
+
+	mutex    sync.Mutex
+	start    time.Time
+	next     time.Time // The earliest turn of the next login
+	delayed  int
+	rejected int
+	lastLog  time.Time
+	now      func() time.Time
+	sleep    func(time.Duration)
+}
+
+func newStartupRamp(auth server.Authenticator, ramp serverConfigStartupRamp) *startupRamp {
+	r := &startupRamp{
+		Authenticator: auth,
+		Duration:      ramp.Duration,
+		Rate:          ramp.Rate,
+		now:           time.Now,
+		sleep:         time.Sleep,
+	}
+	if r.Rate == 0 {
+		r.Rate = defaultStartupRampRate
+	}
+	r.start = r.now()
+	return r
+}
+
+func (r *startupRamp) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	wait, ok := r.admit()
+	if !ok {
+		return false, ""
+	}
+	if wait > 0 {
+		r.sleep(wait + time.Duration(rand.Int63n(int64(startupRampJitter))))
+	}
+	return r.Authenticator.Authenticate(addr, auth, tx)
+}
+
+// rate is the number of logins per second admitted at elapsed.
+func (r *startupRamp) rate(elapsed time.Duration) int {
+	return r.Rate << (4 * elapsed / r.Duration)
+}
+
+// admit gives the login a turn and returns how long it has to wait for it,
+// or false if that's more than startupRampMaxWait.
+func (r *startupRamp) admit() (time.Duration, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := r.now()
+	elapsed := now.Sub(r.start)
+	if elapsed >= r.Duration {
+		return 0, true
+	}
+	turn := r.next
+	if turn.Before(now) {
+		turn = now
+	}
+	wait := turn.Sub(now)
+	if wait > startupRampMaxWait {
+		r.rejected++
+		r.log(now, elapsed)
+		return 0, false
+	}
+	r.next = turn.Add(time.Second / time.Duration(r.rate(elapsed)))
+	if wait > 0 {
+		r.delayed++
+		r.log(now, elapsed)
+	}
+	return wait, true
+}
+
+// log reports the logins held back since the last line, at most once
+// every startupRampLogInterval. logOver reports the rest.
+func (r *startupRamp) log(now time.Time, elapsed time.Duration) {
+	if now.Sub(r.lastLog) < startupRampLogInterval {
+		return
+	}
+	logger.Warn("startup ramp: holding back logins", zap.Int("rate", r.rate(elapsed)),
+		zap.Int("delayed", r.delayed), zap.Int("rejected", r.rejected), zap.Duration("remaining", r.Duration-elapsed))
+	r.lastLog, r.delayed, r.rejected = now, 0, 0
+}
+
+func (r *startupRamp) logOver() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	logger.Info("startup ramp over, logins are no longer limited",
+		zap.Int("delayed", r.delayed), zap.Int("rejected", r.rejected))
+}
+
+// fillStartupRamp must be called after fillAuthGuard, as it wraps it, so
+// that the logins it rejects don't count as failures towards a ban.
+func (c *serverConfig) fillStartupRamp(hyConfig *server.Config) error {
+	ramp := c.Limits.StartupRamp
+	if ramp.Duration == 0 {
+		return nil
+	}
+	if ramp.Duration < 0 {
+		return configError{Field: "limits.startupRamp.duration", Err: errors.New("must not be negative")}
+	}
+	if ramp.Rate < 0 {
+		return configError{Field: "limits.startupRamp.rate", Err: errors.New("must not be negative")}
+	}
+	r := newStartupRamp(hyConfig.Authenticator, ramp)
+	hyConfig.Authenticator = r
+	logger.Info("startup ramp enabled", zap.Duration("duration", r.Duration), zap.Int("rate", r.Rate))
+	time.AfterFunc(r.Duration, r.logOver)
+	return nil
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestStartupRamp(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var slept []time.Duration
+	r := newStartupRamp(passwordAuthenticator("weak_ahh_password"), serverConfigStartupRamp{Duration: 4 * time.Minute, Rate: 10})
+	r.start = now
+	r.now = func() time.Time { return now }
+	r.sleep = func(d time.Duration) { slept = append(slept, d) }
+	addr := &net.UDPAddr{IP: net.ParseIP("198.51.100.7"), Port: 40001}
+
+	// 10 per second at first: the first login goes right away, the next
+	// ones wait 100ms more each, plus up to the jitter
+	for i := 0; i < 5; i++ {
+		ok, id := r.Authenticate(addr, "weak_ahh_password", 0)
+		assert.True(t, ok)
+		assert.Equal(t, "user", id)
+	}
+	if assert.Len(t, slept, 4) {
+		for i, d := range slept {
+			wait := time.Duration(i+1) * 100 * time.Millisecond
+			assert.GreaterOrEqual(t, d, wait)
+			assert.Less(t, d, wait+startupRampJitter)
+		}
+	}
+
+	// The wrong password still fails after its turn
+	ok, _ := r.Authenticate(addr, "guess", 0)
+	assert.False(t, ok)
+
+	// A login more than startupRampMaxWait from its turn is rejected,
+	// without reaching the authenticator
+	r.next = now.Add(startupRampMaxWait + time.Second)
+	slept = nil
+	ok, _ = r.Authenticate(addr, "weak_ahh_password", 0)
+	assert.False(t, ok)
+	assert.Empty(t, slept)
+
+	// Twice as fast each quarter
+	assert.Equal(t, 10, r.rate(0))
+	assert.Equal(t, 20, r.rate(time.Minute))
+	assert.Equal(t, 80, r.rate(4*time.Minute-time.Second))
+
+	// No limit once it's over
+	now = now.Add(4 * time.Minute)
+	ok, _ = r.Authenticate(addr, "weak_ahh_password", 0)
+	assert.True(t, ok)
+	assert.Empty(t, slept)
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statsAPIClient talks to the traffic stats API of a running server.
+type statsAPIClient struct {
+	BaseURL string
+	Secret  string
+	HTTP    *http.Client
+}
+
+type statsAPITraffic struct {
+	Tx uint64 `json:"tx"`
+	Rx uint64 `json:"rx"`
+}
+
+type statsAPIStream struct {
+	State        string    `json:"state"`
+	Auth         string    `json:"auth"`
+	ReqAddr      string    `json:"req_addr"`
+	Tx           uint64    `json:"tx"`
+	Rx           uint64    `json:"rx"`
+	InitialAt    time.Time `json:"initial_at"`
+	LastActiveAt time.Time `json:"last_active_at"`
+}
+
+// newStatsAPIClient returns a client for the API listening on listen, the
+// trafficStats.listen of the server config. A wildcard host is reached on
+// the loopback address.
+func newStatsAPIClient(listen, secret string) (*statsAPIClient, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return &statsAPIClient{
+		BaseURL: "http://" + net.JoinHostPort(host, port),
+		Secret:  secret,
+		HTTP:    &http.Client{Timeout: 3 * time.Second},
+	}, nil
+}
+
+func (c *statsAPIClient) do(method, path string, body, v any) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, &reqBody)
+	if err != nil {
+		return err
+	}
+	if c.Secret != "" {
+		req.Header.Set("Authorization", c.Secret)
+	}
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Our own handlers say why in the body
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if s := strings.TrimSpace(string(msg)); s != "" {
+			return fmt.Errorf("%s %s: HTTP status %d: %s", method, path, resp.StatusCode, s)
+		}
+		return fmt.Errorf("%s %s: HTTP status %d", method, path, resp.StatusCode)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *statsAPIClient) Traffic() (map[string]statsAPITraffic, error) {
+	var traffic map[string]statsAPITraffic
+	if err := c.do(http.MethodGet, "/traffic", nil, &traffic); err != nil {
+		return nil, err
+	}
+	return traffic, nil
+}
+
+func (c *statsAPIClient) Online() (map[string]int, error) {
+	var online map[string]int
+	if err := c.do(http.MethodGet, "/online", nil, &online); err != nil {
+		return nil, err
+	}
+	return online, nil
+}
+
+func (c *statsAPIClient) Streams() ([]statsAPIStream, error) {
+	var wrapper struct {
+		Streams []statsAPIStream `json:"streams"`
+	}
+	if err := c.do(http.MethodGet, "/dump/streams", nil, &wrapper); err != nil {
+		return nil, err
+	}
+	return wrapper.Streams, nil
+}
+
+// Bans returns the IPs banned for failed logins (limits.authFailureBan).
+func (c *statsAPIClient) Bans() ([]authBan, error) {
+	var bans []authBan
+	if err := c.do(http.MethodGet, "/bans", nil, &bans); err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+// ReloadCert makes the server load tls.cert and tls.key again.
+func (c *statsAPIClient) ReloadCert() (certReloadResult, error) {
+	var result certReloadResult
+	err := c.do(http.MethodPost, "/reload-cert", nil, &result)
+	return result, err
+}
+
+func (c *statsAPIClient) Kick(ids ...string) error {
+	return c.do(http.MethodPost, "/kick", ids, nil)
+}
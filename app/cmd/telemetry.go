@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	defaultTelemetryInterval = time.Minute
+	telemetryPushTimeout     = 10 * time.Second
+
+	// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+	otlpCumulative = 2
+)
+
+type serverConfigTelemetry struct {
+	OTLPEndpoint string            `mapstructure:"otlpEndpoint"`
+	Interval     time.Duration     `mapstructure:"interval"`
+	Headers      map[string]string `mapstructure:"headers"`
+}
+
+// telemetryMetrics counts what the telemetry wrappers see, since the
+// server started.
+type telemetryMetrics struct {
+	Connections      atomic.Uint64
+	Disconnections   atomic.Uint64
+	HandshakesOK     atomic.Uint64
+	HandshakesFailed atomic.Uint64
+	TCPRequests      atomic.Uint64
+	UDPSessions      atomic.Uint64
+	Tx               atomic.Uint64
+	Rx               atomic.Uint64
+}
+
+// telemetryAuthenticator counts auth attempts, the handshakes that got as
+// far as the Hysteria protocol.
+type telemetryAuthenticator struct {
+	Authenticator server.Authenticator
+	Metrics       *telemetryMetrics
+}
+
+func (a *telemetryAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	ok, id = a.Authenticator.Authenticate(addr, auth, tx)
+	if ok {
+		a.Metrics.HandshakesOK.Add(1)
+	} else {
+		a.Metrics.HandshakesFailed.Add(1)
+	}
+	return ok, id
+}
+
+var _ server.BandwidthEventLogger = (*telemetryEventLogger)(nil)
+
+// telemetryEventLogger counts connections and requests, and passes
+// everything on to the next logger.
+type telemetryEventLogger struct {
+	EventLogger server.EventLogger
+	Metrics     *telemetryMetrics
+}
+
+func (l *telemetryEventLogger) Connect(addr net.Addr, id string, tx uint64) {
+	l.Metrics.Connections.Add(1)
+	l.EventLogger.Connect(addr, id, tx)
+}
+
+func (l *telemetryEventLogger) Disconnect(addr net.Addr, id string, err error) {
+	l.Metrics.Disconnections.Add(1)
+	l.EventLogger.Disconnect(addr, id, err)
+}
+
+func (l *telemetryEventLogger) BandwidthAdapted(addr net.Addr, id string, oldTx, newTx uint64, ackRate float64) {
+	if bl, ok := l.EventLogger.(server.BandwidthEventLogger); ok {
+		bl.BandwidthAdapted(addr, id, oldTx, newTx, ackRate)
+	}
+}
+
+func (l *telemetryEventLogger) TCPRequest(addr net.Addr, id, reqAddr string) {
+	l.Metrics.TCPRequests.Add(1)
+	l.EventLogger.TCPRequest(addr, id, reqAddr)
+}
+
+func (l *telemetryEventLogger) TCPError(addr net.Addr, id, reqAddr string, err error) {
+	l.EventLogger.TCPError(addr, id, reqAddr, err)
+}
+
+func (l *telemetryEventLogger) UDPRequest(addr net.Addr, id string, sessionID uint32, reqAddr string) {
+	l.Metrics.UDPSessions.Add(1)
+	l.EventLogger.UDPRequest(addr, id, sessionID, reqAddr)
+}
+
+func (l *telemetryEventLogger) UDPError(addr net.Addr, id string, sessionID uint32, err error) {
+	l.EventLogger.UDPError(addr, id, sessionID, err)
+}
+
+// telemetryTrafficLogger counts the bytes sent to (tx) and received from
+// (rx) clients. TrafficLogger may be nil if traffic stats are disabled.
+type telemetryTrafficLogger struct {
+	TrafficLogger server.TrafficLogger
+	Metrics       *telemetryMetrics
+}
+
+func (l *telemetryTrafficLogger) LogTraffic(id string, tx, rx uint64) (ok bool) {
+	l.Metrics.Tx.Add(tx)
+	l.Metrics.Rx.Add(rx)
+	if l.TrafficLogger == nil {
+		return true
+	}
+	return l.TrafficLogger.LogTraffic(id, tx, rx)
+}
+
+func (l *telemetryTrafficLogger) LogOnlineState(id string, online bool) {
+	if l.TrafficLogger != nil {
+		l.TrafficLogger.LogOnlineState(id, online)
+	}
+}
+
+func (l *telemetryTrafficLogger) TraceStream(stream server.HyStream, stats *server.StreamStats) {
+	if l.TrafficLogger != nil {
+		l.TrafficLogger.TraceStream(stream, stats)
+	}
+}
+
+func (l *telemetryTrafficLogger) UntraceStream(stream server.HyStream) {
+	if l.TrafficLogger != nil {
+		l.TrafficLogger.UntraceStream(stream)
+	}
+}
+
+// otlpMetricsURL returns the OTLP/HTTP metrics URL of endpoint, adding the
+// standard /v1/metrics path if it has none, like the OTel SDKs do.
+func otlpMetricsURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q, use http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", errors.New("no host")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/metrics"
+	}
+	return u.String(), nil
+}
+
+// otlpExporter pushes the metrics to an OpenTelemetry collector over
+// OTLP/HTTP, JSON encoded.
+type otlpExporter struct {
+	URL      string
+	Headers  map[string]string
+	Interval time.Duration
+	Metrics  *telemetryMetrics
+	Start    time.Time
+
+	client  *http.Client
+	failing bool
+}
+
+// otlp* are the parts of an ExportMetricsServiceRequest the exporter
+// uses, in the JSON mapping of OTLP.
+type otlpKeyValue struct {
+	Key   string          `json:"key"`
+	Value otlpStringValue `json:"value"`
+}
+
+type otlpStringValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string         `json:"timeUnixNano"`
+	AsInt             string         `json:"asInt"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpDataPoint `json:"dataPoints"`
+	AggregationTemporality int             `json:"aggregationTemporality"`
+	IsMonotonic            bool            `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpDataPoint `json:"dataPoints"`
+}
+
+type otlpMetric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Unit        string     `json:"unit"`
+	Sum         *otlpSum   `json:"sum,omitempty"`
+	Gauge       *otlpGauge `json:"gauge,omitempty"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpMetricsRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+// Payload builds the request body with the metrics as of now.
+func (e *otlpExporter) Payload(now time.Time) ([]byte, error) {
+	start, ts := strconv.FormatInt(e.Start.UnixNano(), 10), strconv.FormatInt(now.UnixNano(), 10)
+	point := func(v uint64, attrs ...string) otlpDataPoint {
+		p := otlpDataPoint{StartTimeUnixNano: start, TimeUnixNano: ts, AsInt: strconv.FormatUint(v, 10)}
+		for i := 0; i+1 < len(attrs); i += 2 {
+			p.Attributes = append(p.Attributes, otlpKeyValue{Key: attrs[i], Value: otlpStringValue{attrs[i+1]}})
+		}
+		return p
+	}
+	counter := func(name, desc, unit string, points ...otlpDataPoint) otlpMetric {
+		return otlpMetric{Name: name, Description: desc, Unit: unit,
+			Sum: &otlpSum{DataPoints: points, AggregationTemporality: otlpCumulative, IsMonotonic: true}}
+	}
+	m := e.Metrics
+	connections, disconnections := m.Connections.Load(), m.Disconnections.Load()
+	active := point(connections - min(disconnections, connections))
+	active.StartTimeUnixNano = ""
+
+	metrics := []otlpMetric{
+		counter("libyalink.connections", "Client connections accepted", "{connection}", point(connections)),
+		{Name: "libyalink.connections.active", Description: "Client connections open", Unit: "{connection}",
+			Gauge: &otlpGauge{DataPoints: []otlpDataPoint{active}}},
+		counter("libyalink.handshakes", "Auth attempts, by result", "{handshake}",
+			point(m.HandshakesOK.Load(), "result", "success"),
+			point(m.HandshakesFailed.Load(), "result", "failure")),
+		counter("libyalink.traffic", "Bytes sent to (tx) and received from (rx) clients", "By",
+			point(m.Tx.Load(), "direction", "tx"),
+			point(m.Rx.Load(), "direction", "rx")),
+		counter("libyalink.tcp.requests", "TCP proxy requests", "{request}", point(m.TCPRequests.Load())),
+		counter("libyalink.udp.sessions", "UDP sessions", "{session}", point(m.UDPSessions.Load())),
+	}
+	req := otlpMetricsRequest{ResourceMetrics: []otlpResourceMetrics{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			{Key: "service.name", Value: otlpStringValue{"libyalink"}},
+			{Key: "service.version", Value: otlpStringValue{appVersion}},
+		}},
+		ScopeMetrics: []otlpScopeMetrics{{
+			Scope:   otlpScope{Name: "libyalink", Version: appVersion},
+			Metrics: metrics,
+		}},
+	}}}
+	return json.Marshal(req)
+}
+
+// Push sends the metrics to the collector once.
+func (e *otlpExporter) Push() error {
+	body, err := e.Payload(time.Now())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryPushTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "libyalink/"+appVersion)
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+	client := e.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+// pushLoop pushes the metrics every interval, and logs when pushing starts
+// and stops failing rather than on every attempt.
+func (e *otlpExporter) pushLoop() {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := e.Push()
+		switch {
+		case err != nil && !e.failing:
+			logger.Warn("failed to push metrics to the OTLP collector, will retry", zap.String("url", e.URL), zap.Error(err))
+		case err == nil && e.failing:
+			logger.Info("pushing metrics to the OTLP collector again", zap.String("url", e.URL))
+		}
+		e.failing = err != nil
+	}
+}
+
+// fillTelemetry must be called after fillEventLogger, fillTrafficLogger and
+// fillQuota, as it wraps them, after fillSelfTest, as it wraps the
+// authenticator, and before fillDebugTrace.
+func (c *serverConfig) fillTelemetry(hyConfig *server.Config) error {
+	if c.Telemetry.OTLPEndpoint == "" {
+		return nil
+	}
+	u, err := otlpMetricsURL(c.Telemetry.OTLPEndpoint)
+	if err != nil {
+		return configError{Field: "telemetry.otlpEndpoint", Err: err}
+	}
+	interval := c.Telemetry.Interval
+	if interval == 0 {
+		interval = defaultTelemetryInterval
+	} else if interval < time.Second {
+		return configError{Field: "telemetry.interval", Err: errors.New("must be at least 1s")}
+	}
+	m := &telemetryMetrics{}
+	hyConfig.Authenticator = &telemetryAuthenticator{Authenticator: hyConfig.Authenticator, Metrics: m}
+	hyConfig.EventLogger = &telemetryEventLogger{EventLogger: hyConfig.EventLogger, Metrics: m}
+	hyConfig.TrafficLogger = &telemetryTrafficLogger{TrafficLogger: hyConfig.TrafficLogger, Metrics: m}
+	e := &otlpExporter{
+		URL:      u,
+		Headers:  c.Telemetry.Headers,
+		Interval: interval,
+		Metrics:  m,
+		Start:    time.Now(),
+	}
+	logger.Info("pushing metrics to OTLP collector", zap.String("url", u), zap.Duration("interval", interval))
+	go e.pushLoop()
+	return nil
+}
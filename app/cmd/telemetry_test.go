@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestOTLPMetricsURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/metrics"},
+		{"https://otel.example.com/", "https://otel.example.com/v1/metrics"},
+		{"https://otel.example.com/otlp/v1/metrics", "https://otel.example.com/otlp/v1/metrics"},
+	}
+	for _, tt := range tests {
+		got, err := otlpMetricsURL(tt.endpoint)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+	for _, endpoint := range []string{"localhost:4317", "grpc://localhost:4317", "http://"} {
+		_, err := otlpMetricsURL(endpoint)
+		assert.Error(t, err, endpoint)
+	}
+}
+
+func TestTelemetryWrappers(t *testing.T) {
+	oldLogger := logger
+	logger = zap.NewNop()
+	defer func() { logger = oldLogger }()
+
+	m := &telemetryMetrics{}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40001}
+	a := &telemetryAuthenticator{Authenticator: passwordAuthenticator("weak_ahh_password"), Metrics: m}
+	ok, _ := a.Authenticate(addr, "weak_ahh_password", 0)
+	assert.True(t, ok)
+	ok, _ = a.Authenticate(addr, "wrong", 0)
+	assert.False(t, ok)
+
+	l := &telemetryEventLogger{EventLogger: &serverLogger{}, Metrics: m}
+	l.Connect(addr, "user", 0)
+	l.Connect(addr, "user", 0)
+	l.TCPRequest(addr, "user", "example.com:443")
+	l.UDPRequest(addr, "user", 1, "1.1.1.1:53")
+	l.Disconnect(addr, "user", nil)
+
+	tl := &telemetryTrafficLogger{Metrics: m}
+	assert.True(t, tl.LogTraffic("user", 100, 20))
+	tl.LogOnlineState("user", true)
+
+	assert.Equal(t, uint64(1), m.HandshakesOK.Load())
+	assert.Equal(t, uint64(1), m.HandshakesFailed.Load())
+	assert.Equal(t, uint64(2), m.Connections.Load())
+	assert.Equal(t, uint64(1), m.Disconnections.Load())
+	assert.Equal(t, uint64(1), m.TCPRequests.Load())
+	assert.Equal(t, uint64(1), m.UDPSessions.Load())
+	assert.Equal(t, uint64(100), m.Tx.Load())
+	assert.Equal(t, uint64(20), m.Rx.Load())
+}
+
+func TestOTLPExporterPush(t *testing.T) {
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/metrics", r.URL.Path)
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	m := &telemetryMetrics{}
+	m.Connections.Add(3)
+	m.Disconnections.Add(1)
+	m.HandshakesFailed.Add(5)
+	m.Tx.Add(1000)
+	url, err := otlpMetricsURL(srv.URL)
+	assert.NoError(t, err)
+	e := &otlpExporter{
+		URL:     url,
+		Headers: map[string]string{"authorization": "Bearer otel_token"},
+		Metrics: m,
+		Start:   time.Now().Add(-time.Minute),
+	}
+	assert.NoError(t, e.Push())
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	assert.Equal(t, "Bearer otel_token", header.Get("Authorization"))
+
+	var req otlpMetricsRequest
+	assert.NoError(t, json.Unmarshal(body, &req))
+	assert.Len(t, req.ResourceMetrics, 1)
+	assert.Equal(t, "service.name", req.ResourceMetrics[0].Resource.Attributes[0].Key)
+	metrics := map[string]otlpMetric{}
+	for _, metric := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+	assert.Equal(t, "3", metrics["libyalink.connections"].Sum.DataPoints[0].AsInt)
+	assert.True(t, metrics["libyalink.connections"].Sum.IsMonotonic)
+	assert.Equal(t, otlpCumulative, metrics["libyalink.connections"].Sum.AggregationTemporality)
+	assert.Equal(t, "2", metrics["libyalink.connections.active"].Gauge.DataPoints[0].AsInt)
+	handshakes := metrics["libyalink.handshakes"].Sum.DataPoints
+	assert.Equal(t, "failure", handshakes[1].Attributes[0].Value.StringValue)
+	assert.Equal(t, "5", handshakes[1].AsInt)
+	assert.Equal(t, "1000", metrics["libyalink.traffic"].Sum.DataPoints[0].AsInt)
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	assert.ErrorContains(t, e.Push(), "401")
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apernet/hysteria/core/v2/client"
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+var (
+	testAuthServer   string
+	testAuthAuth     string
+	testAuthObfs     string
+	testAuthSNI      string
+	testAuthInsecure bool
+	testAuthTimeout  time.Duration
+)
+
+var testAuthCmd = &cobra.Command{
+	Use:   "test-auth",
+	Short: "Check whether a server accepts a password",
+	Long: `Connect to a server, authenticate and disconnect right away, without starting
+any proxy. Use it to check a user's password when they report that it doesn't
+work.
+
+The exit code is 0 if the server accepted the password, 1 if it rejected it,
+2 if the connection failed before auth (wrong port, blocked UDP, wrong
+obfs password or a TLS problem), 3 if the password is right but the
+server is full (limits.maxConnections) and 4 if the password is right but the
+user's data quota is used up (limits.quota).
+
+Examples:
+  libyalink test-auth --server 1.2.3.4:443 --auth "mypassword" --insecure
+  libyalink test-auth --server example.com:443 --auth "alice:her_password" --obfs "obfs_password"`,
+	Run: runTestAuth,
+}
+
+func init() {
+	initTestAuthFlags()
+	rootCmd.AddCommand(testAuthCmd)
+}
+
+func initTestAuthFlags() {
+	testAuthCmd.Flags().StringVar(&testAuthServer, "server", "", "server address, host:port (required)")
+	testAuthCmd.Flags().StringVar(&testAuthAuth, "auth", "", "password to check, user:password for userpass auth (required)")
+	testAuthCmd.Flags().StringVar(&testAuthObfs, "obfs", "", "obfuscation password (salamander)")
+	testAuthCmd.Flags().StringVar(&testAuthSNI, "sni", "", "TLS SNI (default: the server host)")
+	testAuthCmd.Flags().BoolVar(&testAuthInsecure, "insecure", false, "skip TLS certificate verification, e.g. for a self-signed certificate")
+	testAuthCmd.Flags().DurationVar(&testAuthTimeout, "timeout", 10*time.Second, "give up if the server doesn't answer for this long (4s-120s)")
+
+	testAuthCmd.MarkFlagRequired("server")
+	testAuthCmd.MarkFlagRequired("auth")
+}
+
+func runTestAuth(cmd *cobra.Command, args []string) {
+	config := clientConfig{
+		Server: testAuthServer,
+		Auth:   testAuthAuth,
+		TLS: clientConfigTLS{
+			SNI:      testAuthSNI,
+			Insecure: testAuthInsecure,
+		},
+		QUIC: clientConfigQUIC{
+			MaxIdleTimeout: testAuthTimeout,
+		},
+	}
+	if testAuthObfs != "" {
+		config.Obfs.Type = "salamander"
+		config.Obfs.Salamander.Password = testAuthObfs
+	}
+	hyConfig, err := config.Config()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	c, info, err := client.NewClient(hyConfig)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err == nil {
+		_ = c.Close()
+		fmt.Printf("%s allowed: %s accepted the password in %s (UDP enabled: %v)\n",
+			checkOK, testAuthServer, elapsed, info.UDPEnabled)
+		return
+	}
+	message, code := testAuthVerdict(err)
+	fmt.Printf("%s %s\n", checkFail, message)
+	os.Exit(code)
+}
+
+// testAuthVerdict explains a failed handshake and returns the exit code:
+// 1 if the server rejected the password, 2 if auth was never attempted,
+// 3 if the password is right but the server is full, 4 if it's right but the
+// user's quota is used up.
+func testAuthVerdict(err error) (string, int) {
+	var fullErr hyErrors.ServerFullError
+	if errors.As(err, &fullErr) {
+		msg := "full: the server accepted the password but is at limits.maxConnections, try again later"
+		if fullErr.Fallback != "" {
+			msg += " or use the fallback server " + fullErr.Fallback
+		}
+		return msg, 3
+	}
+	var quotaErr hyErrors.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		msg := "quota: the server accepted the password but the user's data quota (limits.quota) is used up"
+		if !quotaErr.Reset.IsZero() {
+			msg += ", it resets on " + quotaErr.Reset.Local().Format(time.DateTime)
+		}
+		return msg, 4
+	}
+	var authErr hyErrors.AuthError
+	if errors.As(err, &authErr) {
+		// The server doesn't say why, a rejected request gets the
+		// masquerade response like any other HTTP/3 request
+		return fmt.Sprintf("denied: the server rejected the password (HTTP status %d)", authErr.StatusCode), 1
+	}
+	var netErr net.Error
+	msg := err.Error()
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout(), strings.Contains(msg, "timeout"):
+		return fmt.Sprintf("no answer: %v. Check the port, that UDP isn't blocked, and the --obfs password "+
+			"(a server with obfs ignores packets without the right one).", err), 2
+	case strings.Contains(msg, "certificate") || strings.Contains(msg, "x509"):
+		return fmt.Sprintf("TLS failed: %v. Use --sni with the certificate's name, or --insecure for a self-signed one.", err), 2
+	default:
+		return fmt.Sprintf("connection failed: %v", err), 2
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	hyErrors "github.com/apernet/hysteria/core/v2/errors"
+)
+
+func TestTestAuthVerdict(t *testing.T) {
+	msg, code := testAuthVerdict(hyErrors.AuthError{StatusCode: 404})
+	assert.Equal(t, 1, code)
+	assert.Contains(t, msg, "404")
+
+	msg, code = testAuthVerdict(hyErrors.ServerFullError{Fallback: "backup.example.com:443"})
+	assert.Equal(t, 3, code)
+	assert.Contains(t, msg, "backup.example.com:443")
+
+	msg, code = testAuthVerdict(hyErrors.QuotaExceededError{Reset: time.Date(2026, 11, 1, 0, 0, 0, 0, time.Local)})
+	assert.Equal(t, 4, code)
+	assert.Contains(t, msg, "2026-11-01 00:00:00")
+
+	_, code = testAuthVerdict(hyErrors.ConnectError{Err: errors.New("timeout: no recent network activity")})
+	assert.Equal(t, 2, code)
+
+	msg, code = testAuthVerdict(hyErrors.ConnectError{Err: errors.New("CRYPTO_ERROR 0x12a (local): tls: failed to verify certificate: x509: certificate signed by unknown authority")})
+	assert.Equal(t, 2, code)
+	assert.Contains(t, msg, "--insecure")
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	defaultTraceTimeout = 10 * time.Minute
+)
+
+type serverConfigDebug struct {
+	TraceUser    string        `mapstructure:"traceUser"`
+	TraceTimeout time.Duration `mapstructure:"traceTimeout"`
+}
+
+// userTracer decides whether events of a connection should be traced.
+// Only one user is traced, and only until the deadline, so that tracing
+// can be left on without flooding the logs of a busy server.
+type userTracer struct {
+	User     string
+	Deadline time.Time
+
+	expireOnce sync.Once
+}
+
+func (t *userTracer) Active(id string) bool {
+	if !strings.EqualFold(id, t.User) {
+		return false
+	}
+	if time.Now().After(t.Deadline) {
+		t.expireOnce.Do(func() {
+			logger.Info("user trace expired, no longer tracing", zap.String("id", t.User))
+		})
+		return false
+	}
+	return true
+}
+
+func (t *userTracer) Log(msg string, fields ...zap.Field) {
+	logger.Named("trace").Info(msg, fields...)
+}
+
+// traceAuthenticator logs every auth attempt that belongs to the traced user.
+// Failed attempts can only be attributed to a user with userpass auth,
+// where the username is part of the auth string.
+type traceAuthenticator struct {
+	Authenticator server.Authenticator
+	Tracer        *userTracer
+}
+
+func (a *traceAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	ok, id = a.Authenticator.Authenticate(addr, auth, tx)
+	traceID := id
+	if !ok {
+		traceID, _, _ = strings.Cut(auth, ":")
+	}
+	if a.Tracer.Active(traceID) {
+		a.Tracer.Log("auth attempt", zap.String("addr", addr.String()), zap.String("id", traceID),
+			zap.Bool("ok", ok), zap.Uint64("tx", tx))
+	}
+	return ok, id
+}
+
+// traceEventLogger promotes the debug-level connection events of the
+// traced user to info level, and passes everything on to the next logger.
+type traceEventLogger struct {
+	EventLogger server.EventLogger
+	Tracer      *userTracer
+}
+
+func (l *traceEventLogger) Connect(addr net.Addr, id string, tx uint64) {
+	if l.Tracer.Active(id) {
+		l.Tracer.Log("connected", zap.String("addr", addr.String()), zap.String("id", id), zap.Uint64("tx", tx))
+	}
+	l.EventLogger.Connect(addr, id, tx)
+}
+
+func (l *traceEventLogger) Disconnect(addr net.Addr, id string, err error) {
+	if l.Tracer.Active(id) {
+		l.Tracer.Log("disconnected", zap.String("addr", addr.String()), zap.String("id", id), zap.Error(err))
+	}
+	l.EventLogger.Disconnect(addr, id, err)
+}
+
+func (l *traceEventLogger) TCPRequest(addr net.Addr, id, reqAddr string) {
+	if l.Tracer.Active(id) {
+		l.Tracer.Log("TCP request", zap.String("addr", addr.String()), zap.String("id", id), zap.String("reqAddr", reqAddr))
+	}
+	l.EventLogger.TCPRequest(addr, id, reqAddr)
+}
+
+func (l *traceEventLogger) TCPError(addr net.Addr, id, reqAddr string, err error) {
+	if l.Tracer.Active(id) {
+		l.Tracer.Log("TCP closed", zap.String("addr", addr.String()), zap.String("id", id), zap.String("reqAddr", reqAddr), zap.Error(err))
+	}
+	l.EventLogger.TCPError(addr, id, reqAddr, err)
+}
+
+func (l *traceEventLogger) UDPRequest(addr net.Addr, id string, sessionID uint32, reqAddr string) {
+	if l.Tracer.Active(id) {
+		l.Tracer.Log("UDP request", zap.String("addr", addr.String()), zap.String("id", id), zap.Uint32("sessionID", sessionID), zap.String("reqAddr", reqAddr))
+	}
+	l.EventLogger.UDPRequest(addr, id, sessionID, reqAddr)
+}
+
+func (l *traceEventLogger) UDPError(addr net.Addr, id string, sessionID uint32, err error) {
+	if l.Tracer.Active(id) {
+		l.Tracer.Log("UDP closed", zap.String("addr", addr.String()), zap.String("id", id), zap.Uint32("sessionID", sessionID), zap.Error(err))
+	}
+	l.EventLogger.UDPError(addr, id, sessionID, err)
+}
+
+// traceTrafficLogger logs every chunk of traffic of the traced user.
+type traceTrafficLogger struct {
+	TrafficLogger server.TrafficLogger
+	Tracer        *userTracer
+}
+
+func (l *traceTrafficLogger) LogTraffic(id string, tx, rx uint64) (ok bool) {
+	if l.Tracer.Active(id) {
+		l.Tracer.Log("traffic", zap.String("id", id), zap.Uint64("tx", tx), zap.Uint64("rx", rx))
+	}
+	return l.TrafficLogger.LogTraffic(id, tx, rx)
+}
+
+func (l *traceTrafficLogger) LogOnlineState(id string, online bool) {
+	l.TrafficLogger.LogOnlineState(id, online)
+}
+
+func (l *traceTrafficLogger) TraceStream(stream server.HyStream, stats *server.StreamStats) {
+	l.TrafficLogger.TraceStream(stream, stats)
+}
+
+func (l *traceTrafficLogger) UntraceStream(stream server.HyStream) {
+	l.TrafficLogger.UntraceStream(stream)
+}
@@ -0,0 +1,502 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// userPassSeparator is what clients put between the username and the
+// password in their auth string.
+const userPassSeparator = ":"
+
+var (
+	usersPassword string
+	usersReplace  bool
+	usersDryRun   bool
+	usersOutput   string
+)
+
+var usersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "Manage the userpass users in the server config",
+	Long: `List, add, remove, import and export the users of auth.userpass in the server
+config file (-c, or the default locations). Only auth.userpass is changed, the
+other keys and their comments are kept, but the file is rewritten with 2-space
+indentation. Each change is checked before anything is written, and the file
+is replaced atomically. Restart the server to apply it.
+
+Usernames are case-insensitive and stored in lowercase. Passwords need at
+least 8 characters and no characters that break share links.
+
+Examples:
+  libyalink users list -c config.yaml
+  libyalink users add alice -c config.yaml
+  libyalink users add bob --password "bobs_password" -c config.yaml
+  libyalink users remove alice bob -c config.yaml
+  libyalink users import users.csv -c config.yaml
+  libyalink users export -c config.yaml -o users.csv
+
+The CSV file has one "username,password" line per user. A header line with
+these names and lines starting with # are skipped.`,
+}
+
+var usersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the usernames",
+	Args:  cobra.NoArgs,
+	Run:   runUsersList,
+}
+
+var usersAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Add a user, with a random password unless --password is given",
+	Args:  cobra.ExactArgs(1),
+	Run:   runUsersAdd,
+}
+
+var usersRemoveCmd = &cobra.Command{
+	Use:   "remove <username>...",
+	Short: "Remove users",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runUsersRemove,
+}
+
+var usersImportCmd = &cobra.Command{
+	Use:   "import <users.csv>",
+	Short: "Add or update the users in a CSV file",
+	Args:  cobra.ExactArgs(1),
+	Run:   runUsersImport,
+}
+
+var usersExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the users and passwords as CSV",
+	Args:  cobra.NoArgs,
+	Run:   runUsersExport,
+}
+
+func init() {
+	initUsersFlags()
+	usersCmd.AddCommand(usersListCmd, usersAddCmd, usersRemoveCmd, usersImportCmd, usersExportCmd)
+	rootCmd.AddCommand(usersCmd)
+}
+
+func initUsersFlags() {
+	usersAddCmd.Flags().StringVar(&usersPassword, "password", "", "password of the user (default: a random one, printed)")
+	usersImportCmd.Flags().BoolVar(&usersReplace, "replace", false, "remove the users that aren't in the CSV file")
+	usersImportCmd.Flags().BoolVar(&usersDryRun, "dry-run", false, "only print what would change")
+	usersExportCmd.Flags().StringVarP(&usersOutput, "output", "o", "", "write to this file instead of stdout")
+}
+
+func runUsersList(cmd *cobra.Command, args []string) {
+	_, _, users := loadUsersConfigOrExit()
+	names := sortedUsernames(users)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	fmt.Fprintf(os.Stderr, "%d user(s)\n", len(names))
+}
+
+func runUsersAdd(cmd *cobra.Command, args []string) {
+	path, root, users := loadUsersConfigOrExit()
+	name := strings.ToLower(args[0])
+	if _, ok := users[name]; ok {
+		fmt.Fprintf(os.Stderr, "Error: user %s already exists. Remove it first to change the password.\n", name)
+		os.Exit(1)
+	}
+	password := usersPassword
+	generated := password == ""
+	if generated {
+		password = randomInitPassword()
+	}
+	if err := validateUserEntry(name, password); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	users[name] = password
+	saveUsersConfigOrExit(path, root, users)
+	if generated {
+		fmt.Printf("%s Added %s with password: %s\n", checkOK, name, password)
+	} else {
+		fmt.Printf("%s Added %s\n", checkOK, name)
+	}
+	fmt.Println("Restart the server to apply.")
+}
+
+func runUsersRemove(cmd *cobra.Command, args []string) {
+	path, root, users := loadUsersConfigOrExit()
+	for _, arg := range args {
+		name := strings.ToLower(arg)
+		if _, ok := users[name]; !ok {
+			fmt.Fprintf(os.Stderr, "Error: no user %s, nothing was removed.\n", name)
+			os.Exit(1)
+		}
+		delete(users, name)
+	}
+	if len(users) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: that would remove every user, and the server doesn't start with an empty auth.userpass.")
+		os.Exit(1)
+	}
+	saveUsersConfigOrExit(path, root, users)
+	fmt.Printf("%s Removed %d user(s), %d left. Restart the server to apply.\n", checkOK, len(args), len(users))
+}
+
+func runUsersImport(cmd *cobra.Command, args []string) {
+	path, root, users := loadUsersConfigOrExit()
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	imported, err := parseUsersCSV(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: nothing was imported:\n%v\n", args[0], err)
+		os.Exit(1)
+	}
+	if len(imported) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: %s has no users.\n", args[0])
+		os.Exit(1)
+	}
+
+	var added, updated, removed []string
+	for name, password := range imported {
+		if old, ok := users[name]; !ok {
+			added = append(added, name)
+		} else if old != password {
+			updated = append(updated, name)
+		}
+	}
+	if usersReplace {
+		for name := range users {
+			if _, ok := imported[name]; !ok {
+				removed = append(removed, name)
+			}
+		}
+		users = imported
+	} else {
+		for name, password := range imported {
+			users[name] = password
+		}
+	}
+	for _, names := range [][]string{added, updated, removed} {
+		slices.Sort(names)
+	}
+	fmt.Printf("Added: %d %s\n", len(added), strings.Join(added, ", "))
+	fmt.Printf("Updated: %d %s\n", len(updated), strings.Join(updated, ", "))
+	if usersReplace {
+		fmt.Printf("Removed: %d %s\n", len(removed), strings.Join(removed, ", "))
+	}
+	if usersDryRun {
+		fmt.Println("Dry run, the config file wasn't changed.")
+		return
+	}
+	if len(added)+len(updated)+len(removed) == 0 {
+		fmt.Printf("%s Nothing to change.\n", checkOK)
+		return
+	}
+	saveUsersConfigOrExit(path, root, users)
+	fmt.Printf("%s %s now has %d user(s). Restart the server to apply.\n", checkOK, path, len(users))
+}
+
+func runUsersExport(cmd *cobra.Command, args []string) {
+	_, _, users := loadUsersConfigOrExit()
+	var buf bytes.Buffer
+	if err := writeUsersCSV(&buf, users); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if usersOutput == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	// It holds every password
+	if err := os.WriteFile(usersOutput, buf.Bytes(), 0o600); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "%s Exported %d user(s) to %s\n", checkOK, len(users), usersOutput)
+}
+
+// loadUsersConfigOrExit reads the config file as a node tree, so that it
+// can be written back with everything but the users unchanged.
+func loadUsersConfigOrExit() (path string, root *yaml.Node, users map[string]string) {
+	path = cfgFile
+	if path == "" {
+		if err := viper.ReadInConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: no config file found, use -c: %v\n", err)
+			os.Exit(1)
+		}
+		path = viper.ConfigFileUsed()
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: %s: only YAML config files are supported.\n", path)
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	root = &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot parse %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	users, err = configUserPass(root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return path, root, users
+}
+
+func saveUsersConfigOrExit(path string, root *yaml.Node, users map[string]string) {
+	if err := setConfigUserPass(root, users); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	_ = enc.Close()
+	if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot write %s: %v\n", path, err)
+		os.Exit(1)
+	}
+}
+
+// writeFileAtomic replaces a file with data, keeping its mode, so that the
+// server never reads a partially written config.
+func writeFileAtomic(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // No-op after the rename
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(info.Mode().Perm()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// yamlMappingValue returns the value of key in a mapping node, or nil.
+func yamlMappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// configMapping returns the top-level mapping of a config document,
+// creating it for an empty file.
+func configMapping(root *yaml.Node) (*yaml.Node, error) {
+	if root.Kind == 0 {
+		root.Kind = yaml.DocumentNode
+	}
+	if root.Kind != yaml.DocumentNode {
+		return nil, errors.New("not a YAML document")
+	}
+	if len(root.Content) == 0 {
+		root.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	m := root.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return nil, errors.New("the config is not a mapping")
+	}
+	return m, nil
+}
+
+// configUserPass returns the users of auth.userpass, with lowercase names.
+// It fails if the config uses another auth type.
+func configUserPass(root *yaml.Node) (map[string]string, error) {
+	m, err := configMapping(root)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]string)
+	authNode := yamlMappingValue(m, "auth")
+	if authNode == nil {
+		return users, nil
+	}
+	if authNode.Kind != yaml.MappingNode {
+		return nil, errors.New("auth is not a mapping")
+	}
+	if t := yamlMappingValue(authNode, "type"); t != nil && !strings.EqualFold(t.Value, "userpass") {
+		return nil, fmt.Errorf("auth.type is %q. Set it to userpass (and remove auth.password) to manage users", t.Value)
+	}
+	up := yamlMappingValue(authNode, "userpass")
+	if up == nil {
+		return users, nil
+	}
+	if up.Kind != yaml.MappingNode {
+		return nil, errors.New("auth.userpass is not a mapping")
+	}
+	for i := 0; i+1 < len(up.Content); i += 2 {
+		users[strings.ToLower(up.Content[i].Value)] = up.Content[i+1].Value
+	}
+	return users, nil
+}
+
+// setConfigUserPass makes auth.userpass hold exactly users. Existing entries
+// stay in place with their comments, new ones are appended in name order.
+func setConfigUserPass(root *yaml.Node, users map[string]string) error {
+	m, err := configMapping(root)
+	if err != nil {
+		return err
+	}
+	authNode := yamlMappingValue(m, "auth")
+	if authNode == nil {
+		authNode = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		m.Content = append(m.Content, yamlStringNode("auth"), authNode)
+	}
+	if yamlMappingValue(authNode, "type") == nil {
+		authNode.Content = append([]*yaml.Node{yamlStringNode("type"), yamlStringNode("userpass")}, authNode.Content...)
+	}
+	up := yamlMappingValue(authNode, "userpass")
+	if up == nil {
+		up = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		authNode.Content = append(authNode.Content, yamlStringNode("userpass"), up)
+	}
+
+	seen := make(map[string]bool, len(users))
+	content := make([]*yaml.Node, 0, 2*len(users))
+	for i := 0; i+1 < len(up.Content); i += 2 {
+		name := strings.ToLower(up.Content[i].Value)
+		password, ok := users[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if up.Content[i+1].Value != password {
+			up.Content[i+1].SetString(password)
+		}
+		content = append(content, up.Content[i], up.Content[i+1])
+	}
+	for _, name := range sortedUsernames(users) {
+		if !seen[name] {
+			content = append(content, yamlStringNode(name), yamlStringNode(users[name]))
+		}
+	}
+	up.Content = content
+	return nil
+}
+
+func yamlStringNode(s string) *yaml.Node {
+	n := &yaml.Node{}
+	n.SetString(s)
+	return n
+}
+
+func sortedUsernames(users map[string]string) []string {
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// validateUserEntry checks a new userpass entry. Existing entries aren't
+// checked, so that a weak old password doesn't block other changes.
+func validateUserEntry(name, password string) error {
+	switch {
+	case name == "":
+		return errors.New("empty username")
+	case strings.Contains(name, userPassSeparator):
+		return fmt.Errorf("username %q contains %q, which separates it from the password", name, userPassSeparator)
+	case strings.ContainsFunc(name, func(r rune) bool { return r <= ' ' || r == 0x7f }):
+		return fmt.Errorf("username %q contains whitespace or control characters", name)
+	}
+	if err := validateInitPassword(password); err != nil {
+		return fmt.Errorf("user %s: %w", name, err)
+	}
+	return nil
+}
+
+// parseUsersCSV reads "username,password" records. It returns every invalid
+// or duplicate entry in the error, with its line number.
+func parseUsersCSV(r io.Reader) (map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	users := make(map[string]string)
+	lines := make(map[string]int)
+	var errs []error
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if first && len(record) == 2 && strings.EqualFold(strings.TrimSpace(record[0]), "username") &&
+			strings.EqualFold(strings.TrimSpace(record[1]), "password") {
+			continue
+		}
+		if len(record) != 2 {
+			errs = append(errs, fmt.Errorf("line %d: want 2 fields (username,password), got %d", line, len(record)))
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(record[0]))
+		password := record[1]
+		if err := validateUserEntry(name, password); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		if prev, ok := lines[name]; ok {
+			errs = append(errs, fmt.Errorf("line %d: user %s is already on line %d", line, name, prev))
+			continue
+		}
+		users[name] = password
+		lines[name] = line
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return users, nil
+}
+
+func writeUsersCSV(w io.Writer, users map[string]string) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"username", "password"})
+	for _, name := range sortedUsernames(users) {
+		_ = cw.Write([]string{name, users[name]})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseUsersCSV(t *testing.T) {
+	users, err := parseUsersCSV(strings.NewReader(`username,password
+# Staff
+Alice,alice_password
+bob,"bob,password"
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice": "alice_password", "bob": "bob,password"}, users)
+
+	_, err = parseUsersCSV(strings.NewReader(`alice,alice_password
+carol:x,carol_password
+dave,short
+ALICE,another_password
+erin
+`))
+	if assert.Error(t, err) {
+		msg := err.Error()
+		assert.Contains(t, msg, "line 2:")
+		assert.Contains(t, msg, "line 3:")
+		assert.Contains(t, msg, "line 4: user alice is already on line 1")
+		assert.Contains(t, msg, "line 5:")
+	}
+}
+
+func TestSetConfigUserPass(t *testing.T) {
+	var root yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte(`listen: :443 # public port
+auth:
+  type: userpass
+  userpass:
+    alice: alice_password # the admin
+    bob: bob_password
+masquerade:
+  type: string
+`), &root))
+	users, err := configUserPass(&root)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"alice": "alice_password", "bob": "bob_password"}, users)
+
+	delete(users, "bob")
+	users["alice"] = "new_alice_password"
+	users["carol"] = "12345678"
+	assert.NoError(t, setConfigUserPass(&root, users))
+	bs, err := yaml.Marshal(&root)
+	assert.NoError(t, err)
+	out := string(bs)
+	assert.Contains(t, out, "# public port")
+	assert.Contains(t, out, "alice: new_alice_password # the admin")
+	assert.NotContains(t, out, "bob")
+	assert.Contains(t, out, "masquerade:")
+
+	var got struct {
+		Auth struct {
+			Type     string            `yaml:"type"`
+			UserPass map[string]string `yaml:"userpass"`
+		} `yaml:"auth"`
+	}
+	assert.NoError(t, yaml.Unmarshal(bs, &got))
+	assert.Equal(t, "userpass", got.Auth.Type)
+	assert.Equal(t, map[string]string{"alice": "new_alice_password", "carol": "12345678"}, got.Auth.UserPass)
+
+	// An empty config gets auth.type too
+	var empty yaml.Node
+	assert.NoError(t, setConfigUserPass(&empty, map[string]string{"dave": "dave_password"}))
+	bs, err = yaml.Marshal(&empty)
+	assert.NoError(t, err)
+	got.Auth.UserPass = nil
+	assert.NoError(t, yaml.Unmarshal(bs, &got))
+	assert.Equal(t, "userpass", got.Auth.Type)
+	assert.Equal(t, map[string]string{"dave": "dave_password"}, got.Auth.UserPass)
+
+	var password yaml.Node
+	assert.NoError(t, yaml.Unmarshal([]byte("auth:\n  type: password\n  password: x\n"), &password))
+	_, err = configUserPass(&password)
+	assert.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/apernet/hysteria/app/v2/internal/utils"
+)
+
+var verifyConfigCmd = &cobra.Command{
+	Use:   "verify-config file",
+	Short: "Verify the checksum footer of a generated config",
+	Long: `Check that a config generated by gen-client has not been changed since,
+for example by a messaging app reformatting it on the way. gen-client ends
+its output with a "sha256: <hash>" comment line, which is recomputed here.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runVerifyConfig,
+}
+
+func init() {
+	rootCmd.AddCommand(verifyConfigCmd)
+}
+
+func runVerifyConfig(cmd *cobra.Command, args []string) {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	_, err = utils.VerifyChecksumFooter(data)
+	switch {
+	case err == nil:
+		fmt.Printf("  %s %s is intact.\n", checkOK, args[0])
+	case errors.Is(err, utils.ErrChecksumLineEndings):
+		fmt.Printf("  %s %s: %v.\n", checkWarn, args[0], err)
+	default:
+		fmt.Printf("  %s %s: %v.\n", checkFail, args[0], err)
+		os.Exit(1)
+	}
+}
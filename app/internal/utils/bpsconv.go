@@ -3,6 +3,7 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -52,6 +53,47 @@ func StringToBps(s string) (uint64, error) {
 	}
 }
 
+// StringToBytes converts a string to a size in bytes, in decimal units.
+// E.g. "50GB", "500 MB", "1t" are all valid. Unlike StringToBps, "b"
+// means bytes here.
+func StringToBytes(s string) (uint64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	spl := 0
+	for i, c := range s {
+		if c < '0' || c > '9' {
+			spl = i
+			break
+		}
+	}
+	if spl == 0 {
+		// No unit or no value
+		return 0, errors.New("invalid format")
+	}
+	v, err := strconv.ParseUint(s[:spl], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var unit uint64
+	switch strings.TrimSpace(s[spl:]) {
+	case "b":
+		unit = Byte
+	case "k", "kb":
+		unit = Kilobyte
+	case "m", "mb":
+		unit = Megabyte
+	case "g", "gb":
+		unit = Gigabyte
+	case "t", "tb":
+		unit = Terabyte
+	default:
+		return 0, errors.New("unsupported unit")
+	}
+	if v > math.MaxUint64/unit {
+		return 0, errors.New("value too large")
+	}
+	return v * unit, nil
+}
+
 // ConvBandwidth handles both string and int types for bandwidth.
 // When using string, it will be parsed as a bandwidth string with units.
 // When using int, it will be parsed as a raw bandwidth in bytes per second.
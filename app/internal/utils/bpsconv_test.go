@@ -38,3 +38,36 @@ func TestStringToBps(t *testing.T) {
 		})
 	}
 }
+
+func TestStringToBytes(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    uint64
+		wantErr bool
+	}{
+		{"bytes", "800 B", 800, false},
+		{"kb", "800 kb", 800_000, false},
+		{"mb", "500MB", 500_000_000, false},
+		{"gb", "50GB", 50_000_000_000, false},
+		{"tb", "2 TB", 2_000_000_000_000, false},
+		{"gb simp", "10g", 10_000_000_000, false},
+		{"invalid 1", "damn", 0, true},
+		{"invalid 2", "6444", 0, true},
+		{"invalid 3", "1.5 GB", 0, true},
+		{"invalid 4", "50 gbps", 0, true},
+		{"overflow", "99999999999 tb", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StringToBytes(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StringToBytes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("StringToBytes() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
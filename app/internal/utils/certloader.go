@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -17,8 +18,9 @@ type LocalCertificateLoader struct {
 	KeyFile  string
 	SNIGuard SNIGuardFunc
 
-	lock  sync.Mutex
-	cache atomic.Pointer[localCertificateCache]
+	lock     sync.Mutex
+	cache    atomic.Pointer[localCertificateCache]
+	watching atomic.Bool
 }
 
 type SNIGuardFunc func(info *tls.ClientHelloInfo, cert *tls.Certificate) error
@@ -47,6 +49,87 @@ func (l *LocalCertificateLoader) InitializeCache() error {
 	return nil
 }
 
+// Watch polls the certificate and key files every interval and reloads them
+// once they have stopped changing, so that a half-written renewal is never
+// picked up. A pair that fails to load or has expired is not swapped in, the
+// old certificate stays in use. onReload is called with either the new
+// certificate or the error. While watching, handshakes always use the cached
+// certificate. Watch blocks until ctx is done.
+func (l *LocalCertificateLoader) Watch(ctx context.Context, interval time.Duration, onReload func(cert *tls.Certificate, err error)) {
+	l.watching.Store(true)
+	defer l.watching.Store(false)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// mod times seen on the previous tick, and of the last pair that failed
+	var pendingCert, pendingKey, failedCert, failedKey time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		certModTime, keyModTime, err := l.checkModTime()
+		if err != nil {
+			// Probably being replaced, check again on the next tick
+			continue
+		}
+		if cache := l.cache.Load(); cache != nil && cache.certModTime.Equal(certModTime) && cache.keyModTime.Equal(keyModTime) {
+			continue
+		}
+		if certModTime.Equal(failedCert) && keyModTime.Equal(failedKey) {
+			// Already reported
+			continue
+		}
+		if !certModTime.Equal(pendingCert) || !keyModTime.Equal(pendingKey) {
+			// Changed since the last tick, wait for the writer to finish
+			pendingCert, pendingKey = certModTime, keyModTime
+			continue
+		}
+		cert, err := l.reload()
+		if err != nil {
+			failedCert, failedKey = certModTime, keyModTime
+		}
+		onReload(cert, err)
+	}
+}
+
+// Reload loads the certificate and key files right away, with the same
+// checks as Watch, and returns the certificate in use before along with the
+// new one. On error the old certificate stays in use.
+func (l *LocalCertificateLoader) Reload() (old, cert *tls.Certificate, err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if cache := l.cache.Load(); cache != nil {
+		old = cache.certificate
+	}
+	cert, err = l.reloadLocked()
+	return old, cert, err
+}
+
+func (l *LocalCertificateLoader) reload() (*tls.Certificate, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.reloadLocked()
+}
+
+func (l *LocalCertificateLoader) reloadLocked() (*tls.Certificate, error) {
+	cache, err := l.makeCache()
+	if err != nil {
+		return nil, err
+	}
+	leaf := cache.certificate.Leaf
+	if now := time.Now(); now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate expired on %s", leaf.NotAfter.UTC().Format(time.RFC3339))
+	} else if now.Before(leaf.NotBefore) {
+		return nil, fmt.Errorf("certificate is not valid until %s", leaf.NotBefore.UTC().Format(time.RFC3339))
+	}
+	l.cache.Store(cache)
+	return cache.certificate, nil
+}
+
 func (l *LocalCertificateLoader) GetCertificate(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	cert, err := l.getCertificateWithCache()
 	if err != nil {
@@ -64,6 +147,12 @@ func (l *LocalCertificateLoader) GetCertificate(info *tls.ClientHelloInfo) (*tls
 	return cert, nil
 }
 
+// Certificate returns the certificate GetCertificate serves, without the
+// SNI guard.
+func (l *LocalCertificateLoader) Certificate() (*tls.Certificate, error) {
+	return l.getCertificateWithCache()
+}
+
 func (l *LocalCertificateLoader) checkModTime() (certModTime, keyModTime time.Time, err error) {
 	fi, err := os.Stat(l.CertFile)
 	if err != nil {
@@ -108,6 +197,10 @@ func (l *LocalCertificateLoader) makeCache() (cache *localCertificateCache, err
 
 func (l *LocalCertificateLoader) getCertificateWithCache() (*tls.Certificate, error) {
 	cache := l.cache.Load()
+	if cache != nil && l.watching.Load() {
+		// Watch takes care of reloading
+		return cache.certificate, nil
+	}
 
 	certModTime, keyModTime, terr := l.checkModTime()
 	if terr != nil {
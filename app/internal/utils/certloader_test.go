@@ -1,13 +1,16 @@
 package utils
 
 import (
+	"context"
 	"crypto/tls"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -137,3 +140,86 @@ func runTestTLSClient(sni string) error {
 	}
 	return nil
 }
+
+func TestCertificateLoaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert"), filepath.Join(dir, "key")
+	assert.NoError(t, GenerateSelfSignedCert([]string{"example.com"}, certFile, keyFile))
+
+	loader := LocalCertificateLoader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+	assert.NoError(t, loader.InitializeCache())
+
+	type reload struct {
+		cert *tls.Certificate
+		err  error
+	}
+	reloads := make(chan reload, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go loader.Watch(ctx, 10*time.Millisecond, func(cert *tls.Certificate, err error) {
+		reloads <- reload{cert, err}
+	})
+	// Make sure the mod times differ even on coarse file systems
+	touch := func() {
+		later := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(certFile, later, later))
+		assert.NoError(t, os.Chtimes(keyFile, later, later))
+	}
+
+	assert.NoError(t, GenerateSelfSignedCert([]string{"2.example.com"}, certFile, keyFile))
+	touch()
+	select {
+	case r := <-reloads:
+		assert.NoError(t, r.err)
+		assert.Equal(t, []string{"2.example.com"}, r.cert.Leaf.DNSNames)
+	case <-time.After(5 * time.Second):
+		t.Fatal("certificate not reloaded")
+	}
+
+	// A broken pair is reported and the last good one stays in use
+	assert.NoError(t, os.WriteFile(certFile, []byte("not a certificate"), 0o644))
+	touch()
+	select {
+	case r := <-reloads:
+		assert.Error(t, r.err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload error not reported")
+	}
+	cert, err := loader.GetCertificate(&tls.ClientHelloInfo{ServerName: "2.example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2.example.com"}, cert.Leaf.DNSNames)
+}
+
+func TestCertificateLoaderReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert"), filepath.Join(dir, "key")
+	assert.NoError(t, GenerateSelfSignedCert([]string{"example.com"}, certFile, keyFile))
+
+	loader := LocalCertificateLoader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+	assert.NoError(t, loader.InitializeCache())
+
+	assert.NoError(t, GenerateSelfSignedCert([]string{"2.example.com"}, certFile, keyFile))
+	old, cert, err := loader.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"example.com"}, old.Leaf.DNSNames)
+	assert.Equal(t, []string{"2.example.com"}, cert.Leaf.DNSNames)
+
+	// A certificate that doesn't match the key is rejected
+	otherCert, otherKey := filepath.Join(dir, "other-cert"), filepath.Join(dir, "other-key")
+	assert.NoError(t, GenerateSelfSignedCert([]string{"3.example.com"}, otherCert, otherKey))
+	data, err := os.ReadFile(otherCert)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(certFile, data, 0o644))
+	old, _, err = loader.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, []string{"2.example.com"}, old.Leaf.DNSNames)
+	cert, err = loader.GetCertificate(&tls.ClientHelloInfo{ServerName: "2.example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2.example.com"}, cert.Leaf.DNSNames)
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+)
+
+var (
+	ErrNoChecksumFooter    = errors.New("no checksum footer found")
+	ErrChecksumMismatch    = errors.New("checksum mismatch, the file was modified")
+	ErrChecksumLineEndings = errors.New("checksum only matches after normalizing line endings, the content is intact but line endings were changed")
+
+	checksumFooterRegexp = regexp.MustCompile(`(?m)^(?:#|//) sha256: ([0-9a-f]{64})\r?\n?\z`)
+)
+
+// AppendChecksumFooter appends a "<comment> sha256: <hash>" line with the
+// SHA-256 of data. comment is the line comment marker of the file format,
+// e.g. "#" for YAML.
+func AppendChecksumFooter(data []byte, comment string) []byte {
+	data = bytes.Clone(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	sum := sha256.Sum256(data)
+	return append(data, []byte(comment+" sha256: "+hex.EncodeToString(sum[:])+"\n")...)
+}
+
+// VerifyChecksumFooter checks data against the checksum in its footer
+// and returns the content without the footer.
+func VerifyChecksumFooter(data []byte) ([]byte, error) {
+	loc := checksumFooterRegexp.FindSubmatchIndex(data)
+	if loc == nil {
+		return nil, ErrNoChecksumFooter
+	}
+	content := data[:loc[0]]
+	want := string(data[loc[2]:loc[3]])
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) == want {
+		return content, nil
+	}
+	normalized := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	sum = sha256.Sum256(normalized)
+	if hex.EncodeToString(sum[:]) == want {
+		return content, ErrChecksumLineEndings
+	}
+	return content, ErrChecksumMismatch
+}
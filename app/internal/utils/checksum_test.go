@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestChecksumFooter(t *testing.T) {
+	content := []byte("server: example.com:443\nauth: weak_ahh_password\n")
+	signed := AppendChecksumFooter(content, "#")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"intact", signed, nil},
+		{"crlf", bytes.ReplaceAll(signed, []byte("\n"), []byte("\r\n")), ErrChecksumLineEndings},
+		{"modified", bytes.Replace(signed, []byte("weak"), []byte("strong"), 1), ErrChecksumMismatch},
+		{"no footer", content, ErrNoChecksumFooter},
+		{"slash comment", AppendChecksumFooter(content, "//"), nil},
+		{"no trailing newline", AppendChecksumFooter([]byte("auth: x"), "#"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := VerifyChecksumFooter(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyChecksumFooter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, err := VerifyChecksumFooter(signed)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("VerifyChecksumFooter() = %q, %v, want %q", got, err, content)
+	}
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var ErrNoClipboard = errors.New("no clipboard tool found (install wl-clipboard, xclip or xsel)")
+
+// clipboardCommands returns the clipboard tools to try on this platform, in order.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip.exe"}}
+	default:
+		var cmds [][]string
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmds = append(cmds, []string{"wl-copy"})
+		}
+		cmds = append(cmds,
+			[]string{"xclip", "-selection", "clipboard"},
+			[]string{"xsel", "--clipboard", "--input"},
+			[]string{"clip.exe"}, // WSL
+		)
+		return cmds
+	}
+}
+
+// CopyToClipboard puts text on the system clipboard using the first
+// available clipboard tool. Returns ErrNoClipboard if there is none.
+func CopyToClipboard(text string) error {
+	for _, c := range clipboardCommands() {
+		path, err := exec.LookPath(c[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, c[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+	return ErrNoClipboard
+}
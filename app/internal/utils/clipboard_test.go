@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCopyToClipboard(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("the clipboard tools are part of the system")
+	}
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	// A headless server has none of the tools
+	if err := CopyToClipboard("server: example.com:443"); !errors.Is(err, ErrNoClipboard) {
+		t.Fatalf("CopyToClipboard() error = %v, want ErrNoClipboard", err)
+	}
+
+	// xsel is used when xclip is missing
+	out := filepath.Join(dir, "clipboard")
+	script := "#!/bin/sh\n[ \"$*\" = \"--clipboard --input\" ] || exit 1\nPATH=/usr/bin:/bin cat > " + out + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "xsel"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyToClipboard("server: example.com:443"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(out)
+	if string(got) != "server: example.com:443" {
+		t.Errorf("clipboard = %q", got)
+	}
+}
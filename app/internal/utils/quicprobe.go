@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+const (
+	quicProbeSize = 1200 // Servers ignore unknown-version packets smaller than this
+
+	// quicProbeVersion is a reserved "greasing" version (RFC 9000 15),
+	// which no server supports, so it always triggers version negotiation.
+	quicProbeVersion = 0x1a2a3a4a
+)
+
+var ErrQUICProbeInvalidResponse = errors.New("response is not a QUIC version negotiation packet")
+
+// ProbeQUIC sends a QUIC Initial-sized packet with an unsupported version to
+// addr and waits for the version negotiation packet that any QUIC server,
+// including Hysteria, answers with before any TLS or auth. It returns the
+// round trip time. Servers using obfuscation will not answer.
+func ProbeQUIC(addr string, timeout time.Duration) (time.Duration, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	pkt := make([]byte, quicProbeSize)
+	pkt[0] = 0xc0 // Long header, fixed bit
+	binary.BigEndian.PutUint32(pkt[1:5], quicProbeVersion)
+	pkt[5] = 8 // DCID length
+	if _, err := rand.Read(pkt[6:14]); err != nil {
+		return 0, err
+	}
+	pkt[14] = 8 // SCID length
+	if _, err := rand.Read(pkt[15:23]); err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	if err := conn.SetDeadline(start.Add(timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := conn.Write(pkt); err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 1500)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	// Version negotiation: long header bit set, version 0
+	if n < 7 || buf[0]&0x80 == 0 || binary.BigEndian.Uint32(buf[1:5]) != 0 {
+		return rtt, ErrQUICProbeInvalidResponse
+	}
+	return rtt, nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+const (
+	EncryptedSecretPrefix = "enc:"
+
+	SecretKeySize = 32 // AES-256
+)
+
+var (
+	ErrInvalidSecretKey    = errors.New("secret key must be 32 bytes, base64 encoded")
+	ErrSecretDecryptFailed = errors.New("cannot decrypt secret, wrong key or corrupted value")
+)
+
+// IsEncryptedSecret reports whether s was produced by EncryptSecret.
+func IsEncryptedSecret(s string) bool {
+	return strings.HasPrefix(s, EncryptedSecretPrefix)
+}
+
+// GenerateSecretKey returns a new random key, base64 encoded.
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, SecretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ParseSecretKey decodes a key returned by GenerateSecretKey.
+func ParseSecretKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != SecretKeySize {
+		return nil, ErrInvalidSecretKey
+	}
+	return key, nil
+}
+
+// EncryptSecret encrypts plaintext with AES-256-GCM into
+// "enc:<base64 of nonce and ciphertext>", which is safe to put in a config.
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return EncryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptSecret reverses EncryptSecret.
+func DecryptSecret(key []byte, value string) (string, error) {
+	aead, err := newSecretAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedSecretPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrSecretDecryptFailed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", ErrSecretDecryptFailed
+	}
+	return string(plaintext), nil
+}
+
+func newSecretAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != SecretKeySize {
+		return nil, ErrInvalidSecretKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	keyStr, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseSecretKey(keyStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := EncryptSecret(key, "weak_ahh_password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncryptedSecret(enc) {
+		t.Errorf("IsEncryptedSecret(%q) = false, want true", enc)
+	}
+	got, err := DecryptSecret(key, enc)
+	if err != nil || got != "weak_ahh_password" {
+		t.Errorf("DecryptSecret() = %q, %v, want %q", got, err, "weak_ahh_password")
+	}
+
+	otherKey := make([]byte, SecretKeySize)
+	if _, err := DecryptSecret(otherKey, enc); !errors.Is(err, ErrSecretDecryptFailed) {
+		t.Errorf("DecryptSecret() with wrong key error = %v, want %v", err, ErrSecretDecryptFailed)
+	}
+	if _, err := DecryptSecret(key, "enc:bm9wZQ=="); !errors.Is(err, ErrSecretDecryptFailed) {
+		t.Errorf("DecryptSecret() with short value error = %v, want %v", err, ErrSecretDecryptFailed)
+	}
+	if _, err := ParseSecretKey("c2hvcnQ="); !errors.Is(err, ErrInvalidSecretKey) {
+		t.Errorf("ParseSecretKey() error = %v, want %v", err, ErrInvalidSecretKey)
+	}
+}
@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	selfUpdateTimeout = 5 * time.Minute
+)
+
+// ReleaseManifest describes the latest release published at an update URL.
+//
+//	{
+//	  "version": "v2.6.1",
+//	  "assets": {
+//	    "linux/amd64": {"url": "https://...", "sha256": "...", "signature": "..."}
+//	  }
+//	}
+//
+// The signature is a base64 ed25519 signature of the binary. It's what
+// makes the update safe: the checksum comes from the same manifest as the
+// URL, so it only catches a corrupted download.
+type ReleaseManifest struct {
+	Version string                  `json:"version"`
+	Assets  map[string]ReleaseAsset `json:"assets"`
+}
+
+type ReleaseAsset struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+// ErrUpToDate is returned by CheckRelease when the release is the current
+// version.
+var ErrUpToDate = errors.New("already up to date")
+
+type SelfUpdater struct {
+	ManifestURL string
+	PublicKey   ed25519.PublicKey // The asset must be signed with it
+	// AllowUnsigned installs assets without a PublicKey to check them
+	// against, trusting the checksum in the manifest alone
+	AllowUnsigned bool
+	Client        *http.Client
+}
+
+func NewSelfUpdater(manifestURL string, publicKey ed25519.PublicKey) *SelfUpdater {
+	return &SelfUpdater{
+		ManifestURL: manifestURL,
+		PublicKey:   publicKey,
+		Client: &http.Client{
+			Timeout: selfUpdateTimeout,
+		},
+	}
+}
+
+func (u *SelfUpdater) FetchManifest() (*ReleaseManifest, error) {
+	resp, err := u.Client.Get(u.ManifestURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	var m ReleaseManifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return nil, err
+	}
+	if m.Version == "" {
+		return nil, errors.New("manifest has no version")
+	}
+	return &m, nil
+}
+
+// CheckRelease fetches the manifest and returns the asset of its release
+// for platform, if the release is newer than currentVersion. It returns
+// ErrUpToDate for the same version, and an error for an older one, so that
+// a stale or replayed manifest can't downgrade the binary.
+func (u *SelfUpdater) CheckRelease(currentVersion, platform string) (*ReleaseManifest, ReleaseAsset, error) {
+	m, err := u.FetchManifest()
+	if err != nil {
+		return nil, ReleaseAsset{}, err
+	}
+	cmp, err := CompareVersions(m.Version, currentVersion)
+	if err != nil {
+		return m, ReleaseAsset{}, fmt.Errorf("cannot compare the release with the current version: %w", err)
+	}
+	switch {
+	case cmp == 0:
+		return m, ReleaseAsset{}, ErrUpToDate
+	case cmp < 0:
+		return m, ReleaseAsset{}, fmt.Errorf("release %s is older than the current version %s, not downgrading", m.Version, currentVersion)
+	}
+	asset, ok := m.Assets[platform]
+	if !ok {
+		return m, ReleaseAsset{}, fmt.Errorf("release %s has no binary for %s", m.Version, platform)
+	}
+	return m, asset, nil
+}
+
+// CompareVersions compares release versions like v2.6.1, with or without
+// the v, and returns -1, 0 or 1 like strings.Compare. A pre-release
+// (v2.7.0-rc1) comes before its release.
+func CompareVersions(a, b string) (int, error) {
+	va, preA, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, preB, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range va {
+		switch {
+		case va[i] < vb[i]:
+			return -1, nil
+		case va[i] > vb[i]:
+			return 1, nil
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0, nil
+	case preA == "":
+		return 1, nil
+	case preB == "":
+		return -1, nil
+	default:
+		return strings.Compare(preA, preB), nil
+	}
+}
+
+func parseVersion(v string) (nums [3]int, pre string, err error) {
+	s := strings.TrimPrefix(v, "v")
+	s, pre, _ = strings.Cut(s, "-")
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nums, "", fmt.Errorf("invalid version %q", v)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nums, "", fmt.Errorf("invalid version %q", v)
+		}
+		nums[i] = n
+	}
+	return nums, pre, nil
+}
+
+// Install downloads the asset next to the executable at exePath, verifies it,
+// and renames it over the current binary, so that exePath always exists.
+// The current binary is kept as exePath + ".bak". Returns the backup path.
+func (u *SelfUpdater) Install(asset ReleaseAsset, exePath string) (string, error) {
+	if asset.URL == "" || asset.SHA256 == "" {
+		return "", errors.New("asset has no URL or checksum")
+	}
+	if u.PublicKey == nil && !u.AllowUnsigned {
+		return "", errors.New("no public key to verify the signature of the asset")
+	}
+	wantSum, err := hex.DecodeString(strings.TrimSpace(asset.SHA256))
+	if err != nil || len(wantSum) != sha256.Size {
+		return "", errors.New("invalid asset checksum")
+	}
+
+	// Download into the same directory so the final rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(exePath), "."+filepath.Base(exePath)+".update-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	resp, err := u.Client.Get(asset.URL)
+	if err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_ = tmp.Close()
+		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	h := sha256.New()
+	var data bytes.Buffer // Only needed for signature verification
+	var w io.Writer = io.MultiWriter(tmp, h)
+	if u.PublicKey != nil {
+		w = io.MultiWriter(w, &data)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		_ = tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if gotSum := h.Sum(nil); !bytes.Equal(gotSum, wantSum) {
+		return "", fmt.Errorf("checksum mismatch: expected %x, got %x", wantSum, gotSum)
+	}
+	if u.PublicKey != nil {
+		sig, err := base64.StdEncoding.DecodeString(asset.Signature)
+		if err != nil || asset.Signature == "" {
+			return "", errors.New("asset is not signed")
+		}
+		if !ed25519.Verify(u.PublicKey, data.Bytes(), sig) {
+			return "", errors.New("signature verification failed")
+		}
+	}
+
+	if err := os.Chmod(tmpPath, 0o755); err != nil {
+		return "", err
+	}
+	backupPath := exePath + ".bak"
+	_ = os.Remove(backupPath)
+	// Back up without moving the current binary away
+	if err := os.Link(exePath, backupPath); err != nil {
+		if err := copyFile(exePath, backupPath); err != nil {
+			return "", fmt.Errorf("failed to back up current binary: %w", err)
+		}
+	}
+	if err := replaceFile(tmpPath, exePath); err != nil {
+		return "", fmt.Errorf("failed to replace binary: %w", err)
+	}
+	return backupPath, nil
+}
+
+// replaceFile atomically renames src over dst. Windows can't replace a
+// running executable, only rename it, so there dst is moved aside first.
+func replaceFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || runtime.GOOS != "windows" {
+		return err
+	}
+	old := dst + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(dst, old); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		// Never leave the system without a binary
+		_ = os.Rename(old, dst)
+		return err
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
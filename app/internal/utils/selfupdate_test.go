@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPlatform = "linux/amd64"
+
+var testBinary = []byte("#!/bin/sh\necho new\n")
+
+// newTestRelease serves a manifest for version with testBinary as the
+// asset of testPlatform, signed with priv.
+func newTestRelease(t *testing.T, version string, priv ed25519.PrivateKey, edit func(*ReleaseAsset)) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	sum := sha256.Sum256(testBinary)
+	asset := ReleaseAsset{
+		URL:       srv.URL + "/libyalink",
+		SHA256:    hex.EncodeToString(sum[:]),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, testBinary)),
+	}
+	if edit != nil {
+		edit(&asset)
+	}
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ReleaseManifest{
+			Version: version,
+			Assets:  map[string]ReleaseAsset{testPlatform: asset},
+		})
+	})
+	mux.HandleFunc("/libyalink", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(testBinary)
+	})
+	return srv
+}
+
+func newTestExe(t *testing.T) string {
+	t.Helper()
+	exePath := filepath.Join(t.TempDir(), "libyalink")
+	if err := os.WriteFile(exePath, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return exePath
+}
+
+func TestSelfUpdate(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		edit          func(*ReleaseAsset)
+		signer        ed25519.PrivateKey
+		publicKey     ed25519.PublicKey
+		allowUnsigned bool
+		wantErr       string
+	}{
+		{"signed", nil, priv, pub, false, ""},
+		{"bad checksum", func(a *ReleaseAsset) { a.SHA256 = strings.Repeat("0", 64) }, priv, pub, false, "checksum mismatch"},
+		{"bad signature", nil, otherPriv, pub, false, "signature verification failed"},
+		{"not signed", func(a *ReleaseAsset) { a.Signature = "" }, priv, pub, false, "not signed"},
+		{"no public key", nil, priv, nil, false, "no public key"},
+		{"allow unsigned", func(a *ReleaseAsset) { a.Signature = "" }, priv, nil, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestRelease(t, "v2.7.0", tt.signer, tt.edit)
+			u := NewSelfUpdater(srv.URL+"/manifest.json", tt.publicKey)
+			u.AllowUnsigned = tt.allowUnsigned
+			_, asset, err := u.CheckRelease("v2.6.1", testPlatform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exePath := newTestExe(t)
+			backupPath, err := u.Install(asset, exePath)
+
+			got, _ := os.ReadFile(exePath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Install() error = %v, want %q", err, tt.wantErr)
+				}
+				if string(got) != "old" {
+					t.Errorf("binary was replaced after a failed update: %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(testBinary) {
+				t.Errorf("binary = %q, want the release", got)
+			}
+			if info, _ := os.Stat(exePath); info.Mode().Perm() != 0o755 {
+				t.Errorf("binary mode = %v, want 0755", info.Mode().Perm())
+			}
+			if backup, _ := os.ReadFile(backupPath); string(backup) != "old" {
+				t.Errorf("backup = %q, want the previous binary", backup)
+			}
+		})
+	}
+}
+
+func TestSelfUpdateCheckRelease(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestRelease(t, "v2.6.1", priv, nil)
+	u := NewSelfUpdater(srv.URL+"/manifest.json", nil)
+
+	if _, _, err := u.CheckRelease("v2.6.1", testPlatform); !errors.Is(err, ErrUpToDate) {
+		t.Errorf("same version: error = %v, want ErrUpToDate", err)
+	}
+	if _, _, err := u.CheckRelease("v2.10.0", testPlatform); err == nil || !strings.Contains(err.Error(), "not downgrading") {
+		t.Errorf("downgrade: error = %v, want a refusal", err)
+	}
+	if _, _, err := u.CheckRelease("Unknown", testPlatform); err == nil {
+		t.Error("unknown version: want an error")
+	}
+	if _, _, err := u.CheckRelease("v2.6.0", "windows/arm64"); err == nil || !strings.Contains(err.Error(), "no binary") {
+		t.Errorf("missing platform: error = %v, want no binary", err)
+	}
+	m, asset, err := u.CheckRelease("v2.6.1-rc1", testPlatform)
+	if err != nil || m.Version != "v2.6.1" || asset.URL == "" {
+		t.Errorf("upgrade from rc: got %v, %v, %v", m, asset, err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		want    int
+		wantErr bool
+	}{
+		{"v2.6.1", "v2.6.1", 0, false},
+		{"2.6.1", "v2.6.1", 0, false},
+		{"v2.6", "v2.6.0", 0, false},
+		{"v2.10.0", "v2.9.9", 1, false},
+		{"v2.6.0", "v2.6.1", -1, false},
+		{"v3", "v2.99.99", 1, false},
+		{"v2.7.0-rc1", "v2.7.0", -1, false},
+		{"v2.7.0", "v2.7.0-rc1", 1, false},
+		{"v2.7.0-rc2", "v2.7.0-rc1", 1, false},
+		{"Unknown", "v2.6.1", 0, true},
+		{"v2.6.1.1", "v2.6.1", 0, true},
+		{"v2..1", "v2.6.1", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := CompareVersions(tt.a, tt.b)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CompareVersions(%q, %q) error = %v, wantErr %v", tt.a, tt.b, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	coreErrs "github.com/apernet/hysteria/core/v2/errors"
@@ -124,6 +125,20 @@ func (c *clientImpl) connect() (*HandshakeInfo, error) {
 		_ = pktConn.Close()
 		return nil, coreErrs.ConnectError{Err: err}
 	}
+	if resp.StatusCode == protocol.StatusServerFull {
+		_ = conn.CloseWithError(closeErrCodeOK, "")
+		_ = pktConn.Close()
+		return nil, coreErrs.ServerFullError{Fallback: resp.Header.Get(protocol.ResponseHeaderFallback)}
+	}
+	if resp.StatusCode == protocol.StatusQuotaExceeded {
+		_ = conn.CloseWithError(closeErrCodeOK, "")
+		_ = pktConn.Close()
+		var reset time.Time
+		if sec, err := strconv.ParseInt(resp.Header.Get(protocol.ResponseHeaderQuotaReset), 10, 64); err == nil {
+			reset = time.Unix(sec, 0)
+		}
+		return nil, coreErrs.QuotaExceededError{Reset: reset}
+	}
 	if resp.StatusCode != protocol.StatusAuthOK {
 		_ = conn.CloseWithError(closeErrCodeProtocolError, "")
 		_ = pktConn.Close()
@@ -3,6 +3,7 @@ package errors
 import (
 	"fmt"
 	"strconv"
+	"time"
 )
 
 // ConfigError is returned when a configuration field is invalid.
@@ -37,6 +38,32 @@ func (a AuthError) Error() string {
 	return "authentication error, HTTP status code: " + strconv.Itoa(a.StatusCode)
 }
 
+// ServerFullError is returned when the server accepts the client's credentials
+// but is already serving as many clients as it allows.
+type ServerFullError struct {
+	Fallback string // Server address suggested instead, can be empty
+}
+
+func (e ServerFullError) Error() string {
+	if e.Fallback == "" {
+		return "server full, try again later"
+	}
+	return "server full, try again later or use the fallback server " + e.Fallback
+}
+
+// QuotaExceededError is returned when the server accepts the client's
+// credentials but the client has used up its data quota.
+type QuotaExceededError struct {
+	Reset time.Time // When the quota resets, zero if the server didn't say
+}
+
+func (e QuotaExceededError) Error() string {
+	if e.Reset.IsZero() {
+		return "data quota used up"
+	}
+	return "data quota used up, it resets on " + e.Reset.Format(time.DateTime)
+}
+
 // DialError is returned when the server rejects the client's dial request.
 // This applies to both TCP and UDP.
 type DialError struct {
@@ -73,3 +100,24 @@ type ProtocolError struct {
 func (p ProtocolError) Error() string {
 	return "protocol error: " + p.Message
 }
+
+// IdleTimeoutError is reported as the disconnect reason when the server
+// closes a client that has not sent or received any traffic for too long.
+type IdleTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e IdleTimeoutError) Error() string {
+	return "idle timeout: no traffic for " + e.Timeout.String()
+}
+
+// CredentialsExpiredError is reported as the disconnect reason when the
+// server closes a client whose credentials stopped being valid, like a
+// token that expired or whose daily window ended (Config.SessionDeadline).
+type CredentialsExpiredError struct {
+	Deadline time.Time
+}
+
+func (e CredentialsExpiredError) Error() string {
+	return "credentials no longer valid since " + e.Deadline.UTC().Format(time.DateTime) + " UTC"
+}
@@ -250,11 +250,21 @@ var _ congestion.CongestionControl = &bbrSender{}
 func NewBbrSender(
 	clock Clock,
 	initialMaxDatagramSize congestion.ByteCount,
+) *bbrSender {
+	return NewBbrSenderWithInitialCwnd(clock, initialMaxDatagramSize, initialCongestionWindowPackets)
+}
+
+// NewBbrSenderWithInitialCwnd is like NewBbrSender, but with a custom
+// initial congestion window in packets instead of the default 32.
+func NewBbrSenderWithInitialCwnd(
+	clock Clock,
+	initialMaxDatagramSize congestion.ByteCount,
+	initialCwndPackets int,
 ) *bbrSender {
 	return newBbrSender(
 		clock,
 		initialMaxDatagramSize,
-		initialCongestionWindowPackets*initialMaxDatagramSize,
+		congestion.ByteCount(initialCwndPackets)*initialMaxDatagramSize,
 		congestion.MaxCongestionWindowPackets*initialMaxDatagramSize,
 	)
 }
@@ -19,8 +19,21 @@ const (
 
 	debugEnv           = "HYSTERIA_BRUTAL_DEBUG"
 	debugPrintInterval = 2
+
+	// Adaptive mode, see NewAdaptiveBrutalSender
+	adaptInterval      = pktInfoSlotCount // seconds, so each decision sees only samples from the current rate
+	adaptMinDivisor    = 8                // the rate never goes below the negotiated rate / 8
+	adaptLossAckRate   = 0.9              // below this the path carries less than we send
+	adaptProbeAckRate  = 0.98             // at or above this there's room to send more
+	adaptDecreaseRatio = 0.85
+	adaptIncreaseRatio = 1.1
+	adaptAppLimited    = 0.8 // no probing while the client takes less than this share of the rate
 )
 
+// AdaptFunc is called when an adaptive sender changes its rate, in bytes
+// per second. ackRate is the share of packets acked over the last interval.
+type AdaptFunc func(oldBps, newBps uint64, ackRate float64)
+
 var _ congestion.CongestionControl = &BrutalSender{}
 
 type BrutalSender struct {
@@ -34,12 +47,18 @@ type BrutalSender struct {
 
 	debug                 bool
 	lastAckPrintTimestamp int64
+
+	// Adaptive mode, maxBps is 0 when it's off
+	maxBps             congestion.ByteCount
+	onAdapt            AdaptFunc
+	lastAdaptTimestamp int64
 }
 
 type pktInfo struct {
 	Timestamp int64
 	AckCount  uint64
 	LossCount uint64
+	AckBytes  uint64
 }
 
 func NewBrutalSender(bps uint64) *BrutalSender {
@@ -56,6 +75,17 @@ func NewBrutalSender(bps uint64) *BrutalSender {
 	return bs
 }
 
+// NewAdaptiveBrutalSender is like NewBrutalSender, but re-estimates what
+// the path carries every few seconds and moves the rate between bps/8 and
+// bps: down when many packets are lost, back up when almost none are and
+// the client uses the rate. onAdapt, if not nil, is told of every change.
+func NewAdaptiveBrutalSender(bps uint64, onAdapt AdaptFunc) *BrutalSender {
+	bs := NewBrutalSender(bps)
+	bs.maxBps = congestion.ByteCount(bps)
+	bs.onAdapt = onAdapt
+	return bs
+}
+
 func (b *BrutalSender) SetRTTStatsProvider(rttStats congestion.RTTStatsProvider) {
 	b.rttStats = rttStats
 }
@@ -108,13 +138,26 @@ func (b *BrutalSender) OnCongestionEventEx(priorInFlight congestion.ByteCount, e
 	if b.pktInfoSlots[slot].Timestamp == currentTimestamp {
 		b.pktInfoSlots[slot].LossCount += uint64(len(lostPackets))
 		b.pktInfoSlots[slot].AckCount += uint64(len(ackedPackets))
+		b.pktInfoSlots[slot].AckBytes += ackedBytes(ackedPackets)
 	} else {
 		// uninitialized slot or too old, reset
 		b.pktInfoSlots[slot].Timestamp = currentTimestamp
 		b.pktInfoSlots[slot].AckCount = uint64(len(ackedPackets))
 		b.pktInfoSlots[slot].LossCount = uint64(len(lostPackets))
+		b.pktInfoSlots[slot].AckBytes = ackedBytes(ackedPackets)
 	}
 	b.updateAckRate(currentTimestamp)
+	if b.maxBps > 0 {
+		b.maybeAdapt(currentTimestamp)
+	}
+}
+
+func ackedBytes(packets []congestion.AckedPacketInfo) uint64 {
+	var n uint64
+	for _, p := range packets {
+		n += uint64(p.BytesAcked)
+	}
+	return n
 }
 
 func (b *BrutalSender) SetMaxDatagramSize(size congestion.ByteCount) {
@@ -162,6 +205,61 @@ func (b *BrutalSender) updateAckRate(currentTimestamp int64) {
 	}
 }
 
+// maybeAdapt re-estimates the rate once per adaptInterval, from the
+// samples of the last interval.
+func (b *BrutalSender) maybeAdapt(currentTimestamp int64) {
+	if b.lastAdaptTimestamp == 0 {
+		b.lastAdaptTimestamp = currentTimestamp
+		return
+	}
+	if currentTimestamp-b.lastAdaptTimestamp < adaptInterval {
+		return
+	}
+	b.lastAdaptTimestamp = currentTimestamp
+	minTimestamp := currentTimestamp - pktInfoSlotCount
+	var ackCount, lossCount, ackBytes uint64
+	for _, info := range b.pktInfoSlots {
+		if info.Timestamp < minTimestamp {
+			continue
+		}
+		ackCount += info.AckCount
+		lossCount += info.LossCount
+		ackBytes += info.AckBytes
+	}
+	if ackCount+lossCount < minSampleCount {
+		return
+	}
+	ackRate := float64(ackCount) / float64(ackCount+lossCount)
+	ackedBps := ackBytes / pktInfoSlotCount
+	newBps := adaptRate(uint64(b.bps), uint64(b.maxBps), ackRate, ackedBps)
+	if newBps == uint64(b.bps) {
+		return
+	}
+	oldBps := uint64(b.bps)
+	b.bps = congestion.ByteCount(newBps)
+	if b.debug {
+		b.debugPrint("Adapted rate: %d -> %d (ack rate %.2f, acked %d/s)", oldBps, newBps, ackRate, ackedBps)
+	}
+	if b.onAdapt != nil {
+		b.onAdapt(oldBps, newBps, ackRate)
+	}
+}
+
+// adaptRate returns the next rate of an adaptive sender sending at bps,
+// which may go up to maxBps, given the share of packets acked and the
+// bytes per second acked over the last interval.
+func adaptRate(bps, maxBps uint64, ackRate float64, ackedBps uint64) uint64 {
+	minBps := maxBps / adaptMinDivisor
+	switch {
+	case ackRate < adaptLossAckRate:
+		return max(minBps, uint64(float64(bps)*adaptDecreaseRatio))
+	case ackRate >= adaptProbeAckRate && float64(ackedBps) >= float64(bps)*adaptAppLimited:
+		return min(maxBps, uint64(float64(bps)*adaptIncreaseRatio))
+	default:
+		return bps
+	}
+}
+
 func (b *BrutalSender) InSlowStart() bool {
 	return false
 }
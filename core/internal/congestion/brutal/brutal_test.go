@@ -0,0 +1,28 @@
+package brutal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptRate(t *testing.T) {
+	const maxBps = 1000000
+	tests := []struct {
+		name     string
+		bps      uint64
+		ackRate  float64
+		ackedBps uint64
+		want     uint64
+	}{
+		{"heavy loss", maxBps, 0.8, 700000, 850000},
+		{"heavy loss at floor", 130000, 0.5, 60000, maxBps / 8},
+		{"some loss", 500000, 0.95, 480000, 500000},
+		{"no loss, in use", 500000, 1, 450000, 550000},
+		{"no loss, capped", 950000, 0.99, 950000, maxBps},
+		{"no loss, app limited", 500000, 1, 100000, 500000},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, adaptRate(tt.bps, maxBps, tt.ackRate, tt.ackedBps), tt.name)
+	}
+}
@@ -13,6 +13,26 @@ func UseBBR(conn *quic.Conn) {
 	))
 }
 
+// UseBBRWithInitialCwnd is like UseBBR, but with a custom initial
+// congestion window in packets. 0 means the default.
+func UseBBRWithInitialCwnd(conn *quic.Conn, packets int) {
+	if packets == 0 {
+		UseBBR(conn)
+		return
+	}
+	conn.SetCongestionControl(bbr.NewBbrSenderWithInitialCwnd(
+		bbr.DefaultClock{},
+		bbr.GetInitialPacketSize(conn.RemoteAddr()),
+		packets,
+	))
+}
+
 func UseBrutal(conn *quic.Conn, tx uint64) {
 	conn.SetCongestionControl(brutal.NewBrutalSender(tx))
 }
+
+// UseBrutalAdaptive is like UseBrutal, but lets the rate follow what the
+// path carries, up to tx. See brutal.NewAdaptiveBrutalSender.
+func UseBrutalAdaptive(conn *quic.Conn, tx uint64, onAdapt brutal.AdaptFunc) {
+	conn.SetCongestionControl(brutal.NewAdaptiveBrutalSender(tx, onAdapt))
+}
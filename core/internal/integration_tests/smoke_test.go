@@ -3,7 +3,9 @@ package integration_tests
 import (
 	"io"
 	"net"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -56,6 +58,198 @@ func TestClientServerBadAuth(t *testing.T) {
 	assert.True(t, ok)
 }
 
+// TestClientServerFull tests that a client over MaxConnections gets a ServerFullError
+// with the fallback server, and that the slot is freed when the first client leaves.
+func TestClientServerFull(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:      serverTLSConfig(),
+		Conn:           udpConn,
+		Authenticator:  auth,
+		MaxConnections: 1,
+		FallbackServer: "backup.example.com:443",
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	// The first client takes the only slot
+	c1, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+
+	c2, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.Nil(t, c2)
+	assert.Equal(t, coreErrs.ServerFullError{Fallback: "backup.example.com:443"}, err)
+
+	_ = c1.Close()
+	assert.Eventually(t, func() bool {
+		c3, _, err := client.NewClient(&client.Config{
+			ServerAddr: udpAddr,
+			TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+		})
+		if err != nil {
+			return false
+		}
+		_ = c3.Close()
+		return true
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// TestClientServerFullListeners tests that servers sharing Clients, like the
+// listeners of one server, share MaxConnections.
+func TestClientServerFullListeners(t *testing.T) {
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "nobody")
+	clients := new(atomic.Int64)
+	addrs := make([]net.Addr, 2)
+	for i := range addrs {
+		udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		assert.NoError(t, err)
+		s, err := server.NewServer(&server.Config{
+			TLSConfig:      serverTLSConfig(),
+			Conn:           udpConn,
+			Authenticator:  auth,
+			MaxConnections: 1,
+			Clients:        clients,
+		})
+		assert.NoError(t, err)
+		defer s.Close()
+		go s.Serve()
+		addrs[i] = udpConn.LocalAddr()
+	}
+
+	// The first listener takes the only slot of both
+	c1, _, err := client.NewClient(&client.Config{
+		ServerAddr: addrs[0],
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	c2, _, err := client.NewClient(&client.Config{
+		ServerAddr: addrs[1],
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.Nil(t, c2)
+	assert.Equal(t, coreErrs.ServerFullError{}, err)
+
+	_ = c1.Close()
+	assert.Eventually(t, func() bool {
+		c3, _, err := client.NewClient(&client.Config{
+			ServerAddr: addrs[1],
+			TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+		})
+		if err != nil {
+			return false
+		}
+		_ = c3.Close()
+		return true
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+type quotaFunc func(id string) (bool, time.Time)
+
+func (f quotaFunc) Check(id string) (bool, time.Time) { return f(id) }
+
+// TestClientServerQuotaExceeded tests that a client over its Quota gets a
+// QuotaExceededError with the reset time, and others still connect.
+func TestClientServerQuotaExceeded(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).RunAndReturn(
+		func(addr net.Addr, auth string, tx uint64) (bool, string) { return true, auth })
+	reset := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+		Quota: quotaFunc(func(id string) (bool, time.Time) {
+			return id != "over", reset
+		}),
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		Auth:       "over",
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.Nil(t, c)
+	var quotaErr coreErrs.QuotaExceededError
+	assert.ErrorAs(t, err, &quotaErr)
+	assert.True(t, reset.Equal(quotaErr.Reset))
+
+	c, _, err = client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		Auth:       "under",
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	_ = c.Close()
+}
+
+type sessionDeadlineFunc func(auth string) time.Time
+
+func (f sessionDeadlineFunc) Deadline(auth string) time.Time { return f(auth) }
+
+// TestClientServerSessionDeadline tests that the server closes a session at
+// its SessionDeadline, and reports it as a CredentialsExpiredError.
+func TestClientServerSessionDeadline(t *testing.T) {
+	// Create server
+	udpConn, udpAddr, err := serverConn()
+	assert.NoError(t, err)
+	auth := mocks.NewMockAuthenticator(t)
+	auth.EXPECT().Authenticate(mock.Anything, mock.Anything, mock.Anything).Return(true, "trial")
+	deadline := time.Now().Add(time.Second)
+	disconnectCh := make(chan error, 1)
+	eventLogger := mocks.NewMockEventLogger(t)
+	eventLogger.EXPECT().Connect(mock.Anything, "trial", mock.Anything).Once()
+	eventLogger.EXPECT().Disconnect(mock.Anything, "trial", mock.Anything).Run(func(addr net.Addr, id string, err error) {
+		disconnectCh <- err
+	}).Once()
+	s, err := server.NewServer(&server.Config{
+		TLSConfig:     serverTLSConfig(),
+		Conn:          udpConn,
+		Authenticator: auth,
+		SessionDeadline: sessionDeadlineFunc(func(auth string) time.Time {
+			return deadline
+		}),
+		EventLogger: eventLogger,
+	})
+	assert.NoError(t, err)
+	defer s.Close()
+	go s.Serve()
+
+	c, _, err := client.NewClient(&client.Config{
+		ServerAddr: udpAddr,
+		TLSConfig:  client.TLSConfig{InsecureSkipVerify: true},
+	})
+	assert.NoError(t, err)
+	defer c.Close()
+
+	select {
+	case err := <-disconnectCh:
+		assert.Equal(t, coreErrs.CredentialsExpiredError{Deadline: deadline}, err)
+		assert.False(t, time.Now().Before(deadline))
+	case <-time.After(5 * time.Second):
+		t.Fatal("session still open after its deadline")
+	}
+	_, err = c.TCP("example.com:80")
+	assert.Error(t, err)
+}
+
 // TestClientServerUDPDisabled tests how the client handles a server that does not support UDP.
 // UDP should return a DialError.
 func TestClientServerUDPDisabled(t *testing.T) {
@@ -11,10 +11,14 @@ const (
 
 	RequestHeaderAuth        = "Hysteria-Auth"
 	ResponseHeaderUDPEnabled = "Hysteria-UDP"
+	ResponseHeaderFallback   = "Hysteria-Fallback"
+	ResponseHeaderQuotaReset = "Hysteria-Quota-Reset" // Unix time
 	CommonHeaderCCRX         = "Hysteria-CC-RX"
 	CommonHeaderPadding      = "Hysteria-Padding"
 
-	StatusAuthOK = 233
+	StatusAuthOK        = 233
+	StatusServerFull    = 234 // Auth passed, but the server has no room for another client
+	StatusQuotaExceeded = 235 // Auth passed, but the client has used up its data quota
 )
 
 // AuthRequest is what client sends to server for authentication.
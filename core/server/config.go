@@ -20,6 +20,25 @@ const (
 	defaultMaxIdleTimeout      = 30 * time.Second
 	defaultMaxIncomingStreams  = 1024
 	defaultUDPIdleTimeout      = 60 * time.Second
+	minIdleTimeout             = 30 * time.Second
+
+	minInitialCongestionWindow = 4    // packets
+	maxInitialCongestionWindow = 1000 // packets
+	minMaxDatagramSize         = 1200 // bytes, the smallest QUIC allows
+	maxMaxDatagramSize         = 1452 // bytes, the largest quic-go sends
+)
+
+// Congestion control the server uses to send to clients (QUICConfig.CongestionControl).
+const (
+	// CongestionControlAuto uses Brutal at the rate the client asks for,
+	// capped by BandwidthConfig.MaxTx, and BBR for clients that don't ask.
+	CongestionControlAuto = ""
+	// CongestionControlBBR always uses BBR.
+	CongestionControlBBR = "bbr"
+	// CongestionControlBrutal uses Brutal at the rate the client asks for,
+	// capped by BandwidthConfig.MaxTx, or at MaxTx for clients that don't
+	// ask. Only clients that don't ask when MaxTx isn't set get BBR.
+	CongestionControlBrutal = "brutal"
 )
 
 type Config struct {
@@ -32,7 +51,13 @@ type Config struct {
 	IgnoreClientBandwidth bool
 	DisableUDP            bool
 	UDPIdleTimeout        time.Duration
+	IdleTimeout           time.Duration // 0 disables it. Closes authenticated connections without traffic.
+	MaxConnections        int           // 0 means unlimited. Authenticated clients over it are told the server is full.
+	FallbackServer        string        // Optional, sent to the clients rejected by MaxConnections.
+	Clients               *atomic.Int64 // Optional, counts the authenticated clients. Servers sharing it share MaxConnections.
 	Authenticator         Authenticator
+	Quota                 Quota           // Optional, checked after the client authenticates.
+	SessionDeadline       SessionDeadline // Optional, closes sessions whose credentials stop being valid.
 	EventLogger           EventLogger
 	TrafficLogger         TrafficLogger
 	MasqHandler           http.Handler
@@ -74,7 +99,27 @@ func (c *Config) fill() error {
 	} else if c.QUICConfig.MaxIncomingStreams < 8 {
 		return errors.ConfigError{Field: "QUICConfig.MaxIncomingStreams", Reason: "must be at least 8"}
 	}
-	c.QUICConfig.DisablePathMTUDiscovery = c.QUICConfig.DisablePathMTUDiscovery || pmtud.DisablePathMTUDiscovery
+	if c.QUICConfig.InitialCongestionWindow != 0 &&
+		(c.QUICConfig.InitialCongestionWindow < minInitialCongestionWindow ||
+			c.QUICConfig.InitialCongestionWindow > maxInitialCongestionWindow) {
+		return errors.ConfigError{Field: "QUICConfig.InitialCongestionWindow", Reason: "must be between 4 and 1000"}
+	}
+	switch c.QUICConfig.CongestionControl {
+	case CongestionControlAuto, CongestionControlBBR, CongestionControlBrutal:
+	default:
+		return errors.ConfigError{Field: "QUICConfig.CongestionControl", Reason: "must be bbr or brutal, or empty for auto"}
+	}
+	if c.QUICConfig.KeepAlivePeriod != 0 &&
+		(c.QUICConfig.KeepAlivePeriod < 2*time.Second || c.QUICConfig.KeepAlivePeriod > 60*time.Second) {
+		return errors.ConfigError{Field: "QUICConfig.KeepAlivePeriod", Reason: "must be between 2s and 60s"}
+	}
+	if c.QUICConfig.MaxDatagramSize != 0 &&
+		(c.QUICConfig.MaxDatagramSize < minMaxDatagramSize || c.QUICConfig.MaxDatagramSize > maxMaxDatagramSize) {
+		return errors.ConfigError{Field: "QUICConfig.MaxDatagramSize", Reason: "must be between 1200 and 1452"}
+	}
+	// A fixed size, discovery would grow the packets past it
+	c.QUICConfig.DisablePathMTUDiscovery = c.QUICConfig.DisablePathMTUDiscovery || pmtud.DisablePathMTUDiscovery ||
+		c.QUICConfig.MaxDatagramSize != 0
 	if c.Conn == nil {
 		return errors.ConfigError{Field: "Conn", Reason: "must be set"}
 	}
@@ -92,6 +137,15 @@ func (c *Config) fill() error {
 	} else if c.UDPIdleTimeout < 2*time.Second || c.UDPIdleTimeout > 600*time.Second {
 		return errors.ConfigError{Field: "UDPIdleTimeout", Reason: "must be between 2s and 600s"}
 	}
+	if c.IdleTimeout != 0 && c.IdleTimeout < minIdleTimeout {
+		return errors.ConfigError{Field: "IdleTimeout", Reason: "must be at least 30s"}
+	}
+	if c.MaxConnections < 0 {
+		return errors.ConfigError{Field: "MaxConnections", Reason: "must not be negative"}
+	}
+	if c.Clients == nil {
+		c.Clients = new(atomic.Int64)
+	}
 	if c.Authenticator == nil {
 		return errors.ConfigError{Field: "Authenticator", Reason: "must be set"}
 	}
@@ -113,7 +167,12 @@ type QUICConfig struct {
 	MaxConnectionReceiveWindow     uint64
 	MaxIdleTimeout                 time.Duration
 	MaxIncomingStreams             int64
-	DisablePathMTUDiscovery        bool // The server may still override this to true on unsupported platforms.
+	DisablePathMTUDiscovery        bool          // The server may still override this to true on unsupported platforms.
+	InitialCongestionWindow        int           // In packets, only applies to BBR. 0 means the default (32).
+	KeepAlivePeriod                time.Duration // 0 means the server does not send keep-alives, clients still do.
+	CongestionControl              string        // One of the CongestionControl constants.
+	MaxDatagramSize                int           // In bytes, disables path MTU discovery. 0 means discovery from 1280 bytes.
+	AdaptiveBandwidth              bool          // Brutal only. Lets each client's rate drop below the negotiated one when the path loses packets.
 }
 
 // RequestHook allows filtering and modifying requests before the server connects to the remote.
@@ -195,7 +254,27 @@ type Authenticator interface {
 	Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string)
 }
 
+// Quota decides whether an authenticated client has data left to use.
+// Clients without are told so instead of being connected, to stop them
+// once connected, have the TrafficLogger disconnect them.
+type Quota interface {
+	// Check returns false if the client with this id may not connect,
+	// and when that changes, zero if unknown.
+	Check(id string) (ok bool, reset time.Time)
+}
+
+// SessionDeadline decides when the session of an authenticated client must
+// end, for credentials that are only valid for a while.
+type SessionDeadline interface {
+	// Deadline returns when to close the session of a client that
+	// authenticated with auth, or the zero time to keep it open.
+	Deadline(auth string) time.Time
+}
+
 // EventLogger is an interface that provides logging logic.
+// A client rejected because the server is full is reported as a Disconnect
+// with errors.ServerFullError, without a Connect before it. The same goes
+// for a client over its Quota, with errors.QuotaExceededError.
 type EventLogger interface {
 	Connect(addr net.Addr, id string, tx uint64)
 	Disconnect(addr net.Addr, id string, err error)
@@ -205,6 +284,14 @@ type EventLogger interface {
 	UDPError(addr net.Addr, id string, sessionID uint32, err error)
 }
 
+// BandwidthEventLogger can be implemented by an EventLogger to be told when
+// QUICConfig.AdaptiveBandwidth changes the rate the server sends to a
+// client at. Rates are in bytes per second, ackRate is the share of packets
+// acked over the last few seconds.
+type BandwidthEventLogger interface {
+	BandwidthAdapted(addr net.Addr, id string, oldTx, newTx uint64, ackRate float64)
+}
+
 type HyStream interface {
 	StreamID() quic.StreamID
 	Read(p []byte) (n int, err error)
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/apernet/quic-go"
+)
+
+// idleTracker closes an authenticated connection that has not carried any
+// proxied traffic for Config.IdleTimeout. QUIC keep-alives don't count, so
+// this also catches clients that stay connected but do nothing.
+// All methods are no-ops on a nil tracker (IdleTimeout disabled).
+type idleTracker struct {
+	timeout    time.Duration
+	lastActive atomic.Int64 // UnixNano
+	timedOut   atomic.Bool
+}
+
+func newIdleTracker(timeout time.Duration) *idleTracker {
+	if timeout <= 0 {
+		return nil
+	}
+	t := &idleTracker{timeout: timeout}
+	t.Touch()
+	return t
+}
+
+func (t *idleTracker) Touch() {
+	if t != nil {
+		t.lastActive.Store(time.Now().UnixNano())
+	}
+}
+
+// TimedOut reports whether Run closed the connection.
+func (t *idleTracker) TimedOut() bool {
+	return t != nil && t.timedOut.Load()
+}
+
+// Run blocks until the connection is closed, either by Run itself once the
+// timeout is reached or by anything else.
+func (t *idleTracker) Run(conn *quic.Conn) {
+	if t == nil {
+		return
+	}
+	interval := t.timeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.Context().Done():
+			return
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, t.lastActive.Load()))
+			if idle >= t.timeout {
+				t.timedOut.Store(true)
+				_ = conn.CloseWithError(closeErrCodeOK, "idle timeout")
+				return
+			}
+		}
+	}
+}
+
+// idleReadWriter touches the tracker whenever data goes through the stream.
+type idleReadWriter struct {
+	io.ReadWriter
+	Tracker *idleTracker
+}
+
+func (rw *idleReadWriter) Read(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Read(p)
+	if n > 0 {
+		rw.Tracker.Touch()
+	}
+	return n, err
+}
+
+func (rw *idleReadWriter) Write(p []byte) (int, error) {
+	n, err := rw.ReadWriter.Write(p)
+	if n > 0 {
+		rw.Tracker.Touch()
+	}
+	return n, err
+}
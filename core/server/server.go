@@ -3,14 +3,18 @@ package server
 import (
 	"context"
 	"crypto/tls"
+	"io"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apernet/quic-go"
 	"github.com/apernet/quic-go/http3"
 
+	"github.com/apernet/hysteria/core/v2/errors"
 	"github.com/apernet/hysteria/core/v2/internal/congestion"
 	"github.com/apernet/hysteria/core/v2/internal/protocol"
 	"github.com/apernet/hysteria/core/v2/internal/utils"
@@ -52,8 +56,10 @@ func NewServer(config *Config) (Server, error) {
 		InitialConnectionReceiveWindow: config.QUICConfig.InitialConnectionReceiveWindow,
 		MaxConnectionReceiveWindow:     config.QUICConfig.MaxConnectionReceiveWindow,
 		MaxIdleTimeout:                 config.QUICConfig.MaxIdleTimeout,
+		KeepAlivePeriod:                config.QUICConfig.KeepAlivePeriod,
 		MaxIncomingStreams:             config.QUICConfig.MaxIncomingStreams,
 		DisablePathMTUDiscovery:        config.QUICConfig.DisablePathMTUDiscovery,
+		InitialPacketSize:              uint16(config.QUICConfig.MaxDatagramSize),
 		EnableDatagrams:                true,
 		MaxDatagramFrameSize:           protocol.MaxDatagramFrameSize,
 		DisablePathManager:             true,
@@ -91,7 +97,7 @@ func (s *serverImpl) Close() error {
 }
 
 func (s *serverImpl) handleClient(conn *quic.Conn) {
-	handler := newH3sHandler(s.config, conn)
+	handler := newH3sHandler(s.config, conn, s.config.Clients)
 	h3s := http3.Server{
 		Handler:        handler,
 		StreamHijacker: handler.ProxyStreamHijacker,
@@ -99,6 +105,12 @@ func (s *serverImpl) handleClient(conn *quic.Conn) {
 	err := h3s.ServeQUICConn(conn)
 	// If the client is authenticated, we need to log the disconnect event
 	if handler.authenticated {
+		s.config.Clients.Add(-1)
+		if handler.idle.TimedOut() {
+			err = errors.IdleTimeoutError{Timeout: s.config.IdleTimeout}
+		} else if handler.expired.Load() {
+			err = errors.CredentialsExpiredError{Deadline: handler.deadline}
+		}
 		if tl := s.config.TrafficLogger; tl != nil {
 			tl.LogOnlineState(handler.authID, false)
 		}
@@ -110,22 +122,30 @@ func (s *serverImpl) handleClient(conn *quic.Conn) {
 }
 
 type h3sHandler struct {
-	config *Config
-	conn   *quic.Conn
+	config  *Config
+	conn    *quic.Conn
+	clients *atomic.Int64
 
 	authenticated bool
 	authMutex     sync.Mutex
 	authID        string
 	connID        uint32 // a random id for dump streams
 
+	idle *idleTracker // nil if IdleTimeout is disabled
+
+	deadline time.Time   // From SessionDeadline, zero if none
+	expired  atomic.Bool // Set when closeAtDeadline closes the connection
+
 	udpSM *udpSessionManager // Only set after authentication
 }
 
-func newH3sHandler(config *Config, conn *quic.Conn) *h3sHandler {
+func newH3sHandler(config *Config, conn *quic.Conn, clients *atomic.Int64) *h3sHandler {
 	return &h3sHandler{
-		config: config,
-		conn:   conn,
-		connID: rand.Uint32(),
+		config:  config,
+		conn:    conn,
+		clients: clients,
+		connID:  rand.Uint32(),
+		idle:    newIdleTracker(config.IdleTimeout),
 	}
 }
 
@@ -146,27 +166,41 @@ func (h *h3sHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		authReq := protocol.AuthRequestFromHeader(r.Header)
 		actualTx := authReq.Rx
 		ok, id := h.config.Authenticator.Authenticate(h.conn.RemoteAddr(), authReq.Auth, actualTx)
-		if ok {
+		var quotaReset time.Time
+		quotaOK := true
+		if ok && h.config.Quota != nil {
+			quotaOK, quotaReset = h.config.Quota.Check(id)
+		}
+		if ok && !quotaOK {
+			// Like a full server, only clients with valid credentials learn about it
+			if !quotaReset.IsZero() {
+				w.Header().Set(protocol.ResponseHeaderQuotaReset, strconv.FormatInt(quotaReset.Unix(), 10))
+			}
+			w.WriteHeader(protocol.StatusQuotaExceeded)
+			if el := h.config.EventLogger; el != nil {
+				el.Disconnect(h.conn.RemoteAddr(), id, errors.QuotaExceededError{Reset: quotaReset})
+			}
+		} else if ok && !h.reserveClient() {
+			// Only clients with valid credentials learn that the server is full,
+			// everyone else still sees the masquerade
+			if h.config.FallbackServer != "" {
+				w.Header().Set(protocol.ResponseHeaderFallback, h.config.FallbackServer)
+			}
+			w.WriteHeader(protocol.StatusServerFull)
+			if el := h.config.EventLogger; el != nil {
+				el.Disconnect(h.conn.RemoteAddr(), id, errors.ServerFullError{Fallback: h.config.FallbackServer})
+			}
+		} else if ok {
 			// Set authenticated flag
 			h.authenticated = true
 			h.authID = id
-			if h.config.IgnoreClientBandwidth {
-				// Ignore client bandwidth, always use BBR
-				congestion.UseBBR(h.conn)
-				actualTx = 0
+			actualTx = h.config.brutalTx(actualTx)
+			if actualTx > 0 && h.config.QUICConfig.AdaptiveBandwidth {
+				congestion.UseBrutalAdaptive(h.conn, actualTx, h.bandwidthAdapted)
+			} else if actualTx > 0 {
+				congestion.UseBrutal(h.conn, actualTx)
 			} else {
-				// actualTx = min(serverTx, clientRx)
-				if h.config.BandwidthConfig.MaxTx > 0 && actualTx > h.config.BandwidthConfig.MaxTx {
-					// We have a maxTx limit and the client is asking for more than that,
-					// return and use the limit instead
-					actualTx = h.config.BandwidthConfig.MaxTx
-				}
-				if actualTx > 0 {
-					congestion.UseBrutal(h.conn, actualTx)
-				} else {
-					// Client doesn't know its own bandwidth, use BBR
-					congestion.UseBBR(h.conn)
-				}
+				congestion.UseBBRWithInitialCwnd(h.conn, h.config.QUICConfig.InitialCongestionWindow)
 			}
 			// Auth OK, send response
 			protocol.AuthResponseToHeader(w.Header(), protocol.AuthResponse{
@@ -182,13 +216,24 @@ func (h *h3sHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			if el := h.config.EventLogger; el != nil {
 				el.Connect(h.conn.RemoteAddr(), id, actualTx)
 			}
+			// Start the idle timer for this session (if enabled)
+			if h.idle != nil {
+				h.idle.Touch()
+				go h.idle.Run(h.conn)
+			}
+			// Close the session when the credentials stop being valid
+			if sd := h.config.SessionDeadline; sd != nil {
+				if h.deadline = sd.Deadline(authReq.Auth); !h.deadline.IsZero() {
+					go h.closeAtDeadline()
+				}
+			}
 			// Initialize UDP session manager (if UDP is enabled)
 			// We use sync.Once to make sure that only one goroutine is started,
 			// as ServeHTTP may be called by multiple goroutines simultaneously
 			if !h.config.DisableUDP {
 				go func() {
 					sm := newUDPSessionManager(
-						&udpIOImpl{h.conn, id, h.config.TrafficLogger, h.config.RequestHook, h.config.Outbound},
+						&udpIOImpl{h.conn, id, h.config.TrafficLogger, h.config.RequestHook, h.config.Outbound, h.idle},
 						&udpEventLoggerImpl{h.conn, id, h.config.EventLogger},
 						h.config.UDPIdleTimeout)
 					h.udpSM = sm
@@ -205,6 +250,60 @@ func (h *h3sHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// closeAtDeadline closes the connection at h.deadline, unless it's closed
+// before that.
+func (h *h3sHandler) closeAtDeadline() {
+	timer := time.NewTimer(time.Until(h.deadline))
+	defer timer.Stop()
+	select {
+	case <-h.conn.Context().Done():
+	case <-timer.C:
+		h.expired.Store(true)
+		_ = h.conn.CloseWithError(closeErrCodeOK, "credentials expired")
+	}
+}
+
+// brutalTx returns the rate to send to a client at with Brutal, given the
+// rate the client asked for (0 if it didn't), or 0 to use BBR instead.
+func (c *Config) brutalTx(clientRx uint64) uint64 {
+	maxTx := c.BandwidthConfig.MaxTx
+	switch {
+	case c.QUICConfig.CongestionControl == CongestionControlBBR:
+		return 0
+	case c.IgnoreClientBandwidth && c.QUICConfig.CongestionControl == CongestionControlBrutal:
+		// The server's own bandwidth, whatever the client asks for
+		return maxTx
+	case c.IgnoreClientBandwidth:
+		return 0
+	case clientRx == 0 && c.QUICConfig.CongestionControl == CongestionControlBrutal:
+		return maxTx
+	case maxTx > 0 && clientRx > maxTx:
+		// actualTx = min(serverTx, clientRx)
+		return maxTx
+	default:
+		return clientRx
+	}
+}
+
+// bandwidthAdapted passes a rate change of the adaptive Brutal sender on
+// to the event logger, if it wants them.
+func (h *h3sHandler) bandwidthAdapted(oldTx, newTx uint64, ackRate float64) {
+	if bl, ok := h.config.EventLogger.(BandwidthEventLogger); ok {
+		bl.BandwidthAdapted(h.conn.RemoteAddr(), h.authID, oldTx, newTx, ackRate)
+	}
+}
+
+// reserveClient counts the connection as an authenticated client,
+// unless that would exceed MaxConnections.
+func (h *h3sHandler) reserveClient() bool {
+	n := h.clients.Add(1)
+	if h.config.MaxConnections > 0 && n > int64(h.config.MaxConnections) {
+		h.clients.Add(-1)
+		return false
+	}
+	return true
+}
+
 func (h *h3sHandler) ProxyStreamHijacker(ft http3.FrameType, id quic.ConnectionTracingID, stream *quic.Stream, err error) (bool, error) {
 	if err != nil || !h.authenticated {
 		return false, nil
@@ -293,11 +392,15 @@ func (h *h3sHandler) handleTCPRequest(stream *utils.QStream) {
 		streamStats.Tx.Add(uint64(n))
 	}
 	// Start proxying
+	var serverRw io.ReadWriter = stream
+	if h.idle != nil {
+		serverRw = &idleReadWriter{ReadWriter: stream, Tracker: h.idle}
+	}
 	if trafficLogger != nil {
-		err = copyTwoWayEx(h.authID, stream, tConn, trafficLogger, streamStats)
+		err = copyTwoWayEx(h.authID, serverRw, tConn, trafficLogger, streamStats)
 	} else {
 		// Use the fast path if no traffic logger is set
-		err = copyTwoWay(stream, tConn)
+		err = copyTwoWay(serverRw, tConn)
 	}
 	if h.config.EventLogger != nil {
 		h.config.EventLogger.TCPError(h.conn.RemoteAddr(), h.authID, reqAddr, err)
@@ -327,6 +430,7 @@ type udpIOImpl struct {
 	TrafficLogger TrafficLogger
 	RequestHook   RequestHook
 	Outbound      Outbound
+	Idle          *idleTracker // Can be nil
 }
 
 func (io *udpIOImpl) ReceiveMessage() (*protocol.UDPMessage, error) {
@@ -341,6 +445,7 @@ func (io *udpIOImpl) ReceiveMessage() (*protocol.UDPMessage, error) {
 			// Invalid message, this is fine - just wait for the next
 			continue
 		}
+		io.Idle.Touch()
 		if io.TrafficLogger != nil {
 			ok := io.TrafficLogger.LogTraffic(io.AuthID, uint64(len(udpMsg.Data)), 0)
 			if !ok {
@@ -354,6 +459,7 @@ func (io *udpIOImpl) ReceiveMessage() (*protocol.UDPMessage, error) {
 }
 
 func (io *udpIOImpl) SendMessage(buf []byte, msg *protocol.UDPMessage) error {
+	io.Idle.Touch()
 	if io.TrafficLogger != nil {
 		ok := io.TrafficLogger.LogTraffic(io.AuthID, 0, uint64(len(msg.Data)))
 		if !ok {
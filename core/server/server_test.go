@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigBrutalTx(t *testing.T) {
+	const maxTx = 10 << 20
+	tests := []struct {
+		name     string
+		cc       string
+		ignore   bool
+		maxTx    uint64
+		clientRx uint64
+		want     uint64
+	}{
+		{"auto, client asks", CongestionControlAuto, false, maxTx, 1 << 20, 1 << 20},
+		{"auto, client asks too much", CongestionControlAuto, false, maxTx, 100 << 20, maxTx},
+		{"auto, client doesn't ask", CongestionControlAuto, false, maxTx, 0, 0},
+		{"auto, ignoring the client", CongestionControlAuto, true, maxTx, 1 << 20, 0},
+		{"bbr", CongestionControlBBR, false, maxTx, 1 << 20, 0},
+		{"brutal, client asks", CongestionControlBrutal, false, maxTx, 1 << 20, 1 << 20},
+		{"brutal, client doesn't ask", CongestionControlBrutal, false, maxTx, 0, maxTx},
+		{"brutal, ignoring the client", CongestionControlBrutal, true, maxTx, 1 << 20, maxTx},
+		{"brutal without bandwidth", CongestionControlBrutal, false, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		c := &Config{
+			QUICConfig:            QUICConfig{CongestionControl: tt.cc},
+			BandwidthConfig:       BandwidthConfig{MaxTx: tt.maxTx},
+			IgnoreClientBandwidth: tt.ignore,
+		}
+		assert.Equal(t, tt.want, c.brutalTx(tt.clientRx), tt.name)
+	}
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apernet/hysteria/core/v2/server"
+)
+
+const (
+	// TokenPrefix identifies a signed, time-limited auth token:
+	//
+	//	hyt1.<id>.<expiry>.<signature>
+	//	hyt1.<id>.<expiry>.<from>-<to>.<signature>
+	//
+	// <id> is the user ID reported to the server (no dots allowed),
+	// <expiry> is a Unix timestamp in seconds, and <signature> is the
+	// unpadded base64url HMAC-SHA256 of everything before it, keyed
+	// with the server's token secret. A scheduled token is only valid
+	// between <from> and <to> each day, in minutes after midnight UTC.
+	TokenPrefix = "hyt1"
+
+	tokenSeparator = "."
+)
+
+var (
+	ErrTokenMalformed = errors.New("malformed token")
+	ErrTokenSignature = errors.New("invalid token signature")
+)
+
+// TokenWindow is the time of day a scheduled token is valid, in minutes
+// after midnight UTC. A window with From after To spans midnight.
+type TokenWindow struct {
+	From, To int
+}
+
+func (w TokenWindow) valid() bool {
+	return w.From >= 0 && w.From < minutesPerDay && w.To >= 0 && w.To < minutesPerDay && w.From != w.To
+}
+
+// Contains reports whether t is inside the window.
+func (w TokenWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	m := t.Hour()*60 + t.Minute()
+	if w.From < w.To {
+		return m >= w.From && m < w.To
+	}
+	return m >= w.From || m < w.To
+}
+
+// End returns the first end of the window after t.
+func (w TokenWindow) End(t time.Time) time.Time {
+	t = t.UTC()
+	end := time.Date(t.Year(), t.Month(), t.Day(), 0, w.To, 0, 0, time.UTC)
+	if !end.After(t) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// String returns the window like "06:00-14:00 UTC".
+func (w TokenWindow) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d UTC", w.From/60, w.From%60, w.To/60, w.To%60)
+}
+
+const minutesPerDay = 24 * 60
+
+// NewToken creates a token for id that expires at expiry.
+func NewToken(secret []byte, id string, expiry time.Time) (string, error) {
+	return newToken(secret, id, expiry, nil)
+}
+
+// NewScheduledToken creates a token for id that expires at expiry, and is
+// only valid inside window until then.
+func NewScheduledToken(secret []byte, id string, expiry time.Time, window TokenWindow) (string, error) {
+	if !window.valid() {
+		return "", errors.New("invalid token window")
+	}
+	return newToken(secret, id, expiry, &window)
+}
+
+func newToken(secret []byte, id string, expiry time.Time, window *TokenWindow) (string, error) {
+	if id == "" || strings.Contains(id, tokenSeparator) {
+		return "", errors.New("token id must be non-empty and must not contain dots")
+	}
+	payload := TokenPrefix + tokenSeparator + id + tokenSeparator + strconv.FormatInt(expiry.Unix(), 10)
+	if window != nil {
+		payload += tokenSeparator + strconv.Itoa(window.From) + "-" + strconv.Itoa(window.To)
+	}
+	return payload + tokenSeparator + tokenSignature(secret, payload), nil
+}
+
+// ParseToken verifies a token and returns its id, expiry and, for a
+// scheduled token, its window. Neither is checked against the current time.
+func ParseToken(secret []byte, token string) (id string, expiry time.Time, window *TokenWindow, err error) {
+	parts := strings.Split(token, tokenSeparator)
+	if (len(parts) != 4 && len(parts) != 5) || parts[0] != TokenPrefix || parts[1] == "" {
+		return "", time.Time{}, nil, ErrTokenMalformed
+	}
+	exp, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", time.Time{}, nil, ErrTokenMalformed
+	}
+	if len(parts) == 5 {
+		from, to, ok := strings.Cut(parts[3], "-")
+		w := TokenWindow{}
+		var errFrom, errTo error
+		w.From, errFrom = strconv.Atoi(from)
+		w.To, errTo = strconv.Atoi(to)
+		if !ok || errFrom != nil || errTo != nil || !w.valid() {
+			return "", time.Time{}, nil, ErrTokenMalformed
+		}
+		window = &w
+	}
+	last := len(parts) - 1
+	payload := strings.Join(parts[:last], tokenSeparator)
+	if !hmac.Equal([]byte(parts[last]), []byte(tokenSignature(secret, payload))) {
+		return "", time.Time{}, nil, ErrTokenSignature
+	}
+	return parts[1], time.Unix(exp, 0), window, nil
+}
+
+// IsToken reports whether an auth string looks like a token.
+func IsToken(auth string) bool {
+	return strings.HasPrefix(auth, TokenPrefix+tokenSeparator)
+}
+
+func tokenSignature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	_ server.Authenticator   = &TokenAuthenticator{}
+	_ server.SessionDeadline = &TokenAuthenticator{}
+)
+
+// TokenAuthenticator accepts valid, unexpired tokens signed with Secret,
+// scheduled ones only inside their window.
+// Auth strings that are not tokens are passed to Next, if set,
+// so tokens can be used alongside any other authenticator.
+type TokenAuthenticator struct {
+	Secret []byte
+	Next   server.Authenticator
+
+	now func() time.Time // For testing
+}
+
+func NewTokenAuthenticator(secret []byte, next server.Authenticator) *TokenAuthenticator {
+	return &TokenAuthenticator{
+		Secret: secret,
+		Next:   next,
+		now:    time.Now,
+	}
+}
+
+func (a *TokenAuthenticator) Authenticate(addr net.Addr, auth string, tx uint64) (ok bool, id string) {
+	if !IsToken(auth) {
+		if a.Next == nil {
+			return false, ""
+		}
+		return a.Next.Authenticate(addr, auth, tx)
+	}
+	id, expiry, window, err := ParseToken(a.Secret, auth)
+	now := a.now()
+	if err != nil || !now.Before(expiry) || (window != nil && !window.Contains(now)) {
+		return false, ""
+	}
+	return true, id
+}
+
+// Deadline ends the session of a client that authenticated with a token
+// when the token expires, or for a scheduled token at the end of the window
+// it connected in, whichever is earlier. Auth strings that are not tokens
+// have no deadline.
+func (a *TokenAuthenticator) Deadline(auth string) time.Time {
+	if !IsToken(auth) {
+		return time.Time{}
+	}
+	_, expiry, window, err := ParseToken(a.Secret, auth)
+	if err != nil {
+		return time.Time{}
+	}
+	if window != nil {
+		if end := window.End(a.now()); end.Before(expiry) {
+			return end
+		}
+	}
+	return expiry
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenAuthenticator(t *testing.T) {
+	secret := []byte("the_cake_is_a_lie")
+	now := time.Unix(1700000000, 0)
+
+	valid, err := NewToken(secret, "trial1", now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := NewToken(secret, "trial2", now.Add(-time.Second))
+	otherSecret, _ := NewToken([]byte("wrong"), "trial3", now.Add(24*time.Hour))
+
+	a := NewTokenAuthenticator(secret, &PasswordAuthenticator{Password: "fallback"})
+	a.now = func() time.Time { return now }
+
+	tests := []struct {
+		name   string
+		auth   string
+		wantOk bool
+		wantId string
+	}{
+		{"valid", valid, true, "trial1"},
+		{"expired", expired, false, ""},
+		{"wrong secret", otherSecret, false, ""},
+		{"tampered id", "hyt1.admin" + valid[len("hyt1.trial1"):], false, ""},
+		{"malformed", "hyt1.nope", false, ""},
+		{"fallback ok", "fallback", true, "user"},
+		{"fallback bad", "nope", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOk, gotId := a.Authenticate(nil, tt.auth, 0)
+			if gotOk != tt.wantOk {
+				t.Errorf("Authenticate() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+			if gotId != tt.wantId {
+				t.Errorf("Authenticate() gotId = %v, want %v", gotId, tt.wantId)
+			}
+		})
+	}
+}
+
+func TestNewTokenInvalidID(t *testing.T) {
+	if _, err := NewToken([]byte("s"), "a.b", time.Now()); err == nil {
+		t.Error("NewToken() expected error for id with dots")
+	}
+	if _, err := NewToken([]byte("s"), "", time.Now()); err == nil {
+		t.Error("NewToken() expected error for empty id")
+	}
+}
+
+func TestScheduledToken(t *testing.T) {
+	secret := []byte("the_cake_is_a_lie")
+	// 06:00-14:00 UTC, 08:00-16:00 in Tripoli
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	token, err := NewScheduledToken(secret, "lab", day.Add(30*24*time.Hour), TokenWindow{From: 360, To: 840})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Spans midnight, 22:00-02:00
+	night, _ := NewScheduledToken(secret, "night", day.Add(30*24*time.Hour), TokenWindow{From: 1320, To: 120})
+
+	a := NewTokenAuthenticator(secret, nil)
+	tests := []struct {
+		name   string
+		auth   string
+		now    time.Time
+		wantOk bool
+	}{
+		{"inside", token, day.Add(10 * time.Hour), true},
+		{"start", token, day.Add(6 * time.Hour), true},
+		{"end", token, day.Add(14 * time.Hour), false},
+		{"before", token, day.Add(5*time.Hour + 59*time.Minute), false},
+		{"next day", token, day.Add(34 * time.Hour), true},
+		{"expired", token, day.Add(40*24*time.Hour + 10*time.Hour), false},
+		{"night late", night, day.Add(23 * time.Hour), true},
+		{"night early", night, day.Add(time.Hour), true},
+		{"night day", night, day.Add(12 * time.Hour), false},
+		{"window tampered", strings.Replace(token, ".360-840.", ".0-1439.", 1), day.Add(3 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.now = func() time.Time { return tt.now }
+			if gotOk, _ := a.Authenticate(nil, tt.auth, 0); gotOk != tt.wantOk {
+				t.Errorf("Authenticate() gotOk = %v, want %v", gotOk, tt.wantOk)
+			}
+		})
+	}
+
+	_, _, window, err := ParseToken(secret, token)
+	if err != nil || window == nil || window.String() != "06:00-14:00 UTC" {
+		t.Errorf("ParseToken() window = %v, err = %v", window, err)
+	}
+	if _, err := NewScheduledToken(secret, "lab", day, TokenWindow{From: 600, To: 600}); err == nil {
+		t.Error("NewScheduledToken() expected error for an empty window")
+	}
+}
+
+func TestTokenDeadline(t *testing.T) {
+	secret := []byte("the_cake_is_a_lie")
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	expiry := day.Add(30 * 24 * time.Hour)
+	trial, _ := NewToken(secret, "trial", expiry)
+	// 06:00-14:00 UTC, 08:00-16:00 in Tripoli
+	lab, _ := NewScheduledToken(secret, "lab", expiry, TokenWindow{From: 360, To: 840})
+	lastDay, _ := NewScheduledToken(secret, "lab", day.Add(10*time.Hour), TokenWindow{From: 360, To: 840})
+	// Spans midnight, 22:00-02:00
+	night, _ := NewScheduledToken(secret, "night", expiry, TokenWindow{From: 1320, To: 120})
+
+	a := NewTokenAuthenticator(secret, &PasswordAuthenticator{Password: "fallback"})
+	tests := []struct {
+		name string
+		auth string
+		now  time.Time
+		want time.Time
+	}{
+		{"expiry", trial, day.Add(10 * time.Hour), expiry},
+		// Connected at 15:59 in Tripoli, cut at 16:00
+		{"window end", lab, day.Add(13*time.Hour + 59*time.Minute), day.Add(14 * time.Hour)},
+		{"window start", lab, day.Add(6 * time.Hour), day.Add(14 * time.Hour)},
+		{"night late", night, day.Add(23 * time.Hour), day.Add(26 * time.Hour)},
+		{"night early", night, day.Add(time.Hour), day.Add(2 * time.Hour)},
+		{"expires in window", lastDay, day.Add(8 * time.Hour), day.Add(10 * time.Hour)},
+		{"not a token", "fallback", day, time.Time{}},
+		{"malformed", "hyt1.nope", day, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.now = func() time.Time { return tt.now }
+			if got := a.Deadline(tt.auth); !got.Equal(tt.want) {
+				t.Errorf("Deadline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}